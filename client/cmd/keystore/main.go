@@ -0,0 +1,85 @@
+// Command keystore seals a seed phrase and optional BIP39 passphrase into an
+// encrypted keystore for the -keystore flag of the solver client.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/linera-protocol/examples/universal-solver/client/solver/keys"
+	"github.com/tyler-smith/go-bip39"
+)
+
+func main() {
+	out := flag.String("out", "keystore.json", "Path to write the keystore to")
+	generate := flag.Bool("generate", false, "Generate a new 24-word seed phrase instead of prompting for one")
+	passwordFile := flag.String("password-file", "", "File holding the keystore password (prompted for when unset)")
+	passphraseFile := flag.String("bip39-passphrase-file", "", "File holding the BIP39 passphrase (prompted for when unset)")
+	flag.Parse()
+
+	if _, err := os.Stat(*out); err == nil {
+		log.Fatalf("%s already exists", *out)
+	}
+
+	var mnemonic keys.Mnemonic
+	if *generate {
+		entropy, err := bip39.NewEntropy(256)
+		if err != nil {
+			log.Fatalf("Failed to generate entropy: %v", err)
+		}
+		mnemonic.Phrase, err = bip39.NewMnemonic(entropy)
+		if err != nil {
+			log.Fatalf("Failed to generate seed phrase: %v", err)
+		}
+	} else {
+		phrase, err := keys.ReadSecret("", "Seed phrase")
+		if err != nil {
+			log.Fatal(err)
+		}
+		mnemonic.Phrase = strings.Join(strings.Fields(phrase), " ")
+	}
+
+	passphrase, err := keys.ReadSecret(*passphraseFile, "BIP39 passphrase (empty for none)")
+	if err != nil {
+		log.Fatal(err)
+	}
+	mnemonic.Passphrase = passphrase
+
+	password, err := keys.ReadSecret(*passwordFile, "Keystore password")
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *passwordFile == "" {
+		confirm, err := keys.ReadSecret("", "Repeat keystore password")
+		if err != nil {
+			log.Fatal(err)
+		}
+		if confirm != password {
+			log.Fatal("Passwords do not match")
+		}
+	}
+
+	data, err := keys.SealMnemonic(mnemonic, password)
+	if err != nil {
+		log.Fatalf("Failed to seal seed phrase: %v", err)
+	}
+	if err := os.WriteFile(*out, data, 0600); err != nil {
+		log.Fatalf("Failed to write keystore: %v", err)
+	}
+
+	derived, err := keys.DeriveKeys(mnemonic.Phrase, keys.DerivationOptions{Passphrase: mnemonic.Passphrase})
+	if err != nil {
+		log.Fatalf("Failed to derive keys: %v", err)
+	}
+	fmt.Printf("Wrote %s\n", *out)
+	if *generate {
+		fmt.Println("Write down the generated seed phrase, it is only shown once:")
+		fmt.Printf("  %s\n", mnemonic.Phrase)
+	}
+	for _, account := range derived.Accounts() {
+		fmt.Printf("  %s/%s: %s\n", account.Chain, account.Name, account.Address)
+	}
+}
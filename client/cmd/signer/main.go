@@ -0,0 +1,106 @@
+// Command signer holds the chain keys and signs for a solver client started
+// with -signer-url, so private keys stay out of the internet-facing server.
+// Every transaction passes its own signing policy, whatever the client asks.
+// With -seed-phrase it doubles as a stand-in signer for tests.
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/linera-protocol/examples/universal-solver/client/solver"
+	"github.com/linera-protocol/examples/universal-solver/client/solver/keys"
+)
+
+func main() {
+	listen := flag.String("listen", "127.0.0.1:9090", "Address to serve the signer JSON-RPC API on")
+	tokenFile := flag.String("token-file", os.Getenv("SIGNER_TOKEN_FILE"), "File holding the bearer token clients must present")
+	keystorePath := flag.String("keystore", os.Getenv("KEYSTORE"), "Encrypted keystore holding the seed phrase (see cmd/keystore)")
+	keystorePasswordFile := flag.String("keystore-password-file", os.Getenv("KEYSTORE_PASSWORD_FILE"), "File holding the keystore password (prompted for when unset)")
+	seedPhrase := flag.String("seed-phrase", "", "Seed phrase for test setups, instead of -keystore")
+	accountsConfig := flag.String("accounts-config", os.Getenv("ACCOUNTS_CONFIG"), "JSON file listing named accounts to derive (e.g. faucet)")
+	legacySolanaKey := flag.Bool("legacy-solana-derivation", false, "Derive the Solana key with the pre-SLIP-0010 scheme used by older pools")
+	bitcoinNetwork := flag.String("bitcoin-network", "regtest", "Bitcoin network (regtest or testnet)")
+	signingPolicy := flag.String("signing-policy", os.Getenv("SIGNING_POLICY"), "JSON file with recipient allowlists, value caps and sweep recipients for signed transactions")
+	policyState := flag.String("policy-state", "signer_policy.json", "File keeping the records signed for and recent spends")
+	recordsDir := flag.String("records-dir", getEnvOrDefault("DATA_DIR", "data"), "Data dir of the client, whose faucet grants, swap intents and cold sweeps signing records must name")
+	tokensConfig := flag.String("tokens-config", os.Getenv("TOKENS_CONFIG"), "JSON file listing ERC-20 and SPL tokens the policy may let through")
+	flag.Parse()
+
+	mnemonic := &keys.Mnemonic{Phrase: *seedPhrase}
+	if *keystorePath != "" {
+		password, err := keys.ReadSecret(*keystorePasswordFile, "Keystore password")
+		if err != nil {
+			log.Fatal(err)
+		}
+		mnemonic, err = keys.LoadMnemonic(*keystorePath, password)
+		if err != nil {
+			log.Fatalf("Failed to load keystore: %v", err)
+		}
+	} else if *seedPhrase == "" {
+		log.Fatal("-keystore or -seed-phrase is required")
+	}
+
+	var token string
+	if *tokenFile != "" {
+		var err error
+		token, err = keys.ReadSecret(*tokenFile, "Signer token")
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	accounts, err := solver.LoadAccounts(*accountsConfig)
+	if err != nil {
+		log.Fatalf("Failed to load accounts: %v", err)
+	}
+	params, err := solver.BitcoinNetParams(*bitcoinNetwork)
+	if err != nil {
+		log.Fatal(err)
+	}
+	chainKeys, err := keys.DeriveKeys(mnemonic.Phrase, keys.DerivationOptions{
+		LegacySolanaKey: *legacySolanaKey,
+		Passphrase:      mnemonic.Passphrase,
+		BitcoinParams:   params,
+		Accounts:        accounts,
+	})
+	if err != nil {
+		log.Fatalf("Failed to derive keys: %v", err)
+	}
+
+	// Tokens are known to the policy by address
+	if *tokensConfig != "" {
+		if err := solver.LoadTokens(*tokensConfig); err != nil {
+			log.Fatalf("Failed to load tokens: %v", err)
+		}
+	}
+	policyConfig, err := solver.LoadSigningPolicy(*signingPolicy)
+	if err != nil {
+		log.Fatalf("Failed to load signing policy: %v", err)
+	}
+	policy, err := solver.NewPolicySigner(solver.NewLocalSigner(chainKeys), policyConfig, *policyState)
+	if err != nil {
+		log.Fatalf("Failed to initialize signing policy: %v", err)
+	}
+	policy.VerifyRecordsIn(*recordsDir)
+
+	for _, account := range chainKeys.Accounts() {
+		solver.Logger.Printf("Account %s/%s: %s (%s)", account.Chain, account.Name, account.Address, account.Path)
+	}
+	if token == "" {
+		solver.Logger.Printf("Warning: no -token-file, any client reaching %s can request signatures", *listen)
+	}
+	solver.Logger.Printf("Checking signing records against %s", *recordsDir)
+	solver.Logger.Printf("Signer listening on %s", *listen)
+	handler := solver.NewSignerHandler(policy, token)
+	log.Fatal(http.ListenAndServe(*listen, handler))
+}
+
+func getEnvOrDefault(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
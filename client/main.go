@@ -2,19 +2,28 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"log"
-	"math/big"
+	"math"
+	"mime"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/linera-protocol/examples/universal-solver/client/solver"
+	"github.com/linera-protocol/examples/universal-solver/client/solver/keys"
 )
 
 var (
@@ -22,9 +31,40 @@ var (
 	githubClient *solver.GithubAuthConfig
 	SolanaRPC    string
 	EthereumRPC  string
-	chainToToken = map[string]string{
+	// requireSwapSignature rejects swaps without a sender-signed authorization
+	requireSwapSignature bool
+	swapNonces           *solver.NonceTracker
+	swapIntents          *solver.IntentStore
+	// depositAddresses gives each swap intent its own deposit address
+	depositAddresses    bool
+	depositPollInterval time.Duration
+	// hotWallets keeps pools between their configured floor and ceiling
+	hotWallets *solver.HotWalletMonitor
+	// faucetLimiter admits faucet requests within the configured limits
+	faucetLimiter *solver.FaucetLimiter
+	// faucetTrustProxy takes the client IP from X-Forwarded-For
+	faucetTrustProxy bool
+	// faucetProxyHops is how many trusted proxies append to X-Forwarded-For
+	faucetProxyHops = 1
+	// uploadDir holds uploaded WASM files, the OS temp dir when empty
+	uploadDir string
+	// maxUploadSize bounds the body of a WASM upload
+	maxUploadSize int64
+	// deployments runs publish and create-application jobs in the background
+	deployments *solver.DeploymentManager
+	// githubSessions maps session cookies to GitHub access tokens
+	githubSessions *solver.SessionStore
+	// oauthFlows are the GitHub sign-ins in progress
+	oauthFlows = solver.NewOAuthFlows()
+	// githubSessionTTL is how long a GitHub session lasts
+	githubSessionTTL time.Duration
+	// loginRedirects are the origins users may return to after signing in,
+	// the first one by default
+	loginRedirects []*url.URL
+	chainToToken   = map[string]string{
 		"ethereum": "ETH",
 		"solana":   "SOL",
+		"bitcoin":  "BTC",
 	}
 )
 
@@ -39,14 +79,59 @@ func initFlags() {
 	solverURL := flag.String("solver-url", getEnvOrDefault("SOLVER_URL", "http://localhost:8080/"), "Universal Solver service URL")
 	solanaRPCURL := flag.String("solana-url", getEnvOrDefault("SOLANA_RPC", "http://localhost:8899"), "Solana RPC endpoint")
 	ethereumRPCURL := flag.String("ethereum-url", getEnvOrDefault("ETHEREUM_RPC", "http://localhost:8545"), "Ethereum RPC endpoint")
-	seedPhrase := flag.String("seed-phrase", "", "Seed phrase for deriving chain keys (required)")
+	bitcoinRPCURL := flag.String("bitcoin-url", getEnvOrDefault("BITCOIN_RPC", "http://localhost:18443"), "Bitcoin Core JSON-RPC endpoint")
+	bitcoinRPCUser := flag.String("bitcoin-rpc-user", os.Getenv("BITCOIN_RPC_USER"), "Bitcoin Core JSON-RPC user")
+	bitcoinRPCPassword := flag.String("bitcoin-rpc-password", os.Getenv("BITCOIN_RPC_PASSWORD"), "Bitcoin Core JSON-RPC password")
+	bitcoinNetwork := flag.String("bitcoin-network", getEnvOrDefault("BITCOIN_NETWORK", "regtest"), "Bitcoin network (regtest or testnet)")
+	bitcoinConfirmations := flag.Int64("bitcoin-min-confirmations", 1, "Confirmations required before a Bitcoin deposit is swapped")
+	ethereumConfirmations := flag.Int64("ethereum-min-confirmations", 1, "Confirmations required before an Ethereum deposit address balance is swapped")
+	keystorePath := flag.String("keystore", os.Getenv("KEYSTORE"), "Encrypted keystore holding the seed phrase (see cmd/keystore)")
+	keystorePasswordFile := flag.String("keystore-password-file", os.Getenv("KEYSTORE_PASSWORD_FILE"), "File holding the keystore password (prompted for when unset)")
+	seedPhrase := flag.String("seed-phrase", "", "Seed phrase for deriving chain keys (deprecated, use -keystore)")
+	passphraseFile := flag.String("bip39-passphrase-file", os.Getenv("BIP39_PASSPHRASE_FILE"), "File holding the BIP39 passphrase used with -seed-phrase")
+	signerURL := flag.String("signer-url", os.Getenv("SIGNER_URL"), "Remote signer JSON-RPC endpoint (see cmd/signer), instead of local keys")
+	signingPolicy := flag.String("signing-policy", os.Getenv("SIGNING_POLICY"), "JSON file with recipient allowlists and value caps for signed transactions")
+	signerTokenFile := flag.String("signer-token-file", os.Getenv("SIGNER_TOKEN_FILE"), "File holding the bearer token for the remote signer")
+	legacySolanaKey := flag.Bool("legacy-solana-derivation", os.Getenv("LEGACY_SOLANA_DERIVATION") == "true", "Derive the Solana key with the pre-SLIP-0010 scheme used by older pools")
+	accountsConfig := flag.String("accounts-config", os.Getenv("ACCOUNTS_CONFIG"), "JSON file listing named accounts to derive (e.g. faucet)")
+	dataDir := flag.String("data-dir", getEnvOrDefault("DATA_DIR", "data"), "Directory for persisted service state")
+	intentTTL := flag.Duration("swap-intent-ttl", time.Hour, "How long a swap reference stays valid")
+	depositAddressesFlag := flag.Bool("deposit-addresses", os.Getenv("DEPOSIT_ADDRESSES") == "true", "Derive a deposit address per swap intent and pay out when it is funded")
+	depositPollFlag := flag.Duration("deposit-poll-interval", 15*time.Second, "How often deposit addresses are checked for funds")
+	hotWalletConfig := flag.String("hot-wallet-config", os.Getenv("HOT_WALLET_CONFIG"), "JSON file with per-chain pool floors, ceilings and cold addresses")
+	hotWalletInterval := flag.Duration("hot-wallet-interval", time.Minute, "How often pool balances are checked against their floor and ceiling")
+	faucetLimitsConfig := flag.String("faucet-limits", os.Getenv("FAUCET_LIMITS"), "JSON file with faucet cooldowns, amount caps and budget")
+	faucetTrustProxyFlag := flag.Bool("faucet-trust-proxy", os.Getenv("FAUCET_TRUST_PROXY") == "true", "Take the faucet client IP from X-Forwarded-For, when behind a reverse proxy")
+	faucetProxyHopsFlag := flag.Int("faucet-proxy-hops", 1, "Number of trusted reverse proxies appending to X-Forwarded-For")
+	liquidityAlertURL := flag.String("liquidity-alert-url", os.Getenv("LIQUIDITY_ALERT_URL"), "URL notified with a JSON POST when a pool falls below or recovers above its floor")
+	uploadDirFlag := flag.String("upload-dir", os.Getenv("UPLOAD_DIR"), "Directory for uploaded WASM files (defaults to the OS temp dir)")
+	maxUploadSizeFlag := flag.Int64("max-upload-size", 50<<20, "Maximum size in bytes of a WASM upload")
+	lineraBinary := flag.String("linera-binary", getEnvOrDefault("LINERA_BINARY", "linera"), "Path of the linera CLI used to publish bytecode and create applications")
+	lineraWallet := flag.String("linera-wallet", os.Getenv("LINERA_WALLET"), "Linera wallet file (defaults to the CLI's own)")
+	lineraStorage := flag.String("linera-storage", os.Getenv("LINERA_STORAGE"), "Linera storage configuration, e.g. rocksdb:/path/client.db (defaults to the CLI's own)")
+	lineraChain := flag.String("linera-chain", os.Getenv("LINERA_CHAIN"), "Chain publishing bytecode and creating applications (defaults to the wallet's default chain)")
+	lineraNetwork := flag.String("linera-network", getEnvOrDefault("LINERA_NETWORK", "default"), "Name of the network the Linera wallet is on, keeping published bytecode cached per network")
+	lineraTimeout := flag.Duration("linera-timeout", 5*time.Minute, "How long a linera command may run")
+	githubURL := flag.String("github-url", getEnvOrDefault("GITHUB_URL", solver.DefaultGithubURL), "GitHub server users sign in to, e.g. a GitHub Enterprise server")
+	githubAPIURL := flag.String("github-api-url", getEnvOrDefault("GITHUB_API_URL", solver.DefaultGithubAPIURL), "GitHub REST API, e.g. https://host/api/v3 for GitHub Enterprise")
+	githubSessionKeyFile := flag.String("github-session-key-file", os.Getenv("GITHUB_SESSION_KEY_FILE"), "File holding the hex-encoded 32-byte key sealing GitHub tokens (sessions last until a restart when unset)")
+	githubSessionTTLFlag := flag.Duration("github-session-ttl", 24*time.Hour, "How long a GitHub session lasts")
+	loginRedirectsFlag := flag.String("github-login-redirects", getEnvOrDefault("GITHUB_LOGIN_REDIRECTS", "http://localhost:3002"), "Comma-separated origins users may return to after signing in with GitHub, the first by default")
+	buildFromRepo := flag.Bool("build-from-repo", os.Getenv("BUILD_FROM_REPO") == "true", "Allow /build_from_repo to build applications with cargo, running the repository's build scripts")
+	cargoBinary := flag.String("cargo-binary", getEnvOrDefault("CARGO_BINARY", "cargo"), "Path of the cargo executable building applications")
+	buildTimeout := flag.Duration("build-timeout", 20*time.Minute, "How long a cargo build may run")
+	buildLocalRoot := flag.String("build-local-root", os.Getenv("BUILD_LOCAL_ROOT"), "Directory of local checkouts /build_from_repo may build, for offline use")
+	buildContainer := flag.String("build-container", os.Getenv("BUILD_CONTAINER"), "Image builds run in, isolated from the host, instead of running cargo on the host")
+	buildContainerRuntime := flag.String("build-container-runtime", getEnvOrDefault("BUILD_CONTAINER_RUNTIME", "docker"), "Docker-compatible CLI running -build-container")
+	tokensConfig := flag.String("tokens-config", os.Getenv("TOKENS_CONFIG"), "JSON file listing supported ERC-20/SPL tokens")
+	requireSignature := flag.Bool("require-swap-signature", os.Getenv("REQUIRE_SWAP_SIGNATURE") == "true", "Require a sender-signed authorization on /post_tx_hash swaps")
 
 	// Only parse flags if not running tests
 	if !testing.Testing() {
 		flag.Parse()
 
-		// Validate required seed phrase
-		if *seedPhrase == "" {
+		// Validate required keystore, seed phrase or remote signer
+		if *keystorePath == "" && *seedPhrase == "" && *signerURL == "" {
 			fmt.Println("Usage:")
 			fmt.Println("  -solver-url string")
 			fmt.Println("        Universal Solver service URL (default: http://localhost:8080/)")
@@ -54,8 +139,98 @@ func initFlags() {
 			fmt.Println("        Solana RPC endpoint (default: http://localhost:8899)")
 			fmt.Println("  -ethereum-url string")
 			fmt.Println("        Ethereum RPC endpoint (default: http://localhost:8545)")
+			fmt.Println("  -bitcoin-url string")
+			fmt.Println("        Bitcoin Core JSON-RPC endpoint (default: http://localhost:18443)")
+			fmt.Println("  -bitcoin-rpc-user string")
+			fmt.Println("        Bitcoin Core JSON-RPC user")
+			fmt.Println("  -bitcoin-rpc-password string")
+			fmt.Println("        Bitcoin Core JSON-RPC password")
+			fmt.Println("  -bitcoin-network string")
+			fmt.Println("        Bitcoin network (regtest or testnet) (default: regtest)")
+			fmt.Println("  -bitcoin-min-confirmations int")
+			fmt.Println("        Confirmations required before a Bitcoin deposit is swapped (default: 1)")
+			fmt.Println("  -ethereum-min-confirmations int")
+			fmt.Println("        Confirmations required before an Ethereum deposit address balance is swapped (default: 1)")
+			fmt.Println("  -keystore string")
+			fmt.Println("        Encrypted keystore holding the seed phrase (see cmd/keystore)")
+			fmt.Println("  -keystore-password-file string")
+			fmt.Println("        File holding the keystore password (prompted for when unset)")
 			fmt.Println("  -seed-phrase string")
-			fmt.Println("        Seed phrase for deriving chain keys (required)")
+			fmt.Println("        Seed phrase for deriving chain keys (deprecated, use -keystore)")
+			fmt.Println("  -bip39-passphrase-file string")
+			fmt.Println("        File holding the BIP39 passphrase used with -seed-phrase")
+			fmt.Println("  -signer-url string")
+			fmt.Println("        Remote signer JSON-RPC endpoint (see cmd/signer), instead of local keys")
+			fmt.Println("  -signer-token-file string")
+			fmt.Println("        File holding the bearer token for the remote signer")
+			fmt.Println("  -signing-policy string")
+			fmt.Println("        JSON file with recipient allowlists and value caps for signed transactions")
+			fmt.Println("  -legacy-solana-derivation")
+			fmt.Println("        Derive the Solana key with the pre-SLIP-0010 scheme used by older pools")
+			fmt.Println("  -accounts-config string")
+			fmt.Println("        JSON file listing named accounts to derive (e.g. faucet)")
+			fmt.Println("  -data-dir string")
+			fmt.Println("        Directory for persisted service state (default: data)")
+			fmt.Println("  -swap-intent-ttl duration")
+			fmt.Println("        How long a swap reference stays valid (default: 1h)")
+			fmt.Println("  -deposit-addresses")
+			fmt.Println("        Derive a deposit address per swap intent and pay out when it is funded")
+			fmt.Println("  -deposit-poll-interval duration")
+			fmt.Println("        How often deposit addresses are checked for funds (default: 15s)")
+			fmt.Println("  -hot-wallet-config string")
+			fmt.Println("        JSON file with per-chain pool floors, ceilings and cold addresses")
+			fmt.Println("  -hot-wallet-interval duration")
+			fmt.Println("        How often pool balances are checked against their floor and ceiling (default: 1m)")
+			fmt.Println("  -liquidity-alert-url string")
+			fmt.Println("        URL notified with a JSON POST when a pool falls below or recovers above its floor")
+			fmt.Println("  -faucet-limits string")
+			fmt.Println("        JSON file with faucet cooldowns, amount caps and budget")
+			fmt.Println("  -faucet-trust-proxy")
+			fmt.Println("        Take the faucet client IP from X-Forwarded-For, when behind a reverse proxy")
+			fmt.Println("  -faucet-proxy-hops int")
+			fmt.Println("        Number of trusted reverse proxies appending to X-Forwarded-For (default: 1)")
+			fmt.Println("  -upload-dir string")
+			fmt.Println("        Directory for uploaded WASM files (defaults to the OS temp dir)")
+			fmt.Println("  -max-upload-size int")
+			fmt.Println("        Maximum size in bytes of a WASM upload (default: 52428800)")
+			fmt.Println("  -linera-binary string")
+			fmt.Println("        Path of the linera CLI used to publish bytecode and create applications (default: linera)")
+			fmt.Println("  -linera-wallet string")
+			fmt.Println("        Linera wallet file (defaults to the CLI's own)")
+			fmt.Println("  -linera-storage string")
+			fmt.Println("        Linera storage configuration, e.g. rocksdb:/path/client.db (defaults to the CLI's own)")
+			fmt.Println("  -linera-chain string")
+			fmt.Println("        Chain publishing bytecode and creating applications (defaults to the wallet's default chain)")
+			fmt.Println("  -linera-network string")
+			fmt.Println("        Name of the network the Linera wallet is on, keeping published bytecode cached per network (default: default)")
+			fmt.Println("  -linera-timeout duration")
+			fmt.Println("        How long a linera command may run (default: 5m)")
+			fmt.Println("  -github-url string")
+			fmt.Println("        GitHub server users sign in to, e.g. a GitHub Enterprise server (default: https://github.com)")
+			fmt.Println("  -github-api-url string")
+			fmt.Println("        GitHub REST API, e.g. https://host/api/v3 for GitHub Enterprise (default: https://api.github.com)")
+			fmt.Println("  -github-session-key-file string")
+			fmt.Println("        File holding the hex-encoded 32-byte key sealing GitHub tokens (sessions last until a restart when unset)")
+			fmt.Println("  -github-session-ttl duration")
+			fmt.Println("        How long a GitHub session lasts (default: 24h)")
+			fmt.Println("  -github-login-redirects string")
+			fmt.Println("        Comma-separated origins users may return to after signing in with GitHub, the first by default (default: http://localhost:3002)")
+			fmt.Println("  -build-from-repo")
+			fmt.Println("        Allow /build_from_repo to build applications with cargo, running the repository's build scripts")
+			fmt.Println("  -cargo-binary string")
+			fmt.Println("        Path of the cargo executable building applications (default: cargo)")
+			fmt.Println("  -build-timeout duration")
+			fmt.Println("        How long a cargo build may run (default: 20m)")
+			fmt.Println("  -build-local-root string")
+			fmt.Println("        Directory of local checkouts /build_from_repo may build, for offline use")
+			fmt.Println("  -build-container string")
+			fmt.Println("        Image builds run in, isolated from the host, instead of running cargo on the host")
+			fmt.Println("  -build-container-runtime string")
+			fmt.Println("        Docker-compatible CLI running -build-container (default: docker)")
+			fmt.Println("  -tokens-config string")
+			fmt.Println("        JSON file listing supported ERC-20/SPL tokens")
+			fmt.Println("  -require-swap-signature")
+			fmt.Println("        Require a sender-signed authorization on /post_tx_hash swaps")
 			os.Exit(1)
 		}
 	}
@@ -66,13 +241,185 @@ func initFlags() {
 	githubClient = solver.NewGithubClient(os.Getenv("GITHUB_CLIENT_ID"),
 		os.Getenv("GITHUB_CLIENT_SECRET"),
 		os.Getenv("GITHUB_REDIRECT_URI"))
+	githubClient.WebURL = *githubURL
+	githubClient.API = solver.NewGithubAPI(*githubAPIURL)
+	for _, origin := range strings.Split(*loginRedirectsFlag, ",") {
+		parsed, err := url.Parse(strings.TrimSpace(origin))
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			log.Fatalf("Invalid -github-login-redirects origin %q", origin)
+		}
+		loginRedirects = append(loginRedirects, parsed)
+	}
+	githubSessionTTL = *githubSessionTTLFlag
 
 	// Initialize RPC endpoints
 	solver.InitRPCEndpoints(*ethereumRPCURL, *solanaRPCURL)
+	if err := solver.InitBitcoin(*bitcoinRPCURL, *bitcoinRPCUser, *bitcoinRPCPassword, *bitcoinNetwork, *bitcoinConfirmations); err != nil {
+		log.Fatalf("Failed to initialize Bitcoin: %v", err)
+	}
+	if err := solver.InitEthereumConfirmations(*ethereumConfirmations); err != nil {
+		log.Fatalf("Failed to initialize Ethereum: %v", err)
+	}
+
+	// Linera CLI used for deployments
+	var lineraChainID solver.ChainID
+	if *lineraChain != "" {
+		var err error
+		if lineraChainID, err = solver.ParseChainID(*lineraChain); err != nil {
+			log.Fatalf("Invalid -linera-chain: %v", err)
+		}
+	}
+	solver.InitLinera(solver.LineraConfig{
+		Binary:  *lineraBinary,
+		Wallet:  *lineraWallet,
+		Storage: *lineraStorage,
+		Chain:   lineraChainID,
+		Timeout: *lineraTimeout,
+		Network: *lineraNetwork,
+	})
+
+	// Building applications from repositories
+	solver.InitBuild(solver.BuildConfig{
+		Enabled:          *buildFromRepo,
+		Cargo:            *cargoBinary,
+		Timeout:          *buildTimeout,
+		LocalRoot:        *buildLocalRoot,
+		Container:        *buildContainer,
+		ContainerRuntime: *buildContainerRuntime,
+	})
+
+	uploadDir = *uploadDirFlag
+	maxUploadSize = *maxUploadSizeFlag
+	if uploadDir != "" {
+		if err := os.MkdirAll(uploadDir, 0700); err != nil {
+			log.Fatalf("Failed to create upload directory: %v", err)
+		}
+	}
+
+	requireSwapSignature = *requireSignature
+	depositAddresses = *depositAddressesFlag
+	depositPollInterval = *depositPollFlag
+
+	// Register configured tokens alongside the native assets
+	if *tokensConfig != "" {
+		if err := solver.LoadTokens(*tokensConfig); err != nil {
+			log.Fatalf("Failed to load tokens: %v", err)
+		}
+	}
+
+	// Load swap intents registered before deposits
+	var err error
+	swapIntents, err = solver.NewIntentStore(filepath.Join(*dataDir, "swap_intents.json"), *intentTTL)
+	if err != nil {
+		log.Fatalf("Failed to load swap intents: %v", err)
+	}
+	swapNonces, err = solver.NewNonceTracker(filepath.Join(*dataDir, "swap_nonces.json"))
+	if err != nil {
+		log.Fatalf("Failed to load swap nonces: %v", err)
+	}
+
+	// Bytecode published before, by digest of the WASM pair
+	if err := solver.InitBytecodeCache(filepath.Join(*dataDir, "bytecode_cache.json")); err != nil {
+		log.Fatalf("Failed to load bytecode cache: %v", err)
+	}
+
+	// Deployment jobs, resumed where a restart interrupted them
+	deployments, err = solver.NewDeploymentManager(filepath.Join(*dataDir, "deployments"))
+	if err != nil {
+		log.Fatalf("Failed to load deployments: %v", err)
+	}
+	deployments.UseGithub(githubClient)
+
+	// GitHub sessions, saved only when their key outlives a restart
+	sessionKey, err := solver.LoadSessionKey(*githubSessionKeyFile)
+	if err != nil {
+		log.Fatalf("Failed to load GitHub session key: %v", err)
+	}
+	sessionsPath := ""
+	if *githubSessionKeyFile != "" {
+		sessionsPath = filepath.Join(*dataDir, "github_sessions.json")
+	}
+	githubSessions, err = solver.NewSessionStore(sessionsPath, sessionKey, githubSessionTTL)
+	if err != nil {
+		log.Fatalf("Failed to load GitHub sessions: %v", err)
+	}
+
+	// Faucet limits and the record of grants
+	faucetConfig, err := solver.LoadFaucetLimits(*faucetLimitsConfig)
+	if err != nil {
+		log.Fatalf("Failed to load faucet limits: %v", err)
+	}
+	faucetLimiter, err = solver.NewFaucetLimiter(faucetConfig, filepath.Join(*dataDir, "faucet_grants.json"))
+	if err != nil {
+		log.Fatalf("Failed to load faucet grants: %v", err)
+	}
+	if len(faucetConfig.Budget) == 0 {
+		solver.Logger.Printf("Warning: no faucet budget is configured, faucet requests will be refused")
+	}
+	faucetTrustProxy = *faucetTrustProxyFlag
+	if *faucetProxyHopsFlag < 1 {
+		log.Fatalf("-faucet-proxy-hops must be at least 1")
+	}
+	faucetProxyHops = *faucetProxyHopsFlag
+
+	// Named accounts derived alongside the pool keys
+	accounts, err := solver.LoadAccounts(*accountsConfig)
+	if err != nil {
+		log.Fatalf("Failed to load accounts: %v", err)
+	}
+
+	// Sign remotely, or initialize keys with seed phrase (tests initialize
+	// their own in TestMain)
+	if *signerURL != "" {
+		var token string
+		if *signerTokenFile != "" {
+			token, err = keys.ReadSecret(*signerTokenFile, "Signer token")
+			if err != nil {
+				log.Fatalf("Failed to read signer token: %v", err)
+			}
+		}
+		solver.SetSigner(solver.NewRemoteSigner(*signerURL, token))
+	} else if !testing.Testing() {
+		mnemonic, err := loadMnemonic(*keystorePath, *keystorePasswordFile, *seedPhrase, *passphraseFile)
+		if err != nil {
+			log.Fatalf("Failed to load seed phrase: %v", err)
+		}
+		derivation := keys.DerivationOptions{
+			LegacySolanaKey: *legacySolanaKey,
+			Passphrase:      mnemonic.Passphrase,
+			Accounts:        accounts,
+		}
+		if err := solver.InitKeysWithOptions(mnemonic.Phrase, derivation); err != nil {
+			log.Fatalf("Failed to initialize keys: %v", err)
+		}
+	}
+
+	// Vet every outbound transaction (tests sign without a policy)
+	if !testing.Testing() {
+		policy, err := solver.LoadSigningPolicy(*signingPolicy)
+		if err != nil {
+			log.Fatalf("Failed to load signing policy: %v", err)
+		}
+		if err := solver.UseSigningPolicy(policy, filepath.Join(*dataDir, "signing_policy.json")); err != nil {
+			log.Fatalf("Failed to initialize signing policy: %v", err)
+		}
+		// Records must name a grant, swap or sweep the client persisted
+		solver.VerifySigningRecords(solver.SigningRecordFaucet, faucetLimiter.VerifyGrant)
+		solver.VerifySigningRecords(solver.SigningRecordSwap, swapIntents.VerifyPayout)
+		solver.VerifySigningRecords(solver.SigningRecordSweep, swapIntents.VerifySweep)
+	}
 
-	// Initialize keys with seed phrase
-	if err := solver.InitKeys(*seedPhrase); err != nil {
-		log.Fatalf("Failed to initialize keys: %v", err)
+	// Sweep excess pool funds to cold storage, registering the cold addresses
+	// with the signing policy
+	if *hotWalletConfig != "" {
+		limits, err := solver.LoadHotWalletLimits(*hotWalletConfig)
+		if err != nil {
+			log.Fatalf("Failed to load hot wallet limits: %v", err)
+		}
+		hotWallets, err = solver.NewHotWalletMonitor(solverClient, limits, *hotWalletInterval, *liquidityAlertURL, filepath.Join(*dataDir, "cold_sweeps.json"))
+		if err != nil {
+			log.Fatalf("Failed to initialize hot wallet limits: %v", err)
+		}
 	}
 
 	// Log configuration (without exposing seed phrase)
@@ -80,7 +427,96 @@ func initFlags() {
 	solver.Logger.Printf("  Solver URL: %s", *solverURL)
 	solver.Logger.Printf("  Solana RPC: %s", *solanaRPCURL)
 	solver.Logger.Printf("  Ethereum RPC: %s", *ethereumRPCURL)
-	solver.Logger.Printf("  Keys: Initialized successfully")
+	solver.Logger.Printf("  Bitcoin RPC: %s (%s)", *bitcoinRPCURL, *bitcoinNetwork)
+	solver.Logger.Printf("  Data dir: %s", *dataDir)
+	solver.Logger.Printf("  Linera CLI: %s on network %s (timeout %s)", *lineraBinary, *lineraNetwork, *lineraTimeout)
+	if uploadDir != "" {
+		solver.Logger.Printf("  Upload dir: %s", uploadDir)
+	}
+	if *githubAPIURL != solver.DefaultGithubAPIURL {
+		solver.Logger.Printf("  GitHub: %s (API %s)", *githubURL, *githubAPIURL)
+	}
+	solver.Logger.Printf("  GitHub login redirects: %s", *loginRedirectsFlag)
+	if *githubSessionKeyFile == "" {
+		solver.Logger.Printf("  GitHub sessions: in memory only (no -github-session-key-file)")
+	}
+	if *buildFromRepo {
+		solver.Logger.Printf("  Builds from repositories: %s (timeout %s)", *cargoBinary, *buildTimeout)
+		if *buildLocalRoot != "" {
+			solver.Logger.Printf("  Build local root: %s", *buildLocalRoot)
+		}
+		if *buildContainer != "" {
+			solver.Logger.Printf("  Build container: %s (%s)", *buildContainer, *buildContainerRuntime)
+		} else {
+			solver.Logger.Printf("Warning: builds run on the host with -build-from-repo; set -build-container to isolate them")
+		}
+	}
+	if *lineraWallet != "" {
+		solver.Logger.Printf("  Linera wallet: %s", *lineraWallet)
+	}
+	if *lineraStorage != "" {
+		solver.Logger.Printf("  Linera storage: %s", *lineraStorage)
+	}
+	if *lineraChain != "" {
+		solver.Logger.Printf("  Linera chain: %s", *lineraChain)
+	}
+	solver.Logger.Printf("  Require swap signature: %t", requireSwapSignature)
+	if depositAddresses {
+		solver.Logger.Printf("  Deposit addresses: polled every %s", depositPollInterval)
+	}
+	if *faucetLimitsConfig != "" {
+		solver.Logger.Printf("  Faucet limits: %s", *faucetLimitsConfig)
+	}
+	if faucetTrustProxy {
+		solver.Logger.Printf("  Faucet client IP: X-Forwarded-For behind %d proxies", faucetProxyHops)
+	}
+	if *hotWalletConfig != "" {
+		solver.Logger.Printf("  Hot wallet limits: %s, checked every %s", *hotWalletConfig, *hotWalletInterval)
+	}
+	if *signingPolicy != "" {
+		solver.Logger.Printf("  Signing policy: %s", *signingPolicy)
+	}
+	if *signerURL != "" {
+		solver.Logger.Printf("  Signer: %s", *signerURL)
+	} else {
+		solver.Logger.Printf("  Keys: Initialized successfully")
+	}
+	if *legacySolanaKey {
+		solver.Logger.Printf("  Solana key: legacy derivation")
+	}
+	for _, account := range solver.Accounts() {
+		solver.Logger.Printf("  Account %s/%s: %s (%s)", account.Chain, account.Name, account.Address, account.Path)
+	}
+
+	// Deposit addresses of unfinished intents must be signable again
+	if depositAddresses && !testing.Testing() {
+		if err := solver.RestoreDepositAccounts(swapIntents); err != nil {
+			log.Fatalf("Failed to restore deposit addresses: %v", err)
+		}
+	}
+}
+
+// loadMnemonic reads the seed phrase from the encrypted keystore, falling back
+// to the deprecated -seed-phrase flag
+func loadMnemonic(keystorePath, passwordFile, seedPhrase, passphraseFile string) (*keys.Mnemonic, error) {
+	if keystorePath != "" {
+		password, err := keys.ReadSecret(passwordFile, "Keystore password")
+		if err != nil {
+			return nil, err
+		}
+		return keys.LoadMnemonic(keystorePath, password)
+	}
+
+	solver.Logger.Printf("Warning: -seed-phrase is deprecated and visible in the process list, use -keystore instead")
+	mnemonic := &keys.Mnemonic{Phrase: seedPhrase}
+	if passphraseFile != "" {
+		passphrase, err := keys.ReadSecret(passphraseFile, "BIP39 passphrase")
+		if err != nil {
+			return nil, err
+		}
+		mnemonic.Passphrase = passphrase
+	}
+	return mnemonic, nil
 }
 
 func getEnvOrDefault(key, defaultValue string) string {
@@ -90,16 +526,17 @@ func getEnvOrDefault(key, defaultValue string) string {
 	return defaultValue
 }
 
+// allowedOrigins are the frontends that may call the API from a browser
+var allowedOrigins = map[string]bool{
+	"http://localhost:3002":       true,
+	"https://uni-solver.ngrok.io": true,
+}
+
 // Add CORS middleware
 func corsMiddleware(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Set CORS headers
 		origin := r.Header.Get("Origin")
-		allowedOrigins := map[string]bool{
-			"http://localhost:3002":       true,
-			"https://uni-solver.ngrok.io": true,
-		}
-
 		if allowedOrigins[origin] {
 			w.Header().Set("Access-Control-Allow-Origin", origin)
 			w.Header().Set("Access-Control-Allow-Credentials", "true")
@@ -114,25 +551,69 @@ func corsMiddleware(next http.HandlerFunc) http.HandlerFunc {
 			return
 		}
 
+		// The session cookie goes along with requests from any site
+		if _, err := r.Cookie(sessionCookie); err == nil && r.Method == http.MethodPost && !trustedOrigin(r) {
+			http.Error(w, "Forbidden: request does not come from an allowed origin", http.StatusForbidden)
+			return
+		}
+
 		next(w, r)
 	}
 }
 
+// trustedOrigin reports whether a cookie-authenticated request comes from a
+// frontend rather than from a page forging it. Its Origin must be allowed, as
+// a CORS or sign-in origin. Without an Origin it must have a JSON body, which
+// other sites cannot send without a CORS preflight.
+func trustedOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		return err == nil && mediaType == "application/json"
+	}
+	if allowedOrigins[origin] {
+		return true
+	}
+	for _, allowed := range loginRedirects {
+		if origin == allowed.Scheme+"://"+allowed.Host {
+			return true
+		}
+	}
+	return false
+}
+
 func main() {
 	// Define routes with CORS middleware
 	http.HandleFunc("/post_tx_hash", corsMiddleware(handlePostTxHash))
+	http.HandleFunc("/swap_intent", corsMiddleware(handleCreateSwapIntent))
 	http.HandleFunc("/faucet", corsMiddleware(handleFaucet))
 	http.HandleFunc("/get_pool_address", corsMiddleware(handleGetPoolAddress))
 	http.HandleFunc("/fetch_balance", corsMiddleware(handleFetchBalance))
+	http.HandleFunc("/balances", corsMiddleware(handleBalances))
 	http.HandleFunc("/quote_swap", corsMiddleware(handleQuoteSwap))
 	http.HandleFunc("/deploy_bytecode", corsMiddleware(handleDeployBytecode))
 	http.HandleFunc("/create_application", corsMiddleware(handleCreateApplication))
+	http.HandleFunc("/deployments", corsMiddleware(handleSubmitDeployment))
+	http.HandleFunc("/deployments/", corsMiddleware(handleDeployment))
+	http.HandleFunc("/build_from_repo", corsMiddleware(handleBuildFromRepo))
 	http.HandleFunc("/auth/github", corsMiddleware(handleGithubAuth))
 	http.HandleFunc("/auth/github/callback", corsMiddleware(handleGithubCallback))
+	http.HandleFunc("/auth/logout", corsMiddleware(handleGithubLogout))
+	http.HandleFunc("/auth/github/revoke", corsMiddleware(handleGithubRevoke))
 	http.HandleFunc("/repos", corsMiddleware(handleListRepos))
 	http.HandleFunc("/repo/files", corsMiddleware(handleFetchRepoFiles))
 	http.HandleFunc("/repo/all-files", corsMiddleware(handleFetchAllFiles))
 
+	// Pay out and sweep intents with their own deposit address
+	if depositAddresses {
+		watcher := solver.NewDepositWatcher(solverClient, swapIntents, depositPollInterval)
+		go watcher.Run(context.Background())
+	}
+	if hotWallets != nil {
+		go hotWallets.Run(context.Background())
+	}
+	go deployments.Run(context.Background())
+
 	// Start server
 	port := getEnvOrDefault("PORT", "3001")
 	log.Printf("Server starting on :%s", port)
@@ -175,8 +656,10 @@ func handlePostTxHash(w http.ResponseWriter, r *http.Request) {
 		tx, err = solverClient.GetSolanaTransaction(SolanaRPC, txHash)
 	case "ethereum":
 		tx, err = solverClient.GetEthereumTransaction(EthereumRPC, txHash)
+	case "bitcoin":
+		tx, err = solverClient.GetBitcoinTransaction(txHash)
 	default:
-		http.Error(w, "Invalid chain parameter. Must be 'solana' or 'ethereum'", http.StatusBadRequest)
+		http.Error(w, "Invalid chain parameter. Must be 'solana', 'ethereum' or 'bitcoin'", http.StatusBadRequest)
 		return
 	}
 
@@ -191,29 +674,98 @@ func handlePostTxHash(w http.ResponseWriter, r *http.Request) {
 		"data":   tx,
 	}
 
-	// If toToken and destinationAddress are provided, execute swap
+	// Solana deposits tagged with a swap reference memo carry their own swap parameters
+	var intent *solver.SwapIntent
+	if chain == "solana" {
+		if reference := solver.SolanaMemo(tx); reference != "" {
+			var ok bool
+			intent, ok = swapIntents.Get(reference)
+			if !ok || intent.Chain != chain {
+				http.Error(w, "Unknown swap reference: "+reference, http.StatusNotFound)
+				return
+			}
+			if (toToken != "" && toToken != intent.ToToken) ||
+				(destinationAddress != "" && destinationAddress != intent.DestinationAddress) {
+				http.Error(w, "Swap parameters do not match swap reference", http.StatusBadRequest)
+				return
+			}
+			toToken = intent.ToToken
+			destinationAddress = intent.DestinationAddress
+		}
+	}
+
+	// If toToken and destinationAddress are known, execute swap
 	if toToken != "" && destinationAddress != "" {
-		// Get the from token based on chain
-		fromToken, err := getTokenForChain(chain)
-		if err != nil {
-			http.Error(w, "Error getting token for chain: "+err.Error(), http.StatusInternalServerError)
+		signature := r.URL.Query().Get("signature")
+		if signature == "" && requireSwapSignature {
+			http.Error(w, "signature parameter is required", http.StatusUnauthorized)
 			return
 		}
+		var auth *solver.SwapAuthorization
+		if signature != "" {
+			expiresAt, err := strconv.ParseInt(r.URL.Query().Get("expiresAt"), 10, 64)
+			if err != nil {
+				http.Error(w, "expiresAt must be a unix time in seconds", http.StatusBadRequest)
+				return
+			}
+			auth = &solver.SwapAuthorization{
+				TxHash:             txHash,
+				ToToken:            toToken,
+				DestinationAddress: destinationAddress,
+				Nonce:              r.URL.Query().Get("nonce"),
+				ExpiresAt:          expiresAt,
+			}
+		}
 
-		// Extract amount from transaction
-		amount, err := extractAmountFromTx(tx)
+		// Find the deposit into the pool and the token it was made in
+		deposit, err := findDeposit(chain, txHash, tx)
 		if err != nil {
 			http.Error(w, "Error extracting amount from transaction: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
 
+		// Verify the caller owns the account the deposit came from, which
+		// need not be the account that sent the transaction
+		if auth != nil {
+			if err := verifySwapAuthorization(r, chain, deposit.Sender, *auth); err != nil {
+				http.Error(w, "Invalid swap authorization: "+err.Error(), http.StatusUnauthorized)
+				return
+			}
+		}
+		fromToken := deposit.Symbol
+		amount := deposit.DecimalAmount()
+
+		// Bind the deposit to its swap reference, or record it as a swap of
+		// its own, so it is only paid out once and the signing policy can
+		// check the payout against it
+		memo := intent != nil
+		if memo {
+			intent, err = swapIntents.Claim(intent.Reference, deposit.TxHash)
+		} else {
+			intent, err = swapIntents.ClaimDeposit(chain, fromToken, toToken, destinationAddress, deposit.TxHash)
+		}
+		if err != nil {
+			http.Error(w, "Error claiming swap reference: "+err.Error(), http.StatusConflict)
+			return
+		}
+
 		// Execute swap with correct fromToken
-		swapResponse, err := solverClient.ExecuteSwap(fromToken, toToken, float64(amount), destinationAddress)
+		swapResponse, err := solverClient.ExecuteSwap(deposit.TxHash, fromToken, toToken, amount, destinationAddress)
 		if err != nil {
-			http.Error(w, "Error executing swap: "+err.Error(), http.StatusInternalServerError)
+			if releaseErr := swapIntents.Release(intent.Reference); releaseErr != nil {
+				solver.Logger.Printf("Failed to release swap reference %s: %v", intent.Reference, releaseErr)
+			}
+			http.Error(w, "Error executing swap: "+err.Error(), signingErrorStatus(err))
 			return
 		}
 
+		if err := swapIntents.Complete(intent.Reference, swapResponse.TxHash); err != nil {
+			solver.Logger.Printf("Failed to complete swap reference %s: %v", intent.Reference, err)
+		}
+		if memo {
+			response["reference"] = intent.Reference
+		}
+
 		response["swap_result"] = swapResponse
 	}
 
@@ -222,49 +774,167 @@ func handlePostTxHash(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
-// Helper function to extract amount from transaction
-func extractAmountFromTx(tx interface{}) (uint64, error) {
-	switch v := tx.(type) {
-	case map[string]interface{}:
-		// For Ethereum
-		if value, ok := v["value"].(string); ok {
-			// Parse decimal string to big.Int
-			bigValue := new(big.Int)
-			if _, success := bigValue.SetString(value, 10); !success {
-				return 0, fmt.Errorf("failed to parse decimal value: %s", value)
-			}
-			// Convert from wei to ETH (divide by 10^18) and check if result fits uint64
-			weiPerEth := new(big.Int).Exp(big.NewInt(10), big.NewInt(18), nil)
-			ethValue := new(big.Int).Div(bigValue, weiPerEth)
-			if !ethValue.IsUint64() {
-				return 0, fmt.Errorf("converted ETH value exceeds uint64 range: %s", ethValue.String())
-			}
-			return ethValue.Uint64(), nil
-		}
-		// For Solana
-		if result, ok := v["result"].(map[string]interface{}); ok {
-			meta := result
-			if meta, ok := meta["meta"].(map[string]interface{}); ok {
-				if preBalances, ok := meta["preBalances"].([]interface{}); ok && len(preBalances) > 0 {
-					if postBalances, ok := meta["postBalances"].([]interface{}); ok && len(postBalances) > 0 {
-						// Get the difference between pre and post balances of sender
-						preBalance := uint64(preBalances[0].(float64))
-						postBalance := uint64(postBalances[0].(float64))
-						if preBalance > postBalance {
-							// Convert from lamports to SOL (divide by 10^9)
-							lamports := preBalance - postBalance
-							solValue := float64(lamports) / 1e9
-							if solValue > float64(^uint64(0)) {
-								return 0, fmt.Errorf("converted SOL value exceeds uint64 range: %f", solValue)
-							}
-							return uint64(solValue), nil
-						}
-					}
-				}
+// verifySwapAuthorization checks the signature on a swap request against the
+// sender of the validated deposit and consumes its nonce
+func verifySwapAuthorization(r *http.Request, chain, sender string, auth solver.SwapAuthorization) error {
+	if sender == "" {
+		return fmt.Errorf("deposit sender not available")
+	}
+
+	query := r.URL.Query()
+	if err := solver.VerifySwapAuthorization(chain, sender, auth, query.Get("signature"), query.Get("signatureScheme")); err != nil {
+		return err
+	}
+
+	return swapNonces.Use(sender, auth.Nonce, auth.ExpiresAt)
+}
+
+// signingErrorStatus reports transactions the signing policy denied as
+// forbidden, payouts paused for low liquidity as unavailable and other
+// failures as internal errors
+func signingErrorStatus(err error) int {
+	var policyErr *solver.PolicyError
+	if errors.As(err, &policyErr) {
+		return http.StatusForbidden
+	}
+	if errors.Is(err, solver.ErrLowLiquidity) {
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+// findDeposit locates the transfer into the pool made by a deposit transaction
+func findDeposit(chain, txHash string, tx interface{}) (*solver.Deposit, error) {
+	switch chain {
+	case "solana":
+		// System transfers or SPL token balance changes into a pool, not fees or other instructions
+		return solverClient.GetSolanaDeposit(tx)
+	case "ethereum":
+		// Native ETH value or ERC-20 Transfer logs into a pool
+		return solverClient.GetEthereumDeposit(txHash)
+	case "bitcoin":
+		// Outputs paying the BTC pool, once confirmed
+		return solverClient.GetBitcoinDeposit(tx)
+	default:
+		return nil, fmt.Errorf("unsupported chain: %s", chain)
+	}
+}
+
+// handleCreateSwapIntent registers a swap before the deposit is made and
+// returns the reference the depositor attaches to their transfer, or with
+// -deposit-addresses the address they deposit to
+func handleCreateSwapIntent(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Get parameters
+	chain := r.URL.Query().Get("chain")
+	toToken := r.URL.Query().Get("toToken")
+	destinationAddress := r.URL.Query().Get("destinationAddress")
+
+	if chain == "" {
+		http.Error(w, "chain parameter is required", http.StatusBadRequest)
+		return
+	}
+	if toToken == "" || destinationAddress == "" {
+		http.Error(w, "toToken and destinationAddress parameters are required", http.StatusBadRequest)
+		return
+	}
+	var derive solver.DepositDeriver
+	if depositAddresses {
+		derive = solver.DeriveDepositAddress
+	} else if chain != "solana" {
+		http.Error(w, "Invalid chain parameter. Swap references are only supported on 'solana'", http.StatusBadRequest)
+		return
+	}
+
+	fromToken, err := getTokenForChain(chain)
+	if err != nil {
+		http.Error(w, "Invalid chain parameter: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	// Deposit addresses are only watched for the native asset
+	if requested := r.URL.Query().Get("fromToken"); requested != "" && requested != fromToken {
+		http.Error(w, fmt.Sprintf("fromToken must be %s on %s", fromToken, chain), http.StatusBadRequest)
+		return
+	}
+
+	poolAddress, err := solverClient.GetPool(fromToken)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error fetching pool: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	intent, err := swapIntents.Create(chain, fromToken, toToken, destinationAddress, derive)
+	if errors.Is(err, solver.ErrTooManyIntents) {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error creating swap intent: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	data := map[string]interface{}{
+		"reference":          intent.Reference,
+		"poolAddress":        poolAddress,
+		"toToken":            intent.ToToken,
+		"destinationAddress": intent.DestinationAddress,
+		"expiresAt":          intent.ExpiresAt,
+	}
+	if intent.DepositAddress != "" {
+		data["fromToken"] = intent.FromToken
+		data["depositAddress"] = intent.DepositAddress
+	}
+
+	// Return response
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"chain":  chain,
+		"data":   data,
+	})
+}
+
+// writeFaucetLimitError answers a refused faucet request, with 429 and
+// Retry-After when waiting helps
+func writeFaucetLimitError(w http.ResponseWriter, err error) {
+	var limitErr *solver.FaucetLimitError
+	if !errors.As(err, &limitErr) {
+		http.Error(w, fmt.Sprintf("Error requesting faucet: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if limitErr.Rule == solver.FaucetRulePerRequest {
+		http.Error(w, limitErr.Error(), http.StatusBadRequest)
+		return
+	}
+	if limitErr.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(limitErr.RetryAfter.Seconds()))))
+	}
+	http.Error(w, limitErr.Error(), http.StatusTooManyRequests)
+}
+
+// clientIP returns the address a request came from. With -faucet-trust-proxy
+// it is the X-Forwarded-For entry added by the outermost trusted proxy:
+// entries further left are set by the client and can be forged.
+func clientIP(r *http.Request) string {
+	if faucetTrustProxy {
+		if forwarded := r.Header.Values("X-Forwarded-For"); len(forwarded) > 0 {
+			entries := strings.Split(strings.Join(forwarded, ","), ",")
+			index := len(entries) - faucetProxyHops
+			if index < 0 {
+				index = 0
 			}
+			return strings.TrimSpace(entries[index])
 		}
 	}
-	return 0, fmt.Errorf("could not extract amount from transaction")
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
 }
 
 func getTokenForChain(chain string) (string, error) {
@@ -297,38 +967,58 @@ func handleFaucet(w http.ResponseWriter, r *http.Request) {
 
 	// Get optional amount parameter
 	amount := r.URL.Query().Get("amount")
-	var amountFloat float64
+	amountFloat := solver.FaucetDefaultAmount(chain)
 	var err error
 	if amount != "" {
 		amountFloat, err = strconv.ParseFloat(amount, 64)
-		if err != nil {
+		if err != nil || math.IsNaN(amountFloat) || math.IsInf(amountFloat, 0) || amountFloat <= 0 {
 			http.Error(w, "Invalid amount value", http.StatusBadRequest)
 			return
 		}
 	}
 
-	var result map[string]interface{}
-
-	switch chain {
-	case "solana":
-		if amount == "" {
-			result, err = solverClient.RequestSolanaAirdrop(address)
-		} else {
-			result, err = solverClient.RequestSolanaAirdropWithAmount(address, amountFloat)
-		}
-	case "ethereum":
-		if amount == "" {
-			result, err = solverClient.RequestEthereumFaucet(address)
-		} else {
-			result, err = solverClient.RequestEthereumFaucetWithAmount(address, amountFloat)
-		}
-	default:
+	if chain != "solana" && chain != "ethereum" {
 		http.Error(w, "Invalid chain parameter. Must be 'solana' or 'ethereum'", http.StatusBadRequest)
 		return
 	}
+	// One address has one spelling, so cooldowns cannot be dodged by changing case
+	if chain == "ethereum" {
+		if !common.IsHexAddress(address) {
+			http.Error(w, "Invalid Ethereum address", http.StatusBadRequest)
+			return
+		}
+		address = common.HexToAddress(address).Hex()
+	}
+	token, err := getTokenForChain(chain)
+	if err != nil {
+		http.Error(w, "Error getting token for chain: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
 
+	// Admit the request within the limits before sending anything
+	grant, err := faucetLimiter.Reserve(chain, token, address, clientIP(r), amountFloat)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Error requesting faucet: %v", err), http.StatusInternalServerError)
+		writeFaucetLimitError(w, err)
+		return
+	}
+
+	// Wait for the payment to confirm when asked to
+	confirm := r.URL.Query().Get("confirm") == "true"
+	result, err := solverClient.RequestFaucet(r.Context(), solver.FaucetRequest{
+		Chain:   chain,
+		Address: address,
+		Amount:  amountFloat,
+		Confirm: confirm,
+		GrantID: grant.ID,
+	})
+	// A payment that was sent counts against the limits even if it did not confirm
+	if result != nil {
+		faucetLimiter.Complete(grant, result.TxID)
+	} else {
+		faucetLimiter.Cancel(grant)
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error requesting faucet: %v", err), signingErrorStatus(err))
 		return
 	}
 
@@ -396,17 +1086,39 @@ func handleFetchBalance(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Optional token symbol or contract address, native asset when empty
+	tokenParam := r.URL.Query().Get("token")
+
 	// Get balance based on chain
 	var balance *solver.Balance
+	var token solver.Token
 	var err error
 
+	if tokenParam != "" {
+		token, err = solver.ResolveToken(chain, tokenParam)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
 	switch chain {
 	case "solana":
-		balance, err = solverClient.GetSolanaBalance(address)
+		if tokenParam == "" || token.IsNative() {
+			balance, err = solverClient.GetSolanaBalance(address)
+		} else {
+			balance, err = solverClient.GetSolanaTokenBalance(address, token)
+		}
 	case "ethereum":
-		balance, err = solverClient.GetEthereumBalance(address)
+		if tokenParam == "" || token.IsNative() {
+			balance, err = solverClient.GetEthereumBalance(address)
+		} else {
+			balance, err = solverClient.GetEthereumTokenBalance(address, token)
+		}
+	case "bitcoin":
+		balance, err = solverClient.GetBitcoinBalance(address)
 	default:
-		http.Error(w, "Invalid chain parameter. Must be 'solana' or 'ethereum'", http.StatusBadRequest)
+		http.Error(w, "Invalid chain parameter. Must be 'solana', 'ethereum' or 'bitcoin'", http.StatusBadRequest)
 		return
 	}
 
@@ -424,6 +1136,38 @@ func handleFetchBalance(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// handleBalances looks up a list of balances across chains in one request
+func handleBalances(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var queries []solver.BalanceQuery
+	if err := json.NewDecoder(r.Body).Decode(&queries); err != nil {
+		http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(queries) == 0 {
+		http.Error(w, "at least one balance query is required", http.StatusBadRequest)
+		return
+	}
+	if len(queries) > solver.MaxBalanceQueries {
+		http.Error(w, fmt.Sprintf("at most %d balance queries are allowed", solver.MaxBalanceQueries), http.StatusBadRequest)
+		return
+	}
+
+	// Entries that fail carry their own error
+	results := solverClient.GetBalances(r.Context(), queries)
+
+	// Return response
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"data":   results,
+	})
+}
+
 func handleQuoteSwap(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -479,96 +1223,205 @@ func handleQuoteSwap(w http.ResponseWriter, r *http.Request) {
 
 // StreamingRequest represents a streaming request with size information
 
-func handleDeployBytecode(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
+// maxFormFieldSize bounds the non-file fields of a multipart upload
+const maxFormFieldSize = 64 << 10
 
-	// Create buffered reader for the request body
-	bodyReader := bufio.NewReaderSize(r.Body, 1024*1024) // 1MB buffer
+// errUploadStorage marks uploads that failed on the server side
+var errUploadStorage = errors.New("failed to store upload")
 
-	// Read the contract size header
-	contractSizeStr, err := bodyReader.ReadString('|')
-	if err != nil {
-		http.Error(w, fmt.Sprintf("Error reading contract size: %v", err), http.StatusBadRequest)
-		return
+// wasmUpload is a contract and service WASM pair received in a request
+type wasmUpload struct {
+	contractFile *os.File
+	serviceFile  *os.File
+	contract     *solver.WasmFile
+	service      *solver.WasmFile
+	// fields are the other fields of a multipart upload
+	fields map[string]string
+}
+
+// Remove deletes the uploaded files
+func (u *wasmUpload) Remove() {
+	for _, file := range []*os.File{u.contractFile, u.serviceFile} {
+		if file != nil {
+			file.Close()
+			os.Remove(file.Name())
+		}
 	}
-	contractSize, err := strconv.ParseInt(strings.TrimSuffix(contractSizeStr, "|"), 10, 64)
-	if err != nil {
-		http.Error(w, fmt.Sprintf("Invalid contract size: %v", err), http.StatusBadRequest)
-		return
+}
+
+// option returns an option of the upload: a multipart field, or a query
+// parameter with the legacy framing
+func (u *wasmUpload) option(r *http.Request, name string) string {
+	if value, ok := u.fields[name]; ok {
+		return value
 	}
+	return r.URL.Query().Get(name)
+}
 
-	// Read the service size header
-	serviceSizeStr, err := bodyReader.ReadString('|')
+// createFile creates a file for an uploaded module in the upload directory
+func (u *wasmUpload) createFile(name string) (*os.File, error) {
+	file, err := os.CreateTemp(uploadDir, name+"-*.wasm")
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Error reading service size: %v", err), http.StatusBadRequest)
-		return
+		return nil, fmt.Errorf("%w: %v", errUploadStorage, err)
+	}
+	return file, nil
+}
+
+// receiveWasmUpload reads the contract and service WASM of a request into
+// files and checks them. The body is either multipart/form-data with
+// "contract" and "service" file fields, or the legacy
+// contractSize|serviceSize|<contract><service> framing. On failure it writes
+// the error response and returns nil.
+func receiveWasmUpload(w http.ResponseWriter, r *http.Request) *wasmUpload {
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
+	upload := &wasmUpload{fields: make(map[string]string)}
+
+	var err error
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "multipart/form-data" {
+		err = upload.readMultipart(r)
+	} else {
+		err = upload.readFramed(r)
+	}
+	if err == nil {
+		upload.contract, err = inspectUploadedWasm("contract", upload.contractFile)
+	}
+	if err == nil {
+		upload.service, err = inspectUploadedWasm("service", upload.serviceFile)
 	}
-	serviceSize, err := strconv.ParseInt(strings.TrimSuffix(serviceSizeStr, "|"), 10, 64)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Invalid service size: %v", err), http.StatusBadRequest)
-		return
+		upload.Remove()
+		status := http.StatusBadRequest
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			status = http.StatusRequestEntityTooLarge
+		} else if errors.Is(err, errUploadStorage) {
+			status = http.StatusInternalServerError
+		}
+		http.Error(w, err.Error(), status)
+		return nil
 	}
 
-	solver.Logger.Printf("Receiving WASM files - Contract: %d bytes, Service: %d bytes",
-		contractSize, serviceSize)
+	solver.Logger.Printf("Successfully received WASM files - Contract: %d bytes (%s), Service: %d bytes (%s)",
+		upload.contract.Size, upload.contract.SHA256, upload.service.Size, upload.service.SHA256)
+	return upload
+}
 
-	// Create temporary files with buffered writers
-	contractFile, err := os.CreateTemp("/Users/luffybhaagi/RustroverProjects/linera-protocol-jvff/examples/universal-solver", "contract.wasm")
+// inspectUploadedWasm checks the uploaded file is a WASM module
+func inspectUploadedWasm(name string, file *os.File) (*solver.WasmFile, error) {
+	if err := file.Sync(); err != nil {
+		return nil, fmt.Errorf("%w: %v", errUploadStorage, err)
+	}
+	wasm, err := solver.InspectWasmFile(file.Name())
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Error creating temp file: %v", err), http.StatusInternalServerError)
-		return
+		return nil, fmt.Errorf("%s: %w", name, err)
 	}
-	defer os.Remove(contractFile.Name())
-	defer contractFile.Close()
+	return wasm, nil
+}
 
-	serviceFile, err := os.CreateTemp("/Users/luffybhaagi/RustroverProjects/linera-protocol-jvff/examples/universal-solver", "service.wasm")
+// readMultipart streams the file fields of a multipart upload to files and
+// keeps the other fields
+func (u *wasmUpload) readMultipart(r *http.Request) error {
+	reader, err := r.MultipartReader()
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Error creating temp file: %v", err), http.StatusInternalServerError)
-		return
+		return fmt.Errorf("invalid multipart body: %w", err)
 	}
-	defer os.Remove(serviceFile.Name())
-	defer serviceFile.Close()
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("invalid multipart body: %w", err)
+		}
 
-	// Create buffered writers
-	contractWriter := bufio.NewWriterSize(contractFile, 1024*1024) // 1MB buffer
-	serviceWriter := bufio.NewWriterSize(serviceFile, 1024*1024)   // 1MB buffer
+		name := part.FormName()
+		switch name {
+		case "contract", "service":
+			target := &u.contractFile
+			if name == "service" {
+				target = &u.serviceFile
+			}
+			if *target != nil {
+				return fmt.Errorf("duplicate %s field", name)
+			}
+			if *target, err = u.createFile(name); err != nil {
+				return err
+			}
+			if _, err := io.Copy(*target, part); err != nil {
+				return fmt.Errorf("error reading %s: %w", name, err)
+			}
+		default:
+			value, err := io.ReadAll(io.LimitReader(part, maxFormFieldSize+1))
+			if err != nil {
+				return fmt.Errorf("error reading %s: %w", name, err)
+			}
+			if len(value) > maxFormFieldSize {
+				return fmt.Errorf("field %s is too large", name)
+			}
+			u.fields[name] = string(value)
+		}
+	}
 
-	// Copy contract WASM with progress tracking
-	contractWritten, err := io.CopyN(contractWriter, bodyReader, contractSize)
-	if err != nil {
-		http.Error(w, fmt.Sprintf("Error writing contract WASM: %v", err), http.StatusInternalServerError)
-		return
+	if u.contractFile == nil || u.serviceFile == nil {
+		return fmt.Errorf("contract and service files are required")
 	}
-	if err := contractWriter.Flush(); err != nil {
-		http.Error(w, fmt.Sprintf("Error flushing contract WASM: %v", err), http.StatusInternalServerError)
-		return
+	return nil
+}
+
+// readFramed reads a contractSize|serviceSize|<contract><service> body
+func (u *wasmUpload) readFramed(r *http.Request) error {
+	// Create buffered reader for the request body
+	bodyReader := bufio.NewReaderSize(r.Body, 1024*1024) // 1MB buffer
+
+	// Read the contract and service size headers
+	var sizes [2]int64
+	for i, name := range []string{"contract", "service"} {
+		sizeStr, err := bodyReader.ReadString('|')
+		if err != nil {
+			return fmt.Errorf("error reading %s size: %w", name, err)
+		}
+		sizes[i], err = strconv.ParseInt(strings.TrimSuffix(sizeStr, "|"), 10, 64)
+		if err != nil || sizes[i] <= 0 || sizes[i] > maxUploadSize {
+			return fmt.Errorf("invalid %s size %q", name, strings.TrimSuffix(sizeStr, "|"))
+		}
 	}
 
-	// Copy service WASM with progress tracking
-	serviceWritten, err := io.CopyN(serviceWriter, bodyReader, serviceSize)
-	if err != nil {
-		http.Error(w, fmt.Sprintf("Error writing service WASM: %v", err), http.StatusInternalServerError)
-		return
+	solver.Logger.Printf("Receiving WASM files - Contract: %d bytes, Service: %d bytes", sizes[0], sizes[1])
+
+	var err error
+	if u.contractFile, err = u.createFile("contract"); err != nil {
+		return err
 	}
-	if err := serviceWriter.Flush(); err != nil {
-		http.Error(w, fmt.Sprintf("Error flushing service WASM: %v", err), http.StatusInternalServerError)
-		return
+	if u.serviceFile, err = u.createFile("service"); err != nil {
+		return err
+	}
+	for i, file := range []*os.File{u.contractFile, u.serviceFile} {
+		if _, err := io.CopyN(file, bodyReader, sizes[i]); err != nil {
+			if errors.Is(err, io.EOF) {
+				return fmt.Errorf("WASM file size mismatch")
+			}
+			return fmt.Errorf("error reading WASM: %w", err)
+		}
 	}
+	return nil
+}
 
-	// Verify sizes
-	if contractWritten != contractSize || serviceWritten != serviceSize {
-		http.Error(w, "WASM file size mismatch", http.StatusBadRequest)
+func handleDeployBytecode(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	solver.Logger.Printf("Successfully received WASM files - Contract: %d bytes, Service: %d bytes",
-		contractWritten, serviceWritten)
+	upload := receiveWasmUpload(w, r)
+	if upload == nil {
+		return
+	}
+	defer upload.Remove()
 
 	// Execute the publish bytecode command
-	bytecodeID, err := solverClient.PublishBytecodeFromFiles(contractFile.Name(), serviceFile.Name())
+	// Bytecode published before is not published again unless forced
+	force := upload.option(r, "force") == "true"
+	published, err := solverClient.PublishBytecodeFromFiles(upload.contractFile.Name(), upload.serviceFile.Name(), force)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Error publishing bytecode: %v", err), http.StatusInternalServerError)
 		return
@@ -579,9 +1432,12 @@ func handleDeployBytecode(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"status": "success",
 		"data": map[string]interface{}{
-			"bytecodeId":   bytecodeID,
-			"contractSize": contractWritten,
-			"serviceSize":  serviceWritten,
+			"bytecodeId":     published.BytecodeID,
+			"cached":         published.Cached,
+			"contractSize":   upload.contract.Size,
+			"serviceSize":    upload.service.Size,
+			"contractSha256": upload.contract.SHA256,
+			"serviceSha256":  upload.service.SHA256,
 		},
 	})
 }
@@ -592,9 +1448,11 @@ func handleCreateApplication(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Get bytecode ID from request body
+	// Get bytecode ID and instantiation options from request body. IDs are
+	// validated as they are decoded.
 	var req struct {
-		BytecodeID string `json:"bytecodeId"`
+		BytecodeID solver.BytecodeID `json:"bytecodeId"`
+		solver.CreateApplicationOptions
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -609,7 +1467,7 @@ func handleCreateApplication(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Create the application
-	applicationID, err := solverClient.CreateApplication(req.BytecodeID)
+	applicationID, err := solverClient.CreateApplication(req.BytecodeID, req.CreateApplicationOptions)
 	if err != nil {
 		http.Error(w, fmt.Sprintf("Error creating application: %v", err), http.StatusInternalServerError)
 		return
@@ -625,102 +1483,409 @@ func handleCreateApplication(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-func handleGithubAuth(w http.ResponseWriter, r *http.Request) {
+// handleSubmitDeployment queues a job publishing the uploaded bytecode and
+// creating an application from it, returning the job right away
+func handleSubmitDeployment(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	upload := receiveWasmUpload(w, r)
+	if upload == nil {
+		return
+	}
+	defer upload.Remove()
+
+	var application solver.CreateApplicationOptions
+	if raw := upload.option(r, "application"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &application); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid application options: %v", err), http.StatusBadRequest)
+			return
+		}
+	}
+	publishOnly := upload.option(r, "publishOnly") == "true"
+	force := upload.option(r, "force") == "true"
+
+	job, err := deployments.Submit(upload.contractFile.Name(), upload.serviceFile.Name(), publishOnly, force, application)
+	if errors.Is(err, solver.ErrDeploymentQueueFull) {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error queueing deployment: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	// Return response
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/deployments/"+job.ID)
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"data":   job,
+	})
+}
+
+// buildFromRepoRequest is the body of POST /build_from_repo
+type buildFromRepoRequest struct {
+	solver.BuildSource
+	Application solver.CreateApplicationOptions `json:"application"`
+	PublishOnly bool                            `json:"publishOnly"`
+	Force       bool                            `json:"force"`
+}
+
+// handleBuildFromRepo queues a job building an application from a GitHub
+// repository or a local checkout, then publishing it like an upload
+func handleBuildFromRepo(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req buildFromRepoRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64<<10)).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	// GitHub repositories are read with the signed-in user's token
+	token := ""
+	if req.LocalPath == "" {
+		var ok bool
+		if token, ok = githubToken(r); !ok {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+	}
+
+	job, err := deployments.SubmitBuild(req.BuildSource, token, req.PublishOnly, req.Force, req.Application)
+	if errors.Is(err, solver.ErrBuildsDisabled) {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+	if errors.Is(err, solver.ErrDeploymentQueueFull) {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	var invalid *solver.InvalidBuildSourceError
+	if errors.As(err, &invalid) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error queueing build: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	// Return response
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", "/deployments/"+job.ID)
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"data":   job,
+	})
+}
+
+// handleDeployment serves GET /deployments/{id} and its event stream at
+// GET /deployments/{id}/events
+func handleDeployment(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	// Generate random state for CSRF protection
-	state := solver.GenerateRandomState()
+	id, stream := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/deployments/"), "/events")
+	if id == "" || strings.Contains(id, "/") {
+		http.NotFound(w, r)
+		return
+	}
+	if stream {
+		streamDeployment(w, r, id)
+		return
+	}
+
+	job, ok := deployments.Get(id)
+	if !ok {
+		http.Error(w, "deployment not found", http.StatusNotFound)
+		return
+	}
+
+	// Return response
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"data":   job,
+	})
+}
+
+// streamDeployment sends the job and then its progress as server-sent events:
+// "log" events with each log line and "job" events when the phase changes.
+// The stream ends once the job is done or failed.
+func streamDeployment(w http.ResponseWriter, r *http.Request, id string) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+	job, events, cancel, ok := deployments.Subscribe(id)
+	if !ok {
+		http.Error(w, "deployment not found", http.StatusNotFound)
+		return
+	}
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	send := func(event string, data interface{}) {
+		encoded, err := json.Marshal(data)
+		if err != nil {
+			return
+		}
+		fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, encoded)
+		flusher.Flush()
+	}
+
+	send(solver.DeploymentEventJob, job)
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event, open := <-events:
+			if !open {
+				// Events may have been dropped for a slow reader, end on the final state
+				if job, ok := deployments.Get(id); ok {
+					send(solver.DeploymentEventJob, job)
+				}
+				return
+			}
+			if event.Type == solver.DeploymentEventLog {
+				send(event.Type, event.Log)
+			} else {
+				send(event.Type, event.Job)
+			}
+		}
+	}
+}
+
+// sessionCookie holds the opaque ID of a GitHub session
+const sessionCookie = "github_session"
+
+// oauthStateCookie ties a GitHub sign-in to the browser that started it
+const oauthStateCookie = "oauth_state"
+
+// githubToken returns the GitHub access token of the request's session
+func githubToken(r *http.Request) (string, bool) {
+	cookie, err := r.Cookie(sessionCookie)
+	if err != nil {
+		return "", false
+	}
+	return githubSessions.Token(cookie.Value)
+}
 
-	// Get the host from the request
-	host := r.Host
+// loginRedirect returns where to send the user after signing in: redirect
+// when its origin is allowed, the first allowed origin when it is empty
+func loginRedirect(redirect string) (*url.URL, bool) {
+	if redirect == "" {
+		target := *loginRedirects[0]
+		if target.Path == "" {
+			target.Path = "/"
+		}
+		return &target, true
+	}
+	target, err := url.Parse(redirect)
+	if err != nil {
+		return nil, false
+	}
+	for _, allowed := range loginRedirects {
+		if target.Scheme == allowed.Scheme && target.Host == allowed.Host {
+			return target, true
+		}
+	}
+	return nil, false
+}
 
-	// Store state in cookie with correct domain
-	stateCookie := &http.Cookie{
-		Name:     "oauth_state",
-		Value:    state,
+// setAuthCookie sets an HttpOnly cookie the frontend sends along with its
+// cross-site requests. maxAge below 0 deletes it.
+func setAuthCookie(w http.ResponseWriter, name, value string, maxAge int) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Value:    value,
 		Path:     "/",
-		Domain:   host, // Use the full ngrok domain
-		MaxAge:   3600,
-		HttpOnly: false,
-		Secure:   true,
-		SameSite: http.SameSiteNoneMode,
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   true,                  // Required for HTTPS
+		SameSite: http.SameSiteNoneMode, // Required for cross-site access
+	})
+}
+
+// handleGithubAuth starts a GitHub sign-in, returning afterwards to the
+// optional redirect query parameter
+func handleGithubAuth(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	redirect, ok := loginRedirect(r.URL.Query().Get("redirect"))
+	if !ok {
+		http.Error(w, "redirect is not an allowed origin", http.StatusBadRequest)
+		return
+	}
+
+	// Random state for CSRF protection and a PKCE code verifier
+	flow, err := oauthFlows.Begin(redirect.String())
+	if errors.Is(err, solver.ErrTooManyOAuthFlows) {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error starting sign-in: %v", err), http.StatusInternalServerError)
+		return
 	}
-	http.SetCookie(w, stateCookie)
+	setAuthCookie(w, oauthStateCookie, flow.State, int(time.Until(flow.ExpiresAt).Seconds()))
 
 	// Redirect to GitHub OAuth
-	authURL := fmt.Sprintf(
-		"https://github.com/login/oauth/authorize?client_id=%s&redirect_uri=%s&state=%s&scope=repo",
-		githubClient.ClientID,
-		githubClient.RedirectURI,
-		state,
-	)
-	http.Redirect(w, r, authURL, http.StatusTemporaryRedirect)
+	http.Redirect(w, r, githubClient.AuthorizeURL(flow), http.StatusTemporaryRedirect)
 }
 
+// handleGithubCallback finishes a GitHub sign-in, keeping the access token in
+// a server-side session
 func handleGithubCallback(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
-	// Verify state
-	stateCookie, err := r.Cookie("oauth_state")
+	// Verify state, which ends the sign-in
+	stateCookie, err := r.Cookie(oauthStateCookie)
 	if err != nil {
 		http.Error(w, "Invalid state", http.StatusBadRequest)
 		return
 	}
-
-	if r.URL.Query().Get("state") != stateCookie.Value {
+	state := r.URL.Query().Get("state")
+	if state == "" || state != stateCookie.Value {
 		http.Error(w, "State mismatch", http.StatusBadRequest)
 		return
 	}
+	setAuthCookie(w, oauthStateCookie, "", -1)
+	flow, ok := oauthFlows.Finish(state)
+	if !ok {
+		http.Error(w, "Sign-in expired, please sign in again", http.StatusBadRequest)
+		return
+	}
+	redirect, _ := url.Parse(flow.Redirect)
+	query := redirect.Query()
+
+	// The user declined, or GitHub refused the sign-in
+	if githubError := r.URL.Query().Get("error"); githubError != "" {
+		query.Set("auth", "error")
+		query.Set("error", githubError)
+		redirect.RawQuery = query.Encode()
+		http.Redirect(w, r, redirect.String(), http.StatusTemporaryRedirect)
+		return
+	}
 
 	// Exchange code for access token
-	code := r.URL.Query().Get("code")
-	token, err := githubClient.ExchangeCodeForToken(code)
+	token, err := githubClient.ExchangeCodeForToken(r.Context(), r.URL.Query().Get("code"), flow.Verifier)
+	var oauthErr *solver.GithubOAuthError
+	if errors.As(err, &oauthErr) {
+		http.Error(w, fmt.Sprintf("Error exchanging code: %v", err), http.StatusBadRequest)
+		return
+	}
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Error exchanging code: %v", err), http.StatusInternalServerError)
+		http.Error(w, fmt.Sprintf("Error exchanging code: %v", err), http.StatusBadGateway)
 		return
 	}
 
-	// Get the host from the request
-	host := r.Host
+	sessionID, err := githubSessions.Create(token)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Error creating session: %v", err), http.StatusInternalServerError)
+		return
+	}
+	setAuthCookie(w, sessionCookie, sessionID, int(githubSessionTTL.Seconds()))
 
-	// Set token in cookie with correct settings for cross-domain access
-	cookie := &http.Cookie{
-		Name:     "github_token",
-		Value:    token,
-		Path:     "/",
-		Domain:   host, // Use the full ngrok domain
-		MaxAge:   3600 * 24,
-		HttpOnly: false,                 // Allow JavaScript access
-		Secure:   true,                  // Required for HTTPS
-		SameSite: http.SameSiteNoneMode, // Required for cross-site access
+	// Redirect to frontend with success parameter
+	query.Set("auth", "success")
+	redirect.RawQuery = query.Encode()
+	http.Redirect(w, r, redirect.String(), http.StatusTemporaryRedirect)
+}
+
+// handleGithubLogout ends the GitHub session. The token stays valid on GitHub,
+// see handleGithubRevoke.
+func handleGithubLogout(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
 	}
-	http.SetCookie(w, cookie)
 
-	// Set state cookie with same settings
-	stateCookie = &http.Cookie{
-		Name:     "oauth_state",
-		Value:    stateCookie.Value,
-		Path:     "/",
-		Domain:   host, // Use the full ngrok domain
-		MaxAge:   3600,
-		HttpOnly: false,
-		Secure:   true,
-		SameSite: http.SameSiteNoneMode,
+	if cookie, err := r.Cookie(sessionCookie); err == nil {
+		githubSessions.Delete(cookie.Value)
 	}
-	http.SetCookie(w, stateCookie)
+	setAuthCookie(w, sessionCookie, "", -1)
 
-	// Redirect to frontend with success parameter
-	frontendURL := "http://localhost:3002"
-	// if strings.Contains(r.Host, "ngrok.io") {
-	// 	frontendURL = "https://uni-solver.ngrok.io"
-	// }
-	http.Redirect(w, r, frontendURL+"/?auth=success", http.StatusTemporaryRedirect)
+	// Return response
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+	})
+}
+
+// handleGithubRevoke revokes the session's token on GitHub and ends the session
+func handleGithubRevoke(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	cookie, err := r.Cookie(sessionCookie)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	token, ok := githubSessions.Token(cookie.Value)
+	if !ok {
+		setAuthCookie(w, sessionCookie, "", -1)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	// The session is kept when GitHub could not revoke the token, to try again
+	if err := githubClient.RevokeToken(r.Context(), token); err != nil {
+		http.Error(w, fmt.Sprintf("Error revoking token: %v", err), githubErrorStatus(w, err))
+		return
+	}
+	githubSessions.Delete(cookie.Value)
+	setAuthCookie(w, sessionCookie, "", -1)
+
+	// Return response
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+	})
+}
+
+// githubErrorStatus is the status answering a failed GitHub API call: the
+// API's own for missing access, 429 with Retry-After for rate limits, 502
+// for other API errors and 500 otherwise
+func githubErrorStatus(w http.ResponseWriter, err error) int {
+	var apiErr *solver.GithubAPIError
+	if !errors.As(err, &apiErr) {
+		return http.StatusInternalServerError
+	}
+	switch {
+	case apiErr.RateLimited:
+		if wait := time.Until(apiErr.ResetAt); wait > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+		}
+		return http.StatusTooManyRequests
+	case apiErr.StatusCode == http.StatusUnauthorized || apiErr.StatusCode == http.StatusForbidden || apiErr.StatusCode == http.StatusNotFound:
+		return apiErr.StatusCode
+	default:
+		return http.StatusBadGateway
+	}
 }
 
 func handleListRepos(w http.ResponseWriter, r *http.Request) {
@@ -729,17 +1894,17 @@ func handleListRepos(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Get token from cookie
-	tokenCookie, err := r.Cookie("github_token")
-	if err != nil {
+	// Get token from the session
+	token, ok := githubToken(r)
+	if !ok {
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
 	// Fetch repositories
-	repos, err := solver.FetchGithubRepos(tokenCookie.Value)
+	repos, err := githubClient.FetchGithubRepos(r.Context(), token)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Error fetching repos: %v", err), http.StatusInternalServerError)
+		http.Error(w, fmt.Sprintf("Error fetching repos: %v", err), githubErrorStatus(w, err))
 		return
 	}
 
@@ -759,9 +1924,9 @@ func handleFetchRepoFiles(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Get token from cookie
-	tokenCookie, err := r.Cookie("github_token")
-	if err != nil {
+	// Get token from the session
+	token, ok := githubToken(r)
+	if !ok {
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
@@ -777,9 +1942,9 @@ func handleFetchRepoFiles(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Fetch repository contents
-	contents, err := githubClient.FetchRepoContents(tokenCookie.Value, owner, repo, path)
+	contents, err := githubClient.FetchRepoContents(r.Context(), token, owner, repo, path)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Error fetching repo contents: %v", err), http.StatusInternalServerError)
+		http.Error(w, fmt.Sprintf("Error fetching repo contents: %v", err), githubErrorStatus(w, err))
 		return
 	}
 
@@ -799,9 +1964,9 @@ func handleFetchAllFiles(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Get token from cookie
-	tokenCookie, err := r.Cookie("github_token")
-	if err != nil {
+	// Get token from the session
+	token, ok := githubToken(r)
+	if !ok {
 		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
@@ -815,10 +1980,24 @@ func handleFetchAllFiles(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Optional ref, comma-separated globs and size limit
+	options := solver.RepoFetchOptions{Ref: r.URL.Query().Get("ref")}
+	if include := r.URL.Query().Get("include"); include != "" {
+		options.Include = strings.Split(include, ",")
+	}
+	if maxFileSize := r.URL.Query().Get("maxFileSize"); maxFileSize != "" {
+		size, err := strconv.ParseInt(maxFileSize, 10, 64)
+		if err != nil || size <= 0 {
+			http.Error(w, "maxFileSize must be a positive number of bytes", http.StatusBadRequest)
+			return
+		}
+		options.MaxFileSize = size
+	}
+
 	// Fetch all repository files recursively
-	files, err := githubClient.FetchRepoFilesRecursively(tokenCookie.Value, owner, repo)
+	files, err := githubClient.FetchRepoFilesRecursively(r.Context(), token, owner, repo, options)
 	if err != nil {
-		http.Error(w, fmt.Sprintf("Error fetching repo files: %v", err), http.StatusInternalServerError)
+		http.Error(w, fmt.Sprintf("Error fetching repo files: %v", err), githubErrorStatus(w, err))
 		return
 	}
 
@@ -0,0 +1,36 @@
+package solver
+
+import (
+	"context"
+	"errors"
+
+	"github.com/linera-protocol/examples/universal-solver/client/solver/keys"
+)
+
+// LoadAccounts reads the named accounts to derive from the JSON file at path, e.g.
+// [{"chain": "ethereum", "name": "faucet", "path": "m/44'/60'/1'/0/0"}]
+func LoadAccounts(path string) ([]keys.AccountSpec, error) {
+	var specs []keys.AccountSpec
+	if err := readJSONFile(path, &specs); err != nil {
+		return nil, err
+	}
+	return specs, nil
+}
+
+// Accounts returns the derived accounts
+func Accounts() []*keys.Account {
+	if chainKeys == nil {
+		return nil
+	}
+	return chainKeys.Accounts()
+}
+
+// faucetEthereumAddress returns the faucet account address, or the pool
+// address when no faucet account is configured
+func faucetEthereumAddress(ctx context.Context, signer Signer) (string, error) {
+	address, err := signer.Address(ctx, keys.ChainEthereum, keys.AccountFaucet)
+	if errors.Is(err, ErrUnknownAccount) {
+		return signer.Address(ctx, keys.ChainEthereum, keys.AccountPool)
+	}
+	return address, err
+}
@@ -0,0 +1,327 @@
+package solver
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcutil"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	ethrpc "github.com/ethereum/go-ethereum/rpc"
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+	"github.com/linera-protocol/examples/universal-solver/client/solver/keys"
+)
+
+const (
+	// MaxBalanceQueries bounds the entries of one GetBalances call, and so
+	// the Solana batches it sends
+	MaxBalanceQueries = 100
+	// solanaMaxAccounts is the most accounts getMultipleAccounts takes
+	solanaMaxAccounts = 100
+	// splAmountOffset is where the u64 amount sits in SPL token account data,
+	// after the mint and owner keys
+	splAmountOffset = 64
+)
+
+// BalanceQuery names a balance to look up. Token is a symbol or contract
+// address from the token configuration, the native asset when empty.
+type BalanceQuery struct {
+	Chain   string `json:"chain"`
+	Address string `json:"address"`
+	Token   string `json:"token,omitempty"`
+}
+
+// BalanceResult is the balance found for a query, or why it was not
+type BalanceResult struct {
+	BalanceQuery
+	Balance *Balance `json:"balance,omitempty"`
+	Error   string   `json:"error,omitempty"`
+}
+
+// balanceLookup is a query with its resolved token and the slot of its result
+type balanceLookup struct {
+	index int
+	token Token
+}
+
+// GetBalances looks up every query, batching the lookups per chain: EVM batch
+// JSON-RPC on Ethereum, getMultipleAccounts on Solana and a single UTXO set
+// scan on Bitcoin. Chains are looked up concurrently. Results are in query
+// order, and a failed lookup only fails its own entry.
+func (c *Client) GetBalances(ctx context.Context, queries []BalanceQuery) []BalanceResult {
+	results := make([]BalanceResult, len(queries))
+	byChain := make(map[string][]balanceLookup)
+	for i, query := range queries {
+		results[i].BalanceQuery = query
+		token, err := resolveBalanceToken(query)
+		if err != nil {
+			results[i].Error = err.Error()
+			continue
+		}
+		byChain[query.Chain] = append(byChain[query.Chain], balanceLookup{index: i, token: token})
+	}
+
+	var wg sync.WaitGroup
+	run := func(lookup func()) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			lookup()
+		}()
+	}
+	for chain, lookups := range byChain {
+		lookups := lookups
+		switch chain {
+		case keys.ChainEthereum:
+			run(func() { c.ethereumBalances(ctx, lookups, results) })
+		case keys.ChainSolana:
+			run(func() { c.solanaBalances(ctx, lookups, results) })
+		case keys.ChainBitcoin:
+			run(func() { c.bitcoinBalances(lookups, results) })
+		}
+	}
+	wg.Wait()
+	return results
+}
+
+// resolveBalanceToken returns the token a query asks for
+func resolveBalanceToken(query BalanceQuery) (Token, error) {
+	native, err := nativeToken(query.Chain)
+	if err != nil {
+		return Token{}, err
+	}
+	if query.Address == "" {
+		return Token{}, fmt.Errorf("address is required")
+	}
+	if query.Token == "" {
+		return native, nil
+	}
+	return ResolveToken(query.Chain, query.Token)
+}
+
+// setBalance fills in the result of lookup from a base-unit amount
+func setBalance(results []BalanceResult, lookup balanceLookup, amount *big.Int) {
+	result := &results[lookup.index]
+	result.Balance = &Balance{
+		Address: result.Address,
+		Amount:  lookup.token.FromBaseUnits(amount),
+		Symbol:  lookup.token.Symbol,
+	}
+	if !lookup.token.IsNative() {
+		result.Balance.Token = lookup.token.Address
+	}
+}
+
+// ethereumBalances reads every balance in one batch request: eth_getBalance
+// for ETH and balanceOf calls for ERC-20 tokens
+func (c *Client) ethereumBalances(ctx context.Context, lookups []balanceLookup, results []BalanceResult) {
+	fail := func(err error) {
+		for _, lookup := range lookups {
+			results[lookup.index].Error = err.Error()
+		}
+	}
+	client, err := ethrpc.DialContext(ctx, EthereumRPC)
+	if err != nil {
+		fail(fmt.Errorf("failed to connect to Ethereum node: %w", err))
+		return
+	}
+	defer client.Close()
+
+	var batch []ethrpc.BatchElem
+	var batched []balanceLookup
+	for _, lookup := range lookups {
+		address := results[lookup.index].Address
+		if !common.IsHexAddress(address) {
+			results[lookup.index].Error = "invalid Ethereum address"
+			continue
+		}
+		if lookup.token.IsNative() {
+			batch = append(batch, ethrpc.BatchElem{
+				Method: "eth_getBalance",
+				Args:   []interface{}{common.HexToAddress(address), "latest"},
+				Result: new(hexutil.Big),
+			})
+		} else {
+			data, err := erc20.Pack("balanceOf", common.HexToAddress(address))
+			if err != nil {
+				results[lookup.index].Error = fmt.Sprintf("failed to encode balanceOf call: %v", err)
+				continue
+			}
+			call := map[string]interface{}{"to": common.HexToAddress(lookup.token.Address), "data": hexutil.Bytes(data)}
+			batch = append(batch, ethrpc.BatchElem{
+				Method: "eth_call",
+				Args:   []interface{}{call, "latest"},
+				Result: new(hexutil.Bytes),
+			})
+		}
+		batched = append(batched, lookup)
+	}
+	if len(batch) == 0 {
+		return
+	}
+	if err := client.BatchCallContext(ctx, batch); err != nil {
+		fail(fmt.Errorf("failed to get balances: %w", err))
+		return
+	}
+
+	for i, elem := range batch {
+		lookup := batched[i]
+		if elem.Error != nil {
+			results[lookup.index].Error = fmt.Sprintf("failed to get balance: %v", elem.Error)
+			continue
+		}
+		switch result := elem.Result.(type) {
+		case *hexutil.Big:
+			setBalance(results, lookup, result.ToInt())
+		case *hexutil.Bytes:
+			values, err := erc20.Unpack("balanceOf", *result)
+			if err != nil || len(values) != 1 {
+				results[lookup.index].Error = fmt.Sprintf("failed to decode balanceOf result: %v", err)
+				continue
+			}
+			balance, ok := values[0].(*big.Int)
+			if !ok {
+				results[lookup.index].Error = fmt.Sprintf("unexpected balanceOf result type %T", values[0])
+				continue
+			}
+			setBalance(results, lookup, balance)
+		}
+	}
+}
+
+// solanaBalances reads every balance with getMultipleAccounts: lamports of
+// the address for SOL, the amount in the associated token account for SPL
+// tokens. Accounts that do not exist hold nothing.
+func (c *Client) solanaBalances(ctx context.Context, lookups []balanceLookup, results []BalanceResult) {
+	var accounts []solana.PublicKey
+	var batched []balanceLookup
+	for _, lookup := range lookups {
+		owner, err := solana.PublicKeyFromBase58(results[lookup.index].Address)
+		if err != nil {
+			results[lookup.index].Error = fmt.Sprintf("invalid Solana address: %v", err)
+			continue
+		}
+		account := owner
+		if !lookup.token.IsNative() {
+			mint, err := solana.PublicKeyFromBase58(lookup.token.Address)
+			if err != nil {
+				results[lookup.index].Error = fmt.Sprintf("invalid mint address: %v", err)
+				continue
+			}
+			account, _, err = solana.FindAssociatedTokenAddress(owner, mint)
+			if err != nil {
+				results[lookup.index].Error = fmt.Sprintf("failed to derive token account: %v", err)
+				continue
+			}
+		}
+		accounts = append(accounts, account)
+		batched = append(batched, lookup)
+	}
+
+	client := rpc.New(SolanaRPC)
+	var wg sync.WaitGroup
+	for start := 0; start < len(accounts); start += solanaMaxAccounts {
+		end := start + solanaMaxAccounts
+		if end > len(accounts) {
+			end = len(accounts)
+		}
+		wg.Add(1)
+		go func(accounts []solana.PublicKey, lookups []balanceLookup) {
+			defer wg.Done()
+
+			out, err := client.GetMultipleAccountsWithOpts(ctx, accounts, &rpc.GetMultipleAccountsOpts{
+				Encoding:   solana.EncodingBase64,
+				Commitment: rpc.CommitmentFinalized,
+			})
+			if err == nil && len(out.Value) != len(accounts) {
+				err = fmt.Errorf("expected %d accounts, got %d", len(accounts), len(out.Value))
+			}
+			for i, lookup := range lookups {
+				if err != nil {
+					results[lookup.index].Error = fmt.Sprintf("failed to get balance: %v", err)
+					continue
+				}
+				account := out.Value[i]
+				switch {
+				case account == nil:
+					setBalance(results, lookup, new(big.Int))
+				case lookup.token.IsNative():
+					setBalance(results, lookup, new(big.Int).SetUint64(account.Lamports))
+				default:
+					data := account.Data.GetBinary()
+					if len(data) < splAmountOffset+8 {
+						results[lookup.index].Error = "invalid token account data"
+						continue
+					}
+					amount := binary.LittleEndian.Uint64(data[splAmountOffset:])
+					setBalance(results, lookup, new(big.Int).SetUint64(amount))
+				}
+			}
+		}(accounts[start:end], batched[start:end])
+	}
+	wg.Wait()
+}
+
+// bitcoinBalances sums the outputs paying each address in a single UTXO set
+// scan. Bitcoin Core runs one scan at a time, so concurrent calls wait their turn.
+func (c *Client) bitcoinBalances(lookups []balanceLookup, results []BalanceResult) {
+	var descriptors []interface{}
+	byScript := make(map[string][]balanceLookup)
+	for _, lookup := range lookups {
+		address := results[lookup.index].Address
+		decoded, err := decodeBitcoinAddress(address)
+		if err != nil {
+			results[lookup.index].Error = err.Error()
+			continue
+		}
+		script, err := txscript.PayToAddrScript(decoded)
+		if err != nil {
+			results[lookup.index].Error = fmt.Sprintf("failed to build output script: %v", err)
+			continue
+		}
+		key := hex.EncodeToString(script)
+		if _, ok := byScript[key]; !ok {
+			descriptors = append(descriptors, map[string]interface{}{"desc": "addr(" + address + ")"})
+		}
+		byScript[key] = append(byScript[key], lookup)
+	}
+	if len(descriptors) == 0 {
+		return
+	}
+
+	var scan struct {
+		Success  bool `json:"success"`
+		Unspents []struct {
+			ScriptPubKey string  `json:"scriptPubKey"`
+			Amount       float64 `json:"amount"`
+		} `json:"unspents"`
+	}
+	err := c.bitcoinScan(descriptors, &scan)
+	if err == nil && !scan.Success {
+		err = fmt.Errorf("UTXO set scan was aborted")
+	}
+	totals := make(map[string]int64)
+	for _, unspent := range scan.Unspents {
+		amount, amountErr := btcutil.NewAmount(unspent.Amount)
+		if amountErr != nil {
+			err = fmt.Errorf("invalid output amount: %w", amountErr)
+			break
+		}
+		totals[unspent.ScriptPubKey] += int64(amount)
+	}
+	for script, scripted := range byScript {
+		for _, lookup := range scripted {
+			if err != nil {
+				results[lookup.index].Error = fmt.Sprintf("failed to get balance: %v", err)
+				continue
+			}
+			setBalance(results, lookup, big.NewInt(totals[script]))
+		}
+	}
+}
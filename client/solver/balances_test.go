@@ -0,0 +1,146 @@
+package solver
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeRPCRequest struct {
+	ID     json.RawMessage   `json:"id"`
+	Method string            `json:"method"`
+	Params []json.RawMessage `json:"params"`
+}
+
+func TestGetBalances(t *testing.T) {
+	require.NoError(t, RegisterToken(Token{Chain: "solana", Symbol: "TEST-SPL", Address: "EPjFWdd5AufqSSqeM2qN1xzybapC8G4wEGGkZwyTDt1v", Decimals: 6}))
+
+	// An Ethereum node that only takes batches
+	var ethBatches int
+	ethServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var batch []fakeRPCRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&batch))
+		ethBatches++
+		var replies []interface{}
+		for _, req := range batch {
+			require.Equal(t, "eth_getBalance", req.Method)
+			replies = append(replies, map[string]interface{}{"jsonrpc": "2.0", "id": req.ID, "result": "0xde0b6b3a7640000"})
+		}
+		json.NewEncoder(w).Encode(replies)
+	}))
+	defer ethServer.Close()
+
+	// A Solana node holding 1.5 SOL in the first account, 2.5 of the token
+	// in the second and nothing in the third
+	var solanaCalls int
+	solanaServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req fakeRPCRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		require.Equal(t, "getMultipleAccounts", req.Method)
+		solanaCalls++
+		var accounts []string
+		require.NoError(t, json.Unmarshal(req.Params[0], &accounts))
+		require.Len(t, accounts, 3)
+
+		data := make([]byte, 165)
+		binary.LittleEndian.PutUint64(data[splAmountOffset:], 2_500_000)
+		account := func(lamports uint64, data []byte) map[string]interface{} {
+			return map[string]interface{}{
+				"lamports": lamports, "owner": "11111111111111111111111111111111", "executable": false, "rentEpoch": 0,
+				"data": []string{base64.StdEncoding.EncodeToString(data), "base64"},
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"jsonrpc": "2.0", "id": req.ID, "result": map[string]interface{}{
+			"context": map[string]interface{}{"slot": 1},
+			"value":   []interface{}{account(1_500_000_000, nil), account(2_039_280, data), nil},
+		}})
+	}))
+	defer solanaServer.Close()
+	InitRPCEndpoints(ethServer.URL, solanaServer.URL)
+
+	const ethAddress = "0x742d35Cc6634C0532925a3b844Bc454e4438f44e"
+	const solAddress = "3h1zGmCwsRJnVk5BuRNMLsPaQu1y2aqXqXDWYCgrp5UG"
+	results := NewClient("").GetBalances(context.Background(), []BalanceQuery{
+		{Chain: "ethereum", Address: ethAddress},
+		{Chain: "solana", Address: solAddress},
+		{Chain: "solana", Address: solAddress, Token: "TEST-SPL"},
+		{Chain: "ethereum", Address: "not-an-address"},
+		{Chain: "solana", Address: "11111111111111111111111111111111"},
+		{Chain: "dogecoin", Address: "D8xyz"},
+		{Chain: "ethereum", Address: ethAddress},
+	})
+	require.Len(t, results, 7)
+
+	// Each chain is looked up in one request
+	assert.Equal(t, 1, ethBatches)
+	assert.Equal(t, 1, solanaCalls)
+
+	assert.Equal(t, &Balance{Address: ethAddress, Amount: 1, Symbol: "ETH"}, results[0].Balance)
+	assert.Equal(t, &Balance{Address: solAddress, Amount: 1.5, Symbol: "SOL"}, results[1].Balance)
+	assert.Equal(t, &Balance{Address: solAddress, Amount: 2.5, Symbol: "TEST-SPL", Token: "EPjFWdd5AufqSSqeM2qN1xzybapC8G4wEGGkZwyTDt1v"}, results[2].Balance)
+	assert.Equal(t, "invalid Ethereum address", results[3].Error)
+	assert.Equal(t, 0.0, results[4].Balance.Amount)
+	assert.Nil(t, results[5].Balance)
+	assert.Contains(t, results[5].Error, "unsupported chain")
+	assert.Equal(t, ethAddress, results[6].Address)
+	assert.Equal(t, 1.0, results[6].Balance.Amount)
+}
+
+func TestBitcoinBalancesOneScanAtATime(t *testing.T) {
+	// A node that, like Bitcoin Core, refuses a scan while another runs
+	var scanning, scans int32
+	bitcoinServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req fakeRPCRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		require.Equal(t, "scantxoutset", req.Method)
+		if !atomic.CompareAndSwapInt32(&scanning, 0, 1) {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(map[string]interface{}{"id": req.ID, "error": map[string]interface{}{"code": -8, "message": "Scan already in progress"}})
+			return
+		}
+		defer atomic.StoreInt32(&scanning, 0)
+		atomic.AddInt32(&scans, 1)
+		time.Sleep(10 * time.Millisecond)
+		json.NewEncoder(w).Encode(map[string]interface{}{"id": req.ID, "result": map[string]interface{}{
+			"success": true, "height": 1, "unspents": []interface{}{},
+		}})
+	}))
+	defer bitcoinServer.Close()
+	require.NoError(t, InitBitcoin(bitcoinServer.URL, "", "", "regtest", 1))
+	defer func() { BitcoinRPC = "" }()
+
+	key, err := btcec.NewPrivateKey(btcec.S256())
+	require.NoError(t, err)
+	address, err := bitcoinKeyAddress(key)
+	require.NoError(t, err)
+
+	// Balance requests and deposit checks overlap without failing each other
+	client := NewClient("")
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			results := client.GetBalances(context.Background(), []BalanceQuery{{Chain: "bitcoin", Address: address.EncodeAddress()}})
+			assert.Empty(t, results[0].Error)
+		}()
+		go func() {
+			defer wg.Done()
+			_, _, err := client.bitcoinUnspents(address.EncodeAddress())
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(8), atomic.LoadInt32(&scans))
+}
@@ -0,0 +1,712 @@
+package solver
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcutil/psbt"
+)
+
+// Bitcoin node settings
+var (
+	BitcoinRPC         string
+	bitcoinRPCUser     string
+	bitcoinRPCPassword string
+	bitcoinParams      = &chaincfg.RegressionNetParams
+	// Deposits need this many confirmations before they are swapped
+	bitcoinMinConfirmations int64 = 1
+	// bitcoinScanMu serializes UTXO set scans, as Bitcoin Core aborts a scan
+	// started while another is running
+	bitcoinScanMu sync.Mutex
+)
+
+const (
+	// bitcoinFallbackFeeRate (sat/vB) is used when the node has no fee
+	// estimate yet, which is always the case on a fresh regtest chain
+	bitcoinFallbackFeeRate int64 = 2
+	// bitcoinFeeTarget is the confirmation target in blocks for fee estimation
+	bitcoinFeeTarget = 6
+	// bitcoinDustLimit is the smallest output the solver creates
+	bitcoinDustLimit int64 = 546
+	// bitcoinCoinbaseMaturity is how many blocks a coinbase output must wait before it can be spent
+	bitcoinCoinbaseMaturity = 100
+)
+
+// BitcoinNetParams returns the parameters of a supported network (regtest or testnet)
+func BitcoinNetParams(network string) (*chaincfg.Params, error) {
+	switch network {
+	case "regtest":
+		return &chaincfg.RegressionNetParams, nil
+	case "testnet":
+		return &chaincfg.TestNet3Params, nil
+	default:
+		return nil, fmt.Errorf("unsupported Bitcoin network: %s", network)
+	}
+}
+
+// InitBitcoin configures the bitcoind JSON-RPC endpoint and network
+func InitBitcoin(url, user, password, network string, minConfirmations int64) error {
+	params, err := BitcoinNetParams(network)
+	if err != nil {
+		return err
+	}
+	if minConfirmations < 1 {
+		return fmt.Errorf("minimum confirmations must be at least 1")
+	}
+
+	BitcoinRPC = url
+	bitcoinParams = params
+	bitcoinRPCUser = user
+	bitcoinRPCPassword = password
+	bitcoinMinConfirmations = minConfirmations
+	Logger.Printf("Initialized Bitcoin RPC endpoint: %s (%s)", url, network)
+	return nil
+}
+
+func bitcoinKeyAddress(key *btcec.PrivateKey) (*btcutil.AddressWitnessPubKeyHash, error) {
+	pubKey := key.PubKey().SerializeCompressed()
+	return btcutil.NewAddressWitnessPubKeyHash(btcutil.Hash160(pubKey), bitcoinParams)
+}
+
+// decodeBitcoinAddress parses an address and checks it belongs to the configured network
+func decodeBitcoinAddress(address string) (btcutil.Address, error) {
+	decoded, err := btcutil.DecodeAddress(address, bitcoinParams)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Bitcoin address: %w", err)
+	}
+	if !decoded.IsForNet(bitcoinParams) {
+		return nil, fmt.Errorf("bitcoin address %s is not for %s", address, bitcoinParams.Name)
+	}
+	return decoded, nil
+}
+
+// bitcoinCall makes a bitcoind JSON-RPC call and decodes its result into result
+func (c *Client) bitcoinCall(method string, params []interface{}, result interface{}) error {
+	if params == nil {
+		params = []interface{}{}
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"jsonrpc": "1.0",
+		"id":      "solver",
+		"method":  method,
+		"params":  params,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", BitcoinRPC, bytes.NewBuffer(body))
+	if err != nil {
+		return fmt.Errorf("error creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if bitcoinRPCUser != "" {
+		req.SetBasicAuth(bitcoinRPCUser, bitcoinRPCPassword)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("error sending request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		return fmt.Errorf("bitcoin RPC authentication failed")
+	}
+
+	// bitcoind reports RPC errors with a non-200 status and a JSON error body
+	var response struct {
+		Result json.RawMessage `json:"result"`
+		Error  *struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return fmt.Errorf("error parsing response (status %d): %w", resp.StatusCode, err)
+	}
+	if response.Error != nil {
+		return fmt.Errorf("bitcoin RPC error %d: %s", response.Error.Code, response.Error.Message)
+	}
+	if result != nil {
+		return json.Unmarshal(response.Result, result)
+	}
+	return nil
+}
+
+// GetBitcoinTransaction fetches a decoded transaction including the outputs
+// its inputs spend (getrawtransaction verbosity 2, requires -txindex)
+func (c *Client) GetBitcoinTransaction(txid string) (interface{}, error) {
+	var tx map[string]interface{}
+	if err := c.bitcoinCall("getrawtransaction", []interface{}{txid, 2}, &tx); err != nil {
+		return nil, fmt.Errorf("failed to get Bitcoin transaction: %w", err)
+	}
+	return tx, nil
+}
+
+// bitcoinScriptPubKey is the decoded form of an output script
+type bitcoinScriptPubKey struct {
+	Address string `json:"address"`
+}
+
+// bitcoinTransaction mirrors the parts of a getrawtransaction response needed to find deposits
+type bitcoinTransaction struct {
+	Txid          string `json:"txid"`
+	Confirmations int64  `json:"confirmations"`
+	Vin           []struct {
+		Prevout *struct {
+			ScriptPubKey bitcoinScriptPubKey `json:"scriptPubKey"`
+		} `json:"prevout"`
+	} `json:"vin"`
+	Vout []struct {
+		Value        float64             `json:"value"`
+		ScriptPubKey bitcoinScriptPubKey `json:"scriptPubKey"`
+	} `json:"vout"`
+}
+
+func decodeBitcoinTransaction(tx interface{}) (*bitcoinTransaction, error) {
+	// Re-encode and decode to get a typed view of the RPC response
+	jsonData, err := json.Marshal(tx)
+	if err != nil {
+		return nil, fmt.Errorf("error re-encoding transaction: %w", err)
+	}
+
+	var decoded bitcoinTransaction
+	if err := json.Unmarshal(jsonData, &decoded); err != nil {
+		return nil, fmt.Errorf("error parsing transaction: %w", err)
+	}
+	if decoded.Txid == "" {
+		return nil, fmt.Errorf("transaction not found")
+	}
+	return &decoded, nil
+}
+
+// sender returns the address of the output spent by the first input
+func (t *bitcoinTransaction) sender() string {
+	if len(t.Vin) == 0 || t.Vin[0].Prevout == nil {
+		return ""
+	}
+	return t.Vin[0].Prevout.ScriptPubKey.Address
+}
+
+// ParseBitcoinDeposit sums the outputs a getrawtransaction response pays to
+// poolAddress. The transaction must have at least minConfirmations.
+func ParseBitcoinDeposit(tx interface{}, poolAddress string, minConfirmations int64) (*Deposit, error) {
+	decoded, err := decodeBitcoinTransaction(tx)
+	if err != nil {
+		return nil, err
+	}
+	if decoded.Confirmations < minConfirmations {
+		return nil, fmt.Errorf("transaction has %d confirmations, %d required", decoded.Confirmations, minConfirmations)
+	}
+
+	deposit := &Deposit{
+		Chain:     "bitcoin",
+		TxHash:    decoded.Txid,
+		Sender:    decoded.sender(),
+		Recipient: poolAddress,
+		Amount:    new(big.Int),
+		Decimals:  8,
+		Symbol:    "BTC",
+	}
+	for _, out := range decoded.Vout {
+		if out.ScriptPubKey.Address != poolAddress {
+			continue
+		}
+		amount, err := btcutil.NewAmount(out.Value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid output amount: %w", err)
+		}
+		deposit.Amount.Add(deposit.Amount, big.NewInt(int64(amount)))
+	}
+
+	if deposit.Amount.Sign() == 0 {
+		return nil, fmt.Errorf("no deposit to the pool found in transaction")
+	}
+	return deposit, nil
+}
+
+// GetBitcoinDeposit returns the BTC deposit a getrawtransaction response made into the BTC pool
+func (c *Client) GetBitcoinDeposit(tx interface{}) (*Deposit, error) {
+	poolAddress, err := c.getPoolAddress("BTC")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pool address: %w", err)
+	}
+	return ParseBitcoinDeposit(tx, poolAddress, bitcoinMinConfirmations)
+}
+
+// bitcoinUTXO is an unspent output the pool can spend
+type bitcoinUTXO struct {
+	TxID          string
+	Vout          uint32
+	Amount        int64
+	Script        []byte
+	Confirmations int64
+}
+
+// bitcoinScan scans the UTXO set for the outputs matching descriptors,
+// waiting for any scan in progress
+func (c *Client) bitcoinScan(descriptors []interface{}, result interface{}) error {
+	bitcoinScanMu.Lock()
+	defer bitcoinScanMu.Unlock()
+	return c.bitcoinCall("scantxoutset", []interface{}{"start", descriptors}, result)
+}
+
+// bitcoinUnspents lists the confirmed outputs paying to address from the UTXO set
+func (c *Client) bitcoinUnspents(address string) ([]bitcoinUTXO, int64, error) {
+	var scan struct {
+		Success  bool  `json:"success"`
+		Height   int64 `json:"height"`
+		Unspents []struct {
+			Txid         string  `json:"txid"`
+			Vout         uint32  `json:"vout"`
+			ScriptPubKey string  `json:"scriptPubKey"`
+			Amount       float64 `json:"amount"`
+			Coinbase     bool    `json:"coinbase"`
+			Height       int64   `json:"height"`
+		} `json:"unspents"`
+		TotalAmount float64 `json:"total_amount"`
+	}
+	descriptor := map[string]interface{}{"desc": "addr(" + address + ")"}
+	if err := c.bitcoinScan([]interface{}{descriptor}, &scan); err != nil {
+		return nil, 0, fmt.Errorf("failed to scan UTXO set: %w", err)
+	}
+	if !scan.Success {
+		return nil, 0, fmt.Errorf("UTXO set scan was aborted")
+	}
+
+	var utxos []bitcoinUTXO
+	for _, unspent := range scan.Unspents {
+		// Mined rewards cannot be spent until they mature
+		if unspent.Coinbase && scan.Height-unspent.Height+1 < bitcoinCoinbaseMaturity {
+			continue
+		}
+		script, err := hex.DecodeString(unspent.ScriptPubKey)
+		if err != nil {
+			return nil, 0, fmt.Errorf("invalid output script: %w", err)
+		}
+		amount, err := btcutil.NewAmount(unspent.Amount)
+		if err != nil {
+			return nil, 0, fmt.Errorf("invalid output amount: %w", err)
+		}
+		utxos = append(utxos, bitcoinUTXO{
+			TxID:          unspent.Txid,
+			Vout:          unspent.Vout,
+			Amount:        int64(amount),
+			Script:        script,
+			Confirmations: scan.Height - unspent.Height + 1,
+		})
+	}
+
+	total, err := btcutil.NewAmount(scan.TotalAmount)
+	if err != nil {
+		return nil, 0, fmt.Errorf("invalid total amount: %w", err)
+	}
+	return utxos, int64(total), nil
+}
+
+// spendableBitcoinOutputs returns the pool's UTXOs that are not already spent
+// by a transaction waiting in the mempool, such as an earlier payout
+func (c *Client) spendableBitcoinOutputs(address string) ([]bitcoinUTXO, error) {
+	utxos, _, err := c.bitcoinUnspents(address)
+	if err != nil {
+		return nil, err
+	}
+	if len(utxos) == 0 {
+		return nil, nil
+	}
+
+	outpoints := make([]interface{}, len(utxos))
+	for i, utxo := range utxos {
+		outpoints[i] = map[string]interface{}{"txid": utxo.TxID, "vout": utxo.Vout}
+	}
+	var spending []struct {
+		SpendingTxid string `json:"spendingtxid"`
+	}
+	if err := c.bitcoinCall("gettxspendingprevout", []interface{}{outpoints}, &spending); err != nil {
+		return nil, fmt.Errorf("failed to check mempool spends: %w", err)
+	}
+
+	var spendable []bitcoinUTXO
+	for i, utxo := range utxos {
+		if i < len(spending) && spending[i].SpendingTxid != "" {
+			continue
+		}
+		spendable = append(spendable, utxo)
+	}
+	return spendable, nil
+}
+
+// bitcoinFeeRate returns the estimated fee rate in sat/vB
+func (c *Client) bitcoinFeeRate() (int64, error) {
+	var estimate struct {
+		FeeRate float64  `json:"feerate"` // BTC/kvB
+		Errors  []string `json:"errors"`
+	}
+	if err := c.bitcoinCall("estimatesmartfee", []interface{}{bitcoinFeeTarget}, &estimate); err != nil {
+		return 0, fmt.Errorf("failed to estimate fee: %w", err)
+	}
+	if estimate.FeeRate <= 0 {
+		return bitcoinFallbackFeeRate, nil
+	}
+	rate := int64(math.Ceil(estimate.FeeRate * btcutil.SatoshiPerBitcoin / 1000))
+	if rate < 1 {
+		rate = 1
+	}
+	return rate, nil
+}
+
+// estimateBitcoinVSize estimates the virtual size of a transaction spending
+// the given number of P2WPKH inputs to outputs with the given scripts
+func estimateBitcoinVSize(inputs int, outputScripts ...[]byte) int64 {
+	// Version, lock time, input and output counts
+	base := 4 + 4 + wire.VarIntSerializeSize(uint64(inputs)) + wire.VarIntSerializeSize(uint64(len(outputScripts)))
+	// Outpoint, empty signature script and sequence
+	base += inputs * (32 + 4 + 1 + 4)
+	for _, script := range outputScripts {
+		base += 8 + wire.VarIntSerializeSize(uint64(len(script))) + len(script)
+	}
+	// Segwit marker and flag, then per input the item count, a signature of
+	// at most 72 bytes and a compressed public key, each length prefixed
+	witness := 2 + inputs*(1+1+72+1+33)
+
+	weight := base*4 + witness
+	return int64((weight + 3) / 4)
+}
+
+// buildBitcoinPayout selects pool UTXOs (largest first) to pay amount to
+// destScript at feeRate sat/vB, returning any change to changeScript. It
+// returns the unsigned transaction, the outputs its inputs spend and the fee.
+func buildBitcoinPayout(utxos []bitcoinUTXO, changeScript, destScript []byte, amount, feeRate int64) (*wire.MsgTx, []*wire.TxOut, int64, error) {
+	if amount < bitcoinDustLimit {
+		return nil, nil, 0, fmt.Errorf("amount %d sat is below the dust limit", amount)
+	}
+
+	sorted := append([]bitcoinUTXO{}, utxos...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Amount > sorted[j].Amount })
+
+	tx := wire.NewMsgTx(2)
+	var prevOuts []*wire.TxOut
+	var total int64
+	for _, utxo := range sorted {
+		hash, err := chainhash.NewHashFromStr(utxo.TxID)
+		if err != nil {
+			return nil, nil, 0, fmt.Errorf("invalid UTXO txid %s: %w", utxo.TxID, err)
+		}
+		tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(hash, utxo.Vout), nil, nil))
+		prevOuts = append(prevOuts, wire.NewTxOut(utxo.Amount, utxo.Script))
+		total += utxo.Amount
+
+		inputs := len(tx.TxIn)
+		feeWithoutChange := estimateBitcoinVSize(inputs, destScript) * feeRate
+		if total < amount+feeWithoutChange {
+			continue
+		}
+
+		tx.AddTxOut(wire.NewTxOut(amount, destScript))
+		feeWithChange := estimateBitcoinVSize(inputs, destScript, changeScript) * feeRate
+		change := total - amount - feeWithChange
+		if change < bitcoinDustLimit {
+			// Change too small to be worth an output goes to the fee
+			return tx, prevOuts, total - amount, nil
+		}
+		tx.AddTxOut(wire.NewTxOut(change, changeScript))
+		return tx, prevOuts, feeWithChange, nil
+	}
+
+	return nil, nil, 0, fmt.Errorf("insufficient pool funds: have %d sat, need %d sat plus fees", total, amount)
+}
+
+// newPsbt wraps an unsigned transaction in a PSBT, attaching the output each
+// input spends so signers can check the amounts and the fee
+func newPsbt(tx *wire.MsgTx, prevOuts []*wire.TxOut) (*psbt.Packet, error) {
+	if len(prevOuts) != len(tx.TxIn) {
+		return nil, fmt.Errorf("expected %d previous outputs, got %d", len(tx.TxIn), len(prevOuts))
+	}
+	packet, err := psbt.NewFromUnsignedTx(tx)
+	if err != nil {
+		return nil, err
+	}
+	updater, err := psbt.NewUpdater(packet)
+	if err != nil {
+		return nil, err
+	}
+	for i, prevOut := range prevOuts {
+		if err := updater.AddInWitnessUtxo(prevOut, i); err != nil {
+			return nil, fmt.Errorf("failed to add the UTXO of input %d: %w", i, err)
+		}
+	}
+	return packet, nil
+}
+
+// decodePsbt parses a base64 encoded PSBT
+func decodePsbt(encoded string) (*psbt.Packet, error) {
+	packet, err := psbt.NewFromRawBytes(strings.NewReader(encoded), true)
+	if err != nil {
+		return nil, fmt.Errorf("invalid PSBT: %w", err)
+	}
+	return packet, nil
+}
+
+// signBitcoinPsbt signs every input of a PSBT spending P2WPKH outputs of key
+// and finalizes them
+func signBitcoinPsbt(packet *psbt.Packet, key *btcec.PrivateKey) error {
+	address, err := bitcoinKeyAddress(key)
+	if err != nil {
+		return err
+	}
+	script, err := txscript.PayToAddrScript(address)
+	if err != nil {
+		return err
+	}
+	updater, err := psbt.NewUpdater(packet)
+	if err != nil {
+		return err
+	}
+
+	sigHashes := txscript.NewTxSigHashes(packet.UnsignedTx)
+	pubKey := key.PubKey().SerializeCompressed()
+	for i, input := range packet.Inputs {
+		if input.WitnessUtxo == nil {
+			return fmt.Errorf("input %d has no witness UTXO", i)
+		}
+		if !bytes.Equal(input.WitnessUtxo.PkScript, script) {
+			return fmt.Errorf("input %d is not spendable by the solver key", i)
+		}
+
+		signature, err := txscript.RawTxInWitnessSignature(packet.UnsignedTx, sigHashes, i,
+			input.WitnessUtxo.Value, input.WitnessUtxo.PkScript, txscript.SigHashAll, key)
+		if err != nil {
+			return fmt.Errorf("failed to sign input %d: %w", i, err)
+		}
+		if _, err := updater.Sign(i, signature, pubKey, nil, nil); err != nil {
+			return fmt.Errorf("failed to add the signature of input %d: %w", i, err)
+		}
+		// A finalized input only carries its final witness
+		if err := psbt.Finalize(packet, i); err != nil {
+			return fmt.Errorf("failed to finalize input %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+func (c *Client) prepareBitcoinTransaction(swap *SwapResponse) error {
+	// Get pool address for the token
+	fromAddress, err := c.getPoolAddress(swap.SwapResult.ToToken)
+	if err != nil {
+		return fmt.Errorf("failed to get source pool address: %w", err)
+	}
+
+	pool, err := decodeBitcoinAddress(fromAddress)
+	if err != nil {
+		return fmt.Errorf("invalid pool address: %w", err)
+	}
+	destination, err := decodeBitcoinAddress(swap.DestinationAddress)
+	if err != nil {
+		return fmt.Errorf("invalid destination address: %w", err)
+	}
+	changeScript, err := txscript.PayToAddrScript(pool)
+	if err != nil {
+		return fmt.Errorf("failed to build change script: %w", err)
+	}
+	destScript, err := txscript.PayToAddrScript(destination)
+	if err != nil {
+		return fmt.Errorf("failed to build destination script: %w", err)
+	}
+
+	amount, err := btcutil.NewAmount(swap.SwapResult.ToAmount)
+	if err != nil {
+		return fmt.Errorf("invalid amount: %w", err)
+	}
+
+	utxos, err := c.spendableBitcoinOutputs(fromAddress)
+	if err != nil {
+		return fmt.Errorf("failed to get pool UTXOs: %w", err)
+	}
+	feeRate, err := c.bitcoinFeeRate()
+	if err != nil {
+		return err
+	}
+
+	tx, prevOuts, fee, err := buildBitcoinPayout(utxos, changeScript, destScript, int64(amount), feeRate)
+	if err != nil {
+		return err
+	}
+	packet, err := newPsbt(tx, prevOuts)
+	if err != nil {
+		return fmt.Errorf("failed to create PSBT: %w", err)
+	}
+	encoded, err := packet.B64Encode()
+	if err != nil {
+		return fmt.Errorf("failed to encode PSBT: %w", err)
+	}
+
+	// Prepare transaction parameters
+	swap.TxToSign = &TransactionPrep{
+		Chain: "bitcoin",
+		RawTx: "", // Will be filled by the signer
+		ChainParams: ChainParams{
+			FromAddress: fromAddress,
+			ToAddress:   swap.DestinationAddress,
+			Amount:      amount.Format(btcutil.AmountBTC),
+			Psbt:        encoded,
+			FeeRate:     feeRate,
+			Fee:         fee,
+		},
+	}
+	return nil
+}
+
+func (c *Client) signBitcoinTransaction(ctx context.Context, swap *SwapResponse) error {
+	signer, err := currentSigner()
+	if err != nil {
+		return err
+	}
+
+	// Sign with the derived account holding the pool funds
+	signed, err := signer.SignBitcoinPsbt(ctx, swap.TxToSign.ChainParams.FromAddress, swap.TxToSign.ChainParams.Psbt)
+	if err != nil {
+		return err
+	}
+	packet, err := decodePsbt(signed)
+	if err != nil {
+		return err
+	}
+	// The signer must not change what it was asked to sign
+	unsigned, err := decodePsbt(swap.TxToSign.ChainParams.Psbt)
+	if err != nil {
+		return err
+	}
+	if packet.UnsignedTx.TxHash() != unsigned.UnsignedTx.TxHash() {
+		return fmt.Errorf("signer returned a different transaction")
+	}
+
+	tx, err := psbt.Extract(packet)
+	if err != nil {
+		return fmt.Errorf("failed to extract signed transaction: %w", err)
+	}
+	var raw bytes.Buffer
+	if err := tx.Serialize(&raw); err != nil {
+		return fmt.Errorf("failed to serialize signed transaction: %w", err)
+	}
+
+	// Keep the finalized PSBT alongside the raw transaction
+	swap.TxToSign.ChainParams.Psbt = signed
+	swap.TxToSign.RawTx = hex.EncodeToString(raw.Bytes())
+	return nil
+}
+
+func (c *Client) submitBitcoinTransaction(swap *SwapResponse) error {
+	var txid string
+	if err := c.bitcoinCall("sendrawtransaction", []interface{}{swap.TxToSign.RawTx}, &txid); err != nil {
+		return fmt.Errorf("failed to submit transaction: %w", err)
+	}
+
+	// Update response with transaction id
+	swap.TxHash = txid
+	swap.Status = "submitted"
+	return nil
+}
+
+// GetBitcoinBalance sums the confirmed outputs paying to address
+func (c *Client) GetBitcoinBalance(address string) (*Balance, error) {
+	if _, err := decodeBitcoinAddress(address); err != nil {
+		return nil, err
+	}
+
+	_, total, err := c.bitcoinUnspents(address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get balance: %w", err)
+	}
+
+	return &Balance{
+		Address: address,
+		Amount:  btcutil.Amount(total).ToBTC(),
+		Symbol:  "BTC",
+	}, nil
+}
+
+// bitcoinMessageHash is the double SHA-256 digest signed by Bitcoin Core's
+// signmessage and wallets implementing BIP-137
+func bitcoinMessageHash(message []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := wire.WriteVarString(&buf, 0, "Bitcoin Signed Message:\n"); err != nil {
+		return nil, err
+	}
+	if err := wire.WriteVarBytes(&buf, 0, message); err != nil {
+		return nil, err
+	}
+	return chainhash.DoubleHashB(buf.Bytes()), nil
+}
+
+func verifyBitcoinSwapAuthorization(sender string, auth SwapAuthorization, signature string) error {
+	address, err := decodeBitcoinAddress(sender)
+	if err != nil {
+		return fmt.Errorf("invalid Bitcoin sender address: %w", err)
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return fmt.Errorf("invalid signature encoding: %w", err)
+	}
+	if len(sig) != 65 {
+		return fmt.Errorf("invalid signature length: %d", len(sig))
+	}
+
+	// BIP-137 headers 39-42 (P2WPKH) are compressed key recovery ids shifted
+	// by the address type. Only P2WPKH and P2PKH senders are supported, so
+	// P2SH-P2WPKH signatures (headers 35-38) are rejected.
+	switch {
+	case sig[0] >= 39 && sig[0] <= 42:
+		sig[0] -= 8
+	case sig[0] < 27 || sig[0] > 34:
+		return fmt.Errorf("unsupported signature header: %d", sig[0])
+	}
+
+	hash, err := bitcoinMessageHash(auth.Message())
+	if err != nil {
+		return err
+	}
+	pubKey, compressed, err := btcec.RecoverCompact(btcec.S256(), sig, hash)
+	if err != nil {
+		return fmt.Errorf("failed to recover signer: %w", err)
+	}
+	serialized := pubKey.SerializeUncompressed()
+	if compressed {
+		serialized = pubKey.SerializeCompressed()
+	}
+
+	var recovered btcutil.Address
+	switch address.(type) {
+	case *btcutil.AddressWitnessPubKeyHash:
+		recovered, err = btcutil.NewAddressWitnessPubKeyHash(btcutil.Hash160(serialized), bitcoinParams)
+	case *btcutil.AddressPubKeyHash:
+		recovered, err = btcutil.NewAddressPubKeyHash(btcutil.Hash160(serialized), bitcoinParams)
+	default:
+		return fmt.Errorf("unsupported Bitcoin sender address type: %s", sender)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to derive signer address: %w", err)
+	}
+
+	if recovered.EncodeAddress() != address.EncodeAddress() {
+		return fmt.Errorf("signature does not match deposit sender: recovered %s, expected %s", recovered.EncodeAddress(), sender)
+	}
+	return nil
+}
@@ -0,0 +1,172 @@
+package solver
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcutil"
+	"github.com/btcsuite/btcutil/psbt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBitcoinPsbtPayout(t *testing.T) {
+	key, err := btcec.NewPrivateKey(btcec.S256())
+	require.NoError(t, err)
+	pool, err := bitcoinKeyAddress(key)
+	require.NoError(t, err)
+	poolScript, err := txscript.PayToAddrScript(pool)
+	require.NoError(t, err)
+
+	destKey, err := btcec.NewPrivateKey(btcec.S256())
+	require.NoError(t, err)
+	dest, err := bitcoinKeyAddress(destKey)
+	require.NoError(t, err)
+	destScript, err := txscript.PayToAddrScript(dest)
+	require.NoError(t, err)
+
+	utxos := []bitcoinUTXO{
+		{TxID: "4a5e1e4baab89f3a32518a88c31bc87f618f76673e2cc77ab2127b7afdeda33b", Vout: 0, Amount: 30_000, Script: poolScript},
+		{TxID: "0e3e2357e806b6cdb1f70b54c3a3a17b6714ee1f0e68bebb44a74b1efd512098", Vout: 1, Amount: 80_000, Script: poolScript},
+	}
+
+	// The larger output alone covers the payout, the rest comes back as change
+	tx, prevOuts, fee, err := buildBitcoinPayout(utxos, poolScript, destScript, 50_000, 2)
+	require.NoError(t, err)
+	require.Len(t, tx.TxIn, 1)
+	require.Len(t, tx.TxOut, 2)
+	assert.Equal(t, int64(80_000), prevOuts[0].Value)
+	assert.Equal(t, int64(50_000), tx.TxOut[0].Value)
+	assert.Equal(t, 80_000-50_000-fee, tx.TxOut[1].Value)
+	assert.Equal(t, estimateBitcoinVSize(1, destScript, poolScript)*2, fee)
+
+	packet, err := newPsbt(tx, prevOuts)
+	require.NoError(t, err)
+	encoded, err := packet.B64Encode()
+	require.NoError(t, err)
+
+	// The signing policy sees the payout and the fee, not the change
+	transfers, policyFee, err := bitcoinTransfers(pool.EncodeAddress(), encoded)
+	require.NoError(t, err)
+	require.Len(t, transfers, 1)
+	assert.Equal(t, dest.EncodeAddress(), transfers[0].Recipient)
+	assert.Equal(t, int64(50_000), transfers[0].Amount.Int64())
+	assert.Equal(t, fee, policyFee.Int64())
+
+	decoded, err := decodePsbt(encoded)
+	require.NoError(t, err)
+	require.NoError(t, signBitcoinPsbt(decoded, key))
+
+	// A PSBT signed by another key must be rejected
+	assert.Error(t, signBitcoinPsbt(packet, destKey))
+
+	// Round trip the finalized PSBT as the signer does
+	finalized, err := decoded.B64Encode()
+	require.NoError(t, err)
+	decoded, err = decodePsbt(finalized)
+	require.NoError(t, err)
+
+	signed, err := psbt.Extract(decoded)
+	require.NoError(t, err)
+	weight := signed.SerializeSizeStripped()*3 + signed.SerializeSize()
+	assert.LessOrEqual(t, int64((weight+3)/4), estimateBitcoinVSize(1, destScript, poolScript))
+
+	// Every input must satisfy the script it spends
+	sigHashes := txscript.NewTxSigHashes(signed)
+	for i := range signed.TxIn {
+		engine, err := txscript.NewEngine(prevOuts[i].PkScript, signed, i,
+			txscript.StandardVerifyFlags, nil, sigHashes, prevOuts[i].Value)
+		require.NoError(t, err)
+		assert.NoError(t, engine.Execute())
+	}
+
+	// Inputs without their witness UTXO hide the fee
+	packet.Inputs[0].WitnessUtxo = nil
+	encoded, err = packet.B64Encode()
+	require.NoError(t, err)
+	_, _, err = bitcoinTransfers(pool.EncodeAddress(), encoded)
+	assertPolicyDenied(t, err, PolicyRuleUnsupported)
+
+	// Not enough funds for the amount plus fees
+	_, _, _, err = buildBitcoinPayout(utxos, poolScript, destScript, 110_000, 2)
+	assert.Error(t, err)
+}
+
+// TestBitcoinRegtestPayout runs against a local bitcoind, e.g.
+// bitcoind -regtest -txindex -rpcuser=solver -rpcpassword=solver
+// BITCOIN_RPC_URL=http://localhost:18443 BITCOIN_RPC_USER=solver BITCOIN_RPC_PASSWORD=solver go test ./solver/
+func TestBitcoinRegtestPayout(t *testing.T) {
+	url := os.Getenv("BITCOIN_RPC_URL")
+	if url == "" {
+		t.Skip("BITCOIN_RPC_URL not set")
+	}
+	require.NoError(t, InitBitcoin(url, os.Getenv("BITCOIN_RPC_USER"), os.Getenv("BITCOIN_RPC_PASSWORD"), "regtest", 1))
+	client := NewClient("")
+
+	// Sign through the same path as swap payouts, with the derived pool account
+	previousKeys, previousSigner := chainKeys, signer
+	defer func() { chainKeys, signer = previousKeys, previousSigner }()
+	require.NoError(t, InitKeys(testMnemonic))
+
+	pool, err := bitcoinKeyAddress(chainKeys.BitcoinKey)
+	require.NoError(t, err)
+	poolScript, err := txscript.PayToAddrScript(pool)
+	require.NoError(t, err)
+
+	destKey, err := btcec.NewPrivateKey(btcec.S256())
+	require.NoError(t, err)
+	dest, err := bitcoinKeyAddress(destKey)
+	require.NoError(t, err)
+	destScript, err := txscript.PayToAddrScript(dest)
+	require.NoError(t, err)
+
+	// Fund the pool with a block reward and let it mature
+	require.NoError(t, client.bitcoinCall("generatetoaddress", []interface{}{101, pool.EncodeAddress()}, nil))
+
+	utxos, err := client.spendableBitcoinOutputs(pool.EncodeAddress())
+	require.NoError(t, err)
+	require.NotEmpty(t, utxos)
+	feeRate, err := client.bitcoinFeeRate()
+	require.NoError(t, err)
+
+	amount, err := btcutil.NewAmount(0.5)
+	require.NoError(t, err)
+	tx, prevOuts, _, err := buildBitcoinPayout(utxos, poolScript, destScript, int64(amount), feeRate)
+	require.NoError(t, err)
+	packet, err := newPsbt(tx, prevOuts)
+	require.NoError(t, err)
+	encoded, err := packet.B64Encode()
+	require.NoError(t, err)
+
+	swap := &SwapResponse{TxToSign: &TransactionPrep{
+		Chain:       "bitcoin",
+		ChainParams: ChainParams{FromAddress: pool.EncodeAddress(), Psbt: encoded},
+	}}
+	require.NoError(t, client.SignTransaction(WithSigningRecord(context.Background(), SigningRecord{
+		Kind: SigningRecordSwap, Reference: "regtest", Chain: "bitcoin", Recipient: dest.EncodeAddress(), Asset: "BTC",
+	}), swap))
+	require.NoError(t, client.SubmitTransaction(swap))
+	txid := swap.TxHash
+	assert.Equal(t, tx.TxHash().String(), txid)
+
+	// Outputs spent by the pending payout are not offered again
+	spendable, err := client.spendableBitcoinOutputs(pool.EncodeAddress())
+	require.NoError(t, err)
+	assert.Len(t, spendable, len(utxos)-len(prevOuts))
+
+	// Once mined the payout is a deposit to the destination
+	require.NoError(t, client.bitcoinCall("generatetoaddress", []interface{}{1, pool.EncodeAddress()}, nil))
+	confirmed, err := client.GetBitcoinTransaction(txid)
+	require.NoError(t, err)
+	deposit, err := ParseBitcoinDeposit(confirmed, dest.EncodeAddress(), 1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(amount), deposit.Amount.Int64())
+	assert.Equal(t, pool.EncodeAddress(), deposit.Sender)
+
+	balance, err := client.GetBitcoinBalance(dest.EncodeAddress())
+	require.NoError(t, err)
+	assert.Equal(t, 0.5, balance.Amount)
+}
@@ -0,0 +1,318 @@
+package solver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// wasmTarget is the target Linera applications are built for
+const wasmTarget = "wasm32-unknown-unknown"
+
+// defaultBuildTimeout bounds a cargo build when no timeout is configured
+const defaultBuildTimeout = 20 * time.Minute
+
+// buildEnv is the environment builds inherit. Anything else, such as keys
+// or tokens passed to the client, is kept from the repository's build scripts.
+var buildEnv = []string{"PATH", "HOME", "CARGO_HOME", "RUSTUP_HOME"}
+
+// containerEnv is the environment the container runtime inherits, to reach
+// its daemon. The container itself only gets the variables set for the build.
+var containerEnv = []string{"PATH", "HOME", "DOCKER_HOST", "DOCKER_CONFIG", "XDG_RUNTIME_DIR"}
+
+// ErrBuildsDisabled is returned for builds when they are not enabled, as a
+// build runs the repository's build scripts on the server
+var ErrBuildsDisabled = errors.New("building from repositories is disabled")
+
+// InvalidBuildSourceError is returned when a build is submitted for a
+// source that cannot be built
+type InvalidBuildSourceError struct {
+	Err error
+}
+
+func (e *InvalidBuildSourceError) Error() string {
+	return "invalid build source: " + e.Err.Error()
+}
+
+func (e *InvalidBuildSourceError) Unwrap() error {
+	return e.Err
+}
+
+// BuildConfig says how applications are built from source
+type BuildConfig struct {
+	// Enabled allows builds at all
+	Enabled bool
+	// Cargo is the path of the cargo executable (default "cargo")
+	Cargo string
+	// Timeout bounds each build (default 20m)
+	Timeout time.Duration
+	// LocalRoot is the directory local checkouts are taken from, none when empty
+	LocalRoot string
+	// Container is the image builds run in, so build scripts cannot reach the
+	// host. Without it cargo runs on the host as the client's user.
+	Container string
+	// ContainerRuntime is the docker-compatible CLI running Container
+	// (default "docker")
+	ContainerRuntime string
+}
+
+// buildConfig is how deployments build applications
+var buildConfig = BuildConfig{}
+
+// InitBuild configures building applications from source
+func InitBuild(config BuildConfig) {
+	if config.Cargo == "" {
+		config.Cargo = "cargo"
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = defaultBuildTimeout
+	}
+	if config.ContainerRuntime == "" {
+		config.ContainerRuntime = "docker"
+	}
+	buildConfig = config
+}
+
+// BuildSource is where an application is built from: a GitHub repository,
+// or a checkout under the configured local root for offline use
+type BuildSource struct {
+	Owner string `json:"owner,omitempty"`
+	Repo  string `json:"repo,omitempty"`
+	// Ref is the branch, tag or commit of the repository, its default branch
+	// when empty
+	Ref string `json:"ref,omitempty"`
+	// LocalPath is relative to the local root
+	LocalPath string `json:"localPath,omitempty"`
+	// Dir is the directory of the crate or workspace within the repository
+	Dir string `json:"dir,omitempty"`
+	// Package is built alone with cargo -p
+	Package string `json:"package,omitempty"`
+	// Contract and Service name the built WASM files, by default the only
+	// *_contract.wasm and *_service.wasm (or those of Package)
+	Contract string `json:"contract,omitempty"`
+	Service  string `json:"service,omitempty"`
+}
+
+// Validate checks the source names one repository and stays inside it
+func (s BuildSource) Validate() error {
+	github := s.Owner != "" || s.Repo != ""
+	if github == (s.LocalPath != "") {
+		return fmt.Errorf("either owner and repo or localPath is required")
+	}
+	if github && (s.Owner == "" || s.Repo == "") {
+		return fmt.Errorf("owner and repo are required")
+	}
+	if s.LocalPath != "" {
+		if buildConfig.LocalRoot == "" {
+			return fmt.Errorf("local checkouts are disabled")
+		}
+		if _, err := relativePath(s.LocalPath); err != nil {
+			return fmt.Errorf("invalid localPath: %w", err)
+		}
+	}
+	if s.Dir != "" {
+		if _, err := relativePath(s.Dir); err != nil {
+			return fmt.Errorf("invalid dir: %w", err)
+		}
+	}
+	for _, name := range []string{s.Contract, s.Service} {
+		if name != "" && (filepath.Base(name) != name || !strings.HasSuffix(name, ".wasm")) {
+			return fmt.Errorf("invalid artifact name %q", name)
+		}
+	}
+	return nil
+}
+
+// relativePath cleans a slash-separated path that must stay below its root
+func relativePath(path string) (string, error) {
+	cleaned := filepath.Clean(filepath.FromSlash(path))
+	if filepath.IsAbs(cleaned) || cleaned == ".." || strings.HasPrefix(cleaned, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%q leaves its root", path)
+	}
+	return cleaned, nil
+}
+
+// buildApplication materializes source under workDir, builds it and returns
+// the contract and service WASM. Build output is passed to log line by line.
+func buildApplication(ctx context.Context, github *GithubAuthConfig, source BuildSource, token, workDir string, log func(string)) (string, string, error) {
+	if !buildConfig.Enabled {
+		return "", "", ErrBuildsDisabled
+	}
+
+	root, err := materializeSource(ctx, github, source, token, workDir, log)
+	if err != nil {
+		return "", "", err
+	}
+	crateDir := root
+	if source.Dir != "" {
+		dir, _ := relativePath(source.Dir)
+		crateDir = filepath.Join(root, dir)
+	}
+
+	// Build outside the checkout, so local checkouts are left untouched
+	targetDir := filepath.Join(workDir, "target")
+	args := []string{"build", "--release", "--target", wasmTarget}
+	if source.Package != "" {
+		args = append(args, "-p", source.Package)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, buildConfig.Timeout)
+	defer cancel()
+	cmd := buildCommand(ctx, root, crateDir, workDir, targetDir, args)
+	log(fmt.Sprintf("Running %s in %s", strings.Join(cmd.Args, " "), crateDir))
+	output := &lineWriter{log: log}
+	cmd.Stdout = output
+	cmd.Stderr = output
+	cmd.WaitDelay = time.Second
+	err = cmd.Run()
+	output.Flush()
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return "", "", fmt.Errorf("build timed out after %s", buildConfig.Timeout)
+	}
+	if err != nil {
+		return "", "", fmt.Errorf("build failed: %w", err)
+	}
+
+	return findArtifacts(filepath.Join(targetDir, wasmTarget, "release"), source)
+}
+
+// buildCommand runs cargo with args in crateDir, on the host with only the
+// buildEnv variables, or in the configured container with root and workDir
+// mounted at the same paths
+func buildCommand(ctx context.Context, root, crateDir, workDir, targetDir string, args []string) *exec.Cmd {
+	if buildConfig.Container == "" {
+		cmd := exec.CommandContext(ctx, buildConfig.Cargo, args...)
+		cmd.Dir = crateDir
+		cmd.Env = append(inheritedEnv(buildEnv), "CARGO_TARGET_DIR="+targetDir)
+		return cmd
+	}
+
+	run := []string{"run", "--rm",
+		"-v", workDir + ":" + workDir,
+		"-w", crateDir,
+		"-e", "HOME=" + workDir,
+		"-e", "CARGO_HOME=" + filepath.Join(workDir, "cargo"),
+		"-e", "CARGO_TARGET_DIR=" + targetDir,
+	}
+	// A local checkout is outside the workspace
+	if !strings.HasPrefix(root, workDir+string(filepath.Separator)) {
+		run = append(run, "-v", root+":"+root)
+	}
+	// Build output stays owned by the client's user, so it can be removed
+	if uid, gid := os.Getuid(), os.Getgid(); uid >= 0 {
+		run = append(run, "--user", fmt.Sprintf("%d:%d", uid, gid))
+	}
+	run = append(run, buildConfig.Container, "cargo")
+	cmd := exec.CommandContext(ctx, buildConfig.ContainerRuntime, append(run, args...)...)
+	cmd.Env = inheritedEnv(containerEnv)
+	return cmd
+}
+
+// inheritedEnv returns the named variables of the client's environment that are set
+func inheritedEnv(names []string) []string {
+	var env []string
+	for _, name := range names {
+		if value, ok := os.LookupEnv(name); ok {
+			env = append(env, name+"="+value)
+		}
+	}
+	return env
+}
+
+// materializeSource returns the directory holding the source: the local
+// checkout, or a snapshot of the GitHub repository written under workDir
+func materializeSource(ctx context.Context, github *GithubAuthConfig, source BuildSource, token, workDir string, log func(string)) (string, error) {
+	if source.LocalPath != "" {
+		path, _ := relativePath(source.LocalPath)
+		root := filepath.Join(buildConfig.LocalRoot, path)
+		if info, err := os.Stat(root); err != nil || !info.IsDir() {
+			return "", fmt.Errorf("local checkout %s not found", source.LocalPath)
+		}
+		log(fmt.Sprintf("Building local checkout %s", source.LocalPath))
+		return root, nil
+	}
+
+	if token == "" {
+		return "", fmt.Errorf("GitHub token is no longer available, submit the build again")
+	}
+	if source.Ref != "" {
+		log(fmt.Sprintf("Fetching %s/%s at %s", source.Owner, source.Repo, source.Ref))
+	} else {
+		log(fmt.Sprintf("Fetching %s/%s", source.Owner, source.Repo))
+	}
+	files, err := github.FetchRepoFilesRecursively(ctx, token, source.Owner, source.Repo, RepoFetchOptions{Ref: source.Ref})
+	if err != nil {
+		return "", err
+	}
+
+	root := filepath.Join(workDir, "src")
+	for _, file := range files {
+		// Symlinks could point outside the workspace
+		if file.Type != "file" {
+			continue
+		}
+		path, err := relativePath(file.Path)
+		if err != nil {
+			return "", fmt.Errorf("invalid repository file: %w", err)
+		}
+		target := filepath.Join(root, path)
+		if err := os.MkdirAll(filepath.Dir(target), 0700); err != nil {
+			return "", fmt.Errorf("failed to write %s: %w", file.Path, err)
+		}
+		content, err := file.Bytes()
+		if err != nil {
+			return "", fmt.Errorf("invalid content of %s: %w", file.Path, err)
+		}
+		if err := os.WriteFile(target, content, 0600); err != nil {
+			return "", fmt.Errorf("failed to write %s: %w", file.Path, err)
+		}
+	}
+	log(fmt.Sprintf("Fetched %d files", len(files)))
+	return root, ctx.Err()
+}
+
+// findArtifacts picks the contract and service WASM among the build outputs
+func findArtifacts(dir string, source BuildSource) (string, string, error) {
+	pick := func(name, suffix string) (string, error) {
+		if name == "" && source.Package != "" {
+			name = strings.ReplaceAll(source.Package, "-", "_") + suffix
+		}
+		if name != "" {
+			path := filepath.Join(dir, name)
+			if _, err := os.Stat(path); err != nil {
+				return "", fmt.Errorf("build produced no %s", name)
+			}
+			return path, nil
+		}
+		matches, err := filepath.Glob(filepath.Join(dir, "*"+suffix))
+		if err != nil {
+			return "", err
+		}
+		switch len(matches) {
+		case 0:
+			return "", fmt.Errorf("build produced no *%s", suffix)
+		case 1:
+			return matches[0], nil
+		default:
+			for i := range matches {
+				matches[i] = filepath.Base(matches[i])
+			}
+			return "", fmt.Errorf("build produced several *%s (%s), name one", suffix, strings.Join(matches, ", "))
+		}
+	}
+
+	contract, err := pick(source.Contract, "_contract.wasm")
+	if err != nil {
+		return "", "", err
+	}
+	service, err := pick(source.Service, "_service.wasm")
+	if err != nil {
+		return "", "", err
+	}
+	return contract, service, nil
+}
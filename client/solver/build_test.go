@@ -0,0 +1,126 @@
+package solver
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildFromLocalCheckout(t *testing.T) {
+	bytecodeID := strings.Repeat("cd", 64)
+
+	previous, previousBuild := lineraCLI, buildConfig
+	defer func() { lineraCLI, buildConfig = previous, previousBuild }()
+	lineraCLI = NewLineraCLI(LineraConfig{Binary: fakeLinera(t, `echo `+bytecodeID)})
+
+	// A cargo stand-in writing the smallest WASM modules for both binaries,
+	// which must not see the client's secrets
+	t.Setenv("SOLVER_TEST_SECRET", "mnemonic")
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "examples", "counter"), 0700))
+	InitBuild(BuildConfig{Enabled: true, LocalRoot: root, Cargo: fakeLinera(t, `
+test -z "$SOLVER_TEST_SECRET" || { echo "secret leaked to build" >&2; exit 1; }
+echo "Compiling counter v0.1.0 ($(basename $(pwd)))" >&2
+out="$CARGO_TARGET_DIR/wasm32-unknown-unknown/release"
+mkdir -p "$out"
+printf '\000asm\001\000\000\000\012\001\000' > "$out/counter_contract.wasm"
+printf '\000asm\001\000\000\000\012\001\000' > "$out/counter_service.wasm"`)})
+
+	manager, err := NewDeploymentManager(filepath.Join(t.TempDir(), "deployments"))
+	require.NoError(t, err)
+	job, err := manager.SubmitBuild(BuildSource{LocalPath: "examples", Dir: "counter"}, "", true, false, CreateApplicationOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, "examples", job.Build.LocalPath)
+
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+	go manager.Run(ctx)
+	require.Eventually(t, func() bool {
+		job, _ := manager.Get(job.ID)
+		return job.Finished()
+	}, 5*time.Second, 10*time.Millisecond)
+
+	done, _ := manager.Get(job.ID)
+	require.Equal(t, DeploymentDone, done.Phase, done.Error)
+	assert.Equal(t, BytecodeID(bytecodeID), done.BytecodeID)
+	assert.Equal(t, int64(11), done.ContractSize)
+	var logs []string
+	for _, entry := range done.Logs {
+		logs = append(logs, entry.Message)
+	}
+	assert.Contains(t, logs, "Building application")
+	assert.Contains(t, logs, "Compiling counter v0.1.0 (counter)")
+
+	// Sources outside the local root, or naming two repositories, are refused
+	for _, source := range []BuildSource{
+		{LocalPath: "../elsewhere"},
+		{LocalPath: "examples", Dir: "/etc"},
+		{LocalPath: "examples", Owner: "linera-io", Repo: "linera-protocol"},
+		{Owner: "linera-io"},
+		{LocalPath: "examples", Contract: "../contract.wasm"},
+	} {
+		_, err := manager.SubmitBuild(source, "", false, false, CreateApplicationOptions{})
+		var invalid *InvalidBuildSourceError
+		assert.True(t, errors.As(err, &invalid), "%+v: %v", source, err)
+	}
+
+	InitBuild(BuildConfig{})
+	_, err = manager.SubmitBuild(BuildSource{LocalPath: "examples"}, "", false, false, CreateApplicationOptions{})
+	assert.ErrorIs(t, err, ErrBuildsDisabled)
+}
+
+func TestBuildCommand(t *testing.T) {
+	previous := buildConfig
+	defer func() { buildConfig = previous }()
+	t.Setenv("SOLVER_TEST_SECRET", "mnemonic")
+	t.Setenv("DOCKER_HOST", "unix:///run/docker.sock")
+	args := []string{"build", "--release"}
+
+	// On the host cargo only gets the toolchain variables
+	InitBuild(BuildConfig{Enabled: true})
+	cmd := buildCommand(context.Background(), "/work/src", "/work/src/app", "/work", "/work/target", args)
+	assert.Equal(t, []string{"cargo", "build", "--release"}, cmd.Args)
+	assert.Equal(t, "/work/src/app", cmd.Dir)
+	assert.Contains(t, cmd.Env, "CARGO_TARGET_DIR=/work/target")
+	assert.Contains(t, cmd.Env, "PATH="+os.Getenv("PATH"))
+	assert.NotContains(t, cmd.Env, "SOLVER_TEST_SECRET=mnemonic")
+	assert.NotContains(t, cmd.Env, "DOCKER_HOST=unix:///run/docker.sock")
+
+	// In a container the workspace and a local checkout are mounted in place
+	InitBuild(BuildConfig{Enabled: true, Container: "rust:1.80", ContainerRuntime: "podman"})
+	cmd = buildCommand(context.Background(), "/checkouts/app", "/checkouts/app", "/work", "/work/target", args)
+	assert.Equal(t, "podman", cmd.Args[0])
+	joined := strings.Join(cmd.Args, " ")
+	assert.Contains(t, joined, "-v /work:/work -w /checkouts/app")
+	assert.Contains(t, joined, "-e CARGO_TARGET_DIR=/work/target")
+	assert.Contains(t, joined, "-v /checkouts/app:/checkouts/app")
+	assert.True(t, strings.HasSuffix(joined, "rust:1.80 cargo build --release"), joined)
+	assert.Contains(t, cmd.Env, "DOCKER_HOST=unix:///run/docker.sock")
+	assert.NotContains(t, cmd.Env, "SOLVER_TEST_SECRET=mnemonic")
+	assert.NotContains(t, joined, "mnemonic")
+}
+
+func TestFindArtifacts(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"counter_contract.wasm", "counter_service.wasm", "fungible_contract.wasm", "fungible_service.wasm"} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), nil, 0600))
+	}
+
+	_, _, err := findArtifacts(dir, BuildSource{})
+	assert.ErrorContains(t, err, "several *_contract.wasm (counter_contract.wasm, fungible_contract.wasm)")
+
+	contract, service, err := findArtifacts(dir, BuildSource{Package: "fungible"})
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "fungible_contract.wasm"), contract)
+	assert.Equal(t, filepath.Join(dir, "fungible_service.wasm"), service)
+
+	_, _, err = findArtifacts(dir, BuildSource{Contract: "counter_contract.wasm", Service: "missing_service.wasm"})
+	assert.ErrorContains(t, err, "build produced no missing_service.wasm")
+}
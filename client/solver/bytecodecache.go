@@ -0,0 +1,156 @@
+package solver
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// BytecodeCacheEntry is bytecode published from a contract and service pair
+type BytecodeCacheEntry struct {
+	Network        string     `json:"network"`
+	ContractSHA256 string     `json:"contractSha256"`
+	ServiceSHA256  string     `json:"serviceSha256"`
+	BytecodeID     BytecodeID `json:"bytecodeId"`
+	PublishedAt    time.Time  `json:"publishedAt"`
+}
+
+// BytecodeCache maps the digests of published WASM pairs to their bytecode
+// ID on each network, persisted to a JSON file
+type BytecodeCache struct {
+	mu      sync.Mutex
+	path    string
+	entries map[string]*BytecodeCacheEntry
+}
+
+// NewBytecodeCache loads the cache saved at path. An empty path keeps it in
+// memory only.
+func NewBytecodeCache(path string) (*BytecodeCache, error) {
+	c := &BytecodeCache{path: path, entries: make(map[string]*BytecodeCacheEntry)}
+	if err := readJSONFile(path, &c.entries); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// bytecodeCacheKey identifies a WASM pair on a network
+func bytecodeCacheKey(network, contractSHA256, serviceSHA256 string) string {
+	return network + "/" + contractSHA256 + "/" + serviceSHA256
+}
+
+// Lookup returns the bytecode published from the pair on network
+func (c *BytecodeCache) Lookup(network, contractSHA256, serviceSHA256 string) (BytecodeID, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[bytecodeCacheKey(network, contractSHA256, serviceSHA256)]
+	if !ok {
+		return "", false
+	}
+	return entry.BytecodeID, true
+}
+
+// Store records the bytecode published from the pair on network
+func (c *BytecodeCache) Store(network, contractSHA256, serviceSHA256 string, bytecodeID BytecodeID) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := bytecodeCacheKey(network, contractSHA256, serviceSHA256)
+	previous, existed := c.entries[key]
+	c.entries[key] = &BytecodeCacheEntry{
+		Network:        network,
+		ContractSHA256: contractSHA256,
+		ServiceSHA256:  serviceSHA256,
+		BytecodeID:     bytecodeID,
+		PublishedAt:    time.Now().UTC(),
+	}
+	if err := writeJSONFile(c.path, c.entries); err != nil {
+		if existed {
+			c.entries[key] = previous
+		} else {
+			delete(c.entries, key)
+		}
+		return err
+	}
+	return nil
+}
+
+// bytecodeCache skips publishing bytecode that was published before, nil
+// to always publish
+var bytecodeCache *BytecodeCache
+
+// InitBytecodeCache loads the bytecode cache saved at path
+func InitBytecodeCache(path string) error {
+	cache, err := NewBytecodeCache(path)
+	if err != nil {
+		return err
+	}
+	bytecodeCache = cache
+	return nil
+}
+
+// PublishResult is bytecode published, or found in the cache
+type PublishResult struct {
+	BytecodeID     BytecodeID `json:"bytecodeId"`
+	ContractSHA256 string     `json:"contractSha256"`
+	ServiceSHA256  string     `json:"serviceSha256"`
+	// Cached is set when the pair was published before and was not again
+	Cached bool `json:"cached"`
+}
+
+// publishBytecode publishes the contract and service files unless the cache
+// knows them on the configured network. force publishes them again.
+func publishBytecode(ctx context.Context, contractPath, servicePath string, force bool) (*PublishResult, error) {
+	contractSHA256, err := fileSHA256(contractPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash contract: %w", err)
+	}
+	serviceSHA256, err := fileSHA256(servicePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash service: %w", err)
+	}
+	result := &PublishResult{ContractSHA256: contractSHA256, ServiceSHA256: serviceSHA256}
+
+	cli := lineraCLI
+	network := cli.config.Network
+	if bytecodeCache != nil && !force {
+		if bytecodeID, ok := bytecodeCache.Lookup(network, contractSHA256, serviceSHA256); ok {
+			Logger.Printf("Bytecode already published on %s as %s", network, bytecodeID)
+			result.BytecodeID = bytecodeID
+			result.Cached = true
+			return result, nil
+		}
+	}
+
+	result.BytecodeID, err = cli.PublishBytecode(ctx, contractPath, servicePath)
+	if err != nil {
+		return nil, err
+	}
+	if bytecodeCache != nil {
+		if err := bytecodeCache.Store(network, contractSHA256, serviceSHA256, result.BytecodeID); err != nil {
+			// The bytecode is published, it is only published again next time
+			Logger.Printf("Failed to cache bytecode %s: %v", result.BytecodeID, err)
+		}
+	}
+	return result, nil
+}
+
+// fileSHA256 returns the hex SHA-256 digest of the file at path
+func fileSHA256(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,69 @@
+package solver
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPublishBytecodeCache(t *testing.T) {
+	dir := t.TempDir()
+	bytecodeID := strings.Repeat("ab", 64)
+
+	// A linera stand-in counting the times it publishes
+	countPath := filepath.Join(dir, "count")
+	previousCLI, previousCache := lineraCLI, bytecodeCache
+	defer func() { lineraCLI, bytecodeCache = previousCLI, previousCache }()
+	lineraCLI = NewLineraCLI(LineraConfig{Binary: fakeLinera(t, "echo x >> "+countPath+"; echo "+bytecodeID)})
+	published := func() int {
+		data, _ := os.ReadFile(countPath)
+		return strings.Count(string(data), "x")
+	}
+
+	contract := filepath.Join(dir, "contract.wasm")
+	service := filepath.Join(dir, "service.wasm")
+	require.NoError(t, os.WriteFile(contract, []byte("contract"), 0600))
+	require.NoError(t, os.WriteFile(service, []byte("service"), 0600))
+
+	cachePath := filepath.Join(dir, "bytecode_cache.json")
+	require.NoError(t, InitBytecodeCache(cachePath))
+	result, err := publishBytecode(context.Background(), contract, service, false)
+	require.NoError(t, err)
+	assert.Equal(t, &PublishResult{
+		BytecodeID:     BytecodeID(bytecodeID),
+		ContractSHA256: "cc8321d6375c494d043fdd0260f21bc0ec51dacc9f6abb7f909cdcd3041b78bf",
+		ServiceSHA256:  "9df6b026a8c6c26e3c3acd2370a16e93fffdc0015ff5bd879218788025db0280",
+	}, result)
+	assert.Equal(t, 1, published())
+
+	// The same pair is found in the cache, also after a restart
+	require.NoError(t, InitBytecodeCache(cachePath))
+	result, err = publishBytecode(context.Background(), contract, service, false)
+	require.NoError(t, err)
+	assert.True(t, result.Cached)
+	assert.Equal(t, BytecodeID(bytecodeID), result.BytecodeID)
+	assert.Equal(t, 1, published())
+
+	// unless forced
+	result, err = publishBytecode(context.Background(), contract, service, true)
+	require.NoError(t, err)
+	assert.False(t, result.Cached)
+	assert.Equal(t, 2, published())
+
+	// Another pair or another network is published
+	require.NoError(t, os.WriteFile(service, []byte("service v2"), 0600))
+	_, err = publishBytecode(context.Background(), contract, service, false)
+	require.NoError(t, err)
+	assert.Equal(t, 3, published())
+
+	lineraCLI = NewLineraCLI(LineraConfig{Binary: lineraCLI.config.Binary, Network: "testnet"})
+	result, err = publishBytecode(context.Background(), contract, service, false)
+	require.NoError(t, err)
+	assert.False(t, result.Cached)
+	assert.Equal(t, 4, published())
+}
@@ -9,16 +9,14 @@ import (
 	"math/big"
 	"net/http"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strconv"
-	"strings"
 	"time"
 
+	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/core/types"
-	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/ethereum/go-ethereum/ethclient"
 	"github.com/gagliardetto/solana-go"
 	"github.com/gagliardetto/solana-go/programs/system"
@@ -32,6 +30,9 @@ var (
 	// RPC endpoints
 	EthereumRPC string
 	SolanaRPC   string
+	// Deposits to deposit addresses need this many Ethereum confirmations
+	// before they are swapped
+	ethereumMinConfirmations uint64 = 1
 	// Chain keys
 	chainKeys *keys.ChainKeys
 )
@@ -43,14 +44,36 @@ func InitRPCEndpoints(ethereumURL, solanaURL string) {
 	Logger.Printf("Initialized RPC endpoints - Ethereum: %s, Solana: %s", ethereumURL, solanaURL)
 }
 
+// InitEthereumConfirmations sets the confirmations a deposit address balance
+// needs before it is swapped
+func InitEthereumConfirmations(minConfirmations int64) error {
+	if minConfirmations < 1 {
+		return fmt.Errorf("minimum confirmations must be at least 1")
+	}
+	ethereumMinConfirmations = uint64(minConfirmations)
+	return nil
+}
+
 // InitKeys initializes the private keys from a seed phrase
 func InitKeys(seedPhrase string) error {
+	return InitKeysWithOptions(seedPhrase, keys.DerivationOptions{})
+}
+
+// InitKeysWithOptions initializes the private keys from a seed phrase using
+// the given derivation options
+func InitKeysWithOptions(seedPhrase string, opts keys.DerivationOptions) error {
+	// Encode Bitcoin account addresses for the configured network
+	if opts.BitcoinParams == nil {
+		opts.BitcoinParams = bitcoinParams
+	}
+
 	var err error
-	chainKeys, err = keys.DeriveKeysFromSeedPhrase(seedPhrase)
+	chainKeys, err = keys.DeriveKeys(seedPhrase, opts)
 	if err != nil {
 		Logger.Printf("Failed to derive keys: %v", err)
 		return fmt.Errorf("failed to derive keys: %w", err)
 	}
+	signer = NewLocalSigner(chainKeys)
 	Logger.Printf("Successfully initialized chain keys")
 	return nil
 }
@@ -121,9 +144,15 @@ func (c *Client) GetEthereumTransaction(_, txHash string) (interface{}, error) {
 		return nil, fmt.Errorf("failed to get Ethereum transaction: %w", err)
 	}
 
+	from, err := types.Sender(types.LatestSignerForChainID(tx.ChainId()), tx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to recover transaction sender: %w", err)
+	}
+
 	// Convert transaction to map for consistent response format
 	return map[string]interface{}{
 		"hash":      tx.Hash().Hex(),
+		"from":      from.Hex(),
 		"value":     tx.Value().String(),
 		"gas":       tx.Gas(),
 		"gasPrice":  tx.GasPrice().String(),
@@ -285,8 +314,13 @@ func (c *Client) CalculateSwap(fromToken, toToken string, amount float64) (*Swap
 	}, nil
 }
 
-// ExecuteSwap performs the swap operation
-func (c *Client) ExecuteSwap(fromToken, toToken string, amount float64, destinationAddress string) (*SwapResponse, error) {
+// ExecuteSwap performs the swap operation paying out the deposit depositTxHash
+func (c *Client) ExecuteSwap(depositTxHash, fromToken, toToken string, amount float64, destinationAddress string) (*SwapResponse, error) {
+	// Payouts wait while the paying pool is below its floor
+	if err := checkLiquidity(c.determineChain(toToken)); err != nil {
+		return nil, err
+	}
+
 	// First calculate the swap
 	swapResult, err := c.CalculateSwap(fromToken, toToken, amount)
 	if err != nil {
@@ -349,8 +383,17 @@ func (c *Client) ExecuteSwap(fromToken, toToken string, amount float64, destinat
 		return nil, fmt.Errorf("failed to prepare transaction: %w", err)
 	}
 
-	// Sign the prepared transaction
-	if err := c.SignTransaction(swapResponse); err != nil {
+	// Sign the prepared transaction, linked to the deposit it pays out
+	token, _ := LookupToken(toToken)
+	ctx := WithSigningRecord(context.Background(), SigningRecord{
+		Kind:      SigningRecordSwap,
+		Reference: depositTxHash,
+		Chain:     chain,
+		Recipient: destinationAddress,
+		Asset:     toToken,
+		Amount:    token.ToBaseUnits(swapResult.ToAmount),
+	})
+	if err := c.SignTransaction(ctx, swapResponse); err != nil {
 		return nil, fmt.Errorf("failed to sign transaction: %w", err)
 	}
 
@@ -363,14 +406,10 @@ func (c *Client) ExecuteSwap(fromToken, toToken string, amount float64, destinat
 }
 
 func (c *Client) determineChain(token string) string {
-	switch token {
-	case "ETH":
-		return "ethereum"
-	case "SOL":
-		return "solana"
-	default:
-		return "unknown"
+	if t, ok := LookupToken(token); ok {
+		return t.Chain
 	}
+	return "unknown"
 }
 
 // PrepareTransaction prepares a transaction for signing based on chain type
@@ -380,6 +419,8 @@ func (c *Client) PrepareTransaction(chain string, swap *SwapResponse) error {
 		return c.prepareEthereumTransaction(swap)
 	case "solana":
 		return c.prepareSolanaTransaction(swap)
+	case "bitcoin":
+		return c.prepareBitcoinTransaction(swap)
 	default:
 		return fmt.Errorf("unsupported chain: %s", chain)
 	}
@@ -498,6 +539,11 @@ func (c *Client) prepareEthereumTransaction(swap *SwapResponse) error {
 		return fmt.Errorf("failed to get source pool address: %w", err)
 	}
 
+	token, ok := LookupToken(swap.SwapResult.ToToken)
+	if !ok || token.Chain != "ethereum" {
+		return fmt.Errorf("unsupported Ethereum token: %s", swap.SwapResult.ToToken)
+	}
+
 	// Query Ethereum node for current gas price
 	client, err := ethclient.Dial(EthereumRPC)
 	if err != nil {
@@ -505,6 +551,11 @@ func (c *Client) prepareEthereumTransaction(swap *SwapResponse) error {
 	}
 	defer client.Close()
 
+	chainID, err := client.NetworkID(context.Background())
+	if err != nil {
+		return fmt.Errorf("failed to get network ID: %w", err)
+	}
+
 	gasPrice, err := client.SuggestGasPrice(context.Background())
 	if err != nil {
 		return fmt.Errorf("failed to get gas price: %w", err)
@@ -516,18 +567,46 @@ func (c *Client) prepareEthereumTransaction(swap *SwapResponse) error {
 		return fmt.Errorf("failed to get nonce: %w", err)
 	}
 
+	// Native payouts transfer value, ERC-20 payouts call transfer on the token contract
+	callTo := common.HexToAddress(swap.DestinationAddress)
+	callMsg := ethereum.CallMsg{
+		From:  common.HexToAddress(fromAddress),
+		To:    &callTo,
+		Value: token.ToBaseUnits(swap.SwapResult.ToAmount),
+	}
+	params := ChainParams{
+		FromAddress: fromAddress,
+		ToAddress:   swap.DestinationAddress,
+		Amount:      strconv.FormatFloat(swap.SwapResult.ToAmount, 'f', -1, 64),
+		ChainID:     chainID.String(),
+		Value:       callMsg.Value.String(),
+		GasPrice:    gasPrice.String(),
+		Nonce:       nonce,
+	}
+	if !token.IsNative() {
+		data, err := encodeERC20Transfer(swap.DestinationAddress, token.ToBaseUnits(swap.SwapResult.ToAmount))
+		if err != nil {
+			return fmt.Errorf("failed to encode token transfer: %w", err)
+		}
+		callTo = common.HexToAddress(token.Address)
+		callMsg.Value = nil
+		callMsg.Data = data
+		params.Value = ""
+		params.TokenAddress = token.Address
+		params.Data = hexutil.Encode(data)
+	}
+
+	gasLimit, err := client.EstimateGas(context.Background(), callMsg)
+	if err != nil {
+		return fmt.Errorf("failed to estimate gas: %w", err)
+	}
+	params.GasLimit = gasLimit
+
 	// Prepare transaction parameters
 	swap.TxToSign = &TransactionPrep{
-		Chain: "ethereum",
-		RawTx: "", // Will be filled by the signer
-		ChainParams: ChainParams{
-			FromAddress: fromAddress,
-			ToAddress:   swap.DestinationAddress,
-			Amount:      fmt.Sprintf("%f", swap.SwapResult.ToAmount),
-			GasPrice:    gasPrice.String(),
-			GasLimit:    21000, // Standard ETH transfer gas limit
-			Nonce:       nonce,
-		},
+		Chain:       "ethereum",
+		RawTx:       "", // Will be filled by the signer
+		ChainParams: params,
 	}
 	return nil
 }
@@ -540,6 +619,11 @@ func (c *Client) prepareSolanaTransaction(swap *SwapResponse) error {
 		return fmt.Errorf("failed to get source pool address: %w", err)
 	}
 
+	token, ok := LookupToken(swap.SwapResult.ToToken)
+	if !ok || token.Chain != "solana" {
+		return fmt.Errorf("unsupported Solana token: %s", swap.SwapResult.ToToken)
+	}
+
 	// Query Solana node for recent blockhash
 	client := rpc.New(SolanaRPC)
 	resp, err := client.GetLatestBlockhash(context.Background(), rpc.CommitmentConfirmed)
@@ -547,74 +631,109 @@ func (c *Client) prepareSolanaTransaction(swap *SwapResponse) error {
 		return fmt.Errorf("failed to get recent blockhash: %w", err)
 	}
 
+	params := ChainParams{
+		FromAddress:     fromAddress,
+		ToAddress:       swap.DestinationAddress,
+		Amount:          fmt.Sprintf("%f", swap.SwapResult.ToAmount),
+		RecentBlockhash: resp.Value.Blockhash.String(),
+		Lamports:        token.ToBaseUnits(swap.SwapResult.ToAmount).Uint64(),
+	}
+
+	// SPL payouts go to the destination's associated token account
+	if !token.IsNative() {
+		destination, err := solana.PublicKeyFromBase58(swap.DestinationAddress)
+		if err != nil {
+			return fmt.Errorf("invalid destination address: %w", err)
+		}
+		mint, err := solana.PublicKeyFromBase58(token.Address)
+		if err != nil {
+			return fmt.Errorf("invalid mint address: %w", err)
+		}
+		ata, _, err := solana.FindAssociatedTokenAddress(destination, mint)
+		if err != nil {
+			return fmt.Errorf("failed to derive destination token account: %w", err)
+		}
+		exists, err := solanaTokenAccountExists(client, ata)
+		if err != nil {
+			return fmt.Errorf("failed to get destination token account: %w", err)
+		}
+
+		params.Lamports = 0
+		params.TokenAddress = token.Address
+		params.TokenAmount = token.ToBaseUnits(swap.SwapResult.ToAmount).String()
+		params.Decimals = uint8(token.Decimals)
+		params.CreateTokenAccount = !exists
+	}
+
 	// Prepare transaction parameters
 	swap.TxToSign = &TransactionPrep{
-		Chain: "solana",
-		RawTx: "", // Will be filled by the signer
-		ChainParams: ChainParams{
-			FromAddress:     fromAddress,
-			ToAddress:       swap.DestinationAddress,
-			Amount:          fmt.Sprintf("%f", swap.SwapResult.ToAmount),
-			RecentBlockhash: resp.Value.Blockhash.String(),
-			Lamports:        swap.SwapResult.ToAmount,
-		},
+		Chain:       "solana",
+		RawTx:       "", // Will be filled by the signer
+		ChainParams: params,
 	}
 	return nil
 }
 
-// SignTransaction signs the prepared transaction based on chain type
-func (c *Client) SignTransaction(swap *SwapResponse) error {
+// SignTransaction signs the prepared transaction based on chain type. ctx
+// must link the transaction to its swap with WithSigningRecord.
+func (c *Client) SignTransaction(ctx context.Context, swap *SwapResponse) error {
 	if swap.TxToSign == nil {
 		return fmt.Errorf("no transaction prepared for signing")
 	}
 
 	switch swap.TxToSign.Chain {
 	case "ethereum":
-		return c.signEthereumTransaction(swap)
+		return c.signEthereumTransaction(ctx, swap)
 	case "solana":
-		return c.signSolanaTransaction(swap)
+		return c.signSolanaTransaction(ctx, swap)
+	case "bitcoin":
+		return c.signBitcoinTransaction(ctx, swap)
 	default:
 		return fmt.Errorf("unsupported chain for signing: %s", swap.TxToSign.Chain)
 	}
 }
 
-func (c *Client) signEthereumTransaction(swap *SwapResponse) error {
-	// Get derived Ethereum key instead of environment variable
-	if chainKeys == nil || chainKeys.EthereumKey == nil {
-		return fmt.Errorf("ethereum private key not initialized")
+func (c *Client) signEthereumTransaction(ctx context.Context, swap *SwapResponse) error {
+	signer, err := currentSigner()
+	if err != nil {
+		return err
+	}
+
+	// ERC-20 payouts carry no value and call transfer on the token contract
+	to := common.HexToAddress(swap.TxToSign.ChainParams.ToAddress)
+	value := big.NewInt(0)
+	var data []byte
+	if swap.TxToSign.ChainParams.TokenAddress != "" {
+		to = common.HexToAddress(swap.TxToSign.ChainParams.TokenAddress)
+		data, err = hexutil.Decode(swap.TxToSign.ChainParams.Data)
+		if err != nil {
+			return fmt.Errorf("failed to decode transaction data: %w", err)
+		}
+	} else if _, ok := value.SetString(swap.TxToSign.ChainParams.Value, 10); !ok {
+		return fmt.Errorf("invalid transaction value: %q", swap.TxToSign.ChainParams.Value)
+	}
+	chainID, ok := new(big.Int).SetString(swap.TxToSign.ChainParams.ChainID, 10)
+	if !ok {
+		return fmt.Errorf("invalid chain ID: %q", swap.TxToSign.ChainParams.ChainID)
 	}
 
 	// Create the transaction object
 	tx := types.NewTransaction(
 		swap.TxToSign.ChainParams.Nonce,
-		common.HexToAddress(swap.TxToSign.ChainParams.ToAddress),
-		func() *big.Int {
-			// Convert decimal to integer by multiplying by 10^18 (standard ETH decimals)
-			amountFloat, _ := strconv.ParseFloat(swap.TxToSign.ChainParams.Amount, 64)
-			amountBigFloat := new(big.Float).SetFloat64(amountFloat)
-			multiplier := new(big.Float).SetFloat64(1e18)
-			result := new(big.Float).Mul(amountBigFloat, multiplier)
-
-			amountBigInt := new(big.Int)
-			result.Int(amountBigInt)
-			return amountBigInt
-		}(),
+		to,
+		value,
 		swap.TxToSign.ChainParams.GasLimit,
 		func() *big.Int {
 			gasPrice, _ := new(big.Int).SetString(swap.TxToSign.ChainParams.GasPrice, 10)
 			return gasPrice
 		}(),
-		nil, // data
+		data,
 	)
 
-	// Get the signer
-	chainID := big.NewInt(1337) // mainnet, adjust as needed
-	signer := types.NewEIP155Signer(chainID)
-
-	// Sign the transaction
-	signedTx, err := types.SignTx(tx, signer, chainKeys.EthereumKey)
+	// Sign with the derived account holding the pool funds
+	signedTx, err := signer.SignEthereumTransaction(ctx, swap.TxToSign.ChainParams.FromAddress, tx, chainID)
 	if err != nil {
-		return fmt.Errorf("failed to sign transaction: %w", err)
+		return err
 	}
 
 	// Convert to raw bytes
@@ -628,43 +747,60 @@ func (c *Client) signEthereumTransaction(swap *SwapResponse) error {
 	return nil
 }
 
-func (c *Client) signSolanaTransaction(swap *SwapResponse) error {
-	// Get derived Solana key instead of environment variable
-	if chainKeys == nil || chainKeys.SolanaKey == nil {
-		return fmt.Errorf("solana private key not initialized")
-	}
-
+func (c *Client) signSolanaTransaction(ctx context.Context, swap *SwapResponse) error {
 	from_address, err := solana.PublicKeyFromBase58(swap.TxToSign.ChainParams.FromAddress)
 	if err != nil {
 		return fmt.Errorf("failed to get from address: %w", err)
 	}
+
+	signer, err := currentSigner()
+	if err != nil {
+		return err
+	}
 	to_address, err := solana.PublicKeyFromBase58(swap.TxToSign.ChainParams.ToAddress)
 
 	if err != nil {
 		return fmt.Errorf("failed to get to address: %w", err)
 	}
 
+	instructions := []solana.Instruction{
+		system.NewTransferInstruction(
+			swap.TxToSign.ChainParams.Lamports,
+			from_address,
+			to_address,
+		).Build(),
+	}
+	if swap.TxToSign.ChainParams.TokenAddress != "" {
+		instructions, err = splTransferInstructions(swap.TxToSign.ChainParams, from_address, to_address)
+		if err != nil {
+			return fmt.Errorf("failed to build token transfer: %w", err)
+		}
+	}
+
 	// Create a new transaction
 	tx, err := solana.NewTransaction(
-		[]solana.Instruction{
-			system.NewTransferInstruction(
-				uint64(swap.TxToSign.ChainParams.Lamports),
-				from_address,
-				to_address,
-			).Build(),
-		},
+		instructions,
 		solana.MustHashFromBase58(swap.TxToSign.ChainParams.RecentBlockhash),
 	)
+	if err != nil {
+		return fmt.Errorf("failed to create transaction: %w", err)
+	}
 
-	// Sign the transaction
-	_, _ = tx.Sign(
-		func(key solana.PublicKey) *solana.PrivateKey {
-			if chainKeys.SolanaKey.PublicKey().Equals(key) {
-				return chainKeys.SolanaKey
-			}
-			return nil
-		},
-	)
+	// Sign the message with each required signer, i.e. the derived account
+	// holding the pool funds
+	message, err := tx.Message.MarshalBinary()
+	if err != nil {
+		return fmt.Errorf("failed to serialize transaction message: %w", err)
+	}
+	signers := tx.Message.AccountKeys[:tx.Message.Header.NumRequiredSignatures]
+	tx.Signatures = make([]solana.Signature, 0, len(signers))
+	for _, key := range signers {
+		signature, err := signer.SignSolanaMessage(ctx, key.String(), message)
+		if err != nil {
+			return err
+		}
+		tx.Signatures = append(tx.Signatures, signature)
+	}
 
 	// Store the raw signed transaction
 	rawTx, err := tx.MarshalBinary()
@@ -687,6 +823,8 @@ func (c *Client) SubmitTransaction(swap *SwapResponse) error {
 		return c.submitEthereumTransaction(swap)
 	case "solana":
 		return c.submitSolanaTransaction(swap)
+	case "bitcoin":
+		return c.submitBitcoinTransaction(swap)
 	default:
 		return fmt.Errorf("unsupported chain for submission: %s", swap.TxToSign.Chain)
 	}
@@ -765,103 +903,6 @@ func (c *Client) submitSolanaTransaction(swap *SwapResponse) error {
 	return nil
 }
 
-func (c *Client) RequestSolanaAirdrop(address string) (map[string]interface{}, error) {
-	// Create RPC client
-	client := rpc.New(SolanaRPC)
-
-	// Parse address
-	pubKey, err := solana.PublicKeyFromBase58(address)
-	if err != nil {
-		return nil, fmt.Errorf("invalid Solana address: %w", err)
-	}
-
-	// Request airdrop (2 SOL)
-	sig, err := client.RequestAirdrop(
-		context.Background(),
-		pubKey,
-		2*solana.LAMPORTS_PER_SOL,
-		rpc.CommitmentFinalized,
-	)
-	if err != nil {
-		return nil, fmt.Errorf("failed to request airdrop: %w", err)
-	}
-
-	// Wait for confirmation
-	// _, err = client.GetConfirmedTransactionWithOpts(context.Background(), sig, &rpc.GetTransactionOpts{
-	// 	Commitment: rpc.CommitmentConfirmed,
-	// })
-	// if err != nil {
-	// 	return nil, fmt.Errorf("failed to confirm airdrop: %w", err)
-	// }
-
-	return map[string]interface{}{
-		"signature": sig.String(),
-		"amount":    "2 SOL",
-		"address":   address,
-	}, nil
-}
-
-func (c *Client) RequestEthereumFaucet(address string) (map[string]interface{}, error) {
-	// For testnet/local network only
-	if !common.IsHexAddress(address) {
-		return nil, fmt.Errorf("invalid Ethereum address")
-	}
-
-	client, err := ethclient.Dial(EthereumRPC)
-	if err != nil {
-		return nil, fmt.Errorf("failed to connect to Ethereum node: %w", err)
-	}
-	defer client.Close()
-
-	// Get the faucet's private key
-	if chainKeys == nil || chainKeys.EthereumKey == nil {
-		return nil, fmt.Errorf("ethereum faucet key not initialized")
-	}
-
-	// Create transaction
-	nonce, err := client.PendingNonceAt(context.Background(), crypto.PubkeyToAddress(chainKeys.EthereumKey.PublicKey))
-	if err != nil {
-		return nil, fmt.Errorf("failed to get nonce: %w", err)
-	}
-
-	value := big.NewInt(1000000000000000000) // 1 ETH
-	gasLimit := uint64(21000)
-	gasPrice, err := client.SuggestGasPrice(context.Background())
-	if err != nil {
-		return nil, fmt.Errorf("failed to get gas price: %w", err)
-	}
-
-	tx := types.NewTransaction(
-		nonce,
-		common.HexToAddress(address),
-		value,
-		gasLimit,
-		gasPrice,
-		nil,
-	)
-
-	chainID, err := client.NetworkID(context.Background())
-	if err != nil {
-		return nil, fmt.Errorf("failed to get chain id: %w", err)
-	}
-
-	signedTx, err := types.SignTx(tx, types.NewEIP155Signer(chainID), chainKeys.EthereumKey)
-	if err != nil {
-		return nil, fmt.Errorf("failed to sign transaction: %w", err)
-	}
-
-	err = client.SendTransaction(context.Background(), signedTx)
-	if err != nil {
-		return nil, fmt.Errorf("failed to send transaction: %w", err)
-	}
-
-	return map[string]interface{}{
-		"txHash":  signedTx.Hash().String(),
-		"amount":  "1 ETH",
-		"address": address,
-	}, nil
-}
-
 // GetSolanaBalance fetches SOL balance for an address
 func (c *Client) GetSolanaBalance(address string) (*Balance, error) {
 	// Create RPC client
@@ -927,102 +968,15 @@ func (c *Client) GetEthereumBalance(address string) (*Balance, error) {
 	}, nil
 }
 
-// Add new functions with amount parameter
-func (c *Client) RequestSolanaAirdropWithAmount(address string, amount float64) (map[string]interface{}, error) {
-	// Convert amount to lamports (1 SOL = 1e9 lamports)
-	lamports := uint64(amount * 1e9)
-
-	client := rpc.New(SolanaRPC)
-	pubKey, err := solana.PublicKeyFromBase58(address)
-	if err != nil {
-		return nil, fmt.Errorf("invalid Solana address: %w", err)
-	}
-
-	sig, err := client.RequestAirdrop(
-		context.Background(),
-		pubKey,
-		lamports,
-		rpc.CommitmentFinalized,
-	)
-	if err != nil {
-		return nil, fmt.Errorf("failed to request airdrop: %w", err)
-	}
-
-	return map[string]interface{}{
-		"signature": sig.String(),
-		"amount":    fmt.Sprintf("%f SOL", amount),
-		"address":   address,
-	}, nil
-}
-
-func (c *Client) RequestEthereumFaucetWithAmount(address string, amount float64) (map[string]interface{}, error) {
-	client, err := ethclient.Dial(EthereumRPC)
-	if err != nil {
-		return nil, fmt.Errorf("failed to connect to Ethereum node: %w", err)
-	}
-	defer client.Close()
-
-	// Convert amount to wei (1 ETH = 1e18 wei)
-	weiAmount := new(big.Int)
-	weiAmount.SetString(fmt.Sprintf("%.0f", amount*1e18), 10)
-
-	// Get the faucet's private key
-	privateKey := chainKeys.EthereumKey
-
-	// Get the faucet's nonce
-	nonce, err := client.PendingNonceAt(context.Background(), crypto.PubkeyToAddress(privateKey.PublicKey))
-	if err != nil {
-		return nil, fmt.Errorf("failed to get nonce: %w", err)
-	}
-
-	// Create transaction
-	gasPrice, err := client.SuggestGasPrice(context.Background())
-	if err != nil {
-		return nil, fmt.Errorf("failed to get gas price: %w", err)
-	}
-
-	tx := types.NewTransaction(
-		nonce,
-		common.HexToAddress(address),
-		weiAmount,
-		21000,
-		gasPrice,
-		nil,
-	)
-
-	// Sign transaction
-	chainID, err := client.NetworkID(context.Background())
-	if err != nil {
-		return nil, fmt.Errorf("failed to get chain ID: %w", err)
-	}
-
-	signedTx, err := types.SignTx(tx, types.NewEIP155Signer(chainID), privateKey)
-	if err != nil {
-		return nil, fmt.Errorf("failed to sign transaction: %w", err)
-	}
-
-	// Send transaction
-	err = client.SendTransaction(context.Background(), signedTx)
-	if err != nil {
-		return nil, fmt.Errorf("failed to send transaction: %w", err)
-	}
-
-	return map[string]interface{}{
-		"hash":    signedTx.Hash().String(),
-		"amount":  fmt.Sprintf("%f ETH", amount),
-		"address": address,
-	}, nil
-}
-
 // PublishBytecode executes the Linera publish-bytecode command with provided WASM content
-func (c *Client) PublishBytecode(contractWasm, serviceWasm []byte) (string, error) {
+func (c *Client) PublishBytecode(contractWasm, serviceWasm []byte, force bool) (*PublishResult, error) {
 	Logger.Printf("Publishing bytecode (contract: %d bytes, service: %d bytes)...", len(contractWasm), len(serviceWasm))
 
 	// Create temporary directory for WASM files
 	tempDir, err := os.MkdirTemp("", "linera-wasm")
 	if err != nil {
 		Logger.Printf("Error creating temp directory: %v", err)
-		return "", fmt.Errorf("failed to create temp directory: %v", err)
+		return nil, fmt.Errorf("failed to create temp directory: %v", err)
 	}
 	defer os.RemoveAll(tempDir)
 
@@ -1033,63 +987,15 @@ func (c *Client) PublishBytecode(contractWasm, serviceWasm []byte) (string, erro
 	// Write files using buffered writer for better performance
 	if err := writeFileBuffered(contractPath, contractWasm); err != nil {
 		Logger.Printf("Error writing contract WASM: %v", err)
-		return "", fmt.Errorf("failed to write contract WASM: %v", err)
+		return nil, fmt.Errorf("failed to write contract WASM: %v", err)
 	}
 
 	if err := writeFileBuffered(servicePath, serviceWasm); err != nil {
 		Logger.Printf("Error writing service WASM: %v", err)
-		return "", fmt.Errorf("failed to write service WASM: %v", err)
-	}
-
-	// Prepare and execute command with environment variables
-	cmd := exec.Command("linera", "publish-bytecode", contractPath, servicePath)
-	cmd.Env = append(os.Environ(),
-		"LINERA_WALLET=/var/folders/3_/ty3nbwgs5cv30xhjxd1s0_3r0000gn/T/.tmpFRJbhX/wallet_0.json",
-		"LINERA_STORAGE=rocksdb:/var/folders/3_/ty3nbwgs5cv30xhjxd1s0_3r0000gn/T/.tmpFRJbhX/client_0.db",
-		"CHAIN_1=e476187f6ddfeb9d588c7b45d3df334d5501d6499b3f9ad5595cae86cce16a65",
-		"OWNER_1=598b7023d32f48573a47acb80ea70781c375fc60a352d8043cf8fcacc5d5b2c9",
-		"CHAIN_2=69705f85ac4c9fef6c02b4d83426aaaf05154c645ec1c61665f8e450f0468bc0",
-		"OWNER_2=5dcc4b83f44bfd28086560c5c4872cfd6979dee316d1b6b3ee8da038199ca0a3",
-	)
-
-	// Get the output using pipe for better performance with large outputs
-	stdout, err := cmd.StdoutPipe()
-	if err != nil {
-		return "", fmt.Errorf("failed to create stdout pipe: %v", err)
-	}
-
-	if err := cmd.Start(); err != nil {
-		return "", fmt.Errorf("failed to start command: %v", err)
-	}
-
-	// Read output using scanner for better memory efficiency
-	var outputBuilder strings.Builder
-	scanner := bufio.NewScanner(stdout)
-	for scanner.Scan() {
-		outputBuilder.WriteString(scanner.Text())
-		outputBuilder.WriteString("\n")
-	}
-
-	if err := cmd.Wait(); err != nil {
-		return "", fmt.Errorf("command failed: %v", err)
-	}
-
-	if err := scanner.Err(); err != nil {
-		return "", fmt.Errorf("error reading command output: %v", err)
+		return nil, fmt.Errorf("failed to write service WASM: %v", err)
 	}
 
-	// Parse the output to get the bytecode ID
-	outputStr := outputBuilder.String()
-	parts := strings.Split(outputStr, "=")
-	if len(parts) != 2 {
-		Logger.Printf("Unexpected output format: %s", outputStr)
-		return "", fmt.Errorf("unexpected output format: %s", outputStr)
-	}
-
-	bytecodeID := strings.TrimSpace(parts[1])
-	Logger.Printf("Successfully published bytecode with ID: %s", bytecodeID)
-
-	return bytecodeID, nil
+	return c.PublishBytecodeFromFiles(contractPath, servicePath, force)
 }
 
 // writeFileBuffered writes data to a file using a buffered writer for better performance
@@ -1108,98 +1014,31 @@ func writeFileBuffered(filepath string, data []byte) error {
 	return writer.Flush()
 }
 
-// PublishBytecodeFromFiles executes the Linera publish-bytecode command with provided file paths
-func (c *Client) PublishBytecodeFromFiles(contractPath, servicePath string) (string, error) {
+// PublishBytecodeFromFiles executes the Linera publish-bytecode command with
+// provided file paths. Files published before on the configured network are
+// not published again unless force is set.
+func (c *Client) PublishBytecodeFromFiles(contractPath, servicePath string, force bool) (*PublishResult, error) {
 	Logger.Printf("Publishing bytecode from files...")
 
-	// Prepare and execute command with environment variables
-	cmd := exec.Command("linera", "publish-bytecode", contractPath, servicePath)
-	cmd.Env = append(os.Environ(),
-		"LINERA_WALLET=/var/folders/3_/ty3nbwgs5cv30xhjxd1s0_3r0000gn/T/.tmpFRJbhX/wallet_0.json",
-		"LINERA_STORAGE=rocksdb:/var/folders/3_/ty3nbwgs5cv30xhjxd1s0_3r0000gn/T/.tmpFRJbhX/client_0.db",
-		"CHAIN_1=e476187f6ddfeb9d588c7b45d3df334d5501d6499b3f9ad5595cae86cce16a65",
-		"OWNER_1=598b7023d32f48573a47acb80ea70781c375fc60a352d8043cf8fcacc5d5b2c9",
-		"CHAIN_2=69705f85ac4c9fef6c02b4d83426aaaf05154c645ec1c61665f8e450f0468bc0",
-		"OWNER_2=5dcc4b83f44bfd28086560c5c4872cfd6979dee316d1b6b3ee8da038199ca0a3",
-	)
-
-	// Get the output using pipe for better performance
-	stdout, err := cmd.StdoutPipe()
+	result, err := publishBytecode(context.Background(), contractPath, servicePath, force)
 	if err != nil {
-		return "", fmt.Errorf("failed to create stdout pipe: %v", err)
-	}
-
-	if err := cmd.Start(); err != nil {
-		return "", fmt.Errorf("failed to start command: %v", err)
-	}
-
-	// Read output using scanner for better memory efficiency
-	var outputBuilder strings.Builder
-	scanner := bufio.NewScanner(stdout)
-	for scanner.Scan() {
-		outputBuilder.WriteString(scanner.Text())
-		outputBuilder.WriteString("\n")
-	}
-
-	if err := cmd.Wait(); err != nil {
-		return "", fmt.Errorf("command failed: %v", err)
-	}
-
-	if err := scanner.Err(); err != nil {
-		return "", fmt.Errorf("error reading command output: %v", err)
+		return nil, err
 	}
 
-	// Parse the output to get the bytecode ID
-	outputStr := strings.TrimSpace(outputBuilder.String())
-
-	Logger.Printf("Successfully published bytecode with ID: %s", outputStr)
-	return outputStr, nil
+	Logger.Printf("Successfully published bytecode with ID: %s", result.BytecodeID)
+	return result, nil
 }
 
-// CreateApplication executes the Linera create-application command with the provided bytecode ID
-func (c *Client) CreateApplication(bytecodeID string) (string, error) {
+// CreateApplication executes the Linera create-application command with the
+// provided bytecode ID and instantiation options
+func (c *Client) CreateApplication(bytecodeID BytecodeID, opts CreateApplicationOptions) (ApplicationID, error) {
 	Logger.Printf("Creating application with bytecode ID: %s", bytecodeID)
 
-	// Prepare the command
-	cmd := exec.Command("linera", "create-application", bytecodeID)
-	cmd.Env = append(os.Environ(),
-		"LINERA_WALLET=/var/folders/3_/ty3nbwgs5cv30xhjxd1s0_3r0000gn/T/.tmpFRJbhX/wallet_0.json",
-		"LINERA_STORAGE=rocksdb:/var/folders/3_/ty3nbwgs5cv30xhjxd1s0_3r0000gn/T/.tmpFRJbhX/client_0.db",
-		"CHAIN_1=e476187f6ddfeb9d588c7b45d3df334d5501d6499b3f9ad5595cae86cce16a65",
-		"OWNER_1=598b7023d32f48573a47acb80ea70781c375fc60a352d8043cf8fcacc5d5b2c9",
-		"CHAIN_2=69705f85ac4c9fef6c02b4d83426aaaf05154c645ec1c61665f8e450f0468bc0",
-		"OWNER_2=5dcc4b83f44bfd28086560c5c4872cfd6979dee316d1b6b3ee8da038199ca0a3",
-	)
-
-	// Get the output using pipe for better performance
-	stdout, err := cmd.StdoutPipe()
+	applicationID, err := lineraCLI.CreateApplication(context.Background(), bytecodeID, opts)
 	if err != nil {
-		return "", fmt.Errorf("failed to create stdout pipe: %v", err)
+		return "", err
 	}
 
-	if err := cmd.Start(); err != nil {
-		return "", fmt.Errorf("failed to start command: %v", err)
-	}
-
-	// Read output using scanner for better memory efficiency
-	var outputBuilder strings.Builder
-	scanner := bufio.NewScanner(stdout)
-	for scanner.Scan() {
-		outputBuilder.WriteString(scanner.Text())
-		outputBuilder.WriteString("\n")
-	}
-
-	if err := cmd.Wait(); err != nil {
-		return "", fmt.Errorf("command failed: %v", err)
-	}
-
-	if err := scanner.Err(); err != nil {
-		return "", fmt.Errorf("error reading command output: %v", err)
-	}
-
-	// Parse the output to get the application ID
-	outputStr := strings.TrimSpace(outputBuilder.String())
-	Logger.Printf("Successfully created application with ID: %s", outputStr)
-
-	return outputStr, nil
+	Logger.Printf("Successfully created application with ID: %s", applicationID)
+	return applicationID, nil
 }
@@ -0,0 +1,558 @@
+package solver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Deployment job phases
+const (
+	DeploymentQueued     = "queued"
+	DeploymentBuilding   = "building"
+	DeploymentPublishing = "publishing"
+	DeploymentCreating   = "creating"
+	DeploymentDone       = "done"
+	DeploymentFailed     = "failed"
+)
+
+// Types of DeploymentEvent
+const (
+	DeploymentEventLog = "log"
+	DeploymentEventJob = "job"
+)
+
+const (
+	// deploymentQueueSize bounds the jobs waiting for the worker
+	deploymentQueueSize = 100
+	// maxDeploymentLogs bounds the log lines kept per job, the oldest are dropped
+	maxDeploymentLogs = 500
+	// deploymentEventBuffer is how far a subscriber may fall behind before
+	// events are dropped for it
+	deploymentEventBuffer = 64
+)
+
+// ErrDeploymentQueueFull is returned when too many jobs wait for the worker
+var ErrDeploymentQueueFull = errors.New("deployment queue is full")
+
+// DeploymentLog is a line of progress of a deployment job
+type DeploymentLog struct {
+	Time    time.Time `json:"time"`
+	Message string    `json:"message"`
+}
+
+// DeploymentJob publishes bytecode and creates an application from it
+type DeploymentJob struct {
+	ID    string `json:"id"`
+	Phase string `json:"phase"`
+	// Build is the source the WASM files are built from, nil for uploads
+	Build *BuildSource `json:"build,omitempty"`
+	// PublishOnly stops the job once the bytecode is published
+	PublishOnly bool `json:"publishOnly,omitempty"`
+	// Force publishes the bytecode even when it was published before
+	Force bool `json:"force,omitempty"`
+	// Cached is set when the bytecode was published before and was not again
+	Cached bool `json:"cached,omitempty"`
+	// Application instantiates the application
+	Application  CreateApplicationOptions `json:"application"`
+	ContractSize int64                    `json:"contractSize"`
+	ServiceSize  int64                    `json:"serviceSize"`
+	BytecodeID   BytecodeID               `json:"bytecodeId,omitempty"`
+	// CreatingFrom is set while create-application runs, so a restart knows
+	// an application may have been created from this bytecode
+	CreatingFrom  BytecodeID      `json:"creatingFrom,omitempty"`
+	ApplicationID ApplicationID   `json:"applicationId,omitempty"`
+	Error         string          `json:"error,omitempty"`
+	Logs          []DeploymentLog `json:"logs"`
+	CreatedAt     time.Time       `json:"createdAt"`
+	UpdatedAt     time.Time       `json:"updatedAt"`
+}
+
+// Finished reports whether the job is done or failed
+func (j *DeploymentJob) Finished() bool {
+	return j.Phase == DeploymentDone || j.Phase == DeploymentFailed
+}
+
+// copy returns a snapshot of the job, without logs unless withLogs
+func (j *DeploymentJob) copy(withLogs bool) *DeploymentJob {
+	copied := *j
+	copied.Logs = nil
+	if withLogs {
+		copied.Logs = append([]DeploymentLog{}, j.Logs...)
+	}
+	return &copied
+}
+
+// DeploymentEvent is progress of a job: a log line, or the job after its
+// phase changed
+type DeploymentEvent struct {
+	Type string
+	Log  *DeploymentLog
+	Job  *DeploymentJob
+}
+
+// DeploymentManager runs deployment jobs one at a time, as linera commands
+// share a wallet. Jobs are persisted in dir with their WASM files, and jobs
+// interrupted by a restart are run again from their last phase, except for
+// those interrupted while creating their application, which fail.
+type DeploymentManager struct {
+	dir    string
+	queue  chan string
+	github *GithubAuthConfig
+
+	mu          sync.Mutex
+	jobs        map[string]*DeploymentJob
+	subscribers map[string][]chan DeploymentEvent
+	// tokens are the GitHub tokens of queued builds, never persisted
+	tokens map[string]string
+}
+
+// NewDeploymentManager loads the jobs saved in dir, queueing unfinished ones
+func NewDeploymentManager(dir string) (*DeploymentManager, error) {
+	m := &DeploymentManager{
+		dir:         dir,
+		queue:       make(chan string, deploymentQueueSize),
+		jobs:        make(map[string]*DeploymentJob),
+		subscribers: make(map[string][]chan DeploymentEvent),
+		tokens:      make(map[string]string),
+	}
+	if err := readJSONFile(m.path(), &m.jobs); err != nil {
+		return nil, err
+	}
+
+	var unfinished []*DeploymentJob
+	for _, job := range m.jobs {
+		if !job.Finished() {
+			unfinished = append(unfinished, job)
+		}
+	}
+	sort.Slice(unfinished, func(i, j int) bool { return unfinished[i].CreatedAt.Before(unfinished[j].CreatedAt) })
+	for _, job := range unfinished {
+		// Creating the application again could create a second one
+		if job.CreatingFrom != "" {
+			m.failLocked(job, fmt.Errorf("interrupted while creating an application from bytecode %s, which may have been created: check the chain before submitting again", job.CreatingFrom))
+			continue
+		}
+		m.logLocked(job, fmt.Sprintf("Resuming after restart (was %s)", job.Phase))
+		job.Phase = DeploymentQueued
+		select {
+		case m.queue <- job.ID:
+		default:
+			m.failLocked(job, ErrDeploymentQueueFull)
+		}
+	}
+	return m, nil
+}
+
+func (m *DeploymentManager) path() string {
+	return filepath.Join(m.dir, "deployments.json")
+}
+
+// UseGithub sets the client fetching GitHub repositories for builds
+func (m *DeploymentManager) UseGithub(client *GithubAuthConfig) {
+	m.github = client
+}
+
+// jobDir holds the WASM files of a job
+func (m *DeploymentManager) jobDir(id string) string {
+	return filepath.Join(m.dir, id)
+}
+
+// Submit queues a job publishing the contract and service WASM files, which
+// are copied so the caller may remove them
+func (m *DeploymentManager) Submit(contractPath, servicePath string, publishOnly, force bool, application CreateApplicationOptions) (*DeploymentJob, error) {
+	id, err := newRecordReference()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(m.jobDir(id), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create job directory: %w", err)
+	}
+	contractSize, err := copyFile(contractPath, filepath.Join(m.jobDir(id), "contract.wasm"))
+	if err != nil {
+		os.RemoveAll(m.jobDir(id))
+		return nil, fmt.Errorf("failed to store contract WASM: %w", err)
+	}
+	serviceSize, err := copyFile(servicePath, filepath.Join(m.jobDir(id), "service.wasm"))
+	if err != nil {
+		os.RemoveAll(m.jobDir(id))
+		return nil, fmt.Errorf("failed to store service WASM: %w", err)
+	}
+
+	now := time.Now().UTC()
+	job := &DeploymentJob{
+		ID:           id,
+		Phase:        DeploymentQueued,
+		PublishOnly:  publishOnly,
+		Force:        force,
+		Application:  application,
+		ContractSize: contractSize,
+		ServiceSize:  serviceSize,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	if err := m.queueJob(job, ""); err != nil {
+		return nil, err
+	}
+	Logger.Printf("Deployment %s queued (contract: %d bytes, service: %d bytes)", id, contractSize, serviceSize)
+	return job.copy(true), nil
+}
+
+// SubmitBuild queues a job building the WASM files from source before
+// publishing them. token reads the GitHub repository and is kept in memory
+// only, so a GitHub build interrupted by a restart fails.
+func (m *DeploymentManager) SubmitBuild(source BuildSource, token string, publishOnly, force bool, application CreateApplicationOptions) (*DeploymentJob, error) {
+	if !buildConfig.Enabled {
+		return nil, ErrBuildsDisabled
+	}
+	if err := source.Validate(); err != nil {
+		return nil, &InvalidBuildSourceError{Err: err}
+	}
+	id, err := newRecordReference()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(m.jobDir(id), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create job directory: %w", err)
+	}
+
+	now := time.Now().UTC()
+	job := &DeploymentJob{
+		ID:          id,
+		Phase:       DeploymentQueued,
+		Build:       &source,
+		PublishOnly: publishOnly,
+		Force:       force,
+		Application: application,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := m.queueJob(job, token); err != nil {
+		return nil, err
+	}
+	if source.LocalPath != "" {
+		Logger.Printf("Deployment %s queued (build of %s)", id, source.LocalPath)
+	} else {
+		Logger.Printf("Deployment %s queued (build of %s/%s)", id, source.Owner, source.Repo)
+	}
+	return job.copy(true), nil
+}
+
+// queueJob saves the new job and hands it to the worker
+func (m *DeploymentManager) queueJob(job *DeploymentJob, token string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.jobs[job.ID] = job
+	if err := writeJSONFile(m.path(), m.jobs); err != nil {
+		delete(m.jobs, job.ID)
+		os.RemoveAll(m.jobDir(job.ID))
+		return err
+	}
+	if token != "" {
+		m.tokens[job.ID] = token
+	}
+	select {
+	case m.queue <- job.ID:
+	default:
+		m.failLocked(job, ErrDeploymentQueueFull)
+		return ErrDeploymentQueueFull
+	}
+	return nil
+}
+
+// copyFile copies the file at from to to and returns its size
+func copyFile(from, to string) (int64, error) {
+	src, err := os.Open(from)
+	if err != nil {
+		return 0, err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(to, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(dst, src)
+	if closeErr := dst.Close(); err == nil {
+		err = closeErr
+	}
+	return n, err
+}
+
+// Get returns a snapshot of the job with its logs
+func (m *DeploymentManager) Get(id string) (*DeploymentJob, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	job, ok := m.jobs[id]
+	if !ok {
+		return nil, false
+	}
+	return job.copy(true), true
+}
+
+// Subscribe returns a snapshot of the job with its logs and the events that
+// follow it. The channel is closed once the job finishes, right away when it
+// has already. cancel stops the subscription.
+func (m *DeploymentManager) Subscribe(id string) (job *DeploymentJob, events <-chan DeploymentEvent, cancel func(), ok bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	current, ok := m.jobs[id]
+	if !ok {
+		return nil, nil, nil, false
+	}
+	ch := make(chan DeploymentEvent, deploymentEventBuffer)
+	if current.Finished() {
+		close(ch)
+		return current.copy(true), ch, func() {}, true
+	}
+	m.subscribers[id] = append(m.subscribers[id], ch)
+
+	cancel = func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		subscribers := m.subscribers[id]
+		for i, subscriber := range subscribers {
+			if subscriber == ch {
+				m.subscribers[id] = append(subscribers[:i], subscribers[i+1:]...)
+				close(ch)
+				break
+			}
+		}
+	}
+	return current.copy(true), ch, cancel, true
+}
+
+// publishLocked sends event to the subscribers of id, skipping those too far behind
+func (m *DeploymentManager) publishLocked(id string, event DeploymentEvent) {
+	for _, ch := range m.subscribers[id] {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
+
+// Run works through the queued jobs until ctx is done
+func (m *DeploymentManager) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case id := <-m.queue:
+			m.run(ctx, id)
+		}
+	}
+}
+
+// run takes a job through its remaining phases
+func (m *DeploymentManager) run(ctx context.Context, id string) {
+	m.mu.Lock()
+	job, ok := m.jobs[id]
+	if !ok || job.Finished() {
+		m.mu.Unlock()
+		return
+	}
+	bytecodeID := job.BytecodeID
+	created := job.ApplicationID != ""
+	publishOnly := job.PublishOnly
+	force := job.Force
+	application := job.Application
+	build := job.Build
+	built := job.ContractSize > 0
+	token := m.tokens[id]
+	m.mu.Unlock()
+
+	// Stream what linera writes to stderr into the job log
+	ctx = withLineraLog(ctx, func(line string) { m.log(id, line) })
+
+	if build != nil && !built && bytecodeID == "" {
+		m.setPhase(id, DeploymentBuilding, "Building application")
+		if err := m.build(ctx, id, *build, token); err != nil {
+			m.fail(id, err)
+			return
+		}
+	}
+
+	if bytecodeID == "" {
+		m.setPhase(id, DeploymentPublishing, "Publishing bytecode")
+		published, err := publishBytecode(ctx, filepath.Join(m.jobDir(id), "contract.wasm"), filepath.Join(m.jobDir(id), "service.wasm"), force)
+		if err != nil {
+			m.fail(id, err)
+			return
+		}
+		bytecodeID = published.BytecodeID
+		m.update(id, func(job *DeploymentJob) {
+			job.BytecodeID = published.BytecodeID
+			job.Cached = published.Cached
+		})
+		if published.Cached {
+			m.log(id, fmt.Sprintf("Bytecode was published before as %s", published.BytecodeID))
+		} else {
+			m.log(id, fmt.Sprintf("Published bytecode %s", published.BytecodeID))
+		}
+	}
+
+	if !publishOnly && !created {
+		if err := m.startCreating(id, bytecodeID); err != nil {
+			m.fail(id, err)
+			return
+		}
+		applicationID, err := lineraCLI.CreateApplication(ctx, bytecodeID, application)
+		if err != nil {
+			m.fail(id, err)
+			return
+		}
+		m.update(id, func(job *DeploymentJob) {
+			job.ApplicationID = applicationID
+			job.CreatingFrom = ""
+		})
+		m.log(id, fmt.Sprintf("Created application %s", applicationID))
+	}
+
+	m.setPhase(id, DeploymentDone, "Deployment done")
+}
+
+// build builds the job's source in a workspace within its directory and
+// keeps the contract and service WASM for publishing
+func (m *DeploymentManager) build(ctx context.Context, id string, source BuildSource, token string) error {
+	workDir := filepath.Join(m.jobDir(id), "build")
+	// A build interrupted by a restart starts over
+	if err := os.RemoveAll(workDir); err != nil {
+		return fmt.Errorf("failed to clear build workspace: %w", err)
+	}
+	defer os.RemoveAll(workDir)
+
+	contractPath, servicePath, err := buildApplication(ctx, m.github, source, token, workDir, func(line string) { m.log(id, line) })
+	if err != nil {
+		return err
+	}
+
+	var sizes [2]int64
+	for i, artifact := range []struct{ from, to string }{
+		{contractPath, "contract.wasm"},
+		{servicePath, "service.wasm"},
+	} {
+		wasm, err := InspectWasmFile(artifact.from)
+		if err != nil {
+			return fmt.Errorf("%s: %w", filepath.Base(artifact.from), err)
+		}
+		if _, err := copyFile(artifact.from, filepath.Join(m.jobDir(id), artifact.to)); err != nil {
+			return fmt.Errorf("failed to store %s: %w", filepath.Base(artifact.from), err)
+		}
+		sizes[i] = wasm.Size
+		m.log(id, fmt.Sprintf("Built %s (%d bytes, sha256 %s)", filepath.Base(artifact.from), wasm.Size, wasm.SHA256))
+	}
+	m.update(id, func(job *DeploymentJob) {
+		job.ContractSize = sizes[0]
+		job.ServiceSize = sizes[1]
+	})
+	return nil
+}
+
+// log appends a line to the job log
+func (m *DeploymentManager) log(id, message string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if job, ok := m.jobs[id]; ok {
+		m.logLocked(job, message)
+	}
+}
+
+func (m *DeploymentManager) logLocked(job *DeploymentJob, message string) {
+	entry := DeploymentLog{Time: time.Now().UTC(), Message: message}
+	job.Logs = append(job.Logs, entry)
+	if len(job.Logs) > maxDeploymentLogs {
+		job.Logs = job.Logs[len(job.Logs)-maxDeploymentLogs:]
+	}
+	m.publishLocked(job.ID, DeploymentEvent{Type: DeploymentEventLog, Log: &entry})
+}
+
+// update changes the job with apply and saves it
+func (m *DeploymentManager) update(id string, apply func(job *DeploymentJob)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if job, ok := m.jobs[id]; ok {
+		apply(job)
+		m.saveLocked(job)
+	}
+}
+
+// setPhase moves the job to phase, logging message
+func (m *DeploymentManager) setPhase(id, phase, message string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job, ok := m.jobs[id]
+	if !ok {
+		return
+	}
+	m.logLocked(job, message)
+	job.Phase = phase
+	m.saveLocked(job)
+}
+
+// startCreating moves the job to the creating phase and records the bytecode
+// it creates from. Unless that record is saved the application is not created.
+func (m *DeploymentManager) startCreating(id string, bytecodeID BytecodeID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job, ok := m.jobs[id]
+	if !ok {
+		return fmt.Errorf("unknown deployment: %s", id)
+	}
+	m.logLocked(job, "Creating application")
+	job.Phase = DeploymentCreating
+	job.CreatingFrom = bytecodeID
+	if err := m.saveLocked(job); err != nil {
+		job.CreatingFrom = ""
+		return fmt.Errorf("failed to record application creation: %w", err)
+	}
+	return nil
+}
+
+// fail marks the job failed with err
+func (m *DeploymentManager) fail(id string, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if job, ok := m.jobs[id]; ok {
+		m.failLocked(job, err)
+	}
+}
+
+func (m *DeploymentManager) failLocked(job *DeploymentJob, err error) {
+	m.logLocked(job, fmt.Sprintf("Deployment failed: %v", err))
+	job.Phase = DeploymentFailed
+	job.Error = err.Error()
+	m.saveLocked(job)
+}
+
+// saveLocked persists the jobs after job changed and tells its subscribers.
+// A finished job's files are removed and its subscriptions closed.
+func (m *DeploymentManager) saveLocked(job *DeploymentJob) error {
+	job.UpdatedAt = time.Now().UTC()
+	err := writeJSONFile(m.path(), m.jobs)
+	if err != nil {
+		// The job goes on, it is only run again from an earlier phase after a restart
+		Logger.Printf("Deployment %s: failed to save jobs: %v", job.ID, err)
+	}
+	m.publishLocked(job.ID, DeploymentEvent{Type: DeploymentEventJob, Job: job.copy(false)})
+
+	if job.Finished() {
+		Logger.Printf("Deployment %s %s", job.ID, job.Phase)
+		os.RemoveAll(m.jobDir(job.ID))
+		delete(m.tokens, job.ID)
+		for _, ch := range m.subscribers[job.ID] {
+			close(ch)
+		}
+		delete(m.subscribers, job.ID)
+	}
+	return err
+}
@@ -0,0 +1,114 @@
+package solver
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeploymentManager(t *testing.T) {
+	bytecodeID := strings.Repeat("ab", 64)
+	applicationID := bytecodeID + testChainID + strings.Repeat("0", 24)
+
+	// A linera stand-in logging progress to stderr
+	previous := lineraCLI
+	defer func() { lineraCLI = previous }()
+	lineraCLI = NewLineraCLI(LineraConfig{Binary: fakeLinera(t, `
+case "$1" in
+publish-bytecode) echo "uploading $(basename $2)" >&2; echo `+bytecodeID+` ;;
+create-application) echo "creating from $2" >&2; echo `+applicationID+` ;;
+esac`)})
+
+	dir := t.TempDir()
+	wasm := filepath.Join(dir, "upload.wasm")
+	require.NoError(t, os.WriteFile(wasm, []byte("\x00asm\x01\x00\x00\x00"), 0600))
+
+	manager, err := NewDeploymentManager(filepath.Join(dir, "deployments"))
+	require.NoError(t, err)
+	job, err := manager.Submit(wasm, wasm, false, false, CreateApplicationOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, DeploymentQueued, job.Phase)
+	assert.Equal(t, int64(8), job.ContractSize)
+
+	snapshot, events, cancel, ok := manager.Subscribe(job.ID)
+	require.True(t, ok)
+	defer cancel()
+	assert.Equal(t, DeploymentQueued, snapshot.Phase)
+
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+	go manager.Run(ctx)
+
+	// Events follow the job through its phases until the stream closes
+	var phases, logs []string
+	for event := range events {
+		if event.Type == DeploymentEventJob {
+			phases = append(phases, event.Job.Phase)
+		} else {
+			logs = append(logs, event.Log.Message)
+		}
+	}
+	assert.Equal(t, []string{DeploymentPublishing, DeploymentPublishing, DeploymentCreating, DeploymentCreating, DeploymentDone}, phases)
+	assert.Contains(t, logs, "uploading contract.wasm")
+	assert.Contains(t, logs, "creating from "+bytecodeID)
+
+	done, ok := manager.Get(job.ID)
+	require.True(t, ok)
+	assert.Equal(t, BytecodeID(bytecodeID), done.BytecodeID)
+	assert.Equal(t, ApplicationID(applicationID), done.ApplicationID)
+	assert.NoDirExists(t, manager.jobDir(job.ID))
+
+	// A job interrupted after publishing resumes with create-application
+	stop()
+	offline, err := NewDeploymentManager(filepath.Join(dir, "deployments"))
+	require.NoError(t, err)
+	interrupted, err := offline.Submit(wasm, wasm, false, false, CreateApplicationOptions{})
+	require.NoError(t, err)
+	offline.update(interrupted.ID, func(job *DeploymentJob) {
+		job.Phase = DeploymentPublishing
+		job.BytecodeID = BytecodeID(bytecodeID)
+	})
+	// A job interrupted while creating its application may have created it,
+	// so it is not created again
+	creating, err := offline.Submit(wasm, wasm, false, false, CreateApplicationOptions{})
+	require.NoError(t, err)
+	offline.update(creating.ID, func(job *DeploymentJob) {
+		job.Phase = DeploymentCreating
+		job.BytecodeID = BytecodeID(bytecodeID)
+		job.CreatingFrom = BytecodeID(bytecodeID)
+	})
+
+	lineraCLI = NewLineraCLI(LineraConfig{Binary: fakeLinera(t, `
+case "$1" in
+publish-bytecode) exit 1 ;;
+create-application) echo `+applicationID+` ;;
+esac`)})
+	restarted, err := NewDeploymentManager(filepath.Join(dir, "deployments"))
+	require.NoError(t, err)
+	go restarted.Run(context.Background())
+	require.Eventually(t, func() bool {
+		job, _ := restarted.Get(interrupted.ID)
+		return job.Finished()
+	}, 5*time.Second, 10*time.Millisecond)
+	resumed, _ := restarted.Get(interrupted.ID)
+	assert.Equal(t, DeploymentDone, resumed.Phase, resumed.Error)
+	assert.Equal(t, ApplicationID(applicationID), resumed.ApplicationID)
+	assert.Equal(t, "Resuming after restart (was publishing)", resumed.Logs[len(resumed.Logs)-4].Message)
+	assert.Empty(t, resumed.CreatingFrom)
+
+	failed, _ := restarted.Get(creating.ID)
+	assert.Equal(t, DeploymentFailed, failed.Phase)
+	assert.Contains(t, failed.Error, "interrupted while creating an application from bytecode "+bytecodeID)
+	assert.Empty(t, failed.ApplicationID)
+
+	// Finished jobs are kept
+	first, ok := restarted.Get(job.ID)
+	require.True(t, ok)
+	assert.Equal(t, DeploymentDone, first.Phase)
+}
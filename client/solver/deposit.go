@@ -0,0 +1,188 @@
+package solver
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/mr-tron/base58"
+)
+
+// memoV1ProgramID is the original SPL Memo program, still accepted by wallets
+var memoV1ProgramID = solana.MustPublicKeyFromBase58("Memo1UhkJRfHyvLMcVucJwxXeuD728EqVDDwQDxFMNo")
+
+// Deposit is a transfer into a pool address found in a chain transaction
+type Deposit struct {
+	Chain     string   `json:"chain"`
+	TxHash    string   `json:"txHash"`
+	Sender    string   `json:"sender"`
+	Recipient string   `json:"recipient"`
+	Amount    *big.Int `json:"amount"`
+	Decimals  int      `json:"decimals"`
+	Symbol    string   `json:"symbol"`
+	Reference string   `json:"reference,omitempty"`
+}
+
+// DecimalAmount converts the base-unit amount to whole tokens
+func (d *Deposit) DecimalAmount() float64 {
+	return Token{Decimals: d.Decimals}.FromBaseUnits(d.Amount)
+}
+
+// solanaTransaction mirrors the parts of a getTransaction response (json
+// encoding) needed to find deposits
+type solanaTransaction struct {
+	Result *struct {
+		Meta *struct {
+			Err             interface{} `json:"err"`
+			LoadedAddresses struct {
+				Writable []string `json:"writable"`
+				Readonly []string `json:"readonly"`
+			} `json:"loadedAddresses"`
+			PreTokenBalances  []solanaTokenBalance `json:"preTokenBalances"`
+			PostTokenBalances []solanaTokenBalance `json:"postTokenBalances"`
+		} `json:"meta"`
+		Transaction struct {
+			Signatures []string `json:"signatures"`
+			Message    struct {
+				AccountKeys  []string `json:"accountKeys"`
+				Instructions []struct {
+					ProgramIDIndex int    `json:"programIdIndex"`
+					Accounts       []int  `json:"accounts"`
+					Data           string `json:"data"`
+				} `json:"instructions"`
+			} `json:"message"`
+		} `json:"transaction"`
+	} `json:"result"`
+}
+
+func decodeSolanaTransaction(tx interface{}) (*solanaTransaction, error) {
+	// Re-encode and decode to get a typed view of the RPC response
+	jsonData, err := json.Marshal(tx)
+	if err != nil {
+		return nil, fmt.Errorf("error re-encoding transaction: %w", err)
+	}
+
+	var decoded solanaTransaction
+	if err := json.Unmarshal(jsonData, &decoded); err != nil {
+		return nil, fmt.Errorf("error parsing transaction: %w", err)
+	}
+	if decoded.Result == nil || decoded.Result.Meta == nil {
+		return nil, fmt.Errorf("transaction not found")
+	}
+	return &decoded, nil
+}
+
+// accountKeys returns the static account keys followed by any keys loaded
+// from address lookup tables, matching instruction account indexes
+func (t *solanaTransaction) accountKeys() []string {
+	keys := append([]string{}, t.Result.Transaction.Message.AccountKeys...)
+	keys = append(keys, t.Result.Meta.LoadedAddresses.Writable...)
+	keys = append(keys, t.Result.Meta.LoadedAddresses.Readonly...)
+	return keys
+}
+
+// memo returns the text of the first memo instruction in the transaction
+func (t *solanaTransaction) memo() string {
+	keys := t.accountKeys()
+	for _, inst := range t.Result.Transaction.Message.Instructions {
+		if inst.ProgramIDIndex >= len(keys) {
+			continue
+		}
+		programID := keys[inst.ProgramIDIndex]
+		if programID != solana.MemoProgramID.String() && programID != memoV1ProgramID.String() {
+			continue
+		}
+		data, err := base58.Decode(inst.Data)
+		if err != nil {
+			continue
+		}
+		if memo := strings.TrimSpace(string(data)); memo != "" {
+			return memo
+		}
+	}
+	return ""
+}
+
+// SolanaMemo returns the memo attached to a Solana transaction, if any
+func SolanaMemo(tx interface{}) string {
+	decoded, err := decodeSolanaTransaction(tx)
+	if err != nil {
+		return ""
+	}
+	return decoded.memo()
+}
+
+// ParseSolanaDeposit finds the deposit a getTransaction response made into a
+// pool, keyed by token symbol in poolByToken. System program transfers into the
+// SOL pool take precedence over SPL token balance changes. The swap reference
+// carried in the memo instruction is returned with the deposit.
+func ParseSolanaDeposit(tx interface{}, poolByToken map[string]string) (*Deposit, error) {
+	decoded, err := decodeSolanaTransaction(tx)
+	if err != nil {
+		return nil, err
+	}
+	if decoded.Result.Meta.Err != nil {
+		return nil, fmt.Errorf("transaction failed: %v", decoded.Result.Meta.Err)
+	}
+
+	if poolAddress, ok := poolByToken["SOL"]; ok {
+		deposit, err := parseSOLDeposit(decoded, poolAddress)
+		if !errors.Is(err, errNoSOLTransfer) {
+			return deposit, err
+		}
+	}
+	return parseSPLDeposit(decoded, poolByToken)
+}
+
+// errNoSOLTransfer is returned when a transaction makes no system transfer to the pool
+var errNoSOLTransfer = errors.New("no SOL transfer to pool found in transaction")
+
+// parseSOLDeposit sums the system program transfers to poolAddress
+func parseSOLDeposit(decoded *solanaTransaction, poolAddress string) (*Deposit, error) {
+	deposit := &Deposit{
+		Chain:     "solana",
+		Recipient: poolAddress,
+		Amount:    new(big.Int),
+		Decimals:  9,
+		Symbol:    "SOL",
+		Reference: decoded.memo(),
+	}
+	if len(decoded.Result.Transaction.Signatures) > 0 {
+		deposit.TxHash = decoded.Result.Transaction.Signatures[0]
+	}
+
+	keys := decoded.accountKeys()
+	for _, inst := range decoded.Result.Transaction.Message.Instructions {
+		if inst.ProgramIDIndex >= len(keys) || keys[inst.ProgramIDIndex] != solana.SystemProgramID.String() {
+			continue
+		}
+		if len(inst.Accounts) < 2 || inst.Accounts[0] >= len(keys) || inst.Accounts[1] >= len(keys) {
+			continue
+		}
+
+		// System transfer: u32 instruction index (2) followed by u64 lamports
+		data, err := base58.Decode(inst.Data)
+		if err != nil || len(data) != 12 || binary.LittleEndian.Uint32(data[:4]) != 2 {
+			continue
+		}
+		if keys[inst.Accounts[1]] != poolAddress {
+			continue
+		}
+
+		from := keys[inst.Accounts[0]]
+		if deposit.Sender != "" && deposit.Sender != from {
+			return nil, fmt.Errorf("transaction contains transfers to the pool from multiple senders")
+		}
+		deposit.Sender = from
+		deposit.Amount.Add(deposit.Amount, new(big.Int).SetUint64(binary.LittleEndian.Uint64(data[4:])))
+	}
+
+	if deposit.Sender == "" {
+		return nil, errNoSOLTransfer
+	}
+	return deposit, nil
+}
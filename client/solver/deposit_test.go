@@ -0,0 +1,357 @@
+package solver
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/mr-tron/base58"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testSolanaSender = "3h1zGmCwsRJnVk5BuRNMLsPaQu1y2aqXqXDWYCgrp5UG"
+	testSolanaOther  = "2Qv1eJ5d8mW8J6rAHrajXgbEZNyAyBkZZDaRtHhh8KVW"
+	testSolanaPool   = "9xQeWvG816bUx9EPjHmaT23yvVM2ZWbrrpZb9PusVFin"
+)
+
+// testSolanaTx builds a getTransaction response (json encoding) with the given
+// account keys, instructions and token balances
+func testSolanaTx(keys []string, instructions []map[string]interface{}, pre, post []map[string]interface{}) map[string]interface{} {
+	if pre == nil {
+		pre = []map[string]interface{}{}
+	}
+	if post == nil {
+		post = []map[string]interface{}{}
+	}
+	return map[string]interface{}{
+		"result": map[string]interface{}{
+			"meta": map[string]interface{}{
+				"err":               nil,
+				"preTokenBalances":  pre,
+				"postTokenBalances": post,
+			},
+			"transaction": map[string]interface{}{
+				"signatures": []string{"5VERv8NMvzbJMEkV8xnrLkEaWRtSz9CosKDYjCJjBRnbJLgp8uirBgmQpjKhoR4tjF3ZpRzrFmBV6UjKdiSZkQUW"},
+				"message": map[string]interface{}{
+					"accountKeys":  keys,
+					"instructions": instructions,
+				},
+			},
+		},
+	}
+}
+
+// testSystemTransfer is a system program transfer between account indexes,
+// with the system program at programIndex
+func testSystemTransfer(programIndex, from, to int, lamports uint64) map[string]interface{} {
+	data := make([]byte, 12)
+	binary.LittleEndian.PutUint32(data, 2)
+	binary.LittleEndian.PutUint64(data[4:], lamports)
+	return map[string]interface{}{"programIdIndex": programIndex, "accounts": []int{from, to}, "data": base58.Encode(data)}
+}
+
+func testMemo(programIndex int, memo string) map[string]interface{} {
+	return map[string]interface{}{"programIdIndex": programIndex, "accounts": []int{}, "data": base58.Encode([]byte(memo))}
+}
+
+func TestParseSolanaDeposit(t *testing.T) {
+	system := solana.SystemProgramID.String()
+	memo := solana.MemoProgramID.String()
+	pools := map[string]string{"SOL": testSolanaPool}
+
+	tests := []struct {
+		name          string
+		keys          []string
+		instructions  []map[string]interface{}
+		wantErr       string
+		wantSender    string
+		wantAmount    int64
+		wantReference string
+	}{
+		{
+			name:         "transfer without memo",
+			keys:         []string{testSolanaSender, testSolanaPool, system},
+			instructions: []map[string]interface{}{testSystemTransfer(2, 0, 1, 1_500_000_000)},
+			wantSender:   testSolanaSender,
+			wantAmount:   1_500_000_000,
+		},
+		{
+			name: "transfer with memo",
+			keys: []string{testSolanaSender, testSolanaPool, system, memo},
+			instructions: []map[string]interface{}{
+				testSystemTransfer(2, 0, 1, 1_000),
+				testMemo(3, " us-0123456789abcdef01234567 "),
+			},
+			wantSender:    testSolanaSender,
+			wantAmount:    1_000,
+			wantReference: "us-0123456789abcdef01234567",
+		},
+		{
+			name: "memo through the v1 program",
+			keys: []string{testSolanaSender, testSolanaPool, system, memoV1ProgramID.String()},
+			instructions: []map[string]interface{}{
+				testMemo(3, "us-v1"),
+				testSystemTransfer(2, 0, 1, 1_000),
+			},
+			wantSender:    testSolanaSender,
+			wantAmount:    1_000,
+			wantReference: "us-v1",
+		},
+		{
+			name:         "transfer to another account",
+			keys:         []string{testSolanaSender, testSolanaOther, system},
+			instructions: []map[string]interface{}{testSystemTransfer(2, 0, 1, 1_000)},
+			wantErr:      "no deposit to a pool found",
+		},
+		{
+			name: "transfers from one sender are summed",
+			keys: []string{testSolanaSender, testSolanaPool, system, testSolanaOther},
+			instructions: []map[string]interface{}{
+				testSystemTransfer(2, 0, 1, 1_000),
+				testSystemTransfer(2, 0, 3, 5_000),
+				testSystemTransfer(2, 0, 1, 2_000),
+			},
+			wantSender: testSolanaSender,
+			wantAmount: 3_000,
+		},
+		{
+			name: "transfers from several senders",
+			keys: []string{testSolanaSender, testSolanaPool, system, testSolanaOther},
+			instructions: []map[string]interface{}{
+				testSystemTransfer(2, 0, 1, 1_000),
+				testSystemTransfer(2, 3, 1, 2_000),
+			},
+			wantErr: "multiple senders",
+		},
+		{
+			name: "not a system program transfer",
+			keys: []string{testSolanaSender, testSolanaPool, solana.TokenProgramID.String()},
+			instructions: []map[string]interface{}{
+				testSystemTransfer(2, 0, 1, 1_000),
+			},
+			wantErr: "no deposit to a pool found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx := testSolanaTx(tt.keys, tt.instructions, nil, nil)
+			assert.Equal(t, tt.wantReference, SolanaMemo(tx))
+
+			deposit, err := ParseSolanaDeposit(tx, pools)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "SOL", deposit.Symbol)
+			assert.Equal(t, testSolanaPool, deposit.Recipient)
+			assert.Equal(t, tt.wantSender, deposit.Sender)
+			assert.Equal(t, tt.wantAmount, deposit.Amount.Int64())
+			assert.Equal(t, tt.wantReference, deposit.Reference)
+		})
+	}
+
+	// Failed transactions deposit nothing
+	failed := testSolanaTx([]string{testSolanaSender, testSolanaPool, system}, []map[string]interface{}{testSystemTransfer(2, 0, 1, 1_000)}, nil, nil)
+	failed["result"].(map[string]interface{})["meta"].(map[string]interface{})["err"] = map[string]interface{}{"InstructionError": []interface{}{0, "Custom"}}
+	_, err := ParseSolanaDeposit(failed, pools)
+	assert.ErrorContains(t, err, "transaction failed")
+
+	assert.Equal(t, "", SolanaMemo(map[string]interface{}{"result": nil}))
+}
+
+func TestIntentStoreClaim(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "swap_intents.json")
+
+	tests := []struct {
+		name string
+		ttl  time.Duration
+		run  func(t *testing.T, store *IntentStore, reference string)
+	}{
+		{
+			name: "double claim",
+			ttl:  time.Hour,
+			run: func(t *testing.T, store *IntentStore, reference string) {
+				_, err := store.Claim(reference, "tx-1")
+				require.NoError(t, err)
+				_, err = store.Claim(reference, "tx-2")
+				assert.ErrorContains(t, err, "already claimed")
+
+				intent, ok := store.Get(reference)
+				require.True(t, ok)
+				assert.Equal(t, "tx-1", intent.DepositTxHash)
+			},
+		},
+		{
+			name: "release then reclaim",
+			ttl:  time.Hour,
+			run: func(t *testing.T, store *IntentStore, reference string) {
+				_, err := store.Claim(reference, "tx-1")
+				require.NoError(t, err)
+				require.NoError(t, store.Release(reference))
+
+				intent, ok := store.Get(reference)
+				require.True(t, ok)
+				assert.Equal(t, IntentStatusPending, intent.Status)
+				assert.Empty(t, intent.DepositTxHash)
+
+				claimed, err := store.Claim(reference, "tx-2")
+				require.NoError(t, err)
+				assert.Equal(t, "tx-2", claimed.DepositTxHash)
+			},
+		},
+		{
+			name: "complete then claim",
+			ttl:  time.Hour,
+			run: func(t *testing.T, store *IntentStore, reference string) {
+				_, err := store.Claim(reference, "tx-1")
+				require.NoError(t, err)
+				require.NoError(t, store.Complete(reference, "payout-1"))
+				_, err = store.Claim(reference, "tx-2")
+				assert.ErrorContains(t, err, "already completed")
+
+				// The payout survives a restart
+				reloaded, err := NewIntentStore(path, time.Hour)
+				require.NoError(t, err)
+				intent, ok := reloaded.Get(reference)
+				require.True(t, ok)
+				assert.Equal(t, IntentStatusCompleted, intent.Status)
+				assert.Equal(t, "payout-1", intent.PayoutTxHash)
+			},
+		},
+		{
+			name: "claim an expired intent",
+			ttl:  -time.Second,
+			run: func(t *testing.T, store *IntentStore, reference string) {
+				_, err := store.Claim(reference, "tx-1")
+				assert.ErrorContains(t, err, "has expired")
+			},
+		},
+		{
+			name: "unknown reference",
+			ttl:  time.Hour,
+			run: func(t *testing.T, store *IntentStore, reference string) {
+				_, err := store.Claim("us-unknown", "tx-1")
+				assert.ErrorContains(t, err, "unknown swap reference")
+				assert.Error(t, store.Release("us-unknown"))
+				assert.Error(t, store.Complete("us-unknown", "payout-1"))
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, err := NewIntentStore(path, tt.ttl)
+			require.NoError(t, err)
+			intent, err := store.Create("solana", "SOL", "ETH", "0x742d35Cc6634C0532925a3b844Bc454e4438f44e", nil)
+			require.NoError(t, err)
+			assert.Equal(t, IntentStatusPending, intent.Status)
+			tt.run(t, store, intent.Reference)
+		})
+	}
+}
+
+func TestIntentStoreRecords(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "swap_intents.json")
+	store, err := NewIntentStore(path, time.Hour)
+	require.NoError(t, err)
+	destination := "0x742d35Cc6634C0532925a3b844Bc454e4438f44e"
+	payout := func(reference, recipient string) SigningRecord {
+		return SigningRecord{Kind: SigningRecordSwap, Reference: reference, Chain: "ethereum", Recipient: recipient, Asset: "ETH", Amount: big.NewInt(1)}
+	}
+	ctx := context.Background()
+
+	// Deposits made without an intent are claimed under their hash, once
+	claimed, err := store.ClaimDeposit("solana", "SOL", "ETH", destination, "sig-1")
+	require.NoError(t, err)
+	assert.Equal(t, "sig-1", claimed.Reference)
+	assert.Equal(t, IntentStatusClaimed, claimed.Status)
+	_, err = store.ClaimDeposit("solana", "SOL", "ETH", destination, "sig-1")
+	assert.ErrorContains(t, err, "already claimed")
+
+	// Payouts must match a claimed intent
+	payoutVerifier, sweepVerifier := IntentVerifiers(path)
+	assert.NoError(t, store.VerifyPayout(ctx, payout("sig-1", destination)))
+	assert.NoError(t, payoutVerifier(ctx, payout("sig-1", "0x742D35CC6634C0532925A3B844BC454E4438F44E")))
+	assert.ErrorContains(t, payoutVerifier(ctx, payout("sig-1", "0x0000000000000000000000000000000000000001")), "swap pays out to")
+	assert.ErrorIs(t, payoutVerifier(ctx, payout("sig-2", destination)), ErrUnknownRecord)
+
+	// A released deposit is claimed again, but not paid out meanwhile
+	require.NoError(t, store.Release("sig-1"))
+	assert.ErrorContains(t, store.VerifyPayout(ctx, payout("sig-1", destination)), "not claimed")
+	_, err = store.ClaimDeposit("solana", "SOL", "ETH", destination, "sig-1")
+	require.NoError(t, err)
+	require.NoError(t, store.Complete("sig-1", "0xpayout"))
+	assert.ErrorContains(t, payoutVerifier(ctx, payout("sig-1", destination)), "completed")
+
+	// Intents with a deposit address match their payout by deposit hash and
+	// are swept once paid out
+	derive := func(chain string, index uint32) (string, error) { return "0xdepositaddress", nil }
+	intent, err := store.Create("ethereum", "ETH", "ETH", destination, derive)
+	require.NoError(t, err)
+	sweep := SigningRecord{Kind: SigningRecordSweep, Reference: intent.Reference, Chain: "ethereum", Recipient: "0xpool", Asset: "ETH"}
+	_, err = store.Claim(intent.Reference, "0xdeposit")
+	require.NoError(t, err)
+	assert.NoError(t, payoutVerifier(ctx, payout("0xdeposit", destination)))
+	assert.ErrorContains(t, sweepVerifier(ctx, sweep), "not paid out")
+	require.NoError(t, store.Complete(intent.Reference, "0xpayout2"))
+	assert.NoError(t, sweepVerifier(ctx, sweep))
+	assert.NoError(t, store.VerifySweep(ctx, sweep))
+	sweep.Reference = "sig-1"
+	assert.ErrorIs(t, store.VerifySweep(ctx, sweep), ErrUnknownRecord)
+}
+
+func TestIntentStorePrune(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "swap_intents.json")
+	store, err := NewIntentStore(path, -intentRetention-time.Minute)
+	require.NoError(t, err)
+	destination := "0x742d35Cc6634C0532925a3b844Bc454e4438f44e"
+	derive := func(chain string, index uint32) (string, error) { return fmt.Sprintf("0xdeposit%d", index), nil }
+
+	// Intents long past their expiry, unclaimed or claimed
+	unclaimed, err := store.Create("solana", "SOL", "ETH", destination, nil)
+	require.NoError(t, err)
+	claimed, err := store.Create("solana", "SOL", "ETH", destination, nil)
+	require.NoError(t, err)
+	// Claimed before it expired
+	store.intents[claimed.Reference].Status = IntentStatusClaimed
+	first, err := store.Create("ethereum", "ETH", "ETH", destination, derive)
+	require.NoError(t, err)
+	require.NoError(t, store.Expire(first.Reference))
+	last, err := store.Create("ethereum", "ETH", "ETH", destination, derive)
+	require.NoError(t, err)
+	require.NoError(t, store.Expire(last.Reference))
+
+	// Creating an intent prunes the unclaimed ones, except the one holding
+	// the highest deposit index
+	_, err = store.Create("solana", "SOL", "ETH", destination, nil)
+	require.NoError(t, err)
+	reloaded, err := NewIntentStore(path, time.Hour)
+	require.NoError(t, err)
+	for reference, want := range map[string]bool{unclaimed.Reference: false, claimed.Reference: true, first.Reference: false, last.Reference: true} {
+		_, ok := reloaded.Get(reference)
+		assert.Equal(t, want, ok, reference)
+	}
+	next, err := reloaded.Create("ethereum", "ETH", "ETH", destination, derive)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(2), *next.DepositIndex)
+
+	// Unexpired pending intents are capped
+	capped, err := NewIntentStore("", time.Hour)
+	require.NoError(t, err)
+	for i := 0; i < maxPendingIntents-1; i++ {
+		capped.intents[fmt.Sprintf("us-%d", i)] = &SwapIntent{Status: IntentStatusPending, ExpiresAt: time.Now().Add(time.Hour)}
+	}
+	_, err = capped.Create("solana", "SOL", "ETH", destination, nil)
+	require.NoError(t, err)
+	_, err = capped.Create("solana", "SOL", "ETH", destination, nil)
+	assert.ErrorIs(t, err, ErrTooManyIntents)
+}
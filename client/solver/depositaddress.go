@@ -0,0 +1,203 @@
+package solver
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+	"github.com/linera-protocol/examples/universal-solver/client/solver/keys"
+)
+
+// depositGracePeriod is how long past its expiry a deposit address is still
+// watched for a late deposit
+const depositGracePeriod = time.Hour
+
+// DeriveDepositAddress derives the deposit account with the given HD index on
+// chain and returns its address. Deposit addresses only accept the chain's
+// native asset.
+func DeriveDepositAddress(chain string, index uint32) (string, error) {
+	signer, err := currentSigner()
+	if err != nil {
+		return "", err
+	}
+	spec, err := keys.DepositAccountSpec(chain, index)
+	if err != nil {
+		return "", err
+	}
+	return signer.DeriveAccount(context.Background(), spec)
+}
+
+// RestoreDepositAccounts derives the deposit accounts of intents that are not
+// swept yet, so the signer holds their keys again after a restart
+func RestoreDepositAccounts(intents *IntentStore) error {
+	for _, intent := range intents.WithDepositAddress(IntentStatusPending, IntentStatusClaimed, IntentStatusCompleted) {
+		address, err := DeriveDepositAddress(intent.Chain, *intent.DepositIndex)
+		if err != nil {
+			return fmt.Errorf("failed to derive deposit address of %s: %w", intent.Reference, err)
+		}
+		if address != intent.DepositAddress {
+			return fmt.Errorf("deposit address of %s derived as %s, expected %s", intent.Reference, address, intent.DepositAddress)
+		}
+	}
+	return nil
+}
+
+// depositBalance returns the confirmed native balance of a deposit address in
+// base units, so a deposit reorganized away after its payout cannot have been
+// counted
+func (c *Client) depositBalance(ctx context.Context, chain, address string) (*big.Int, error) {
+	switch chain {
+	case keys.ChainEthereum:
+		client, err := ethclient.Dial(EthereumRPC)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to Ethereum node: %w", err)
+		}
+		defer client.Close()
+		head, err := client.BlockNumber(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get block number: %w", err)
+		}
+		// The balance as of the newest block with enough confirmations
+		if head+1 < ethereumMinConfirmations {
+			return new(big.Int), nil
+		}
+		block := new(big.Int).SetUint64(head + 1 - ethereumMinConfirmations)
+		balance, err := client.BalanceAt(ctx, common.HexToAddress(address), block)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get balance: %w", err)
+		}
+		return balance, nil
+	case keys.ChainSolana:
+		pubKey, err := solana.PublicKeyFromBase58(address)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Solana address: %w", err)
+		}
+		balance, err := rpc.New(SolanaRPC).GetBalance(ctx, pubKey, rpc.CommitmentFinalized)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get balance: %w", err)
+		}
+		return new(big.Int).SetUint64(balance.Value), nil
+	case keys.ChainBitcoin:
+		utxos, _, err := c.bitcoinUnspents(address)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get balance: %w", err)
+		}
+		total := new(big.Int)
+		for _, utxo := range utxos {
+			if utxo.Confirmations >= bitcoinMinConfirmations {
+				total.Add(total, big.NewInt(utxo.Amount))
+			}
+		}
+		return total, nil
+	default:
+		return nil, fmt.Errorf("unsupported chain: %s", chain)
+	}
+}
+
+// DepositWatcher pays out swap intents once their deposit address is funded
+// and sweeps each deposit into the pool after its payout
+type DepositWatcher struct {
+	client   *Client
+	intents  *IntentStore
+	interval time.Duration
+}
+
+// NewDepositWatcher returns a watcher polling the deposit addresses of intents
+// every interval
+func NewDepositWatcher(client *Client, intents *IntentStore, interval time.Duration) *DepositWatcher {
+	return &DepositWatcher{client: client, intents: intents, interval: interval}
+}
+
+// Run polls until ctx is done
+func (w *DepositWatcher) Run(ctx context.Context) {
+	Logger.Printf("Watching deposit addresses every %s", w.interval)
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		w.Poll(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Poll pays out every funded pending intent and sweeps every paid out one.
+// Intents just past their expiry are still paid out: their deposit address
+// belongs to them alone, so a late deposit is swapped at the current rate
+// rather than left stranded. After depositGracePeriod they are marked expired
+// and no longer polled. Failed sweeps are retried on the next poll.
+func (w *DepositWatcher) Poll(ctx context.Context) {
+	now := time.Now()
+	for _, intent := range w.intents.WithDepositAddress(IntentStatusPending) {
+		if now.After(intent.ExpiresAt.Add(depositGracePeriod)) {
+			if err := w.intents.Expire(intent.Reference); err != nil {
+				Logger.Printf("Failed to expire swap %s: %v", intent.Reference, err)
+			}
+			continue
+		}
+		if err := w.payOut(ctx, intent); err != nil {
+			Logger.Printf("Failed to pay out swap %s: %v", intent.Reference, err)
+		}
+	}
+	for _, intent := range w.intents.WithDepositAddress(IntentStatusCompleted) {
+		if err := w.sweep(ctx, intent); err != nil {
+			Logger.Printf("Failed to sweep deposit address %s: %v", intent.DepositAddress, err)
+		}
+	}
+}
+
+// payOut swaps whatever the deposit address of intent holds
+func (w *DepositWatcher) payOut(ctx context.Context, intent *SwapIntent) error {
+	balance, err := w.client.depositBalance(ctx, intent.Chain, intent.DepositAddress)
+	if err != nil {
+		return err
+	}
+	if balance.Sign() == 0 {
+		return nil
+	}
+	token, ok := LookupToken(intent.FromToken)
+	if !ok {
+		return fmt.Errorf("unknown token: %s", intent.FromToken)
+	}
+	amount := token.FromBaseUnits(balance)
+	Logger.Printf("Deposit of %g %s received at %s for swap %s", amount, token.Symbol, intent.DepositAddress, intent.Reference)
+	if time.Now().After(intent.ExpiresAt) {
+		Logger.Printf("Swap %s expired at %s, paying out the late deposit at the current rate", intent.Reference, intent.ExpiresAt.Format(time.RFC3339))
+	}
+
+	if _, err := w.intents.ClaimFunded(intent.Reference); err != nil {
+		return err
+	}
+	// The intent reference identifies the deposit to the signing policy
+	swapResponse, err := w.client.ExecuteSwap(intent.Reference, intent.FromToken, intent.ToToken, amount, intent.DestinationAddress)
+	if err != nil {
+		if releaseErr := w.intents.Release(intent.Reference); releaseErr != nil {
+			Logger.Printf("Failed to release swap reference %s: %v", intent.Reference, releaseErr)
+		}
+		return err
+	}
+	if err := w.intents.Complete(intent.Reference, swapResponse.TxHash); err != nil {
+		return err
+	}
+	Logger.Printf("Paid out swap %s in %s", intent.Reference, swapResponse.TxHash)
+	return nil
+}
+
+// sweep empties the deposit address of a paid out intent into the pool
+func (w *DepositWatcher) sweep(ctx context.Context, intent *SwapIntent) error {
+	txHash, err := w.client.SweepDeposit(ctx, intent)
+	if err != nil {
+		return err
+	}
+	if txHash != "" {
+		Logger.Printf("Swept deposit address %s into the pool in %s", intent.DepositAddress, txHash)
+	}
+	return w.intents.Swept(intent.Reference, txHash)
+}
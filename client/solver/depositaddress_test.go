@@ -0,0 +1,261 @@
+package solver
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/linera-protocol/examples/universal-solver/client/solver/keys"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDepositAddresses(t *testing.T) {
+	chainKeys := testChainKeys(t)
+	SetSigner(NewLocalSigner(chainKeys))
+
+	path := filepath.Join(t.TempDir(), "swap_intents.json")
+	intents, err := NewIntentStore(path, 0)
+	require.NoError(t, err)
+
+	// Each intent gets the next HD index and its own address
+	first, err := intents.Create("ethereum", "ETH", "SOL", "destination", DeriveDepositAddress)
+	require.NoError(t, err)
+	second, err := intents.Create("ethereum", "ETH", "SOL", "destination", DeriveDepositAddress)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(0), *first.DepositIndex)
+	assert.Equal(t, uint32(1), *second.DepositIndex)
+	assert.NotEqual(t, first.DepositAddress, second.DepositAddress)
+	account, ok := chainKeys.AccountByAddress(keys.ChainEthereum, first.DepositAddress)
+	require.True(t, ok)
+	assert.Equal(t, "m/44'/60'/100'/0/0", account.Path)
+
+	// Intents without a deriver have no deposit address
+	reference, err := intents.Create("solana", "SOL", "ETH", "destination", nil)
+	require.NoError(t, err)
+	assert.Nil(t, reference.DepositIndex)
+
+	// Indexes are not reused after a restart, and swept intents are not rederived
+	require.NoError(t, intents.Swept(first.Reference, ""))
+	restarted, err := NewIntentStore(path, 0)
+	require.NoError(t, err)
+	third, err := restarted.Create("bitcoin", "BTC", "ETH", "destination", DeriveDepositAddress)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(2), *third.DepositIndex)
+	assert.Len(t, restarted.WithDepositAddress(IntentStatusPending), 2)
+
+	SetSigner(NewLocalSigner(testChainKeys(t)))
+	require.NoError(t, RestoreDepositAccounts(restarted))
+	_, err = signer.Address(context.Background(), keys.ChainEthereum, "deposit-0")
+	assert.ErrorIs(t, err, ErrUnknownAccount)
+	_, err = signer.Address(context.Background(), keys.ChainEthereum, "deposit-1")
+	assert.NoError(t, err)
+}
+
+// fakeSolver serves the solver queries a payout makes: the pools by token,
+// swap quotes at rate and the swap mutation
+func fakeSolver(t *testing.T, pools map[string]string, rate float64) *httptest.Server {
+	amountPattern := regexp.MustCompile(`amount:([0-9.]+)`)
+	tokensPattern := regexp.MustCompile(`fromToken:\\"([^\\]+)\\",toToken:\\"([^\\]+)\\"`)
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		query := string(body)
+		switch {
+		case strings.Contains(query, "getAllPools"):
+			var result []map[string]string
+			for token, address := range pools {
+				result = append(result, map[string]string{"chainName": token, "poolAddress": address})
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]interface{}{"getAllPools": result}})
+		case strings.Contains(query, "calculateSwap"):
+			match := amountPattern.FindStringSubmatch(query)
+			require.Len(t, match, 2)
+			amount, err := strconv.ParseFloat(match[1], 64)
+			require.NoError(t, err)
+			tokens := tokensPattern.FindStringSubmatch(query)
+			require.Len(t, tokens, 3)
+			json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]interface{}{"calculateSwap": map[string]interface{}{
+				"fromToken": tokens[1], "toToken": tokens[2], "fromAmount": amount, "toAmount": amount * rate, "exchangeRate": rate,
+			}}})
+		case strings.Contains(query, "mutation"):
+			json.NewEncoder(w).Encode(map[string]interface{}{"data": "swapped"})
+		default:
+			t.Fatalf("unexpected solver query %s", query)
+		}
+	}))
+}
+
+// fakeEthereumNode is an Ethereum node at block head. Addresses are credited
+// at the block of their deposit and debited by the transactions sent.
+type fakeEthereumNode struct {
+	t        *testing.T
+	head     uint64
+	gasPrice *big.Int
+	deposits map[common.Address]fakeDeposit
+	sent     []*types.Transaction
+}
+
+type fakeDeposit struct {
+	block  uint64
+	amount *big.Int
+}
+
+func newFakeEthereumNode(t *testing.T, head uint64) *fakeEthereumNode {
+	return &fakeEthereumNode{t: t, head: head, gasPrice: big.NewInt(1e9), deposits: make(map[common.Address]fakeDeposit)}
+}
+
+func (n *fakeEthereumNode) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req fakeRPCRequest
+	require.NoError(n.t, json.NewDecoder(r.Body).Decode(&req))
+	var result interface{}
+	switch req.Method {
+	case "eth_blockNumber":
+		result = hexutil.EncodeUint64(n.head)
+	case "eth_getBalance":
+		var address common.Address
+		var block string
+		require.NoError(n.t, json.Unmarshal(req.Params[0], &address))
+		require.NoError(n.t, json.Unmarshal(req.Params[1], &block))
+		result = (*hexutil.Big)(n.balance(address, block))
+	case "eth_gasPrice":
+		result = (*hexutil.Big)(n.gasPrice)
+	case "eth_getTransactionCount":
+		result = hexutil.EncodeUint64(uint64(len(n.sent)))
+	case "eth_estimateGas":
+		result = hexutil.EncodeUint64(ethereumTransferGas)
+	case "net_version":
+		result = "1337"
+	case "eth_sendRawTransaction":
+		var raw hexutil.Bytes
+		require.NoError(n.t, json.Unmarshal(req.Params[0], &raw))
+		tx := new(types.Transaction)
+		require.NoError(n.t, tx.UnmarshalBinary(raw))
+		n.sent = append(n.sent, tx)
+		result = tx.Hash()
+	default:
+		n.t.Fatalf("unexpected method %s", req.Method)
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"jsonrpc": "2.0", "id": req.ID, "result": result})
+}
+
+// balance is what address holds at block, less what it sent since
+func (n *fakeEthereumNode) balance(address common.Address, block string) *big.Int {
+	at := n.head
+	if block != "latest" {
+		number, err := hexutil.DecodeUint64(block)
+		require.NoError(n.t, err)
+		at = number
+	}
+	balance := new(big.Int)
+	if deposit, ok := n.deposits[address]; ok && deposit.block <= at {
+		balance.Set(deposit.amount)
+	}
+	for _, tx := range n.sent {
+		from, err := types.Sender(types.LatestSignerForChainID(tx.ChainId()), tx)
+		require.NoError(n.t, err)
+		if from == address {
+			balance.Sub(balance, tx.Value())
+			balance.Sub(balance, new(big.Int).Mul(tx.GasPrice(), new(big.Int).SetUint64(tx.Gas())))
+		}
+	}
+	return balance
+}
+
+func TestDepositWatcher(t *testing.T) {
+	chainKeys := testChainKeys(t)
+	SetSigner(NewLocalSigner(chainKeys))
+	defer SetSigner(NewLocalSigner(chainKeys))
+	require.NoError(t, InitEthereumConfirmations(3))
+	defer InitEthereumConfirmations(1)
+
+	pool := "0x9858EfFD232B4033E47d90003D41EC34EcaEda94"
+	destination := "0x6Fac4D18c912343BF86fa7049364Dd4E424Ab9C0"
+	solverServer := fakeSolver(t, map[string]string{"ETH": pool}, 0.5)
+	defer solverServer.Close()
+	node := newFakeEthereumNode(t, 10)
+	ethServer := httptest.NewServer(node)
+	defer ethServer.Close()
+	InitRPCEndpoints(ethServer.URL, "")
+	client := NewClient(solverServer.URL)
+
+	// Intents expire at once, their deposits are still paid out
+	intents, err := NewIntentStore(filepath.Join(t.TempDir(), "swap_intents.json"), -time.Second)
+	require.NoError(t, err)
+	intent, err := intents.Create("ethereum", "ETH", "ETH", destination, DeriveDepositAddress)
+	require.NoError(t, err)
+	watcher := NewDepositWatcher(client, intents, time.Minute)
+
+	// A deposit in block 9 waits for its third confirmation
+	node.deposits[common.HexToAddress(intent.DepositAddress)] = fakeDeposit{block: 9, amount: big.NewInt(1e18)}
+	watcher.Poll(context.Background())
+	assert.Empty(t, node.sent)
+	pending, ok := intents.Get(intent.Reference)
+	require.True(t, ok)
+	assert.Equal(t, IntentStatusPending, pending.Status)
+
+	// Once confirmed it is paid out from the pool at the quoted rate, then
+	// swept into the pool less the fee
+	node.head = 11
+	watcher.Poll(context.Background())
+	require.Len(t, node.sent, 2)
+	payout, sweep := node.sent[0], node.sent[1]
+	assert.Equal(t, destination, payout.To().Hex())
+	assert.Equal(t, big.NewInt(5e17), payout.Value())
+	assert.Equal(t, big.NewInt(1337), payout.ChainId())
+	assert.Equal(t, pool, sweep.To().Hex())
+	fee := new(big.Int).Mul(node.gasPrice, big.NewInt(ethereumTransferGas))
+	assert.Equal(t, new(big.Int).Sub(big.NewInt(1e18), fee), sweep.Value())
+	sweepFrom, err := types.Sender(types.LatestSignerForChainID(sweep.ChainId()), sweep)
+	require.NoError(t, err)
+	assert.Equal(t, intent.DepositAddress, sweepFrom.Hex())
+
+	swept, ok := intents.Get(intent.Reference)
+	require.True(t, ok)
+	assert.Equal(t, IntentStatusSwept, swept.Status)
+	assert.Equal(t, payout.Hash().Hex(), swept.PayoutTxHash)
+	assert.Equal(t, sweep.Hash().Hex(), swept.SweepTxHash)
+
+	// Nothing is paid or swept twice
+	watcher.Poll(context.Background())
+	assert.Len(t, node.sent, 2)
+
+	// Addresses are no longer watched once the grace period is over
+	abandoned, err := intents.Create("ethereum", "ETH", "ETH", destination, DeriveDepositAddress)
+	require.NoError(t, err)
+	intents.intents[abandoned.Reference].ExpiresAt = time.Now().Add(-depositGracePeriod - time.Second)
+	node.deposits[common.HexToAddress(abandoned.DepositAddress)] = fakeDeposit{block: 1, amount: big.NewInt(1e18)}
+	watcher.Poll(context.Background())
+	assert.Len(t, node.sent, 2)
+	expired, ok := intents.Get(abandoned.Reference)
+	require.True(t, ok)
+	assert.Equal(t, IntentStatusExpired, expired.Status)
+
+	// Balances that do not cover the fee are left in place
+	dust, err := intents.Create("ethereum", "ETH", "ETH", destination, DeriveDepositAddress)
+	require.NoError(t, err)
+	node.deposits[common.HexToAddress(dust.DepositAddress)] = fakeDeposit{block: 1, amount: big.NewInt(1000)}
+	txHash, err := client.SweepDeposit(context.Background(), dust)
+	require.NoError(t, err)
+	assert.Empty(t, txHash)
+	assert.Len(t, node.sent, 2)
+
+	// Amounts are swept as asked
+	txHash, err = client.sweepNative(context.Background(), "ethereum", "cold", pool, destination, big.NewInt(2e17))
+	require.NoError(t, err)
+	require.Len(t, node.sent, 3)
+	assert.Equal(t, node.sent[2].Hash().Hex(), txHash)
+	assert.Equal(t, big.NewInt(2e17), node.sent[2].Value())
+}
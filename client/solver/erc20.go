@@ -0,0 +1,188 @@
+package solver
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+// erc20ABI covers the parts of the ERC-20 interface the solver uses
+const erc20ABI = `[
+	{"type":"function","name":"transfer","stateMutability":"nonpayable","inputs":[{"name":"to","type":"address"},{"name":"value","type":"uint256"}],"outputs":[{"name":"","type":"bool"}]},
+	{"type":"function","name":"balanceOf","stateMutability":"view","inputs":[{"name":"owner","type":"address"}],"outputs":[{"name":"","type":"uint256"}]}
+]`
+
+var (
+	erc20           = mustParseABI(erc20ABI)
+	erc20TransferID = crypto.Keccak256Hash([]byte("Transfer(address,address,uint256)"))
+)
+
+func mustParseABI(definition string) abi.ABI {
+	parsed, err := abi.JSON(strings.NewReader(definition))
+	if err != nil {
+		panic(fmt.Sprintf("invalid ABI: %v", err))
+	}
+	return parsed
+}
+
+// GetEthereumDeposit fetches a transaction and its receipt and returns the
+// native ETH or configured ERC-20 transfer it made into the matching pool
+func (c *Client) GetEthereumDeposit(txHash string) (*Deposit, error) {
+	client, err := ethclient.Dial(EthereumRPC)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to Ethereum node: %w", err)
+	}
+	defer client.Close()
+
+	hash := common.HexToHash(txHash)
+	tx, isPending, err := client.TransactionByHash(context.Background(), hash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get Ethereum transaction: %w", err)
+	}
+	if isPending {
+		return nil, fmt.Errorf("transaction %s is still pending", txHash)
+	}
+
+	receipt, err := client.TransactionReceipt(context.Background(), hash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get transaction receipt: %w", err)
+	}
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		return nil, fmt.Errorf("transaction %s failed", txHash)
+	}
+
+	from, err := types.Sender(types.LatestSignerForChainID(tx.ChainId()), tx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to recover transaction sender: %w", err)
+	}
+
+	pools, err := c.GetAllPools()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pools: %w", err)
+	}
+	poolByToken := make(map[string]common.Address)
+	for _, pool := range pools {
+		if common.IsHexAddress(pool.PoolAddress) {
+			poolByToken[pool.ChainName] = common.HexToAddress(pool.PoolAddress)
+		}
+	}
+
+	// Native ETH sent straight to the pool
+	if ethPool, ok := poolByToken["ETH"]; ok && tx.To() != nil && *tx.To() == ethPool && tx.Value().Sign() > 0 {
+		return &Deposit{
+			Chain:     "ethereum",
+			TxHash:    tx.Hash().Hex(),
+			Sender:    from.Hex(),
+			Recipient: ethPool.Hex(),
+			Amount:    tx.Value(),
+			Decimals:  18,
+			Symbol:    "ETH",
+		}, nil
+	}
+
+	// ERC-20 Transfer events into the pool of a configured token
+	deposit, err := parseERC20Deposit(receipt, poolByToken)
+	if err != nil {
+		return nil, err
+	}
+	deposit.TxHash = tx.Hash().Hex()
+	return deposit, nil
+}
+
+// parseERC20Deposit sums the Transfer logs of configured tokens whose
+// recipient is that token's pool. All transfers must be of the same token and
+// from the same sender.
+func parseERC20Deposit(receipt *types.Receipt, poolByToken map[string]common.Address) (*Deposit, error) {
+	var deposit *Deposit
+	for _, log := range receipt.Logs {
+		if len(log.Topics) != 3 || log.Topics[0] != erc20TransferID {
+			continue
+		}
+		token, ok := TokenByAddress("ethereum", log.Address.Hex())
+		if !ok {
+			continue
+		}
+		pool, ok := poolByToken[token.Symbol]
+		if !ok || common.BytesToAddress(log.Topics[2].Bytes()) != pool {
+			continue
+		}
+
+		sender := common.BytesToAddress(log.Topics[1].Bytes()).Hex()
+		if deposit == nil {
+			deposit = &Deposit{
+				Chain:     "ethereum",
+				Sender:    sender,
+				Recipient: pool.Hex(),
+				Amount:    new(big.Int),
+				Decimals:  token.Decimals,
+				Symbol:    token.Symbol,
+			}
+		} else if deposit.Symbol != token.Symbol {
+			return nil, fmt.Errorf("transaction deposits more than one token")
+		} else if deposit.Sender != sender {
+			return nil, fmt.Errorf("transaction contains transfers to the pool from multiple senders")
+		}
+		deposit.Amount.Add(deposit.Amount, new(big.Int).SetBytes(log.Data))
+	}
+
+	if deposit == nil {
+		return nil, fmt.Errorf("no deposit to a pool found in transaction")
+	}
+	return deposit, nil
+}
+
+// GetEthereumTokenBalance fetches the ERC-20 balance of address via balanceOf
+func (c *Client) GetEthereumTokenBalance(address string, token Token) (*Balance, error) {
+	if !common.IsHexAddress(address) {
+		return nil, fmt.Errorf("invalid Ethereum address")
+	}
+
+	client, err := ethclient.Dial(EthereumRPC)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to Ethereum node: %w", err)
+	}
+	defer client.Close()
+
+	data, err := erc20.Pack("balanceOf", common.HexToAddress(address))
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode balanceOf call: %w", err)
+	}
+
+	contract := common.HexToAddress(token.Address)
+	output, err := client.CallContract(context.Background(), ethereum.CallMsg{To: &contract, Data: data}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call balanceOf: %w", err)
+	}
+
+	values, err := erc20.Unpack("balanceOf", output)
+	if err != nil || len(values) != 1 {
+		return nil, fmt.Errorf("failed to decode balanceOf result: %v", err)
+	}
+	balance, ok := values[0].(*big.Int)
+	if !ok {
+		return nil, fmt.Errorf("unexpected balanceOf result type %T", values[0])
+	}
+
+	return &Balance{
+		Address: address,
+		Amount:  token.FromBaseUnits(balance),
+		Symbol:  token.Symbol,
+		Token:   token.Address,
+	}, nil
+}
+
+// encodeERC20Transfer builds the calldata for transfer(to, amount)
+func encodeERC20Transfer(to string, amount *big.Int) ([]byte, error) {
+	if !common.IsHexAddress(to) {
+		return nil, fmt.Errorf("invalid Ethereum address: %s", to)
+	}
+	return erc20.Pack("transfer", common.HexToAddress(to), amount)
+}
@@ -0,0 +1,106 @@
+package solver
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseERC20Deposit(t *testing.T) {
+	usdc := common.HexToAddress("0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48")
+	dai := common.HexToAddress("0x6B175474E89094C44Da98b954EedeAC495271d0F")
+	require.NoError(t, RegisterToken(Token{Chain: "ethereum", Symbol: "TEST-USDC", Address: usdc.Hex(), Decimals: 6}))
+	require.NoError(t, RegisterToken(Token{Chain: "ethereum", Symbol: "TEST-DAI", Address: dai.Hex(), Decimals: 18}))
+
+	pool := common.HexToAddress("0x00000000000000000000000000000000000000aa")
+	sender := common.HexToAddress("0x742d35Cc6634C0532925a3b844Bc454e4438f44e")
+	other := common.HexToAddress("0x00000000000000000000000000000000000000bb")
+	pools := map[string]common.Address{"TEST-USDC": pool, "TEST-DAI": pool}
+
+	transfer := func(token, from, to common.Address, amount int64) *types.Log {
+		return &types.Log{
+			Address: token,
+			Topics:  []common.Hash{erc20TransferID, common.BytesToHash(from.Bytes()), common.BytesToHash(to.Bytes())},
+			Data:    common.LeftPadBytes(big.NewInt(amount).Bytes(), 32),
+		}
+	}
+
+	tests := []struct {
+		name       string
+		logs       []*types.Log
+		wantErr    string
+		wantSymbol string
+		wantAmount int64
+	}{
+		{
+			name:       "single transfer",
+			logs:       []*types.Log{transfer(usdc, sender, pool, 290_000)},
+			wantSymbol: "TEST-USDC",
+			wantAmount: 290_000,
+		},
+		{
+			name: "transfers from one sender are summed",
+			logs: []*types.Log{
+				transfer(usdc, sender, pool, 1_000),
+				transfer(usdc, sender, other, 5_000),
+				transfer(usdc, sender, pool, 2_000),
+			},
+			wantSymbol: "TEST-USDC",
+			wantAmount: 3_000,
+		},
+		{
+			name: "transfers from several senders",
+			logs: []*types.Log{
+				transfer(usdc, sender, pool, 1_000),
+				transfer(usdc, other, pool, 2_000),
+			},
+			wantErr: "multiple senders",
+		},
+		{
+			name: "transfers of several tokens",
+			logs: []*types.Log{
+				transfer(usdc, sender, pool, 1_000),
+				transfer(dai, sender, pool, 2_000),
+			},
+			wantErr: "more than one token",
+		},
+		{
+			name:    "transfer to another account",
+			logs:    []*types.Log{transfer(usdc, sender, other, 1_000)},
+			wantErr: "no deposit to a pool found",
+		},
+		{
+			name:    "unknown token",
+			logs:    []*types.Log{transfer(other, sender, pool, 1_000)},
+			wantErr: "no deposit to a pool found",
+		},
+		{
+			name: "not a Transfer event",
+			logs: []*types.Log{{
+				Address: usdc,
+				Topics:  []common.Hash{common.HexToHash("0x01"), common.BytesToHash(sender.Bytes()), common.BytesToHash(pool.Bytes())},
+				Data:    common.LeftPadBytes(big.NewInt(1_000).Bytes(), 32),
+			}},
+			wantErr: "no deposit to a pool found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deposit, err := parseERC20Deposit(&types.Receipt{Logs: tt.logs}, pools)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantSymbol, deposit.Symbol)
+			assert.Equal(t, sender.Hex(), deposit.Sender)
+			assert.Equal(t, pool.Hex(), deposit.Recipient)
+			assert.Equal(t, tt.wantAmount, deposit.Amount.Int64())
+		})
+	}
+}
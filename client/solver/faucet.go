@@ -0,0 +1,209 @@
+package solver
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+	"github.com/linera-protocol/examples/universal-solver/client/solver/keys"
+)
+
+// Statuses of a FaucetResult
+const (
+	FaucetStatusSubmitted = "submitted"
+	FaucetStatusConfirmed = "confirmed"
+)
+
+const (
+	// faucetConfirmTimeout bounds the wait for a confirmed faucet payment
+	faucetConfirmTimeout = time.Minute
+	// solanaConfirmPoll is how often an airdrop's status is checked
+	solanaConfirmPoll = time.Second
+)
+
+// faucetDefaultAmounts is what a faucet request without an amount sends
+var faucetDefaultAmounts = map[string]float64{
+	keys.ChainEthereum: 1,
+	keys.ChainSolana:   2,
+}
+
+// FaucetDefaultAmount returns the amount sent on chain when a request names none
+func FaucetDefaultAmount(chain string) float64 {
+	return faucetDefaultAmounts[chain]
+}
+
+// FaucetRequest asks the faucet to fund an address
+type FaucetRequest struct {
+	Chain   string
+	Address string
+	// Amount is in whole tokens, zero for the chain's default
+	Amount float64
+	// Confirm waits until the payment is confirmed before returning
+	Confirm bool
+	// GrantID is the FaucetGrant admitting the request, which the signing
+	// policy checks transfers against
+	GrantID string
+}
+
+// FaucetResult is a faucet payment on any chain
+type FaucetResult struct {
+	Chain   string `json:"chain"`
+	Address string `json:"address"`
+	// TxID is the transaction hash or signature of the payment
+	TxID string `json:"txId"`
+	// Amount is a decimal string in base units (wei, lamports), which JSON
+	// numbers cannot hold exactly
+	Amount string `json:"amount"`
+	Symbol string `json:"symbol"`
+	Status string `json:"status"`
+}
+
+// RequestFaucet sends test funds to an address: a SOL airdrop on Solana, a
+// transfer from the faucet account on Ethereum. When a submitted payment
+// fails to confirm, the result is returned along with the error.
+func (c *Client) RequestFaucet(ctx context.Context, req FaucetRequest) (*FaucetResult, error) {
+	amount := req.Amount
+	if amount == 0 {
+		amount = FaucetDefaultAmount(req.Chain)
+	}
+	token, err := nativeToken(req.Chain)
+	if err != nil || amount == 0 {
+		return nil, fmt.Errorf("no faucet on chain: %s", req.Chain)
+	}
+	baseUnits := token.ToBaseUnits(amount)
+	result := &FaucetResult{
+		Chain:   req.Chain,
+		Address: req.Address,
+		Amount:  baseUnits.String(),
+		Symbol:  token.Symbol,
+		Status:  FaucetStatusSubmitted,
+	}
+
+	switch req.Chain {
+	case keys.ChainSolana:
+		err = c.solanaAirdrop(ctx, result, baseUnits, req.Confirm)
+	case keys.ChainEthereum:
+		err = c.ethereumFaucetTransfer(ctx, result, baseUnits, req.GrantID, req.Confirm)
+	}
+	if err != nil && result.TxID == "" {
+		return nil, err
+	}
+	return result, err
+}
+
+// solanaAirdrop requests an airdrop of amount lamports, waiting for it to be
+// confirmed with confirm
+func (c *Client) solanaAirdrop(ctx context.Context, result *FaucetResult, amount *big.Int, confirm bool) error {
+	pubKey, err := solana.PublicKeyFromBase58(result.Address)
+	if err != nil {
+		return fmt.Errorf("invalid Solana address: %w", err)
+	}
+
+	client := rpc.New(SolanaRPC)
+	signature, err := client.RequestAirdrop(ctx, pubKey, amount.Uint64(), rpc.CommitmentFinalized)
+	if err != nil {
+		return fmt.Errorf("failed to request airdrop: %w", err)
+	}
+	result.TxID = signature.String()
+	if !confirm {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, faucetConfirmTimeout)
+	defer cancel()
+	ticker := time.NewTicker(solanaConfirmPoll)
+	defer ticker.Stop()
+	for {
+		statuses, err := client.GetSignatureStatuses(ctx, true, signature)
+		if err != nil {
+			return fmt.Errorf("failed to confirm airdrop %s: %w", result.TxID, err)
+		}
+		if len(statuses.Value) > 0 && statuses.Value[0] != nil {
+			status := statuses.Value[0]
+			if status.Err != nil {
+				return fmt.Errorf("airdrop %s failed: %v", result.TxID, status.Err)
+			}
+			if status.ConfirmationStatus == rpc.ConfirmationStatusConfirmed ||
+				status.ConfirmationStatus == rpc.ConfirmationStatusFinalized {
+				result.Status = FaucetStatusConfirmed
+				return nil
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("failed to confirm airdrop %s: %w", result.TxID, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// ethereumFaucetTransfer sends amount wei from the faucet account, or
+// the pool when none is configured, paying grantID and waiting for it to be
+// mined with confirm
+func (c *Client) ethereumFaucetTransfer(ctx context.Context, result *FaucetResult, amount *big.Int, grantID string, confirm bool) error {
+	if !common.IsHexAddress(result.Address) {
+		return fmt.Errorf("invalid Ethereum address")
+	}
+
+	client, err := ethclient.Dial(EthereumRPC)
+	if err != nil {
+		return fmt.Errorf("failed to connect to Ethereum node: %w", err)
+	}
+	defer client.Close()
+
+	signer, err := currentSigner()
+	if err != nil {
+		return err
+	}
+	faucetAddress, err := faucetEthereumAddress(ctx, signer)
+	if err != nil {
+		return err
+	}
+
+	nonce, err := client.PendingNonceAt(ctx, common.HexToAddress(faucetAddress))
+	if err != nil {
+		return fmt.Errorf("failed to get nonce: %w", err)
+	}
+	gasPrice, err := client.SuggestGasPrice(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get gas price: %w", err)
+	}
+	chainID, err := client.NetworkID(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get chain id: %w", err)
+	}
+	tx := types.NewTransaction(nonce, common.HexToAddress(result.Address), amount, ethereumTransferGas, gasPrice, nil)
+
+	// Link the transfer to the grant admitting it
+	signingCtx := faucetSigningContext(grantID, keys.ChainEthereum, result.Address, result.Symbol, amount)
+	signedTx, err := signer.SignEthereumTransaction(signingCtx, faucetAddress, tx, chainID)
+	if err != nil {
+		return err
+	}
+	if err := client.SendTransaction(ctx, signedTx); err != nil {
+		return fmt.Errorf("failed to send transaction: %w", err)
+	}
+	result.TxID = signedTx.Hash().Hex()
+	if !confirm {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, faucetConfirmTimeout)
+	defer cancel()
+	receipt, err := bind.WaitMined(ctx, client, signedTx)
+	if err != nil {
+		return fmt.Errorf("failed to confirm transaction %s: %w", result.TxID, err)
+	}
+	if receipt.Status != types.ReceiptStatusSuccessful {
+		return fmt.Errorf("transaction %s reverted", result.TxID)
+	}
+	result.Status = FaucetStatusConfirmed
+	return nil
+}
@@ -0,0 +1,85 @@
+package solver
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestFaucet(t *testing.T) {
+	const signature = "5UYoBkwP4UUxLm6LuYUZfsi2PJww2GXwVNhXBKCRLGUqQYN7MBHXBtxEgzqxH2Nf7FnQYYP2GNP3sABr82dhUv1D"
+
+	// A Solana node that confirms airdrops on the second status check. It runs
+	// on the server goroutine, so it asserts rather than stopping the test.
+	var airdropped uint64
+	checks := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     interface{}     `json:"id"`
+			Method string          `json:"method"`
+			Params json.RawMessage `json:"params"`
+		}
+		if !assert.NoError(t, json.NewDecoder(r.Body).Decode(&req)) {
+			http.Error(w, "invalid request", http.StatusBadRequest)
+			return
+		}
+		var result interface{}
+		switch req.Method {
+		case "requestAirdrop":
+			var params []interface{}
+			if !assert.NoError(t, json.Unmarshal(req.Params, &params)) || !assert.GreaterOrEqual(t, len(params), 2) {
+				http.Error(w, "invalid params", http.StatusBadRequest)
+				return
+			}
+			lamports, ok := params[1].(float64)
+			assert.True(t, ok, "airdrop amount is not a number")
+			airdropped = uint64(lamports)
+			result = signature
+		case "getSignatureStatuses":
+			checks++
+			status := "processed"
+			if checks > 1 {
+				status = "confirmed"
+			}
+			result = map[string]interface{}{
+				"context": map[string]interface{}{"slot": 1},
+				"value":   []interface{}{map[string]interface{}{"slot": 1, "confirmations": 1, "err": nil, "confirmationStatus": status}},
+			}
+		default:
+			assert.Fail(t, "unexpected method", req.Method)
+			http.Error(w, "unexpected method", http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"jsonrpc": "2.0", "id": req.ID, "result": result})
+	}))
+	defer server.Close()
+	InitRPCEndpoints("", server.URL)
+
+	client := NewClient("")
+	address := "3h1zGmCwsRJnVk5BuRNMLsPaQu1y2aqXqXDWYCgrp5UG"
+
+	// Without an amount the default is sent, and the result is not confirmed
+	result, err := client.RequestFaucet(context.Background(), FaucetRequest{Chain: "solana", Address: address})
+	require.NoError(t, err)
+	assert.Equal(t, &FaucetResult{
+		Chain: "solana", Address: address, TxID: signature,
+		Amount: "2000000000", Symbol: "SOL", Status: FaucetStatusSubmitted,
+	}, result)
+	assert.Equal(t, uint64(2_000_000_000), airdropped)
+	assert.Zero(t, checks)
+
+	// With Confirm the status is polled until confirmed
+	result, err = client.RequestFaucet(context.Background(), FaucetRequest{Chain: "solana", Address: address, Amount: 0.5, Confirm: true})
+	require.NoError(t, err)
+	assert.Equal(t, FaucetStatusConfirmed, result.Status)
+	assert.Equal(t, "500000000", result.Amount)
+	assert.Equal(t, 2, checks)
+
+	_, err = client.RequestFaucet(context.Background(), FaucetRequest{Chain: "bitcoin", Address: address})
+	assert.Error(t, err)
+}
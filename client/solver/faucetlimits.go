@@ -0,0 +1,343 @@
+package solver
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Rules a FaucetLimitError can name
+const (
+	FaucetRuleAddressCooldown = "address-cooldown"
+	FaucetRuleIPCooldown      = "ip-cooldown"
+	FaucetRulePerRequest      = "per-request-cap"
+	FaucetRulePerDay          = "daily-cap"
+	FaucetRuleBudget          = "budget"
+)
+
+const (
+	defaultFaucetAddressCooldown = 24 * time.Hour
+	defaultFaucetIPCooldown      = time.Hour
+	faucetDay                    = 24 * time.Hour
+)
+
+// FaucetLimitError is returned when a faucet request exceeds a limit.
+// RetryAfter is zero when waiting does not help.
+type FaucetLimitError struct {
+	Rule       string
+	Reason     string
+	RetryAfter time.Duration
+}
+
+func (e *FaucetLimitError) Error() string {
+	return fmt.Sprintf("faucet limit reached (%s): %s", e.Rule, e.Reason)
+}
+
+// FaucetLimitsConfig bounds what the faucet gives out, e.g.
+//
+//	{
+//	  "addressCooldown": "24h",
+//	  "ipCooldown": "1h",
+//	  "maxPerRequest": {"ETH": 1, "SOL": 2},
+//	  "maxPerDay": {"ETH": 100},
+//	  "budget": {"ETH": 1000}
+//	}
+//
+// Amounts are whole tokens keyed by symbol. Requests are capped at the
+// chain's default amount unless maxPerRequest says otherwise, and assets
+// without a budget are not given out at all.
+type FaucetLimitsConfig struct {
+	AddressCooldown string             `json:"addressCooldown"`
+	IPCooldown      string             `json:"ipCooldown"`
+	MaxPerRequest   map[string]float64 `json:"maxPerRequest"`
+	MaxPerDay       map[string]float64 `json:"maxPerDay"`
+	Budget          map[string]float64 `json:"budget"`
+}
+
+// LoadFaucetLimits reads the limits at path. An empty path returns the
+// default limits, which have no budget and so refuse every request.
+func LoadFaucetLimits(path string) (*FaucetLimitsConfig, error) {
+	var config FaucetLimitsConfig
+	if err := readJSONFile(path, &config); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+// FaucetGrant is one faucet payment. Grants are recorded when a request is
+// admitted and kept once it is sent. Sent grants older than every limit
+// window are folded into one grant per asset, with Pruned counting the
+// grants it sums, so they still count against the budget.
+type FaucetGrant struct {
+	ID      string    `json:"id"`
+	Time    time.Time `json:"time"`
+	Chain   string    `json:"chain"`
+	Asset   string    `json:"asset"`
+	Address string    `json:"address"`
+	IP      string    `json:"ip"`
+	Amount  float64   `json:"amount"`
+	TxHash  string    `json:"txHash,omitempty"`
+	Pruned  int       `json:"pruned,omitempty"`
+}
+
+// FaucetLimiter admits faucet requests within the limits and keeps the
+// record of grants in a JSON file
+type FaucetLimiter struct {
+	addressCooldown time.Duration
+	ipCooldown      time.Duration
+	maxPerRequest   map[string]float64
+	maxPerDay       map[string]float64
+	budget          map[string]float64
+
+	mu     sync.Mutex
+	path   string
+	grants []*FaucetGrant
+}
+
+// NewFaucetLimiter returns a limiter enforcing config, persisting grants at path
+func NewFaucetLimiter(config *FaucetLimitsConfig, path string) (*FaucetLimiter, error) {
+	l := &FaucetLimiter{
+		addressCooldown: defaultFaucetAddressCooldown,
+		ipCooldown:      defaultFaucetIPCooldown,
+		maxPerRequest:   make(map[string]float64),
+		maxPerDay:       config.MaxPerDay,
+		budget:          config.Budget,
+		path:            path,
+	}
+	for chain, amount := range faucetDefaultAmounts {
+		token, err := nativeToken(chain)
+		if err != nil {
+			return nil, err
+		}
+		l.maxPerRequest[token.Symbol] = amount
+	}
+	for asset, max := range config.MaxPerRequest {
+		l.maxPerRequest[asset] = max
+	}
+	var err error
+	if config.AddressCooldown != "" {
+		if l.addressCooldown, err = time.ParseDuration(config.AddressCooldown); err != nil {
+			return nil, fmt.Errorf("invalid address cooldown: %w", err)
+		}
+	}
+	if config.IPCooldown != "" {
+		if l.ipCooldown, err = time.ParseDuration(config.IPCooldown); err != nil {
+			return nil, fmt.Errorf("invalid IP cooldown: %w", err)
+		}
+	}
+	if err := readJSONFile(path, &l.grants); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Reserve admits a request for amount of asset to address from ip, recording
+// the grant until it is completed or cancelled
+func (l *FaucetLimiter) Reserve(chain, asset, address, ip string, amount float64) (*FaucetGrant, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.pruneLocked(now)
+	if err := l.checkLocked(asset, address, ip, amount, now); err != nil {
+		Logger.Printf("Faucet: refused %g %s to %s from %s: %v", amount, asset, address, ip, err)
+		return nil, err
+	}
+
+	id, err := newRecordReference()
+	if err != nil {
+		return nil, err
+	}
+	grant := &FaucetGrant{ID: id, Time: now, Chain: chain, Asset: asset, Address: address, IP: ip, Amount: amount}
+	l.grants = append(l.grants, grant)
+	if err := writeJSONFile(l.path, l.grants); err != nil {
+		l.grants = l.grants[:len(l.grants)-1]
+		return nil, err
+	}
+	copied := *grant
+	return &copied, nil
+}
+
+func (l *FaucetLimiter) checkLocked(asset, address, ip string, amount float64, now time.Time) error {
+	if max, ok := l.maxPerRequest[asset]; ok && amount > max {
+		return &FaucetLimitError{Rule: FaucetRulePerRequest,
+			Reason: fmt.Sprintf("%g %s exceeds the maximum of %g %s per request", amount, asset, max, asset)}
+	}
+	budget, ok := l.budget[asset]
+	if !ok {
+		return &FaucetLimitError{Rule: FaucetRuleBudget, Reason: fmt.Sprintf("no %s budget is configured", asset)}
+	}
+
+	var total, today float64
+	var lastAddress, lastIP time.Time
+	for _, grant := range l.grants {
+		if grant.Asset == asset {
+			total += grant.Amount
+			if now.Sub(grant.Time) < faucetDay {
+				today += grant.Amount
+			}
+		}
+		if grant.Address == address && grant.Time.After(lastAddress) {
+			lastAddress = grant.Time
+		}
+		if ip != "" && grant.IP == ip && grant.Time.After(lastIP) {
+			lastIP = grant.Time
+		}
+	}
+
+	if wait := lastAddress.Add(l.addressCooldown).Sub(now); wait > 0 {
+		return &FaucetLimitError{Rule: FaucetRuleAddressCooldown, RetryAfter: wait,
+			Reason: fmt.Sprintf("%s was funded less than %s ago", address, l.addressCooldown)}
+	}
+	if wait := lastIP.Add(l.ipCooldown).Sub(now); wait > 0 {
+		return &FaucetLimitError{Rule: FaucetRuleIPCooldown, RetryAfter: wait,
+			Reason: fmt.Sprintf("a request from %s was funded less than %s ago", ip, l.ipCooldown)}
+	}
+	if total+amount > budget {
+		return &FaucetLimitError{Rule: FaucetRuleBudget,
+			Reason: fmt.Sprintf("the %g %s budget is used up (%g given out)", budget, asset, total)}
+	}
+	if max, ok := l.maxPerDay[asset]; ok && today+amount > max {
+		return &FaucetLimitError{Rule: FaucetRulePerDay, RetryAfter: l.dailyRetryLocked(asset, max, amount, today, now),
+			Reason: fmt.Sprintf("%g %s would exceed the daily maximum of %g %s (%g given out)", amount, asset, max, asset, today)}
+	}
+	return nil
+}
+
+// pruneLocked folds the sent grants older than every limit window into one
+// grant per asset, saved with the next change
+func (l *FaucetLimiter) pruneLocked(now time.Time) {
+	window := faucetDay
+	if l.addressCooldown > window {
+		window = l.addressCooldown
+	}
+	if l.ipCooldown > window {
+		window = l.ipCooldown
+	}
+
+	// The folded grants come first, keeping grants in the order they were made
+	var folded, kept []*FaucetGrant
+	byAsset := make(map[string]*FaucetGrant)
+	for _, grant := range l.grants {
+		if (grant.TxHash == "" && grant.Pruned == 0) || now.Sub(grant.Time) < window {
+			kept = append(kept, grant)
+			continue
+		}
+		summary, ok := byAsset[grant.Asset]
+		if !ok {
+			summary = &FaucetGrant{ID: "pruned-" + grant.Asset, Chain: grant.Chain, Asset: grant.Asset}
+			byAsset[grant.Asset] = summary
+			folded = append(folded, summary)
+		}
+		summary.Amount += grant.Amount
+		summary.Pruned++
+		if grant.Pruned > 0 {
+			summary.Pruned += grant.Pruned - 1
+		}
+		if grant.Time.After(summary.Time) {
+			summary.Time = grant.Time
+		}
+	}
+	if len(folded) > 0 {
+		l.grants = append(folded, kept...)
+	}
+}
+
+// dailyRetryLocked returns how long until enough of the last day's grants of
+// asset, totalling today, expire for amount to fit under max
+func (l *FaucetLimiter) dailyRetryLocked(asset string, max, amount, today float64, now time.Time) time.Duration {
+	// Grants are kept in the order they were made
+	for _, grant := range l.grants {
+		if grant.Asset != asset || now.Sub(grant.Time) >= faucetDay {
+			continue
+		}
+		today -= grant.Amount
+		if today+amount <= max {
+			return grant.Time.Add(faucetDay).Sub(now)
+		}
+	}
+	return 0
+}
+
+// Complete records the transaction that paid grant
+func (l *FaucetLimiter) Complete(grant *FaucetGrant, txHash string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, recorded := range l.grants {
+		if recorded.ID == grant.ID {
+			recorded.TxHash = txHash
+		}
+	}
+	if err := writeJSONFile(l.path, l.grants); err != nil {
+		// The payment is sent already, keep going with the in-memory record
+		Logger.Printf("Faucet: failed to save grants: %v", err)
+	}
+}
+
+// VerifyGrant checks that a faucet signing record pays an unpaid grant, to
+// its address and at most its amount
+func (l *FaucetLimiter) VerifyGrant(_ context.Context, record SigningRecord) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return verifyFaucetGrant(l.grants, record)
+}
+
+// FaucetGrantVerifier checks faucet signing records against the grants
+// persisted at path, reading them afresh for every record
+func FaucetGrantVerifier(path string) RecordVerifier {
+	return func(_ context.Context, record SigningRecord) error {
+		var grants []*FaucetGrant
+		if err := readJSONFile(path, &grants); err != nil {
+			return err
+		}
+		return verifyFaucetGrant(grants, record)
+	}
+}
+
+func verifyFaucetGrant(grants []*FaucetGrant, record SigningRecord) error {
+	for _, grant := range grants {
+		if grant.ID != record.Reference {
+			continue
+		}
+		if grant.TxHash != "" {
+			return fmt.Errorf("grant was paid in %s", grant.TxHash)
+		}
+		if grant.Pruned > 0 {
+			return fmt.Errorf("grant sums %d pruned grants", grant.Pruned)
+		}
+		if grant.Chain != record.Chain || grant.Asset != record.Asset {
+			return fmt.Errorf("grant is for %s on %s", grant.Asset, grant.Chain)
+		}
+		if grant.Address != record.Recipient && !(grant.Chain == "ethereum" && strings.EqualFold(grant.Address, record.Recipient)) {
+			return fmt.Errorf("grant is for %s", grant.Address)
+		}
+		token, ok := LookupToken(grant.Asset)
+		if !ok {
+			return fmt.Errorf("unknown token: %s", grant.Asset)
+		}
+		if record.Amount == nil || record.Amount.Cmp(token.ToBaseUnits(grant.Amount)) > 0 {
+			return fmt.Errorf("grant is for %g %s", grant.Amount, grant.Asset)
+		}
+		return nil
+	}
+	return ErrUnknownRecord
+}
+
+// Cancel forgets a grant whose payment failed, so it counts against no limit
+func (l *FaucetLimiter) Cancel(grant *FaucetGrant) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for i, recorded := range l.grants {
+		if recorded.ID == grant.ID {
+			l.grants = append(l.grants[:i], l.grants[i+1:]...)
+			break
+		}
+	}
+	if err := writeJSONFile(l.path, l.grants); err != nil {
+		Logger.Printf("Faucet: failed to save grants: %v", err)
+	}
+}
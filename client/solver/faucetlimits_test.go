@@ -0,0 +1,140 @@
+package solver
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// assertFaucetLimited checks err is a FaucetLimitError for rule
+func assertFaucetLimited(t *testing.T, err error, rule string) *FaucetLimitError {
+	t.Helper()
+	var limitErr *FaucetLimitError
+	if assert.True(t, errors.As(err, &limitErr), "expected a faucet limit error, got %v", err) {
+		assert.Equal(t, rule, limitErr.Rule)
+	}
+	return limitErr
+}
+
+func TestFaucetLimiter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "faucet_grants.json")
+	config := &FaucetLimitsConfig{
+		IPCooldown:    "0s",
+		MaxPerRequest: map[string]float64{"ETH": 2, "SOL": 4},
+		MaxPerDay:     map[string]float64{"ETH": 3},
+		Budget:        map[string]float64{"ETH": 10, "SOL": 5},
+	}
+	limiter, err := NewFaucetLimiter(config, path)
+	require.NoError(t, err)
+
+	// Each address is funded once per cooldown
+	first, err := limiter.Reserve("ethereum", "ETH", "0xa", "10.0.0.1", 1)
+	require.NoError(t, err)
+	limiter.Complete(first, "0xhash")
+	_, err = limiter.Reserve("ethereum", "ETH", "0xa", "10.0.0.2", 1)
+	limitErr := assertFaucetLimited(t, err, FaucetRuleAddressCooldown)
+	assert.InDelta(t, (24 * time.Hour).Seconds(), limitErr.RetryAfter.Seconds(), 5)
+
+	// Amounts are capped per request and per day
+	_, err = limiter.Reserve("ethereum", "ETH", "0xb", "10.0.0.1", 2.5)
+	assertFaucetLimited(t, err, FaucetRulePerRequest)
+	_, err = limiter.Reserve("ethereum", "ETH", "0xb", "10.0.0.1", 2)
+	require.NoError(t, err)
+	_, err = limiter.Reserve("ethereum", "ETH", "0xc", "10.0.0.1", 1)
+	limitErr = assertFaucetLimited(t, err, FaucetRulePerDay)
+	assert.InDelta(t, (24 * time.Hour).Seconds(), limitErr.RetryAfter.Seconds(), 5)
+
+	// Failed payments do not count
+	grant, err := limiter.Reserve("solana", "SOL", "sol1", "10.0.0.1", 4)
+	require.NoError(t, err)
+	limiter.Cancel(grant)
+	_, err = limiter.Reserve("solana", "SOL", "sol2", "10.0.0.1", 4)
+	require.NoError(t, err)
+
+	// The budget and the grants survive a restart
+	restarted, err := NewFaucetLimiter(config, path)
+	require.NoError(t, err)
+	_, err = restarted.Reserve("solana", "SOL", "sol3", "10.0.0.1", 2)
+	limitErr = assertFaucetLimited(t, err, FaucetRuleBudget)
+	assert.Zero(t, limitErr.RetryAfter)
+	_, err = restarted.Reserve("ethereum", "ETH", "0xa", "10.0.0.3", 0.1)
+	assertFaucetLimited(t, err, FaucetRuleAddressCooldown)
+	assert.Len(t, restarted.grants, 3)
+	assert.Equal(t, "0xhash", restarted.grants[0].TxHash)
+
+	// Faucet signing records must pay an unpaid grant, at most its amount
+	verify := FaucetGrantVerifier(path)
+	record := func(reference, recipient string, amount int64) SigningRecord {
+		return SigningRecord{Kind: SigningRecordFaucet, Reference: reference, Chain: "ethereum", Recipient: recipient, Asset: "ETH", Amount: big.NewInt(amount)}
+	}
+	pending := restarted.grants[1]
+	assert.NoError(t, verify(context.Background(), record(pending.ID, "0xB", 2e18)))
+	assert.NoError(t, restarted.VerifyGrant(context.Background(), record(pending.ID, "0xb", 1e18)))
+	assert.ErrorContains(t, verify(context.Background(), record(pending.ID, "0xb", 3e18)), "grant is for 2 ETH")
+	assert.ErrorContains(t, verify(context.Background(), record(pending.ID, "0xc", 1e18)), "grant is for 0xb")
+	assert.ErrorContains(t, verify(context.Background(), record(first.ID, "0xa", 1e18)), "grant was paid")
+	assert.ErrorIs(t, verify(context.Background(), record("forged", "0xb", 1e18)), ErrUnknownRecord)
+}
+
+func TestFaucetLimiterDefaults(t *testing.T) {
+	// Without limits nothing is given out
+	limiter, err := NewFaucetLimiter(&FaucetLimitsConfig{}, "")
+	require.NoError(t, err)
+	_, err = limiter.Reserve("ethereum", "ETH", "0xa", "10.0.0.1", 1)
+	limitErr := assertFaucetLimited(t, err, FaucetRuleBudget)
+	assert.Contains(t, limitErr.Reason, "no ETH budget")
+
+	// Requests are capped at the default amount unless configured otherwise
+	limiter, err = NewFaucetLimiter(&FaucetLimitsConfig{Budget: map[string]float64{"ETH": 10, "SOL": 10}}, "")
+	require.NoError(t, err)
+	_, err = limiter.Reserve("ethereum", "ETH", "0xa", "10.0.0.1", FaucetDefaultAmount("ethereum")+0.5)
+	assertFaucetLimited(t, err, FaucetRulePerRequest)
+	_, err = limiter.Reserve("solana", "SOL", "sol1", "10.0.0.1", FaucetDefaultAmount("solana"))
+	assert.NoError(t, err)
+}
+
+func TestFaucetLimiterPrune(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "faucet_grants.json")
+	config := &FaucetLimitsConfig{MaxPerRequest: map[string]float64{"ETH": 5}, Budget: map[string]float64{"ETH": 10}}
+	limiter, err := NewFaucetLimiter(config, path)
+	require.NoError(t, err)
+	old := time.Now().Add(-faucetDay - time.Hour)
+	limiter.grants = []*FaucetGrant{
+		{ID: "sent-1", Time: old, Chain: "ethereum", Asset: "ETH", Address: "0xa", IP: "10.0.0.1", Amount: 1, TxHash: "0x1"},
+		{ID: "unsent", Time: old, Chain: "ethereum", Asset: "ETH", Address: "0xb", IP: "10.0.0.2", Amount: 1},
+		{ID: "sent-2", Time: old.Add(time.Minute), Chain: "ethereum", Asset: "ETH", Address: "0xc", IP: "10.0.0.3", Amount: 2, TxHash: "0x2"},
+		{ID: "recent", Time: time.Now(), Chain: "ethereum", Asset: "ETH", Address: "0xd", IP: "10.0.0.4", Amount: 1, TxHash: "0x3"},
+	}
+
+	// Sent grants past every window are folded into one when the next
+	// request is saved, unsent and recent ones are kept
+	_, err = limiter.Reserve("ethereum", "ETH", "0xe", "10.0.0.5", 1)
+	require.NoError(t, err)
+	restarted, err := NewFaucetLimiter(config, path)
+	require.NoError(t, err)
+	require.Len(t, restarted.grants, 4)
+	summary := restarted.grants[0]
+	assert.Equal(t, "pruned-ETH", summary.ID)
+	assert.Equal(t, 3.0, summary.Amount)
+	assert.Equal(t, 2, summary.Pruned)
+	assert.WithinDuration(t, old.Add(time.Minute), summary.Time, time.Second)
+	assert.Equal(t, []string{"unsent", "recent"}, []string{restarted.grants[1].ID, restarted.grants[2].ID})
+
+	// Folded grants no longer hold their address, but still count against
+	// the budget
+	_, err = restarted.Reserve("ethereum", "ETH", "0xa", "10.0.0.1", 4)
+	require.NoError(t, err)
+	_, err = restarted.Reserve("ethereum", "ETH", "0xf", "10.0.0.6", 0.5)
+	assertFaucetLimited(t, err, FaucetRuleBudget)
+
+	// Folded grants are never signed for
+	assert.ErrorContains(t, FaucetGrantVerifier(path)(context.Background(), SigningRecord{
+		Kind: SigningRecordFaucet, Reference: summary.ID, Chain: "ethereum", Recipient: "0xa", Asset: "ETH", Amount: big.NewInt(1),
+	}), "pruned grants")
+}
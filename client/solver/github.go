@@ -1,11 +1,13 @@
 package solver
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
-	"math/rand"
 	"net/http"
+	"net/url"
 	"strings"
 )
 
@@ -29,6 +31,10 @@ type GithubAuthConfig struct {
 	ClientID     string
 	ClientSecret string
 	RedirectURI  string
+	// WebURL is where users sign in, github.com or a GitHub Enterprise server
+	WebURL string
+	// API is the REST API repositories are read from
+	API *GithubAPI
 }
 
 type GithubContent struct {
@@ -45,9 +51,11 @@ type GithubContent struct {
 type RepoFile struct {
 	Path    string `json:"path"`
 	Content string `json:"content"`
-	Type    string `json:"type"`
-	Size    int    `json:"size"`
-	SHA     string `json:"sha"`
+	// Encoding is "base64" when Content holds binary data base64-encoded
+	Encoding string `json:"encoding,omitempty"`
+	Type     string `json:"type"`
+	Size     int    `json:"size"`
+	SHA      string `json:"sha"`
 }
 
 func NewGithubClient(clientid, clientSecret, redirectUri string) *GithubAuthConfig {
@@ -55,192 +63,143 @@ func NewGithubClient(clientid, clientSecret, redirectUri string) *GithubAuthConf
 		ClientID:     clientid,
 		ClientSecret: clientSecret,
 		RedirectURI:  redirectUri,
+		WebURL:       DefaultGithubURL,
+		API:          NewGithubAPI(DefaultGithubAPIURL),
 	}
 }
 
-func (c *GithubAuthConfig) ExchangeCodeForToken(code string) (string, error) {
+// GithubOAuthError is an error answered by the GitHub OAuth token endpoint,
+// such as bad_verification_code for an expired or reused code
+type GithubOAuthError struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description"`
+	URI         string `json:"error_uri"`
+}
+
+func (e *GithubOAuthError) Error() string {
+	if e.Description == "" {
+		return "GitHub OAuth error: " + e.Code
+	}
+	return fmt.Sprintf("GitHub OAuth error %s: %s", e.Code, e.Description)
+}
+
+// AuthorizeURL is where the user signs in for the flow
+func (c *GithubAuthConfig) AuthorizeURL(flow *OAuthFlow) string {
+	query := url.Values{
+		"client_id":             {c.ClientID},
+		"redirect_uri":          {c.RedirectURI},
+		"state":                 {flow.State},
+		"scope":                 {"repo"},
+		"code_challenge":        {PKCEChallenge(flow.Verifier)},
+		"code_challenge_method": {"S256"},
+	}
+	return strings.TrimSuffix(c.WebURL, "/") + "/login/oauth/authorize?" + query.Encode()
+}
+
+// ExchangeCodeForToken trades the code of a sign-in for an access token,
+// proving the sign-in was started here with its PKCE code verifier
+func (c *GithubAuthConfig) ExchangeCodeForToken(ctx context.Context, code, verifier string) (string, error) {
 	// Prepare request body
-	data := strings.NewReader(fmt.Sprintf(
-		"client_id=%s&client_secret=%s&code=%s",
-		c.ClientID,
-		c.ClientSecret,
-		code,
-	))
+	data := url.Values{
+		"client_id":     {c.ClientID},
+		"client_secret": {c.ClientSecret},
+		"code":          {code},
+		"redirect_uri":  {c.RedirectURI},
+		"code_verifier": {verifier},
+	}
 
 	// Make request to GitHub
-	req, err := http.NewRequest("POST", "https://github.com/login/oauth/access_token", data)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(c.WebURL, "/")+"/login/oauth/access_token", strings.NewReader(data.Encode()))
 	if err != nil {
 		return "", err
 	}
-
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 	req.Header.Set("Accept", "application/json")
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := c.API.HTTPClient.Do(req)
 	if err != nil {
 		return "", err
 	}
 	defer resp.Body.Close()
 
-	// Parse response
+	// Parse response, GitHub answers errors with 200 and an error field
 	var result struct {
+		GithubOAuthError
 		AccessToken string `json:"access_token"`
 		TokenType   string `json:"token_type"`
 		Scope       string `json:"scope"`
 	}
-
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return "", err
+		return "", fmt.Errorf("error parsing token response (%s): %w", resp.Status, err)
+	}
+	if result.Code != "" {
+		return "", &result.GithubOAuthError
+	}
+	if result.AccessToken == "" {
+		return "", fmt.Errorf("GitHub returned no access token (%s)", resp.Status)
 	}
 
 	return result.AccessToken, nil
 }
 
-func FetchGithubRepos(token string) ([]GithubRepo, error) {
-	// Make request to GitHub API
-	req, err := http.NewRequest("GET", "https://api.github.com/user/repos", nil)
+// RevokeToken revokes the access token, signing the user out of this app on
+// GitHub. A token that is already revoked is not an error.
+func (c *GithubAuthConfig) RevokeToken(ctx context.Context, token string) error {
+	body, err := json.Marshal(map[string]string{"access_token": token})
 	if err != nil {
-		return nil, err
+		return err
 	}
-
-	req.Header.Set("Authorization", "token "+token)
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, c.API.url("/applications/"+url.PathEscape(c.ClientID)+"/token"), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(c.ClientID, c.ClientSecret)
 	req.Header.Set("Accept", "application/vnd.github.v3+json")
+	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := c.API.HTTPClient.Do(req)
 	if err != nil {
-		return nil, err
+		return fmt.Errorf("error making request: %w", err)
 	}
 	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode == http.StatusNoContent || resp.StatusCode == http.StatusNotFound {
+		return nil
+	}
+	return githubError(resp, respBody)
+}
 
-	// Parse response
+// FetchGithubRepos lists the repositories of the token's user, all pages of them
+func (c *GithubAuthConfig) FetchGithubRepos(ctx context.Context, token string) ([]GithubRepo, error) {
 	var repos []GithubRepo
-	if err := json.NewDecoder(resp.Body).Decode(&repos); err != nil {
+	err := c.API.GetPages(ctx, token, "/user/repos?per_page=100", func(body []byte) error {
+		var page []GithubRepo
+		if err := json.Unmarshal(body, &page); err != nil {
+			return err
+		}
+		repos = append(repos, page...)
+		return nil
+	})
+	if err != nil {
 		return nil, err
 	}
-
 	return repos, nil
 }
 
-func GenerateRandomState() string {
-	// Generate a random string for CSRF protection
-	const letters = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
-	b := make([]byte, 32)
-	for i := range b {
-		b[i] = letters[rand.Intn(len(letters))]
-	}
-	return string(b)
+// GenerateRandomState returns a random OAuth state for CSRF protection
+func GenerateRandomState() (string, error) {
+	return randomToken(32)
 }
 
-func (c *GithubAuthConfig) FetchRepoContents(token, owner, repo, path string) ([]GithubContent, error) {
-	// Create API URL
-	apiURL := fmt.Sprintf("https://api.github.com/repos/%s/%s/contents/%s", owner, repo, path)
-
-	// Create request
-	req, err := http.NewRequest("GET", apiURL, nil)
-	if err != nil {
-		return nil, fmt.Errorf("error creating request: %v", err)
-	}
-
-	// Add headers
-	req.Header.Set("Authorization", "token "+token)
-	req.Header.Set("Accept", "application/vnd.github.v3+json")
-
-	// Make request
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("error making request: %v", err)
-	}
-	defer resp.Body.Close()
-
-	// Check status code
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("GitHub API error: %s", string(body))
-	}
-
-	// Parse response
+func (c *GithubAuthConfig) FetchRepoContents(ctx context.Context, token, owner, repo, path string) ([]GithubContent, error) {
 	var contents []GithubContent
-	if err := json.NewDecoder(resp.Body).Decode(&contents); err != nil {
-		return nil, fmt.Errorf("error parsing response: %v", err)
+	apiPath := fmt.Sprintf("/repos/%s/%s/contents/%s", url.PathEscape(owner), url.PathEscape(repo), escapeRef(strings.TrimPrefix(path, "/")))
+	if err := c.API.GetJSON(ctx, token, apiPath, &contents); err != nil {
+		return nil, err
 	}
-
 	return contents, nil
 }
 
-func (c *GithubAuthConfig) FetchFileContent(token, downloadURL string) ([]byte, error) {
-	// Create request
-	req, err := http.NewRequest("GET", downloadURL, nil)
-	if err != nil {
-		return nil, fmt.Errorf("error creating request: %v", err)
-	}
-
-	// Add headers
-	req.Header.Set("Authorization", "token "+token)
-	req.Header.Set("Accept", "application/vnd.github.v3.raw")
-
-	// Make request
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("error making request: %v", err)
-	}
-	defer resp.Body.Close()
-
-	// Check status code
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("GitHub API error: %s", string(body))
-	}
-
-	// Read response body
-	content, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("error reading response: %v", err)
-	}
-
-	return content, nil
-}
-
-// FetchRepoFilesRecursively fetches all files from a repository recursively
-func (c *GithubAuthConfig) FetchRepoFilesRecursively(token, owner, repo string) ([]RepoFile, error) {
-	var allFiles []RepoFile
-
-	// Start with root directory
-	err := c.fetchDirectoryContents(token, owner, repo, "", &allFiles)
-	if err != nil {
-		return nil, fmt.Errorf("error fetching repository contents: %v", err)
-	}
-
-	return allFiles, nil
-}
-
-// fetchDirectoryContents recursively fetches contents of a directory
-func (c *GithubAuthConfig) fetchDirectoryContents(token, owner, repo, path string, files *[]RepoFile) error {
-	contents, err := c.FetchRepoContents(token, owner, repo, path)
-	if err != nil {
-		return err
-	}
-
-	for _, item := range contents {
-		if item.Type == "dir" {
-			// Recursively fetch directory contents
-			err = c.fetchDirectoryContents(token, owner, repo, item.Path, files)
-			if err != nil {
-				return err
-			}
-		} else if item.Type == "file" {
-			// Fetch file content
-			content, err := c.FetchFileContent(token, item.DownloadURL)
-			if err != nil {
-				return err
-			}
-
-			*files = append(*files, RepoFile{
-				Path:    item.Path,
-				Content: string(content),
-				Type:    item.Type,
-				Size:    item.Size,
-				SHA:     item.SHA,
-			})
-		}
-	}
-
-	return nil
+func (c *GithubAuthConfig) FetchFileContent(ctx context.Context, token, downloadURL string) ([]byte, error) {
+	content, _, err := c.API.Get(ctx, token, downloadURL, "application/vnd.github.v3.raw")
+	return content, err
 }
@@ -0,0 +1,297 @@
+package solver
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultGithubAPIURL is the REST API of github.com
+	DefaultGithubAPIURL = "https://api.github.com"
+	// DefaultGithubURL is github.com, where users sign in
+	DefaultGithubURL = "https://github.com"
+
+	// defaultRateLimitWait is how long a request waits for the rate limit to reset
+	defaultRateLimitWait = time.Minute
+	// maxGithubPages bounds the pages read from a paginated list
+	maxGithubPages = 50
+	// maxETagCacheEntries bounds the responses kept for conditional requests
+	maxETagCacheEntries = 1000
+	// defaultETagCacheBytes bounds the size of the responses kept by default
+	defaultETagCacheBytes = 32 << 20
+	// maxETagCacheBody is the largest response body kept for conditional requests
+	maxETagCacheBody = 1 << 20
+	// maxGithubErrorBody bounds the error responses read
+	maxGithubErrorBody = 1 << 20
+)
+
+// githubNextLink finds the next page in a Link header
+var githubNextLink = regexp.MustCompile(`<([^>]+)>;\s*rel="next"`)
+
+// GithubAPIError is an error response of the GitHub API
+type GithubAPIError struct {
+	StatusCode       int
+	Message          string
+	DocumentationURL string
+	// RateLimited is set when the request was refused for the rate limit,
+	// which resets at ResetAt
+	RateLimited bool
+	ResetAt     time.Time
+}
+
+func (e *GithubAPIError) Error() string {
+	if e.RateLimited {
+		return fmt.Sprintf("GitHub API rate limit exceeded until %s: %s", e.ResetAt.Format(time.RFC3339), e.Message)
+	}
+	return fmt.Sprintf("GitHub API error (%d): %s", e.StatusCode, e.Message)
+}
+
+// githubCachedResponse is a response kept to revalidate with its ETag
+type githubCachedResponse struct {
+	etag   string
+	header http.Header
+	body   []byte
+}
+
+// size approximates the memory the response holds under key
+func (r *githubCachedResponse) size(key string) int64 {
+	size := len(key) + len(r.etag) + len(r.body)
+	for name, values := range r.header {
+		size += len(name)
+		for _, value := range values {
+			size += len(value)
+		}
+	}
+	return int64(size)
+}
+
+// GithubAPI calls the GitHub REST API at BaseURL, which may be a GitHub
+// Enterprise server (https://host/api/v3) or a test server. Responses with
+// an ETag are revalidated rather than fetched again, and requests refused for
+// the rate limit are retried once it resets, if that is soon enough.
+type GithubAPI struct {
+	BaseURL    string
+	HTTPClient *http.Client
+	// MaxRateLimitWait is how long a request may wait for the rate limit to reset
+	MaxRateLimitWait time.Duration
+	// MaxCacheBytes bounds the size of the responses kept for revalidation
+	MaxCacheBytes int64
+
+	mu         sync.Mutex
+	cache      map[string]*githubCachedResponse
+	cacheBytes int64
+}
+
+// NewGithubAPI returns a client of the API at baseURL
+func NewGithubAPI(baseURL string) *GithubAPI {
+	return &GithubAPI{
+		BaseURL:          strings.TrimSuffix(baseURL, "/"),
+		HTTPClient:       http.DefaultClient,
+		MaxRateLimitWait: defaultRateLimitWait,
+		MaxCacheBytes:    defaultETagCacheBytes,
+		cache:            make(map[string]*githubCachedResponse),
+	}
+}
+
+// url resolves an API path, leaving absolute URLs such as next pages as they are
+func (a *GithubAPI) url(path string) string {
+	if strings.HasPrefix(path, "https://") || strings.HasPrefix(path, "http://") {
+		return path
+	}
+	return a.BaseURL + path
+}
+
+// Get requests path with token, revalidating a cached response and waiting
+// out the rate limit. It returns the response body and headers, or a
+// *GithubAPIError for statuses other than 200.
+func (a *GithubAPI) Get(ctx context.Context, token, path, accept string) ([]byte, http.Header, error) {
+	url := a.url(path)
+	// Responses depend on who asks, so they are cached per token
+	tokenHash := sha256.Sum256([]byte(token))
+	cacheKey := hex.EncodeToString(tokenHash[:]) + " " + accept + " " + url
+
+	a.mu.Lock()
+	cached := a.cache[cacheKey]
+	a.mu.Unlock()
+	var etag string
+	if cached != nil {
+		etag = cached.etag
+	}
+
+	resp, err := a.do(ctx, token, url, accept, etag)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotModified {
+		return cached.body, cached.header, nil
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error reading response: %w", err)
+	}
+	// Blobs are named by their hash and never change, so they are not worth
+	// the memory of revalidating
+	if etag := resp.Header.Get("ETag"); etag != "" && len(body) <= maxETagCacheBody && !strings.Contains(url, "/git/blobs/") {
+		a.store(cacheKey, &githubCachedResponse{etag: etag, header: resp.Header, body: body})
+	}
+	return body, resp.Header, nil
+}
+
+// Open requests path like Get but returns the body unread, for downloads too
+// large to hold in memory such as tarballs. Opened responses are not cached.
+// The caller closes the body.
+func (a *GithubAPI) Open(ctx context.Context, token, path, accept string) (io.ReadCloser, http.Header, error) {
+	resp, err := a.do(ctx, token, a.url(path), accept, "")
+	if err != nil {
+		return nil, nil, err
+	}
+	return resp.Body, resp.Header, nil
+}
+
+// do sends a GET request, retrying once when it is refused for a rate limit
+// that resets soon enough. It returns responses with status 200, or 304 when
+// revalidating etag, and a *GithubAPIError for the others.
+func (a *GithubAPI) do(ctx context.Context, token, url, accept, etag string) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, fmt.Errorf("error creating request: %w", err)
+		}
+		if token != "" {
+			req.Header.Set("Authorization", "token "+token)
+		}
+		req.Header.Set("Accept", accept)
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+
+		resp, err := a.HTTPClient.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("error making request: %w", err)
+		}
+		if resp.StatusCode == http.StatusOK || (resp.StatusCode == http.StatusNotModified && etag != "") {
+			return resp, nil
+		}
+		body, err := io.ReadAll(io.LimitReader(resp.Body, maxGithubErrorBody))
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("error reading response: %w", err)
+		}
+
+		apiErr := githubError(resp, body)
+		if !apiErr.RateLimited || attempt > 0 {
+			return nil, apiErr
+		}
+		wait := time.Until(apiErr.ResetAt)
+		if wait > a.MaxRateLimitWait {
+			return nil, apiErr
+		}
+		Logger.Printf("GitHub API rate limited, retrying in %s", wait.Round(time.Second))
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// store keeps a response for revalidation, making room when the cache is full
+func (a *GithubAPI) store(key string, response *githubCachedResponse) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	size := response.size(key)
+	if size > a.MaxCacheBytes {
+		return
+	}
+	a.evictLocked(key)
+	for len(a.cache) > 0 && (len(a.cache) >= maxETagCacheEntries || a.cacheBytes+size > a.MaxCacheBytes) {
+		for evicted := range a.cache {
+			a.evictLocked(evicted)
+			break
+		}
+	}
+	a.cache[key] = response
+	a.cacheBytes += size
+}
+
+// evictLocked drops the response cached under key, if any
+func (a *GithubAPI) evictLocked(key string) {
+	if cached, ok := a.cache[key]; ok {
+		a.cacheBytes -= cached.size(key)
+		delete(a.cache, key)
+	}
+}
+
+// githubError reads the error response, telling rate limits from other refusals
+func githubError(resp *http.Response, body []byte) *GithubAPIError {
+	apiErr := &GithubAPIError{StatusCode: resp.StatusCode}
+	var message struct {
+		Message          string `json:"message"`
+		DocumentationURL string `json:"documentation_url"`
+	}
+	if json.Unmarshal(body, &message) == nil && message.Message != "" {
+		apiErr.Message = message.Message
+		apiErr.DocumentationURL = message.DocumentationURL
+	} else {
+		apiErr.Message = strings.TrimSpace(string(body))
+	}
+
+	if resp.StatusCode != http.StatusForbidden && resp.StatusCode != http.StatusTooManyRequests {
+		return apiErr
+	}
+	// Secondary rate limits say when to retry, primary ones when they reset
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+		apiErr.RateLimited = true
+		apiErr.ResetAt = time.Now().Add(time.Duration(seconds) * time.Second)
+	} else if resp.Header.Get("X-RateLimit-Remaining") == "0" {
+		apiErr.RateLimited = true
+		reset, _ := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64)
+		apiErr.ResetAt = time.Unix(reset, 0)
+	}
+	return apiErr
+}
+
+// GetJSON requests path and decodes the JSON response into v
+func (a *GithubAPI) GetJSON(ctx context.Context, token, path string, v interface{}) error {
+	body, _, err := a.Get(ctx, token, path, "application/vnd.github.v3+json")
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("error parsing response: %w", err)
+	}
+	return nil
+}
+
+// GetPages requests path and the pages its Link headers point to, passing
+// each JSON page to page
+func (a *GithubAPI) GetPages(ctx context.Context, token, path string, page func(body []byte) error) error {
+	for i := 0; path != ""; i++ {
+		if i == maxGithubPages {
+			return fmt.Errorf("more than %d pages of results", maxGithubPages)
+		}
+		body, header, err := a.Get(ctx, token, path, "application/vnd.github.v3+json")
+		if err != nil {
+			return err
+		}
+		if err := page(body); err != nil {
+			return fmt.Errorf("error parsing response: %w", err)
+		}
+		path = ""
+		if match := githubNextLink.FindStringSubmatch(header.Get("Link")); match != nil {
+			path = match[1]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,136 @@
+package solver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGithubAPI(t *testing.T) {
+	var requests, notModified, limited atomic.Int32
+
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		switch r.URL.Path {
+		case "/user/repos":
+			// Two pages, linked with the Link header
+			page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+			if page == 0 {
+				assert.Equal(t, "100", r.URL.Query().Get("per_page"))
+				w.Header().Set("Link", fmt.Sprintf(`<%s/user/repos?per_page=100&page=2>; rel="next", <%s/user/repos?per_page=100&page=2>; rel="last"`, server.URL, server.URL))
+				fmt.Fprint(w, `[{"id": 1, "name": "counter"}, {"id": 2, "name": "fungible"}]`)
+			} else {
+				fmt.Fprint(w, `[{"id": 3, "name": "matching-engine"}]`)
+			}
+		case "/repos/linera-io/counter/contents/src":
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				notModified.Add(1)
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Header().Set("ETag", `"v1"`)
+			fmt.Fprint(w, `[{"type": "file", "name": "lib.rs", "path": "src/lib.rs"}]`)
+		case "/repos/linera-io/limited/contents/":
+			// Limited once for a second, then served
+			if limited.Add(1) == 1 {
+				w.Header().Set("X-RateLimit-Remaining", "0")
+				w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Add(time.Second).Unix(), 10))
+				http.Error(w, `{"message": "API rate limit exceeded"}`, http.StatusForbidden)
+				return
+			}
+			fmt.Fprint(w, `[]`)
+		case "/repos/linera-io/secondary/contents/":
+			w.Header().Set("Retry-After", "3600")
+			http.Error(w, `{"message": "You have exceeded a secondary rate limit"}`, http.StatusForbidden)
+		default:
+			http.Error(w, `{"message": "Not Found", "documentation_url": "https://docs.github.com/rest"}`, http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	client := NewGithubClient("", "", "")
+	client.API = NewGithubAPI(server.URL + "/")
+	ctx := context.Background()
+
+	repos, err := client.FetchGithubRepos(ctx, "secret")
+	require.NoError(t, err)
+	require.Len(t, repos, 3)
+	assert.Equal(t, "matching-engine", repos[2].Name)
+
+	// The second request is revalidated with the ETag and answered from the cache
+	for i := 0; i < 2; i++ {
+		contents, err := client.FetchRepoContents(ctx, "secret", "linera-io", "counter", "src")
+		require.NoError(t, err)
+		require.Len(t, contents, 1)
+		assert.Equal(t, "src/lib.rs", contents[0].Path)
+	}
+	assert.Equal(t, int32(1), notModified.Load())
+
+	// Another token is not answered from the cache of the first
+	_, err = client.FetchRepoContents(ctx, "other", "linera-io", "counter", "src")
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), notModified.Load())
+
+	// A rate limit resetting soon is waited out
+	_, err = client.FetchRepoContents(ctx, "secret", "linera-io", "limited", "")
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), limited.Load())
+
+	// One resetting later fails right away
+	_, err = client.FetchRepoContents(ctx, "secret", "linera-io", "secondary", "")
+	var apiErr *GithubAPIError
+	require.True(t, errors.As(err, &apiErr), err)
+	assert.True(t, apiErr.RateLimited)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), apiErr.ResetAt, time.Minute)
+
+	_, err = client.FetchRepoContents(ctx, "secret", "linera-io", "missing", "")
+	require.True(t, errors.As(err, &apiErr), err)
+	assert.False(t, apiErr.RateLimited)
+	assert.Equal(t, http.StatusNotFound, apiErr.StatusCode)
+	assert.Equal(t, "Not Found", apiErr.Message)
+	assert.Equal(t, "https://docs.github.com/rest", apiErr.DocumentationURL)
+}
+
+func TestGithubAPICache(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"`+r.URL.Path+`"`)
+		fmt.Fprint(w, strings.Repeat("x", 100))
+	}))
+	defer server.Close()
+	api := NewGithubAPI(server.URL)
+	api.MaxCacheBytes = 1000
+	ctx := context.Background()
+
+	// Older responses make room for newer ones within the byte bound
+	for i := 0; i < 20; i++ {
+		_, _, err := api.Get(ctx, "secret", fmt.Sprintf("/repos/linera-io/counter/contents/%d", i), "application/json")
+		require.NoError(t, err)
+		assert.LessOrEqual(t, api.cacheBytes, api.MaxCacheBytes)
+	}
+	cached := len(api.cache)
+	assert.Greater(t, cached, 0)
+	assert.Less(t, cached, 20)
+	var total int64
+	for key, response := range api.cache {
+		total += response.size(key)
+	}
+	assert.Equal(t, total, api.cacheBytes)
+
+	// Blobs are not cached
+	_, _, err := api.Get(ctx, "secret", "/repos/linera-io/counter/git/blobs/abc", "application/json")
+	require.NoError(t, err)
+	for key := range api.cache {
+		assert.NotContains(t, key, "/git/blobs/")
+	}
+}
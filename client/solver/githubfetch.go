@@ -0,0 +1,299 @@
+package solver
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+const (
+	// defaultBlobWorkers is how many blobs are fetched at once by default
+	defaultBlobWorkers = 8
+	// defaultMaxRepoSize bounds the content of the files fetched by default
+	defaultMaxRepoSize = 256 << 20
+	// maxRepoTarballSize bounds the compressed tarball downloaded
+	maxRepoTarballSize = 1 << 30
+)
+
+// RepoFetchOptions selects what FetchRepoFilesRecursively fetches
+type RepoFetchOptions struct {
+	// Ref is the branch, tag or commit, the default branch when empty
+	Ref string
+	// Include keeps only files matching one of the globs, all when empty. A
+	// glob without "/" matches the file name, others the whole path.
+	Include []string
+	// MaxFileSize skips larger files, when positive
+	MaxFileSize int64
+	// MaxTotalSize bounds the content of all the fetched files, which are held
+	// in memory (default 256 MiB)
+	MaxTotalSize int64
+	// Workers is how many blobs are fetched at once (default 8)
+	Workers int
+}
+
+// included reports whether the file at filePath with size is kept
+func (o RepoFetchOptions) included(filePath string, size int64) bool {
+	if o.MaxFileSize > 0 && size > o.MaxFileSize {
+		return false
+	}
+	if len(o.Include) == 0 {
+		return true
+	}
+	for _, pattern := range o.Include {
+		name := filePath
+		if !strings.Contains(pattern, "/") {
+			name = path.Base(filePath)
+		}
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// maxTotalSize returns MaxTotalSize or its default
+func (o RepoFetchOptions) maxTotalSize() int64 {
+	if o.MaxTotalSize > 0 {
+		return o.MaxTotalSize
+	}
+	return defaultMaxRepoSize
+}
+
+// repoTooLarge is the error for repositories above the limit
+func repoTooLarge(limit int64) error {
+	return fmt.Errorf("repository files exceed %d bytes", limit)
+}
+
+// gitTree is the response of the Git Trees API
+type gitTree struct {
+	SHA  string `json:"sha"`
+	Tree []struct {
+		Path string `json:"path"`
+		Mode string `json:"mode"`
+		Type string `json:"type"`
+		SHA  string `json:"sha"`
+		Size int64  `json:"size"`
+	} `json:"tree"`
+	Truncated bool `json:"truncated"`
+}
+
+// gitBlob is the response of the Git Blobs API
+type gitBlob struct {
+	Content  string `json:"content"`
+	Encoding string `json:"encoding"`
+}
+
+// FetchRepoFilesRecursively fetches the files of a repository at opts.Ref.
+// It lists the whole tree in one call and fetches the selected blobs
+// concurrently, or downloads the tarball when the tree is too large to list.
+func (c *GithubAuthConfig) FetchRepoFilesRecursively(ctx context.Context, token, owner, repo string, opts RepoFetchOptions) ([]RepoFile, error) {
+	ref := opts.Ref
+	if ref == "" {
+		ref = "HEAD"
+	}
+
+	var tree gitTree
+	treePath := fmt.Sprintf("/repos/%s/%s/git/trees/%s?recursive=1", url.PathEscape(owner), url.PathEscape(repo), escapeRef(ref))
+	if err := c.API.GetJSON(ctx, token, treePath, &tree); err != nil {
+		return nil, fmt.Errorf("error fetching repository tree: %w", err)
+	}
+	if tree.Truncated {
+		// The tree lists only part of the repository, the tarball has it all
+		return c.fetchRepoTarball(ctx, token, owner, repo, ref, opts)
+	}
+
+	var files []RepoFile
+	var total int64
+	for _, entry := range tree.Tree {
+		// Directories are implied by paths and submodules are other repositories
+		if entry.Type != "blob" || !opts.included(entry.Path, entry.Size) {
+			continue
+		}
+		if total += entry.Size; total > opts.maxTotalSize() {
+			return nil, repoTooLarge(opts.maxTotalSize())
+		}
+		fileType := "file"
+		if entry.Mode == "120000" {
+			fileType = "symlink"
+		}
+		files = append(files, RepoFile{Path: entry.Path, Type: fileType, Size: int(entry.Size), SHA: entry.SHA})
+	}
+
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = defaultBlobWorkers
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	indexes := make(chan int)
+	var (
+		wg       sync.WaitGroup
+		errMu    sync.Mutex
+		firstErr error
+	)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range indexes {
+				file := &files[index]
+				var blob gitBlob
+				blobPath := fmt.Sprintf("/repos/%s/%s/git/blobs/%s", url.PathEscape(owner), url.PathEscape(repo), file.SHA)
+				err := c.API.GetJSON(ctx, token, blobPath, &blob)
+				var content []byte
+				if err == nil {
+					content, err = decodeBlob(blob)
+				}
+				if err != nil {
+					errMu.Lock()
+					if firstErr == nil {
+						firstErr = fmt.Errorf("error fetching %s: %w", file.Path, err)
+						cancel()
+					}
+					errMu.Unlock()
+					continue
+				}
+				file.Content, file.Encoding = encodeRepoContent(content)
+			}
+		}()
+	}
+	for i := range files {
+		select {
+		case indexes <- i:
+		case <-ctx.Done():
+		}
+	}
+	close(indexes)
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// fetchRepoTarball fetches the files of a repository from its tarball, read
+// as it downloads. The download and the files kept are bounded.
+func (c *GithubAuthConfig) fetchRepoTarball(ctx context.Context, token, owner, repo, ref string, opts RepoFetchOptions) ([]RepoFile, error) {
+	tarballPath := fmt.Sprintf("/repos/%s/%s/tarball/%s", url.PathEscape(owner), url.PathEscape(repo), escapeRef(ref))
+	tarball, _, err := c.API.Open(ctx, token, tarballPath, "application/vnd.github.v3+json")
+	if err != nil {
+		return nil, fmt.Errorf("error fetching repository tarball: %w", err)
+	}
+	defer tarball.Close()
+
+	gz, err := gzip.NewReader(&boundedReader{r: tarball, remaining: maxRepoTarballSize})
+	if err != nil {
+		return nil, fmt.Errorf("error reading repository tarball: %w", err)
+	}
+	archive := tar.NewReader(gz)
+
+	var files []RepoFile
+	remaining := opts.maxTotalSize()
+	for {
+		header, err := archive.Next()
+		if err == io.EOF {
+			return files, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading repository tarball: %w", err)
+		}
+
+		// Paths start with a <owner>-<repo>-<commit> directory
+		_, filePath, ok := strings.Cut(header.Name, "/")
+		if !ok || filePath == "" {
+			continue
+		}
+		var file RepoFile
+		switch header.Typeflag {
+		case tar.TypeReg:
+			if !opts.included(filePath, header.Size) {
+				continue
+			}
+			if header.Size > remaining {
+				return nil, repoTooLarge(opts.maxTotalSize())
+			}
+			content, err := io.ReadAll(io.LimitReader(archive, header.Size))
+			if err != nil {
+				return nil, fmt.Errorf("error reading %s: %w", filePath, err)
+			}
+			remaining -= int64(len(content))
+			file = RepoFile{Path: filePath, Type: "file", Size: len(content)}
+			file.Content, file.Encoding = encodeRepoContent(content)
+		case tar.TypeSymlink:
+			if !opts.included(filePath, int64(len(header.Linkname))) {
+				continue
+			}
+			file = RepoFile{Path: filePath, Type: "symlink", Content: header.Linkname, Size: len(header.Linkname)}
+		default:
+			continue
+		}
+		files = append(files, file)
+	}
+}
+
+// boundedReader reads from r until remaining bytes were read, then fails
+type boundedReader struct {
+	r         io.Reader
+	remaining int64
+}
+
+func (b *boundedReader) Read(p []byte) (int, error) {
+	if b.remaining <= 0 {
+		return 0, fmt.Errorf("repository tarball exceeds %d bytes", maxRepoTarballSize)
+	}
+	if int64(len(p)) > b.remaining {
+		p = p[:b.remaining]
+	}
+	n, err := b.r.Read(p)
+	b.remaining -= int64(n)
+	return n, err
+}
+
+// escapeRef escapes a ref for a URL path, keeping the slashes of branch names
+func escapeRef(ref string) string {
+	return strings.ReplaceAll(url.PathEscape(ref), "%2F", "/")
+}
+
+// decodeBlob returns the content of a blob
+func decodeBlob(blob gitBlob) ([]byte, error) {
+	switch blob.Encoding {
+	case "base64":
+		// GitHub wraps the base64 content in lines
+		return base64.StdEncoding.DecodeString(strings.ReplaceAll(blob.Content, "\n", ""))
+	case "utf-8", "":
+		return []byte(blob.Content), nil
+	default:
+		return nil, fmt.Errorf("unknown blob encoding %q", blob.Encoding)
+	}
+}
+
+// encodeRepoContent returns text as is and binary data base64-encoded, with
+// its encoding
+func encodeRepoContent(content []byte) (string, string) {
+	if utf8.Valid(content) && bytes.IndexByte(content, 0) < 0 {
+		return string(content), ""
+	}
+	return base64.StdEncoding.EncodeToString(content), "base64"
+}
+
+// Bytes returns the content of the file, decoding base64 content
+func (f RepoFile) Bytes() ([]byte, error) {
+	if f.Encoding == "base64" {
+		return base64.StdEncoding.DecodeString(f.Content)
+	}
+	return []byte(f.Content), nil
+}
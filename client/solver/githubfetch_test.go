@@ -0,0 +1,115 @@
+package solver
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFetchRepoFiles(t *testing.T) {
+	blobs := map[string][]byte{
+		"1": []byte("[package]\nname = \"counter\"\n"),
+		"2": []byte("fn main() {}\n"),
+		"3": {0x89, 'P', 'N', 'G', 0x00, 0xff},
+		"4": []byte(strings.Repeat("x", 100)),
+	}
+	var truncated atomic.Bool
+	var blobRequests atomic.Int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "token secret", r.Header.Get("Authorization"))
+		switch {
+		case r.URL.Path == "/repos/linera-io/counter/git/trees/release/v1":
+			assert.Equal(t, "1", r.URL.Query().Get("recursive"))
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"truncated": truncated.Load(),
+				"tree": []map[string]interface{}{
+					{"path": "Cargo.toml", "mode": "100644", "type": "blob", "sha": "1", "size": 28},
+					{"path": "src", "mode": "040000", "type": "tree", "sha": "5"},
+					{"path": "src/main.rs", "mode": "100644", "type": "blob", "sha": "2", "size": 13},
+					{"path": "assets/logo.png", "mode": "100644", "type": "blob", "sha": "3", "size": 6},
+					{"path": "assets/large.txt", "mode": "100644", "type": "blob", "sha": "4", "size": 100},
+					{"path": "vendor/lib", "mode": "160000", "type": "commit", "sha": "6"},
+				},
+			})
+		case strings.HasPrefix(r.URL.Path, "/repos/linera-io/counter/git/blobs/"):
+			blobRequests.Add(1)
+			content := base64.StdEncoding.EncodeToString(blobs[strings.TrimPrefix(r.URL.Path, "/repos/linera-io/counter/git/blobs/")])
+			json.NewEncoder(w).Encode(gitBlob{Content: content[:4] + "\n" + content[4:], Encoding: "base64"})
+		case r.URL.Path == "/repos/linera-io/counter/tarball/release/v1":
+			var archive bytes.Buffer
+			gz := gzip.NewWriter(&archive)
+			tw := tar.NewWriter(gz)
+			for name, sha := range map[string]string{"Cargo.toml": "1", "src/main.rs": "2", "assets/logo.png": "3"} {
+				require.NoError(t, tw.WriteHeader(&tar.Header{Name: "linera-io-counter-abc123/" + name, Mode: 0644, Size: int64(len(blobs[sha])), Typeflag: tar.TypeReg}))
+				tw.Write(blobs[sha])
+			}
+			require.NoError(t, tw.Close())
+			require.NoError(t, gz.Close())
+			w.Write(archive.Bytes())
+		default:
+			http.Error(w, `{"message": "Not Found"}`, http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	client := NewGithubClient("", "", "")
+	client.API = NewGithubAPI(server.URL)
+	options := RepoFetchOptions{Ref: "release/v1", Include: []string{"*.toml", "src/*.rs", "assets/*"}, MaxFileSize: 50, Workers: 2}
+	files, err := client.FetchRepoFilesRecursively(context.Background(), "secret", "linera-io", "counter", options)
+	require.NoError(t, err)
+
+	// Directories and submodules are left out, as are files above the size limit
+	byPath := make(map[string]RepoFile)
+	for _, file := range files {
+		byPath[file.Path] = file
+	}
+	require.Len(t, byPath, 3)
+	assert.Equal(t, string(blobs["1"]), byPath["Cargo.toml"].Content)
+	assert.Empty(t, byPath["Cargo.toml"].Encoding)
+	assert.Equal(t, "base64", byPath["assets/logo.png"].Encoding)
+	logo, err := byPath["assets/logo.png"].Bytes()
+	require.NoError(t, err)
+	assert.Equal(t, blobs["3"], logo)
+	assert.Equal(t, int32(3), blobRequests.Load())
+
+	// Only the matching files are fetched
+	files, err = client.FetchRepoFilesRecursively(context.Background(), "secret", "linera-io", "counter", RepoFetchOptions{Ref: "release/v1", Include: []string{"*.rs"}})
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	assert.Equal(t, "src/main.rs", files[0].Path)
+
+	// A tree too large to list is read from the tarball
+	truncated.Store(true)
+	files, err = client.FetchRepoFilesRecursively(context.Background(), "secret", "linera-io", "counter", options)
+	require.NoError(t, err)
+	require.Len(t, files, 3)
+	byPath = make(map[string]RepoFile)
+	for _, file := range files {
+		byPath[file.Path] = file
+	}
+	assert.Equal(t, string(blobs["2"]), byPath["src/main.rs"].Content)
+	assert.Equal(t, "base64", byPath["assets/logo.png"].Encoding)
+
+	// The files kept are bounded, from the tree and from the tarball
+	bounded := options
+	bounded.MaxTotalSize = 30
+	_, err = client.FetchRepoFilesRecursively(context.Background(), "secret", "linera-io", "counter", bounded)
+	assert.ErrorContains(t, err, "repository files exceed 30 bytes")
+	truncated.Store(false)
+	_, err = client.FetchRepoFilesRecursively(context.Background(), "secret", "linera-io", "counter", bounded)
+	assert.ErrorContains(t, err, "repository files exceed 30 bytes")
+
+	_, err = client.FetchRepoFilesRecursively(context.Background(), "secret", "linera-io", "missing", RepoFetchOptions{})
+	assert.ErrorContains(t, err, "Not Found")
+}
@@ -0,0 +1,261 @@
+package solver
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/nacl/secretbox"
+)
+
+const (
+	// oauthFlowTTL is how long a user has to sign in on GitHub
+	oauthFlowTTL = 10 * time.Minute
+	// maxOAuthFlows bounds the sign-ins in progress
+	maxOAuthFlows = 1000
+)
+
+// ErrTooManyOAuthFlows is returned when too many sign-ins are in progress
+var ErrTooManyOAuthFlows = errors.New("too many GitHub sign-ins in progress")
+
+// randomToken returns n random bytes, base64url-encoded
+func randomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate random token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// PKCEChallenge returns the S256 code challenge of a PKCE code verifier
+func PKCEChallenge(verifier string) string {
+	digest := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(digest[:])
+}
+
+// OAuthFlow is a GitHub sign-in in progress
+type OAuthFlow struct {
+	// State ties the callback to this sign-in
+	State string
+	// Verifier is the PKCE code verifier, its challenge goes to GitHub
+	Verifier string
+	// Redirect is where the user goes once signed in
+	Redirect  string
+	ExpiresAt time.Time
+}
+
+// OAuthFlows keeps the sign-ins in progress in memory, until their callback
+type OAuthFlows struct {
+	mu    sync.Mutex
+	flows map[string]*OAuthFlow
+}
+
+// NewOAuthFlows returns an empty set of sign-ins
+func NewOAuthFlows() *OAuthFlows {
+	return &OAuthFlows{flows: make(map[string]*OAuthFlow)}
+}
+
+// Begin starts a sign-in returning to redirect, with a random state and
+// PKCE code verifier
+func (f *OAuthFlows) Begin(redirect string) (*OAuthFlow, error) {
+	state, err := GenerateRandomState()
+	if err != nil {
+		return nil, err
+	}
+	verifier, err := randomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	flow := &OAuthFlow{State: state, Verifier: verifier, Redirect: redirect, ExpiresAt: time.Now().Add(oauthFlowTTL)}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.flows) >= maxOAuthFlows {
+		f.pruneLocked()
+		if len(f.flows) >= maxOAuthFlows {
+			return nil, ErrTooManyOAuthFlows
+		}
+	}
+	f.flows[state] = flow
+	return flow, nil
+}
+
+// Finish ends the sign-in with state, which can only be finished once
+func (f *OAuthFlows) Finish(state string) (*OAuthFlow, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	flow, ok := f.flows[state]
+	if !ok {
+		return nil, false
+	}
+	delete(f.flows, state)
+	if time.Now().After(flow.ExpiresAt) {
+		return nil, false
+	}
+	return flow, true
+}
+
+func (f *OAuthFlows) pruneLocked() {
+	now := time.Now()
+	for state, flow := range f.flows {
+		if now.After(flow.ExpiresAt) {
+			delete(f.flows, state)
+		}
+	}
+}
+
+// storedSession is a session as saved: the access token sealed with the
+// store key
+type storedSession struct {
+	Nonce      string    `json:"nonce"`
+	Ciphertext string    `json:"ciphertext"`
+	CreatedAt  time.Time `json:"createdAt"`
+	ExpiresAt  time.Time `json:"expiresAt"`
+}
+
+// SessionStore maps opaque session IDs to GitHub access tokens. Tokens are
+// sealed with secretbox under the store key and sessions are saved under the
+// SHA-256 of their ID, so the file gives away neither.
+type SessionStore struct {
+	mu       sync.Mutex
+	path     string
+	key      *[32]byte
+	ttl      time.Duration
+	sessions map[string]*storedSession
+}
+
+// NewSessionStore loads the sessions saved at path, sealed with key. An
+// empty path keeps them in memory only.
+func NewSessionStore(path string, key *[32]byte, ttl time.Duration) (*SessionStore, error) {
+	s := &SessionStore{path: path, key: key, ttl: ttl, sessions: make(map[string]*storedSession)}
+	if err := readJSONFile(path, &s.sessions); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// LoadSessionKey reads a hex-encoded 32-byte key from the file at path. An
+// empty path returns a random key, so sessions only last until a restart.
+func LoadSessionKey(path string) (*[32]byte, error) {
+	var key [32]byte
+	if path == "" {
+		if _, err := rand.Read(key[:]); err != nil {
+			return nil, fmt.Errorf("failed to generate session key: %w", err)
+		}
+		return &key, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	decoded, err := hex.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil || len(decoded) != len(key) {
+		return nil, fmt.Errorf("%s must hold %d hex-encoded bytes", path, len(key))
+	}
+	copy(key[:], decoded)
+	return &key, nil
+}
+
+// sessionKey is what a session ID is saved under
+func sessionKey(id string) string {
+	digest := sha256.Sum256([]byte(id))
+	return hex.EncodeToString(digest[:])
+}
+
+// Create starts a session holding token and returns its ID
+func (s *SessionStore) Create(token string) (string, error) {
+	id, err := randomToken(32)
+	if err != nil {
+		return "", err
+	}
+	var nonce [24]byte
+	if _, err := rand.Read(nonce[:]); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	now := time.Now().UTC()
+	session := &storedSession{
+		Nonce:      hex.EncodeToString(nonce[:]),
+		Ciphertext: hex.EncodeToString(secretbox.Seal(nil, []byte(token), &nonce, s.key)),
+		CreatedAt:  now,
+		ExpiresAt:  now.Add(s.ttl),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pruneLocked()
+	key := sessionKey(id)
+	s.sessions[key] = session
+	if err := writeJSONFile(s.path, s.sessions); err != nil {
+		delete(s.sessions, key)
+		return "", err
+	}
+	return id, nil
+}
+
+// Token returns the access token of the session, unless it ended
+func (s *SessionStore) Token(id string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	session, ok := s.sessions[sessionKey(id)]
+	if !ok || time.Now().After(session.ExpiresAt) {
+		return "", false
+	}
+	return s.open(session)
+}
+
+// Delete ends the session and returns its access token
+func (s *SessionStore) Delete(id string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := sessionKey(id)
+	session, ok := s.sessions[key]
+	if !ok {
+		return "", false
+	}
+	delete(s.sessions, key)
+	if err := writeJSONFile(s.path, s.sessions); err != nil {
+		Logger.Printf("Failed to save GitHub sessions: %v", err)
+	}
+	if time.Now().After(session.ExpiresAt) {
+		return "", false
+	}
+	return s.open(session)
+}
+
+// open decrypts the token of a session, failing for sessions sealed with
+// another key
+func (s *SessionStore) open(session *storedSession) (string, bool) {
+	var nonce [24]byte
+	nonceBytes, err := hex.DecodeString(session.Nonce)
+	if err != nil || len(nonceBytes) != len(nonce) {
+		return "", false
+	}
+	copy(nonce[:], nonceBytes)
+	ciphertext, err := hex.DecodeString(session.Ciphertext)
+	if err != nil {
+		return "", false
+	}
+	token, ok := secretbox.Open(nil, ciphertext, &nonce, s.key)
+	if !ok {
+		return "", false
+	}
+	return string(token), true
+}
+
+// pruneLocked drops expired sessions, saved with the next change
+func (s *SessionStore) pruneLocked() {
+	now := time.Now()
+	for key, session := range s.sessions {
+		if now.After(session.ExpiresAt) {
+			delete(s.sessions, key)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package solver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessionStore(t *testing.T) {
+	dir := t.TempDir()
+	keyFile := filepath.Join(dir, "session.key")
+	require.NoError(t, os.WriteFile(keyFile, []byte(strings.Repeat("ab", 32)+"\n"), 0600))
+	key, err := LoadSessionKey(keyFile)
+	require.NoError(t, err)
+
+	path := filepath.Join(dir, "github_sessions.json")
+	sessions, err := NewSessionStore(path, key, time.Hour)
+	require.NoError(t, err)
+	id, err := sessions.Create("gho_secret")
+	require.NoError(t, err)
+
+	// The file gives away neither the token nor the session ID
+	saved, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.NotContains(t, string(saved), "gho_secret")
+	assert.NotContains(t, string(saved), id)
+
+	// Sessions outlive a restart with the same key only
+	reloaded, err := NewSessionStore(path, key, time.Hour)
+	require.NoError(t, err)
+	token, ok := reloaded.Token(id)
+	require.True(t, ok)
+	assert.Equal(t, "gho_secret", token)
+	otherKey, err := LoadSessionKey("")
+	require.NoError(t, err)
+	rekeyed, err := NewSessionStore(path, otherKey, time.Hour)
+	require.NoError(t, err)
+	_, ok = rekeyed.Token(id)
+	assert.False(t, ok)
+
+	token, ok = reloaded.Delete(id)
+	require.True(t, ok)
+	assert.Equal(t, "gho_secret", token)
+	_, ok = reloaded.Token(id)
+	assert.False(t, ok)
+
+	expiring, err := NewSessionStore("", key, -time.Second)
+	require.NoError(t, err)
+	id, err = expiring.Create("gho_expired")
+	require.NoError(t, err)
+	_, ok = expiring.Token(id)
+	assert.False(t, ok)
+
+	require.NoError(t, os.WriteFile(keyFile, []byte("short"), 0600))
+	_, err = LoadSessionKey(keyFile)
+	assert.ErrorContains(t, err, "must hold 32 hex-encoded bytes")
+}
+
+func TestGithubOAuth(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login/oauth/access_token":
+			require.NoError(t, r.ParseForm())
+			assert.Equal(t, "client-secret", r.PostForm.Get("client_secret"))
+			if r.PostForm.Get("code") != "good-code" || r.PostForm.Get("code_verifier") == "" {
+				// GitHub answers errors with 200
+				json.NewEncoder(w).Encode(map[string]string{
+					"error":             "bad_verification_code",
+					"error_description": "The code passed is incorrect or expired.",
+				})
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]string{"access_token": "gho_" + r.PostForm.Get("code_verifier")[:4], "token_type": "bearer"})
+		case "/api/v3/applications/client-id/token":
+			assert.Equal(t, http.MethodDelete, r.Method)
+			user, password, _ := r.BasicAuth()
+			assert.Equal(t, "client-id", user)
+			assert.Equal(t, "client-secret", password)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	client := NewGithubClient("client-id", "client-secret", "http://localhost:3001/auth/github/callback")
+	client.WebURL = server.URL
+	client.API = NewGithubAPI(server.URL + "/api/v3")
+
+	flows := NewOAuthFlows()
+	flow, err := flows.Begin("http://localhost:3002/")
+	require.NoError(t, err)
+	assert.Len(t, flow.State, 43)
+
+	authorize, err := url.Parse(client.AuthorizeURL(flow))
+	require.NoError(t, err)
+	assert.Equal(t, flow.State, authorize.Query().Get("state"))
+	assert.Equal(t, "S256", authorize.Query().Get("code_challenge_method"))
+	assert.Equal(t, PKCEChallenge(flow.Verifier), authorize.Query().Get("code_challenge"))
+	assert.NotEqual(t, flow.Verifier, authorize.Query().Get("code_challenge"))
+
+	// A sign-in finishes once
+	finished, ok := flows.Finish(flow.State)
+	require.True(t, ok)
+	assert.Equal(t, "http://localhost:3002/", finished.Redirect)
+	_, ok = flows.Finish(flow.State)
+	assert.False(t, ok)
+
+	token, err := client.ExchangeCodeForToken(context.Background(), "good-code", flow.Verifier)
+	require.NoError(t, err)
+	assert.Equal(t, "gho_"+flow.Verifier[:4], token)
+
+	_, err = client.ExchangeCodeForToken(context.Background(), "used-code", flow.Verifier)
+	var oauthErr *GithubOAuthError
+	require.True(t, errors.As(err, &oauthErr), err)
+	assert.Equal(t, "bad_verification_code", oauthErr.Code)
+
+	require.NoError(t, client.RevokeToken(context.Background(), token))
+
+	// The RFC 7636 example
+	assert.Equal(t, "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM", PKCEChallenge("dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"))
+}
@@ -0,0 +1,371 @@
+package solver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/linera-protocol/examples/universal-solver/client/solver/keys"
+)
+
+// defaultSweepCooldown leaves time for a cold sweep to confirm before the pool
+// balance is read for the next one
+const defaultSweepCooldown = 30 * time.Minute
+
+// maxColdSweeps is how many past sweeps the monitor keeps on disk
+const maxColdSweeps = 100
+
+// ErrLowLiquidity is returned for payouts on a chain whose pool is below its floor
+var ErrLowLiquidity = errors.New("payouts paused: pool liquidity is below its floor")
+
+// HotWalletLimits bounds the pool balance kept online on one chain, in whole
+// units of the chain's native asset. Zero disables a bound.
+type HotWalletLimits struct {
+	// Floor pauses payouts on the chain while the pool holds less
+	Floor float64 `json:"floor"`
+	// Ceiling is the most the pool keeps, the excess is swept to ColdAddress
+	Ceiling     float64 `json:"ceiling"`
+	ColdAddress string  `json:"coldAddress"`
+	// Cooldown is the wait after a sweep before sweeping again (default 30m)
+	Cooldown string `json:"cooldown"`
+}
+
+// LoadHotWalletLimits reads the limits per chain from the JSON file at path, e.g.
+// {"ethereum": {"floor": 5, "ceiling": 50, "coldAddress": "0x..."}}
+func LoadHotWalletLimits(path string) (map[string]HotWalletLimits, error) {
+	limits := make(map[string]HotWalletLimits)
+	if err := readJSONFile(path, &limits); err != nil {
+		return nil, err
+	}
+	return limits, nil
+}
+
+// pausedChains are the chains whose payouts wait for the pool to be refilled
+var pausedChains = struct {
+	sync.Mutex
+	chains map[string]bool
+}{chains: make(map[string]bool)}
+
+// checkLiquidity fails when payouts on chain are paused
+func checkLiquidity(chain string) error {
+	pausedChains.Lock()
+	defer pausedChains.Unlock()
+	if pausedChains.chains[chain] {
+		return fmt.Errorf("%w on %s", ErrLowLiquidity, chain)
+	}
+	return nil
+}
+
+// setPaused records whether payouts on chain are paused and reports whether
+// that changed
+func setPaused(chain string, paused bool) bool {
+	pausedChains.Lock()
+	defer pausedChains.Unlock()
+	changed := pausedChains.chains[chain] != paused
+	pausedChains.chains[chain] = paused
+	return changed
+}
+
+// hotWallet is the state the monitor keeps per chain
+type hotWallet struct {
+	limits    HotWalletLimits
+	token     Token
+	cooldown  time.Duration
+	lastSweep time.Time
+}
+
+// ColdSweep is one sweep of pool funds to cold storage. Sweeps are recorded
+// before they are signed, so the signing policy can check them against the
+// record, and kept once sent.
+type ColdSweep struct {
+	Reference string    `json:"reference"`
+	Time      time.Time `json:"time"`
+	Chain     string    `json:"chain"`
+	Recipient string    `json:"recipient"`
+	// Amount is in base units of the chain's native asset
+	Amount string `json:"amount"`
+	TxHash string `json:"txHash,omitempty"`
+}
+
+// HotWalletMonitor keeps each pool between its floor and ceiling: it sweeps
+// the excess to cold storage and pauses payouts while a pool is short
+type HotWalletMonitor struct {
+	client   *Client
+	interval time.Duration
+	alertURL string
+	wallets  map[string]*hotWallet
+
+	mu     sync.Mutex
+	path   string
+	sweeps []*ColdSweep
+}
+
+// NewHotWalletMonitor returns a monitor checking the pools against limits
+// every interval. Alerts are logged and, with alertURL, posted as JSON.
+// Sweeps are saved at path, so cooldowns outlast a restart; an empty path
+// keeps them in memory only.
+func NewHotWalletMonitor(client *Client, limits map[string]HotWalletLimits, interval time.Duration, alertURL, path string) (*HotWalletMonitor, error) {
+	m := &HotWalletMonitor{
+		client:   client,
+		interval: interval,
+		alertURL: alertURL,
+		wallets:  make(map[string]*hotWallet),
+		path:     path,
+	}
+	if err := readJSONFile(path, &m.sweeps); err != nil {
+		return nil, err
+	}
+	for chain, limit := range limits {
+		token, err := nativeToken(chain)
+		if err != nil {
+			return nil, err
+		}
+		if limit.Ceiling > 0 && limit.ColdAddress == "" {
+			return nil, fmt.Errorf("%s ceiling needs a cold address", chain)
+		}
+		if limit.Ceiling > 0 && limit.Ceiling < limit.Floor {
+			return nil, fmt.Errorf("%s ceiling %g is below floor %g", chain, limit.Ceiling, limit.Floor)
+		}
+		wallet := &hotWallet{limits: limit, token: token, cooldown: defaultSweepCooldown}
+		if limit.Cooldown != "" {
+			wallet.cooldown, err = time.ParseDuration(limit.Cooldown)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s cooldown: %w", chain, err)
+			}
+		}
+		for _, sweep := range m.sweeps {
+			if sweep.Chain == chain && sweep.Time.After(wallet.lastSweep) {
+				wallet.lastSweep = sweep.Time
+			}
+		}
+		m.wallets[chain] = wallet
+
+		// The signing policy only lets sweeps leave the solver for cold storage
+		if policy, ok := signer.(*PolicySigner); ok && limit.ColdAddress != "" {
+			policy.AllowSweepRecipient(chain, limit.ColdAddress)
+		}
+	}
+	VerifySigningRecords(SigningRecordSweep, m.VerifySweep)
+	return m, nil
+}
+
+// nativeToken returns the native asset of chain
+func nativeToken(chain string) (Token, error) {
+	symbols := map[string]string{
+		keys.ChainEthereum: "ETH",
+		keys.ChainSolana:   "SOL",
+		keys.ChainBitcoin:  "BTC",
+	}
+	token, ok := LookupToken(symbols[chain])
+	if !ok {
+		return Token{}, fmt.Errorf("unsupported chain: %s", chain)
+	}
+	return token, nil
+}
+
+// Run checks the pools until ctx is done
+func (m *HotWalletMonitor) Run(ctx context.Context) {
+	Logger.Printf("Checking hot wallet limits every %s", m.interval)
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+	for {
+		m.Check(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Check reads every limited pool balance once, pausing or resuming payouts
+// and sweeping any excess
+func (m *HotWalletMonitor) Check(ctx context.Context) {
+	for chain, wallet := range m.wallets {
+		if err := m.check(ctx, chain, wallet); err != nil {
+			Logger.Printf("Hot wallet check failed on %s: %v", chain, err)
+		}
+	}
+}
+
+func (m *HotWalletMonitor) check(ctx context.Context, chain string, wallet *hotWallet) error {
+	pool, err := m.client.getPoolAddress(wallet.token.Symbol)
+	if err != nil {
+		return fmt.Errorf("failed to get pool address: %w", err)
+	}
+	balance, err := m.client.poolBalance(chain, pool)
+	if err != nil {
+		return err
+	}
+
+	limits := wallet.limits
+	paused := limits.Floor > 0 && balance.Amount < limits.Floor
+	if setPaused(chain, paused) {
+		m.alert(chain, balance, paused)
+	}
+
+	if limits.Ceiling <= 0 || balance.Amount <= limits.Ceiling || time.Since(wallet.lastSweep) < wallet.cooldown {
+		return nil
+	}
+	excess := wallet.token.ToBaseUnits(balance.Amount - limits.Ceiling)
+	sweep, err := m.recordSweep(chain, limits.ColdAddress, excess)
+	if err != nil {
+		return err
+	}
+	txHash, err := m.client.sweepNative(ctx, chain, sweep.Reference, pool, limits.ColdAddress, excess)
+	if err != nil || txHash == "" {
+		m.finishSweep(sweep.Reference, "")
+		if err != nil {
+			return fmt.Errorf("failed to sweep to cold storage: %w", err)
+		}
+		return nil
+	}
+	m.finishSweep(sweep.Reference, txHash)
+	wallet.lastSweep = sweep.Time
+	Logger.Printf("Swept %g %s above the %g ceiling to cold storage %s in %s",
+		wallet.token.FromBaseUnits(excess), wallet.token.Symbol, limits.Ceiling, limits.ColdAddress, txHash)
+	return nil
+}
+
+// recordSweep saves a sweep of amount to recipient before it is signed
+func (m *HotWalletMonitor) recordSweep(chain, recipient string, amount *big.Int) (*ColdSweep, error) {
+	reference, err := newRecordReference()
+	if err != nil {
+		return nil, err
+	}
+	sweep := &ColdSweep{
+		Reference: reference,
+		Time:      time.Now().UTC(),
+		Chain:     chain,
+		Recipient: recipient,
+		Amount:    amount.String(),
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sweeps = append(m.sweeps, sweep)
+	if len(m.sweeps) > maxColdSweeps {
+		m.sweeps = m.sweeps[len(m.sweeps)-maxColdSweeps:]
+	}
+	if err := writeJSONFile(m.path, m.sweeps); err != nil {
+		m.sweeps = m.sweeps[:len(m.sweeps)-1]
+		return nil, err
+	}
+	return sweep, nil
+}
+
+// finishSweep records the transaction of a sweep, or forgets a sweep that
+// sent nothing when txHash is empty
+func (m *HotWalletMonitor) finishSweep(reference, txHash string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, sweep := range m.sweeps {
+		if sweep.Reference != reference {
+			continue
+		}
+		if txHash == "" {
+			m.sweeps = append(m.sweeps[:i], m.sweeps[i+1:]...)
+		} else {
+			sweep.TxHash = txHash
+		}
+		break
+	}
+	if err := writeJSONFile(m.path, m.sweeps); err != nil {
+		Logger.Printf("Failed to save cold sweeps: %v", err)
+	}
+}
+
+// VerifySweep checks that a sweep signing record sends a recorded, unsent
+// sweep to its cold address, for at most its amount
+func (m *HotWalletMonitor) VerifySweep(_ context.Context, record SigningRecord) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return verifyColdSweep(m.sweeps, record)
+}
+
+// ColdSweepVerifier checks sweep signing records against the sweeps persisted
+// at path, reading them afresh for every record
+func ColdSweepVerifier(path string) RecordVerifier {
+	return func(_ context.Context, record SigningRecord) error {
+		var sweeps []*ColdSweep
+		if err := readJSONFile(path, &sweeps); err != nil {
+			return err
+		}
+		return verifyColdSweep(sweeps, record)
+	}
+}
+
+func verifyColdSweep(sweeps []*ColdSweep, record SigningRecord) error {
+	for _, sweep := range sweeps {
+		if sweep.Reference != record.Reference {
+			continue
+		}
+		if sweep.TxHash != "" {
+			return fmt.Errorf("sweep was sent in %s", sweep.TxHash)
+		}
+		if sweep.Chain != record.Chain || sweep.Recipient != record.Recipient {
+			return fmt.Errorf("sweep is to %s on %s", sweep.Recipient, sweep.Chain)
+		}
+		amount, ok := new(big.Int).SetString(sweep.Amount, 10)
+		if !ok || record.Amount == nil || record.Amount.Cmp(amount) > 0 {
+			return fmt.Errorf("sweep is for %s", sweep.Amount)
+		}
+		return nil
+	}
+	return ErrUnknownRecord
+}
+
+// poolBalance reads the pool balance with the chain's balance reader
+func (c *Client) poolBalance(chain, pool string) (*Balance, error) {
+	switch chain {
+	case keys.ChainEthereum:
+		return c.GetEthereumBalance(pool)
+	case keys.ChainSolana:
+		return c.GetSolanaBalance(pool)
+	case keys.ChainBitcoin:
+		return c.GetBitcoinBalance(pool)
+	default:
+		return nil, fmt.Errorf("unsupported chain: %s", chain)
+	}
+}
+
+// alert reports a pool falling below or recovering above its floor
+func (m *HotWalletMonitor) alert(chain string, balance *Balance, paused bool) {
+	floor := m.wallets[chain].limits.Floor
+	if paused {
+		Logger.Printf("ALERT: %s pool holds %g %s, below its floor of %g. Payouts are paused.", chain, balance.Amount, balance.Symbol, floor)
+	} else {
+		Logger.Printf("%s pool holds %g %s, back above its floor of %g. Payouts resumed.", chain, balance.Amount, balance.Symbol, floor)
+	}
+	if m.alertURL == "" {
+		return
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"chain":   chain,
+		"pool":    balance.Address,
+		"balance": balance.Amount,
+		"symbol":  balance.Symbol,
+		"floor":   floor,
+		"paused":  paused,
+	})
+	if err != nil {
+		Logger.Printf("Failed to encode liquidity alert: %v", err)
+		return
+	}
+	resp, err := m.client.http.Post(m.alertURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		Logger.Printf("Failed to send liquidity alert: %v", err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= http.StatusBadRequest {
+		Logger.Printf("Liquidity alert rejected: %s", resp.Status)
+	}
+}
@@ -0,0 +1,124 @@
+package solver
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHotWalletLimits(t *testing.T) {
+	chainKeys := testChainKeys(t)
+	SetSigner(NewLocalSigner(chainKeys))
+	require.NoError(t, UseSigningPolicy(&SigningPolicyConfig{}, ""))
+	defer SetSigner(NewLocalSigner(chainKeys))
+
+	// Ceilings need somewhere to sweep to, above the floor
+	_, err := NewHotWalletMonitor(nil, map[string]HotWalletLimits{"ethereum": {Ceiling: 10}}, 0, "", "")
+	assert.Error(t, err)
+	_, err = NewHotWalletMonitor(nil, map[string]HotWalletLimits{"ethereum": {Floor: 10, Ceiling: 5, ColdAddress: "0x01"}}, 0, "", "")
+	assert.Error(t, err)
+	_, err = NewHotWalletMonitor(nil, map[string]HotWalletLimits{"dogecoin": {Floor: 1}}, 0, "", "")
+	assert.Error(t, err)
+
+	// The cold address becomes a sweep recipient, other addresses do not,
+	// and sweeps must have been recorded by the monitor
+	pool := "0x9858EfFD232B4033E47d90003D41EC34EcaEda94"
+	cold := "0x6Fac4D18c912343BF86fa7049364Dd4E424Ab9C0"
+	path := filepath.Join(t.TempDir(), "cold_sweeps.json")
+	limits := map[string]HotWalletLimits{"ethereum": {Floor: 1, Ceiling: 10, ColdAddress: cold}}
+	monitor, err := NewHotWalletMonitor(nil, limits, 0, "", path)
+	require.NoError(t, err)
+	sweepTo := func(reference, to string, value int64) (*types.Transaction, error) {
+		ctx := WithSigningRecord(context.Background(), SigningRecord{
+			Kind: SigningRecordSweep, Reference: reference, Chain: "ethereum", Recipient: to, Asset: "ETH", Amount: big.NewInt(value),
+		})
+		tx := types.NewTransaction(0, common.HexToAddress(to), big.NewInt(value), 21000, big.NewInt(1e9), nil)
+		return signer.SignEthereumTransaction(ctx, pool, tx, big.NewInt(1337))
+	}
+	_, err = sweepTo("unrecorded", cold, 1e18)
+	assertPolicyDenied(t, err, PolicyRuleUnknownRecord)
+
+	sweep, err := monitor.recordSweep("ethereum", cold, big.NewInt(1e18))
+	require.NoError(t, err)
+	_, err = sweepTo(sweep.Reference, "0x0000000000000000000000000000000000000001", 1e18)
+	assertPolicyDenied(t, err, PolicyRuleRecipient)
+	_, err = sweepTo(sweep.Reference, cold, 2e18)
+	assertPolicyDenied(t, err, PolicyRuleRecordMismatch)
+	_, err = sweepTo(sweep.Reference, cold, 1e18)
+	assert.NoError(t, err)
+	monitor.finishSweep(sweep.Reference, "0xsent")
+
+	// The sweep outlasts a restart, keeping its cooldown
+	restarted, err := NewHotWalletMonitor(nil, limits, 0, "", path)
+	require.NoError(t, err)
+	assert.WithinDuration(t, sweep.Time, restarted.wallets["ethereum"].lastSweep, time.Second)
+	assert.ErrorContains(t, restarted.VerifySweep(context.Background(), SigningRecord{Reference: sweep.Reference}), "sweep was sent")
+
+	// Payouts on a chain below its floor are paused until it recovers
+	assert.True(t, setPaused("ethereum", true))
+	assert.True(t, errors.Is(checkLiquidity("ethereum"), ErrLowLiquidity))
+	assert.NoError(t, checkLiquidity("solana"))
+	assert.False(t, setPaused("ethereum", true))
+	assert.True(t, setPaused("ethereum", false))
+	assert.NoError(t, checkLiquidity("ethereum"))
+}
+
+func TestHotWalletSweep(t *testing.T) {
+	chainKeys := testChainKeys(t)
+	SetSigner(NewLocalSigner(chainKeys))
+	require.NoError(t, UseSigningPolicy(&SigningPolicyConfig{}, ""))
+	defer SetSigner(NewLocalSigner(chainKeys))
+
+	pool := "0x9858EfFD232B4033E47d90003D41EC34EcaEda94"
+	cold := "0x6Fac4D18c912343BF86fa7049364Dd4E424Ab9C0"
+	token, _ := LookupToken("ETH")
+	solverServer := fakeSolver(t, map[string]string{"ETH": pool}, 1)
+	defer solverServer.Close()
+	node := newFakeEthereumNode(t, 1)
+	node.deposits[common.HexToAddress(pool)] = fakeDeposit{block: 0, amount: token.ToBaseUnits(15)}
+	ethServer := httptest.NewServer(node)
+	defer ethServer.Close()
+	InitRPCEndpoints(ethServer.URL, "")
+	defer setPaused("ethereum", false)
+
+	path := filepath.Join(t.TempDir(), "cold_sweeps.json")
+	limits := map[string]HotWalletLimits{"ethereum": {Floor: 1, Ceiling: 10, ColdAddress: cold, Cooldown: "1h"}}
+	monitor, err := NewHotWalletMonitor(NewClient(solverServer.URL), limits, time.Minute, "", path)
+	require.NoError(t, err)
+
+	// The excess above the ceiling goes to cold storage, through the policy
+	monitor.Check(context.Background())
+	require.Len(t, node.sent, 1)
+	assert.Equal(t, cold, node.sent[0].To().Hex())
+	assert.Equal(t, big.NewInt(5e18), node.sent[0].Value())
+	require.Len(t, monitor.sweeps, 1)
+	assert.Equal(t, "5000000000000000000", monitor.sweeps[0].Amount)
+	assert.Equal(t, node.sent[0].Hash().Hex(), monitor.sweeps[0].TxHash)
+
+	// Refills above the ceiling wait for the cooldown, across restarts too
+	node.deposits[common.HexToAddress(pool)] = fakeDeposit{block: 0, amount: token.ToBaseUnits(30)}
+	monitor.Check(context.Background())
+	assert.Len(t, node.sent, 1)
+	restarted, err := NewHotWalletMonitor(NewClient(solverServer.URL), limits, time.Minute, "", path)
+	require.NoError(t, err)
+	restarted.Check(context.Background())
+	assert.Len(t, node.sent, 1)
+
+	// After the cooldown the pool is swept back to its ceiling
+	restarted.wallets["ethereum"].lastSweep = time.Now().Add(-time.Hour)
+	restarted.Check(context.Background())
+	require.Len(t, node.sent, 2)
+	assert.Equal(t, cold, node.sent[1].To().Hex())
+	remaining := node.balance(common.HexToAddress(pool), "latest")
+	assert.InDelta(t, 10, token.FromBaseUnits(remaining), 1e-3)
+	assert.Len(t, restarted.sweeps, 2)
+}
@@ -0,0 +1,450 @@
+package solver
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Swap intent lifecycle
+const (
+	IntentStatusPending   = "pending"
+	IntentStatusClaimed   = "claimed"
+	IntentStatusCompleted = "completed"
+	// IntentStatusSwept marks a completed intent whose deposit address was
+	// emptied into the pool
+	IntentStatusSwept = "swept"
+	// IntentStatusExpired marks a pending intent whose deposit address is no
+	// longer watched
+	IntentStatusExpired = "expired"
+)
+
+const (
+	// intentRetention is how long pending and expired intents are kept past
+	// their expiry before they are pruned
+	intentRetention = 24 * time.Hour
+	// maxPendingIntents bounds the unexpired intents waiting for a deposit
+	maxPendingIntents = 10000
+)
+
+// ErrTooManyIntents is returned when too many intents wait for a deposit
+var ErrTooManyIntents = errors.New("too many swap intents are waiting for a deposit")
+
+// SwapIntent is a swap a user registers before depositing. The reference is
+// attached to the deposit (e.g. as a Solana memo) so the deposit can be matched
+// to its payout parameters even when many users share one pool address.
+// Intents with a deposit address need no reference: everything paid to the
+// address belongs to the intent.
+type SwapIntent struct {
+	Reference          string `json:"reference"`
+	Chain              string `json:"chain"`
+	FromToken          string `json:"fromToken,omitempty"`
+	ToToken            string `json:"toToken"`
+	DestinationAddress string `json:"destinationAddress"`
+	Status             string `json:"status"`
+	// DepositIndex is the HD index of DepositAddress (see keys.DepositAccountSpec)
+	DepositIndex   *uint32   `json:"depositIndex,omitempty"`
+	DepositAddress string    `json:"depositAddress,omitempty"`
+	DepositTxHash  string    `json:"depositTxHash,omitempty"`
+	PayoutTxHash   string    `json:"payoutTxHash,omitempty"`
+	SweepTxHash    string    `json:"sweepTxHash,omitempty"`
+	CreatedAt      time.Time `json:"createdAt"`
+	ExpiresAt      time.Time `json:"expiresAt"`
+}
+
+// DepositDeriver returns the deposit address with the given HD index on chain
+type DepositDeriver func(chain string, index uint32) (string, error)
+
+// IntentStore keeps swap intents keyed by reference, persisted to a JSON file
+type IntentStore struct {
+	mu      sync.Mutex
+	path    string
+	ttl     time.Duration
+	intents map[string]*SwapIntent
+	// nextDepositIndex is one past the highest deposit index handed out. The
+	// intent holding the highest index is never pruned, so indexes are never
+	// reused.
+	nextDepositIndex uint32
+}
+
+// NewIntentStore loads the intents saved at path. An empty path keeps intents
+// in memory only.
+func NewIntentStore(path string, ttl time.Duration) (*IntentStore, error) {
+	s := &IntentStore{
+		path:    path,
+		ttl:     ttl,
+		intents: make(map[string]*SwapIntent),
+	}
+	if err := readJSONFile(path, &s.intents); err != nil {
+		return nil, err
+	}
+	for _, intent := range s.intents {
+		if intent.DepositIndex != nil && *intent.DepositIndex >= s.nextDepositIndex {
+			s.nextDepositIndex = *intent.DepositIndex + 1
+		}
+	}
+	return s, nil
+}
+
+// Create registers a new pending intent and returns it. With derive, the
+// intent gets a fresh deposit address at the next HD index.
+func (s *IntentStore) Create(chain, fromToken, toToken, destinationAddress string, derive DepositDeriver) (*SwapIntent, error) {
+	reference, err := newSwapReference()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	intent := &SwapIntent{
+		Reference:          reference,
+		Chain:              chain,
+		FromToken:          fromToken,
+		ToToken:            toToken,
+		DestinationAddress: destinationAddress,
+		Status:             IntentStatusPending,
+		CreatedAt:          now,
+		ExpiresAt:          now.Add(s.ttl),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.pruneLocked(now)
+	if s.pendingLocked(now) >= maxPendingIntents {
+		return nil, ErrTooManyIntents
+	}
+	if derive != nil {
+		index := s.nextDepositIndex
+		address, err := derive(chain, index)
+		if err != nil {
+			return nil, fmt.Errorf("failed to derive deposit address: %w", err)
+		}
+		intent.DepositIndex = &index
+		intent.DepositAddress = address
+	}
+
+	s.intents[reference] = intent
+	if err := s.saveLocked(); err != nil {
+		delete(s.intents, reference)
+		return nil, err
+	}
+	if intent.DepositIndex != nil {
+		s.nextDepositIndex++
+	}
+
+	copied := *intent
+	return &copied, nil
+}
+
+// Get returns a copy of the intent with the given reference
+func (s *IntentStore) Get(reference string) (*SwapIntent, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	intent, ok := s.intents[reference]
+	if !ok {
+		return nil, false
+	}
+	copied := *intent
+	return &copied, true
+}
+
+// Claim binds a deposit to a pending intent so it cannot be paid out twice
+func (s *IntentStore) Claim(reference, depositTxHash string) (*SwapIntent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.claimLocked(reference, depositTxHash, true)
+}
+
+// ClaimFunded claims an intent whose deposit address was funded. The address
+// belongs to the intent alone, so it is claimed even past its expiry.
+func (s *IntentStore) ClaimFunded(reference string) (*SwapIntent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	intent, ok := s.intents[reference]
+	if ok && intent.DepositAddress == "" {
+		return nil, fmt.Errorf("swap reference %s has no deposit address", reference)
+	}
+	return s.claimLocked(reference, "", false)
+}
+
+func (s *IntentStore) claimLocked(reference, depositTxHash string, checkExpiry bool) (*SwapIntent, error) {
+	intent, ok := s.intents[reference]
+	if !ok {
+		return nil, fmt.Errorf("unknown swap reference: %s", reference)
+	}
+	if intent.Status != IntentStatusPending {
+		return nil, fmt.Errorf("swap reference %s is already %s", reference, intent.Status)
+	}
+	if checkExpiry && time.Now().After(intent.ExpiresAt) {
+		return nil, fmt.Errorf("swap reference %s has expired", reference)
+	}
+
+	intent.Status = IntentStatusClaimed
+	intent.DepositTxHash = depositTxHash
+	if err := s.saveLocked(); err != nil {
+		intent.Status = IntentStatusPending
+		intent.DepositTxHash = ""
+		return nil, err
+	}
+
+	copied := *intent
+	return &copied, nil
+}
+
+// ClaimDeposit records a deposit made without an intent as a claimed intent,
+// referenced by its transaction hash, so it cannot be paid out twice. A
+// deposit whose payout failed and was released may be claimed again.
+func (s *IntentStore) ClaimDeposit(chain, fromToken, toToken, destinationAddress, depositTxHash string) (*SwapIntent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	intent, ok := s.intents[depositTxHash]
+	if ok && (intent.Status != IntentStatusPending || intent.Chain != chain) {
+		return nil, fmt.Errorf("deposit %s is already %s", depositTxHash, intent.Status)
+	}
+	if !ok {
+		now := time.Now().UTC()
+		intent = &SwapIntent{
+			Reference: depositTxHash,
+			Chain:     chain,
+			CreatedAt: now,
+			ExpiresAt: now,
+		}
+		s.intents[depositTxHash] = intent
+	}
+	previous := *intent
+	intent.FromToken = fromToken
+	intent.ToToken = toToken
+	intent.DestinationAddress = destinationAddress
+	intent.Status = IntentStatusClaimed
+	intent.DepositTxHash = depositTxHash
+	if err := s.saveLocked(); err != nil {
+		if ok {
+			*intent = previous
+		} else {
+			delete(s.intents, depositTxHash)
+		}
+		return nil, err
+	}
+
+	copied := *intent
+	return &copied, nil
+}
+
+// Expire stops watching the deposit address of a pending intent
+func (s *IntentStore) Expire(reference string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	intent, ok := s.intents[reference]
+	if !ok {
+		return fmt.Errorf("unknown swap reference: %s", reference)
+	}
+	if intent.Status != IntentStatusPending {
+		return fmt.Errorf("swap reference %s is already %s", reference, intent.Status)
+	}
+	intent.Status = IntentStatusExpired
+	if err := s.saveLocked(); err != nil {
+		intent.Status = IntentStatusPending
+		return err
+	}
+	return nil
+}
+
+// Release returns a claimed intent to pending, e.g. after a failed payout
+func (s *IntentStore) Release(reference string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	intent, ok := s.intents[reference]
+	if !ok {
+		return fmt.Errorf("unknown swap reference: %s", reference)
+	}
+	intent.Status = IntentStatusPending
+	intent.DepositTxHash = ""
+	return s.saveLocked()
+}
+
+// Complete records the payout transaction of a claimed intent
+func (s *IntentStore) Complete(reference, payoutTxHash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	intent, ok := s.intents[reference]
+	if !ok {
+		return fmt.Errorf("unknown swap reference: %s", reference)
+	}
+	intent.Status = IntentStatusCompleted
+	intent.PayoutTxHash = payoutTxHash
+	return s.saveLocked()
+}
+
+// Swept records the transaction that emptied a completed intent's deposit
+// address into the pool
+func (s *IntentStore) Swept(reference, sweepTxHash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	intent, ok := s.intents[reference]
+	if !ok {
+		return fmt.Errorf("unknown swap reference: %s", reference)
+	}
+	intent.Status = IntentStatusSwept
+	intent.SweepTxHash = sweepTxHash
+	return s.saveLocked()
+}
+
+// WithDepositAddress returns copies of the intents with a deposit address in
+// one of the given statuses
+func (s *IntentStore) WithDepositAddress(statuses ...string) []*SwapIntent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var intents []*SwapIntent
+	for _, intent := range s.intents {
+		if intent.DepositAddress == "" {
+			continue
+		}
+		for _, status := range statuses {
+			if intent.Status == status {
+				copied := *intent
+				intents = append(intents, &copied)
+				break
+			}
+		}
+	}
+	sort.Slice(intents, func(i, j int) bool {
+		return *intents[i].DepositIndex < *intents[j].DepositIndex
+	})
+	return intents
+}
+
+// VerifyPayout checks that a swap signing record pays out a claimed intent,
+// named by its reference or deposit transaction hash, to its destination
+func (s *IntentStore) VerifyPayout(_ context.Context, record SigningRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return verifyIntentPayout(s.intents, record)
+}
+
+// VerifySweep checks that a sweep signing record empties the deposit address
+// of a paid out intent
+func (s *IntentStore) VerifySweep(_ context.Context, record SigningRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return verifyIntentSweep(s.intents, record)
+}
+
+// IntentVerifiers check swap and sweep signing records against the intents
+// persisted at path, reading them afresh for every record
+func IntentVerifiers(path string) (payout, sweep RecordVerifier) {
+	load := func() (map[string]*SwapIntent, error) {
+		intents := make(map[string]*SwapIntent)
+		return intents, readJSONFile(path, &intents)
+	}
+	payout = func(_ context.Context, record SigningRecord) error {
+		intents, err := load()
+		if err != nil {
+			return err
+		}
+		return verifyIntentPayout(intents, record)
+	}
+	sweep = func(_ context.Context, record SigningRecord) error {
+		intents, err := load()
+		if err != nil {
+			return err
+		}
+		return verifyIntentSweep(intents, record)
+	}
+	return payout, sweep
+}
+
+func verifyIntentPayout(intents map[string]*SwapIntent, record SigningRecord) error {
+	intent, ok := intents[record.Reference]
+	if !ok {
+		for _, candidate := range intents {
+			if candidate.DepositTxHash != "" && candidate.DepositTxHash == record.Reference {
+				intent = candidate
+				break
+			}
+		}
+	}
+	if intent == nil {
+		return ErrUnknownRecord
+	}
+	if intent.Status != IntentStatusClaimed {
+		return fmt.Errorf("swap is %s, not claimed", intent.Status)
+	}
+	if intent.ToToken != record.Asset {
+		return fmt.Errorf("swap pays out %s", intent.ToToken)
+	}
+	// Hex addresses are case-insensitive, base58 and bech32 ones are not
+	if intent.DestinationAddress != record.Recipient &&
+		!(record.Chain == "ethereum" && strings.EqualFold(intent.DestinationAddress, record.Recipient)) {
+		return fmt.Errorf("swap pays out to %s", intent.DestinationAddress)
+	}
+	return nil
+}
+
+func verifyIntentSweep(intents map[string]*SwapIntent, record SigningRecord) error {
+	intent, ok := intents[record.Reference]
+	if !ok || intent.DepositAddress == "" {
+		return ErrUnknownRecord
+	}
+	if intent.Status != IntentStatusCompleted {
+		return fmt.Errorf("swap is %s, not paid out", intent.Status)
+	}
+	if intent.Chain != record.Chain {
+		return fmt.Errorf("deposit address is on %s", intent.Chain)
+	}
+	return nil
+}
+
+// pruneLocked drops pending and expired intents past their retention. Claimed
+// and paid out intents are kept, as they stop deposits from being paid out
+// twice.
+func (s *IntentStore) pruneLocked(now time.Time) {
+	for reference, intent := range s.intents {
+		if intent.Status != IntentStatusPending && intent.Status != IntentStatusExpired {
+			continue
+		}
+		if !now.After(intent.ExpiresAt.Add(intentRetention)) {
+			continue
+		}
+		if intent.DepositIndex != nil && *intent.DepositIndex+1 == s.nextDepositIndex {
+			continue
+		}
+		delete(s.intents, reference)
+	}
+}
+
+// pendingLocked counts the unexpired intents waiting for a deposit
+func (s *IntentStore) pendingLocked(now time.Time) int {
+	pending := 0
+	for _, intent := range s.intents {
+		if intent.Status == IntentStatusPending && !now.After(intent.ExpiresAt) {
+			pending++
+		}
+	}
+	return pending
+}
+
+func (s *IntentStore) saveLocked() error {
+	return writeJSONFile(s.path, s.intents)
+}
+
+// newSwapReference returns a random reference short enough to fit in a memo
+func newSwapReference() (string, error) {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate swap reference: %w", err)
+	}
+	return "us-" + hex.EncodeToString(b), nil
+}
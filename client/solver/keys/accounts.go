@@ -0,0 +1,196 @@
+package keys
+
+import (
+	"crypto/ecdsa"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/gagliardetto/solana-go"
+)
+
+// Chains accounts can be derived for
+const (
+	ChainEthereum = "ethereum"
+	ChainSolana   = "solana"
+	ChainBitcoin  = "bitcoin"
+)
+
+// Well-known account names
+const (
+	// AccountPool holds pool funds and signs swap payouts
+	AccountPool = "pool"
+	// AccountFaucet funds faucet requests when configured, otherwise the pool pays
+	AccountFaucet = "faucet"
+	// AccountFees collects fees
+	AccountFees = "fees"
+)
+
+// depositAccount is the BIP44 account number reserved for per-swap deposit
+// addresses, away from the pool (0') and the accounts operators configure
+const depositAccount = 100
+
+// DepositAccountSpec returns the account receiving the deposit of the
+// index-th swap intent on chain
+func DepositAccountSpec(chain string, index uint32) (AccountSpec, error) {
+	spec := AccountSpec{Chain: chain, Name: fmt.Sprintf("deposit-%d", index)}
+	switch chain {
+	case ChainEthereum:
+		spec.Path = fmt.Sprintf("m/44'/60'/%d'/0/%d", depositAccount, index)
+	case ChainSolana:
+		// ed25519 derivation only supports hardened indexes
+		spec.Path = fmt.Sprintf("m/44'/501'/%d'/%d'", depositAccount, index)
+	case ChainBitcoin:
+		spec.Path = fmt.Sprintf("m/84'/1'/%d'/0/%d", depositAccount, index)
+	default:
+		return AccountSpec{}, fmt.Errorf("unsupported chain: %s", chain)
+	}
+	return spec, nil
+}
+
+// AccountSpec names a derivation path on a chain, e.g.
+// {"chain": "ethereum", "name": "faucet", "path": "m/44'/60'/1'/0/0"}
+type AccountSpec struct {
+	Chain string `json:"chain"`
+	Name  string `json:"name"`
+	Path  string `json:"path"`
+}
+
+// Account is a named key derived from the seed. Only the key field matching
+// Chain is set.
+type Account struct {
+	Chain   string `json:"chain"`
+	Name    string `json:"name"`
+	Path    string `json:"path"`
+	Address string `json:"address"`
+
+	EthereumKey *ecdsa.PrivateKey  `json:"-"`
+	SolanaKey   *solana.PrivateKey `json:"-"`
+	BitcoinKey  *btcec.PrivateKey  `json:"-"`
+}
+
+// accountSet indexes accounts by chain and name and by chain and address
+type accountSet struct {
+	mu        sync.RWMutex
+	byName    map[string]*Account
+	byAddress map[string]*Account
+}
+
+func accountKey(chain, value string) string {
+	return chain + "/" + value
+}
+
+// addressKey normalizes addresses for lookup: hex addresses are
+// case-insensitive, base58 and bech32 addresses are compared as given
+func addressKey(chain, address string) string {
+	if chain == ChainEthereum {
+		address = strings.ToLower(address)
+	}
+	return accountKey(chain, address)
+}
+
+// DeriveAccount derives the key at spec.Path and registers it under
+// spec.Name. Deriving the same name and path again returns the existing account.
+func (k *ChainKeys) DeriveAccount(spec AccountSpec) (*Account, error) {
+	if spec.Name == "" {
+		return nil, fmt.Errorf("account name is required")
+	}
+	if k.seed == nil {
+		return nil, fmt.Errorf("keys were not derived from a seed")
+	}
+
+	k.accounts.mu.Lock()
+	defer k.accounts.mu.Unlock()
+
+	if existing, ok := k.accounts.byName[accountKey(spec.Chain, spec.Name)]; ok {
+		if existing.Path != spec.Path {
+			return nil, fmt.Errorf("account %s/%s already derived at %s", spec.Chain, spec.Name, existing.Path)
+		}
+		return existing, nil
+	}
+
+	account := &Account{Chain: spec.Chain, Name: spec.Name, Path: spec.Path}
+	var err error
+	switch spec.Chain {
+	case ChainEthereum:
+		account.EthereumKey, err = deriveEthereumKey(k.seed, spec.Path)
+		if err == nil {
+			account.Address = crypto.PubkeyToAddress(account.EthereumKey.PublicKey).Hex()
+		}
+	case ChainSolana:
+		if spec.Path == legacySolanaPath {
+			account.SolanaKey, err = deriveLegacySolanaKey(k.seed)
+		} else {
+			account.SolanaKey, err = deriveSolanaKey(k.seed, spec.Path)
+		}
+		if err == nil {
+			account.Address = account.SolanaKey.PublicKey().String()
+		}
+	case ChainBitcoin:
+		account.BitcoinKey, err = deriveBitcoinKey(k.seed, spec.Path)
+		if err == nil {
+			var address *btcutil.AddressWitnessPubKeyHash
+			address, err = btcutil.NewAddressWitnessPubKeyHash(
+				btcutil.Hash160(account.BitcoinKey.PubKey().SerializeCompressed()), k.bitcoinParams)
+			if err == nil {
+				account.Address = address.EncodeAddress()
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unsupported chain: %s", spec.Chain)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive %s: %w", spec.Path, err)
+	}
+
+	if existing, ok := k.accounts.byAddress[addressKey(spec.Chain, account.Address)]; ok {
+		return nil, fmt.Errorf("account %s/%s has the same address as %s", spec.Chain, spec.Name, existing.Name)
+	}
+	if k.accounts.byName == nil {
+		k.accounts.byName = make(map[string]*Account)
+		k.accounts.byAddress = make(map[string]*Account)
+	}
+	k.accounts.byName[accountKey(spec.Chain, spec.Name)] = account
+	k.accounts.byAddress[addressKey(spec.Chain, account.Address)] = account
+	return account, nil
+}
+
+// Account returns the account registered under name on chain
+func (k *ChainKeys) Account(chain, name string) (*Account, bool) {
+	k.accounts.mu.RLock()
+	defer k.accounts.mu.RUnlock()
+
+	account, ok := k.accounts.byName[accountKey(chain, name)]
+	return account, ok
+}
+
+// AccountByAddress returns the account whose address on chain is address
+func (k *ChainKeys) AccountByAddress(chain, address string) (*Account, bool) {
+	k.accounts.mu.RLock()
+	defer k.accounts.mu.RUnlock()
+
+	account, ok := k.accounts.byAddress[addressKey(chain, address)]
+	return account, ok
+}
+
+// Accounts returns every derived account ordered by chain and name
+func (k *ChainKeys) Accounts() []*Account {
+	k.accounts.mu.RLock()
+	defer k.accounts.mu.RUnlock()
+
+	accounts := make([]*Account, 0, len(k.accounts.byName))
+	for _, account := range k.accounts.byName {
+		accounts = append(accounts, account)
+	}
+	sort.Slice(accounts, func(i, j int) bool {
+		if accounts[i].Chain != accounts[j].Chain {
+			return accounts[i].Chain < accounts[j].Chain
+		}
+		return accounts[i].Name < accounts[j].Name
+	})
+	return accounts
+}
@@ -6,54 +6,122 @@ import (
 	"crypto/sha512"
 	"fmt"
 
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/btcsuite/btcd/chaincfg"
+	"github.com/btcsuite/btcutil/hdkeychain"
 	"github.com/gagliardetto/solana-go"
 	hdwallet "github.com/miguelmota/go-ethereum-hdwallet"
 	"github.com/tyler-smith/go-bip39"
 )
 
+// Default derivation paths of the pool accounts
+const (
+	EthereumDerivationPath = "m/44'/60'/0'/0/0"
+	SolanaDerivationPath   = "m/44'/501'/0'/0'"
+	BitcoinDerivationPath  = "m/84'/1'/0'/0/0"
+	// legacySolanaPath labels the pre-SLIP-0010 Solana pool key
+	legacySolanaPath = "legacy"
+)
+
+// ChainKeys holds the pool key of each chain and any other named accounts
+// derived from the same seed
 type ChainKeys struct {
 	EthereumKey *ecdsa.PrivateKey
 	SolanaKey   *solana.PrivateKey
+	BitcoinKey  *btcec.PrivateKey
+
+	seed          []byte
+	bitcoinParams *chaincfg.Params
+	accounts      accountSet
+}
+
+// DerivationOptions selects how keys are derived from the seed
+type DerivationOptions struct {
+	// LegacySolanaKey derives the Solana key as sha512(seed)[:32], as earlier
+	// versions did, instead of SLIP-0010 at m/44'/501'/0'/0'. Only pools
+	// created with those versions need it.
+	LegacySolanaKey bool
+	// Passphrase is the optional BIP39 passphrase
+	Passphrase string
+	// BitcoinParams selects the network Bitcoin addresses are encoded for
+	// (regtest when nil)
+	BitcoinParams *chaincfg.Params
+	// Accounts lists additional named accounts to derive
+	Accounts []AccountSpec
 }
 
-// DeriveKeysFromSeedPhrase derives both Ethereum and Solana private keys from a seed phrase
+// DeriveKeysFromSeedPhrase derives the Ethereum, Solana and Bitcoin private keys from a seed phrase
 func DeriveKeysFromSeedPhrase(seedPhrase string) (*ChainKeys, error) {
+	return DeriveKeys(seedPhrase, DerivationOptions{})
+}
+
+// DeriveKeys derives the Ethereum, Solana and Bitcoin pool keys and the
+// configured accounts from a seed phrase
+func DeriveKeys(seedPhrase string, opts DerivationOptions) (*ChainKeys, error) {
 	// Validate seed phrase
 	if !bip39.IsMnemonicValid(seedPhrase) {
 		return nil, fmt.Errorf("invalid seed phrase")
 	}
 
 	// Generate seed from mnemonic
-	seed := bip39.NewSeed(seedPhrase, "")
+	seed := bip39.NewSeed(seedPhrase, opts.Passphrase)
+
+	keys := &ChainKeys{
+		seed:          seed,
+		bitcoinParams: opts.BitcoinParams,
+	}
+	if keys.bitcoinParams == nil {
+		keys.bitcoinParams = &chaincfg.RegressionNetParams
+	}
 
 	// Derive Ethereum key
-	ethKey, err := deriveEthereumKey(seed)
+	ethereumPool, err := keys.DeriveAccount(AccountSpec{Chain: ChainEthereum, Name: AccountPool, Path: EthereumDerivationPath})
 	if err != nil {
 		return nil, fmt.Errorf("failed to derive Ethereum key: %w", err)
 	}
+	keys.EthereumKey = ethereumPool.EthereumKey
 
 	// Derive Solana key
-	solKey, err := deriveSolanaKey(seed)
+	solanaPath := SolanaDerivationPath
+	if opts.LegacySolanaKey {
+		solanaPath = legacySolanaPath
+	}
+	solanaPool, err := keys.DeriveAccount(AccountSpec{Chain: ChainSolana, Name: AccountPool, Path: solanaPath})
 	if err != nil {
 		return nil, fmt.Errorf("failed to derive Solana key: %w", err)
 	}
+	keys.SolanaKey = solanaPool.SolanaKey
+
+	// Derive Bitcoin key
+	bitcoinPool, err := keys.DeriveAccount(AccountSpec{Chain: ChainBitcoin, Name: AccountPool, Path: BitcoinDerivationPath})
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive Bitcoin key: %w", err)
+	}
+	keys.BitcoinKey = bitcoinPool.BitcoinKey
+
+	// Derive the other named accounts
+	for _, spec := range opts.Accounts {
+		if _, err := keys.DeriveAccount(spec); err != nil {
+			return nil, fmt.Errorf("failed to derive %s account %s: %w", spec.Chain, spec.Name, err)
+		}
+	}
 
-	return &ChainKeys{
-		EthereumKey: ethKey,
-		SolanaKey:   solKey,
-	}, nil
+	return keys, nil
 }
 
 // deriveEthereumKey derives an Ethereum private key from a seed using BIP44
-func deriveEthereumKey(seed []byte) (*ecdsa.PrivateKey, error) {
+func deriveEthereumKey(seed []byte, path string) (*ecdsa.PrivateKey, error) {
 	wallet, err := hdwallet.NewFromSeed(seed)
 	if err != nil {
 		return nil, err
 	}
 
-	// BIP44 path for Ethereum: m/44'/60'/0'/0/0
-	path := hdwallet.MustParseDerivationPath("m/44'/60'/0'/0/0")
-	account, err := wallet.Derive(path, true)
+	// BIP44 path for Ethereum, by default m/44'/60'/0'/0/0
+	derivationPath, err := hdwallet.ParseDerivationPath(path)
+	if err != nil {
+		return nil, err
+	}
+	account, err := wallet.Derive(derivationPath, false)
 	if err != nil {
 		return nil, err
 	}
@@ -66,8 +134,24 @@ func deriveEthereumKey(seed []byte) (*ecdsa.PrivateKey, error) {
 	return privateKey, nil
 }
 
-// deriveSolanaKey derives a Solana private key from a seed using ed25519
-func deriveSolanaKey(seed []byte) (*solana.PrivateKey, error) {
+// deriveSolanaKey derives a Solana private key from a seed using SLIP-0010,
+// matching the addresses wallets show for the same mnemonic
+func deriveSolanaKey(seed []byte, path string) (*solana.PrivateKey, error) {
+	indexes, err := parseDerivationPath(path)
+	if err != nil {
+		return nil, err
+	}
+	key, _, err := deriveSLIP10Ed25519(seed, indexes)
+	if err != nil {
+		return nil, err
+	}
+
+	solPrivKey := solana.PrivateKey(ed25519.NewKeyFromSeed(key))
+	return &solPrivKey, nil
+}
+
+// deriveLegacySolanaKey derives a Solana private key from a seed using ed25519
+func deriveLegacySolanaKey(seed []byte) (*solana.PrivateKey, error) {
 	// Use SHA512 to derive ed25519 key from seed
 	hash := sha512.Sum512(seed)
 
@@ -79,3 +163,26 @@ func deriveSolanaKey(seed []byte) (*solana.PrivateKey, error) {
 
 	return &solPrivKey, nil
 }
+
+// deriveBitcoinKey derives a Bitcoin private key from a seed using BIP32,
+// by default at the BIP84 path m/84'/1'/0'/0/0
+func deriveBitcoinKey(seed []byte, path string) (*btcec.PrivateKey, error) {
+	indexes, err := parseDerivationPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// The network only affects the extended key encoding, not the derived keys
+	key, err := hdkeychain.NewMaster(seed, &chaincfg.RegressionNetParams)
+	if err != nil {
+		return nil, err
+	}
+	for _, index := range indexes {
+		key, err = key.Derive(index)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return key.ECPrivKey()
+}
@@ -0,0 +1,119 @@
+package keys
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testMnemonic = "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+
+func TestSLIP10Ed25519Vectors(t *testing.T) {
+	// SLIP-0010 test vector 1 for ed25519
+	seed, err := hex.DecodeString("000102030405060708090a0b0c0d0e0f")
+	require.NoError(t, err)
+
+	tests := []struct {
+		path      string
+		key       string
+		chainCode string
+	}{
+		{
+			path:      "m",
+			key:       "2b4be7f19ee27bbf30c667b642d5f4aa69fd169872f8fc3059c08ebae2eb19e7",
+			chainCode: "90046a93de5380a72b5e45010748567d5ea02bbf6522f979e05c0d8d8ca9fffb",
+		},
+		{
+			path:      "m/0'",
+			key:       "68e0fe46dfb67e368c75379acec591dad19df3cde26e63b93a8e704f1dade7a3",
+			chainCode: "8b59aa11380b624e81507a27fedda59fea6d0b779a778918a2fd3590e16e9c69",
+		},
+		{
+			path:      "m/0'/1'",
+			key:       "b1d0bad404bf35da785a64ca1ac54b2617211d2777696fbffaf208f746ae84f2",
+			chainCode: "a320425f77d1b5c2505a6b1b27382b37368ee640e3557c315416801243552f14",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			path, err := parseDerivationPath(tt.path)
+			require.NoError(t, err)
+			key, chainCode, err := deriveSLIP10Ed25519(seed, path)
+			require.NoError(t, err)
+			assert.Equal(t, tt.key, hex.EncodeToString(key))
+			assert.Equal(t, tt.chainCode, hex.EncodeToString(chainCode))
+		})
+	}
+
+	// ed25519 has no public derivation
+	_, _, err = deriveSLIP10Ed25519(seed, []uint32{0})
+	assert.Error(t, err)
+}
+
+func TestSolanaKeyMatchesWallets(t *testing.T) {
+	// Address Phantom, Solflare and solana-keygen show for the first account
+	keys, err := DeriveKeysFromSeedPhrase(testMnemonic)
+	require.NoError(t, err)
+	assert.Equal(t, "HAgk14JpMQLgt6rVgv7cBQFJWFto5Dqxi472uT3DKpqk", keys.SolanaKey.PublicKey().String())
+
+	// Pools created before SLIP-0010 derivation keep their address
+	legacy, err := DeriveKeys(testMnemonic, DerivationOptions{LegacySolanaKey: true})
+	require.NoError(t, err)
+	assert.Equal(t, "DvWFKfpfYkBupFCG1eKLkfhmij4UgdcsRHPvLTVy9Krr", legacy.SolanaKey.PublicKey().String())
+
+	// Other chains are unaffected by the option
+	assert.Equal(t, keys.EthereumKey.D, legacy.EthereumKey.D)
+}
+
+func TestNamedAccounts(t *testing.T) {
+	keys, err := DeriveKeys(testMnemonic, DerivationOptions{
+		Accounts: []AccountSpec{
+			{Chain: ChainEthereum, Name: AccountFaucet, Path: "m/44'/60'/0'/0/1"},
+			{Chain: ChainSolana, Name: AccountFaucet, Path: "m/44'/501'/1'/0'"},
+		},
+	})
+	require.NoError(t, err)
+
+	// Pool accounts are registered at the default paths
+	pool, ok := keys.Account(ChainEthereum, AccountPool)
+	require.True(t, ok)
+	assert.Equal(t, EthereumDerivationPath, pool.Path)
+	assert.Equal(t, "0x9858EfFD232B4033E47d90003D41EC34EcaEda94", pool.Address)
+	assert.Equal(t, keys.EthereumKey, pool.EthereumKey)
+
+	faucet, ok := keys.Account(ChainEthereum, AccountFaucet)
+	require.True(t, ok)
+	assert.Equal(t, "0x6Fac4D18c912343BF86fa7049364Dd4E424Ab9C0", faucet.Address)
+
+	// Ethereum lookups ignore case, others are exact
+	found, ok := keys.AccountByAddress(ChainEthereum, "0x6fac4d18c912343bf86fa7049364dd4e424ab9c0")
+	require.True(t, ok)
+	assert.Equal(t, AccountFaucet, found.Name)
+
+	solFaucet, ok := keys.Account(ChainSolana, AccountFaucet)
+	require.True(t, ok)
+	found, ok = keys.AccountByAddress(ChainSolana, solFaucet.Address)
+	require.True(t, ok)
+	assert.Equal(t, solFaucet, found)
+	_, ok = keys.AccountByAddress(ChainSolana, strings.ToLower(solFaucet.Address))
+	assert.False(t, ok)
+
+	// Deposit addresses can be derived on demand, idempotently
+	deposit, err := keys.DeriveAccount(AccountSpec{Chain: ChainBitcoin, Name: "deposit-1", Path: "m/84'/1'/0'/0/1"})
+	require.NoError(t, err)
+	again, err := keys.DeriveAccount(AccountSpec{Chain: ChainBitcoin, Name: "deposit-1", Path: "m/84'/1'/0'/0/1"})
+	require.NoError(t, err)
+	assert.Same(t, deposit, again)
+
+	// A name cannot move to another path and two names cannot share a key
+	_, err = keys.DeriveAccount(AccountSpec{Chain: ChainBitcoin, Name: "deposit-1", Path: "m/84'/1'/0'/0/2"})
+	assert.Error(t, err)
+	_, err = keys.DeriveAccount(AccountSpec{Chain: ChainBitcoin, Name: "deposit-2", Path: "m/84'/1'/0'/0/1"})
+	assert.Error(t, err)
+
+	assert.Len(t, keys.Accounts(), 6)
+}
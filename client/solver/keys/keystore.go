@@ -0,0 +1,173 @@
+package keys
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/tyler-smith/go-bip39"
+	"golang.org/x/crypto/nacl/secretbox"
+	"golang.org/x/crypto/scrypt"
+	"golang.org/x/term"
+)
+
+// keystoreVersion is the current sealed mnemonic file format
+const keystoreVersion = 1
+
+// scrypt parameters for new keystores (the go-ethereum standard strength)
+var (
+	scryptN = 1 << 18
+	scryptR = 8
+	scryptP = 1
+)
+
+// Mnemonic is the secret held in a keystore
+type Mnemonic struct {
+	Phrase string `json:"mnemonic"`
+	// Passphrase is the optional BIP39 passphrase ("25th word")
+	Passphrase string `json:"passphrase,omitempty"`
+}
+
+// sealedMnemonic is the on-disk keystore: a mnemonic sealed with NaCl
+// secretbox under a key stretched from the password with scrypt
+type sealedMnemonic struct {
+	Version   int    `json:"version"`
+	KDF       string `json:"kdf"`
+	KDFParams struct {
+		N    int    `json:"n"`
+		R    int    `json:"r"`
+		P    int    `json:"p"`
+		Salt string `json:"salt"`
+	} `json:"kdfparams"`
+	Nonce      string `json:"nonce"`
+	Ciphertext string `json:"ciphertext"`
+}
+
+// SealMnemonic encrypts a mnemonic and optional BIP39 passphrase with password
+func SealMnemonic(mnemonic Mnemonic, password string) ([]byte, error) {
+	if !bip39.IsMnemonicValid(mnemonic.Phrase) {
+		return nil, fmt.Errorf("invalid seed phrase")
+	}
+	if password == "" {
+		return nil, fmt.Errorf("password is required")
+	}
+
+	plaintext, err := json.Marshal(mnemonic)
+	if err != nil {
+		return nil, err
+	}
+
+	var sealed sealedMnemonic
+	sealed.Version = keystoreVersion
+	sealed.KDF = "scrypt"
+	sealed.KDFParams.N = scryptN
+	sealed.KDFParams.R = scryptR
+	sealed.KDFParams.P = scryptP
+
+	salt := make([]byte, 32)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	var nonce [24]byte
+	if _, err := rand.Read(nonce[:]); err != nil {
+		return nil, err
+	}
+
+	key, err := keystoreKey(password, salt, scryptN, scryptR, scryptP)
+	if err != nil {
+		return nil, err
+	}
+
+	sealed.KDFParams.Salt = hex.EncodeToString(salt)
+	sealed.Nonce = hex.EncodeToString(nonce[:])
+	sealed.Ciphertext = hex.EncodeToString(secretbox.Seal(nil, plaintext, &nonce, key))
+	return json.MarshalIndent(sealed, "", "  ")
+}
+
+// OpenMnemonic decrypts a keystore produced by SealMnemonic
+func OpenMnemonic(data []byte, password string) (*Mnemonic, error) {
+	var sealed sealedMnemonic
+	if err := json.Unmarshal(data, &sealed); err != nil {
+		return nil, fmt.Errorf("invalid keystore: %w", err)
+	}
+	if sealed.Version != keystoreVersion || sealed.KDF != "scrypt" {
+		return nil, fmt.Errorf("unsupported keystore version %d (%s)", sealed.Version, sealed.KDF)
+	}
+
+	salt, err := hex.DecodeString(sealed.KDFParams.Salt)
+	if err != nil {
+		return nil, fmt.Errorf("invalid keystore salt: %w", err)
+	}
+	nonceBytes, err := hex.DecodeString(sealed.Nonce)
+	if err != nil || len(nonceBytes) != 24 {
+		return nil, fmt.Errorf("invalid keystore nonce")
+	}
+	ciphertext, err := hex.DecodeString(sealed.Ciphertext)
+	if err != nil {
+		return nil, fmt.Errorf("invalid keystore ciphertext: %w", err)
+	}
+
+	key, err := keystoreKey(password, salt, sealed.KDFParams.N, sealed.KDFParams.R, sealed.KDFParams.P)
+	if err != nil {
+		return nil, err
+	}
+	var nonce [24]byte
+	copy(nonce[:], nonceBytes)
+
+	plaintext, ok := secretbox.Open(nil, ciphertext, &nonce, key)
+	if !ok {
+		return nil, fmt.Errorf("wrong keystore password")
+	}
+
+	var mnemonic Mnemonic
+	if err := json.Unmarshal(plaintext, &mnemonic); err != nil {
+		return nil, fmt.Errorf("invalid keystore contents: %w", err)
+	}
+	return &mnemonic, nil
+}
+
+// LoadMnemonic reads and decrypts the keystore at path
+func LoadMnemonic(path, password string) (*Mnemonic, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read keystore: %w", err)
+	}
+	return OpenMnemonic(data, password)
+}
+
+func keystoreKey(password string, salt []byte, n, r, p int) (*[32]byte, error) {
+	derived, err := scrypt.Key([]byte(password), salt, n, r, p, 32)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive keystore key: %w", err)
+	}
+	var key [32]byte
+	copy(key[:], derived)
+	return &key, nil
+}
+
+// ReadSecret reads a secret from the file at path, or prompts for it on the
+// terminal when path is empty
+func ReadSecret(path, prompt string) (string, error) {
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	}
+
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return "", fmt.Errorf("no terminal to prompt for %s", strings.ToLower(prompt))
+	}
+	fmt.Fprintf(os.Stderr, "%s: ", prompt)
+	secret, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", strings.ToLower(prompt), err)
+	}
+	return string(secret), nil
+}
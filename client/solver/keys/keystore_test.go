@@ -0,0 +1,40 @@
+package keys
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeystore(t *testing.T) {
+	// Keep scrypt cheap in tests
+	defer func(n int) { scryptN = n }(scryptN)
+	scryptN = 1 << 10
+
+	mnemonic := Mnemonic{Phrase: testMnemonic, Passphrase: "TREZOR"}
+	data, err := SealMnemonic(mnemonic, "correct horse")
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "abandon")
+
+	opened, err := OpenMnemonic(data, "correct horse")
+	require.NoError(t, err)
+	assert.Equal(t, mnemonic, *opened)
+
+	_, err = OpenMnemonic(data, "wrong horse")
+	assert.EqualError(t, err, "wrong keystore password")
+
+	_, err = SealMnemonic(Mnemonic{Phrase: "not a mnemonic"}, "correct horse")
+	assert.Error(t, err)
+}
+
+func TestBIP39Passphrase(t *testing.T) {
+	plain, err := DeriveKeys(testMnemonic, DerivationOptions{})
+	require.NoError(t, err)
+	withPassphrase, err := DeriveKeys(testMnemonic, DerivationOptions{Passphrase: "TREZOR"})
+	require.NoError(t, err)
+
+	plainPool, _ := plain.Account(ChainEthereum, AccountPool)
+	passphrasePool, _ := withPassphrase.Account(ChainEthereum, AccountPool)
+	assert.NotEqual(t, plainPool.Address, passphrasePool.Address)
+}
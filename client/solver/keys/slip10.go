@@ -0,0 +1,65 @@
+package keys
+
+import (
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// hardenedOffset marks a hardened child index
+const hardenedOffset uint32 = 0x80000000
+
+// parseDerivationPath parses a BIP32 style path such as m/44'/501'/0'/0'
+func parseDerivationPath(path string) ([]uint32, error) {
+	parts := strings.Split(strings.TrimSpace(path), "/")
+	if len(parts) == 0 || parts[0] != "m" {
+		return nil, fmt.Errorf("derivation path must start with m: %s", path)
+	}
+
+	indexes := make([]uint32, 0, len(parts)-1)
+	for _, part := range parts[1:] {
+		hardened := strings.HasSuffix(part, "'") || strings.HasSuffix(part, "h") || strings.HasSuffix(part, "H")
+		if hardened {
+			part = part[:len(part)-1]
+		}
+		index, err := strconv.ParseUint(part, 10, 32)
+		if err != nil || uint32(index) >= hardenedOffset {
+			return nil, fmt.Errorf("invalid derivation path component %q in %s", part, path)
+		}
+		if hardened {
+			index += uint64(hardenedOffset)
+		}
+		indexes = append(indexes, uint32(index))
+	}
+	return indexes, nil
+}
+
+// deriveSLIP10Ed25519 derives an ed25519 private key seed and chain code from
+// a BIP39 seed following SLIP-0010. ed25519 only supports hardened children.
+func deriveSLIP10Ed25519(seed []byte, path []uint32) (key, chainCode []byte, err error) {
+	mac := hmac.New(sha512.New, []byte("ed25519 seed"))
+	mac.Write(seed)
+	sum := mac.Sum(nil)
+	key, chainCode = sum[:32], sum[32:]
+
+	for _, index := range path {
+		if index < hardenedOffset {
+			return nil, nil, fmt.Errorf("ed25519 derivation only supports hardened indexes")
+		}
+
+		// 0x00 || parent key || index
+		data := make([]byte, 0, 37)
+		data = append(data, 0x00)
+		data = append(data, key...)
+		data = binary.BigEndian.AppendUint32(data, index)
+
+		mac := hmac.New(sha512.New, chainCode)
+		mac.Write(data)
+		sum := mac.Sum(nil)
+		key, chainCode = sum[:32], sum[32:]
+	}
+	return key, chainCode, nil
+}
@@ -0,0 +1,264 @@
+package solver
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// defaultLineraTimeout bounds a linera command when no timeout is configured
+const defaultLineraTimeout = 5 * time.Minute
+
+// LineraConfig says how to run the linera CLI. Empty fields fall back to the
+// CLI's own defaults and the environment of the service.
+type LineraConfig struct {
+	// Binary is the path of the linera executable (default "linera")
+	Binary string
+	// Wallet is passed as LINERA_WALLET
+	Wallet string
+	// Storage is passed as LINERA_STORAGE, e.g. "rocksdb:/path/client.db"
+	Storage string
+	// Chain publishes bytecode and creates applications, instead of the
+	// wallet's default chain
+	Chain ChainID
+	// Timeout bounds each command (default 5m)
+	Timeout time.Duration
+	// Network names the network the wallet is on, keeping bytecode cached
+	// per network (default "default")
+	Network string
+}
+
+// LineraCommandError is a linera command that failed or printed something
+// unexpected, with its output. Stdout is only kept when it could not be parsed.
+type LineraCommandError struct {
+	Command string
+	Stdout  string
+	Stderr  string
+	Err     error
+}
+
+func (e *LineraCommandError) Error() string {
+	msg := fmt.Sprintf("%s failed: %v", e.Command, e.Err)
+	if e.Stdout != "" {
+		msg += fmt.Sprintf("; stdout: %s", e.Stdout)
+	}
+	if e.Stderr != "" {
+		msg += fmt.Sprintf("; stderr: %s", e.Stderr)
+	}
+	return msg
+}
+
+func (e *LineraCommandError) Unwrap() error {
+	return e.Err
+}
+
+// LineraCLI runs linera commands against the configured wallet and storage
+type LineraCLI struct {
+	config LineraConfig
+}
+
+// NewLineraCLI returns a CLI runner for config
+func NewLineraCLI(config LineraConfig) *LineraCLI {
+	if config.Binary == "" {
+		config.Binary = "linera"
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = defaultLineraTimeout
+	}
+	if config.Network == "" {
+		config.Network = "default"
+	}
+	return &LineraCLI{config: config}
+}
+
+// lineraCLI runs the linera commands of every client
+var lineraCLI = NewLineraCLI(LineraConfig{})
+
+// InitLinera configures how linera commands are run. A missing binary is
+// only logged, as the service runs without deployments.
+func InitLinera(config LineraConfig) {
+	lineraCLI = NewLineraCLI(config)
+	if _, err := exec.LookPath(lineraCLI.config.Binary); err != nil {
+		Logger.Printf("Warning: linera binary not found, deployments will fail: %v", err)
+	}
+}
+
+// Run executes linera with args and returns its stdout. When the command
+// fails or times out, the error is a *LineraCommandError carrying stderr.
+func (l *LineraCLI) Run(ctx context.Context, args ...string) (string, error) {
+	stdout, _, err := l.run(ctx, args...)
+	return stdout, err
+}
+
+// run executes linera with args and returns its stdout and stderr
+func (l *LineraCLI) run(ctx context.Context, args ...string) (string, string, error) {
+	ctx, cancel := context.WithTimeout(ctx, l.config.Timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, l.config.Binary, args...)
+	cmd.Env = os.Environ()
+	if l.config.Wallet != "" {
+		cmd.Env = append(cmd.Env, "LINERA_WALLET="+l.config.Wallet)
+	}
+	if l.config.Storage != "" {
+		cmd.Env = append(cmd.Env, "LINERA_STORAGE="+l.config.Storage)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if log, ok := ctx.Value(lineraLogKey{}).(func(string)); ok {
+		logLines := &lineWriter{log: log}
+		defer logLines.Flush()
+		cmd.Stderr = io.MultiWriter(&stderr, logLines)
+	}
+	// Children of a killed command may hold the output pipes open
+	cmd.WaitDelay = time.Second
+
+	err := cmd.Run()
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		err = fmt.Errorf("timed out after %s", l.config.Timeout)
+	}
+	if err != nil {
+		return "", "", &LineraCommandError{Command: lineraCommand(args), Stderr: strings.TrimSpace(stderr.String()), Err: err}
+	}
+	return stdout.String(), strings.TrimSpace(stderr.String()), nil
+}
+
+type lineraLogKey struct{}
+
+// withLineraLog returns a context under which linera commands pass each line
+// they write to stderr to log as it is written
+func withLineraLog(ctx context.Context, log func(line string)) context.Context {
+	return context.WithValue(ctx, lineraLogKey{}, log)
+}
+
+// lineWriter calls log with every complete line written to it
+type lineWriter struct {
+	log     func(string)
+	pending []byte
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.pending = append(w.pending, p...)
+	for {
+		end := bytes.IndexByte(w.pending, '\n')
+		if end < 0 {
+			return len(p), nil
+		}
+		if line := strings.TrimSpace(string(w.pending[:end])); line != "" {
+			w.log(line)
+		}
+		w.pending = w.pending[end+1:]
+	}
+}
+
+// Flush logs a last line without a newline
+func (w *lineWriter) Flush() {
+	if line := strings.TrimSpace(string(w.pending)); line != "" {
+		w.log(line)
+	}
+	w.pending = nil
+}
+
+// lineraCommand names the command run with args, e.g. "linera publish-bytecode"
+func lineraCommand(args []string) string {
+	if len(args) == 0 {
+		return "linera"
+	}
+	return "linera " + args[0]
+}
+
+// runForID runs linera with args and returns the ID of length hex characters
+// it prints. An output without one is a *LineraCommandError with the output.
+func (l *LineraCLI) runForID(ctx context.Context, kind string, length int, args ...string) (string, error) {
+	stdout, stderr, err := l.run(ctx, args...)
+	if err != nil {
+		return "", err
+	}
+	id, ok := findHexID(stdout, length)
+	if !ok {
+		return "", &LineraCommandError{
+			Command: lineraCommand(args),
+			Stdout:  strings.TrimSpace(stdout),
+			Stderr:  stderr,
+			Err:     fmt.Errorf("no %s (%d hex characters) in output", kind, length),
+		}
+	}
+	return id, nil
+}
+
+// withChain appends the configured chain to args of commands taking an
+// optional chain as their last argument
+func (l *LineraCLI) withChain(args ...string) []string {
+	if l.config.Chain != "" {
+		args = append(args, string(l.config.Chain))
+	}
+	return args
+}
+
+// PublishBytecode runs publish-bytecode on the contract and service files
+func (l *LineraCLI) PublishBytecode(ctx context.Context, contractPath, servicePath string) (BytecodeID, error) {
+	id, err := l.runForID(ctx, "bytecode ID", bytecodeIDLength, l.withChain("publish-bytecode", contractPath, servicePath)...)
+	return BytecodeID(id), err
+}
+
+// CreateApplicationOptions instantiate an application, e.g.
+//
+//	{
+//	  "argument": {"owner": "..."},
+//	  "parameters": {"fee": 3},
+//	  "chain": "e476...",
+//	  "requiredApplicationIds": ["..."]
+//	}
+type CreateApplicationOptions struct {
+	// Argument is the instantiation argument
+	Argument json.RawMessage `json:"argument,omitempty"`
+	// Parameters are the application parameters
+	Parameters json.RawMessage `json:"parameters,omitempty"`
+	// Chain creates the application, instead of the configured chain
+	Chain ChainID `json:"chain,omitempty"`
+	// RequiredApplicationIDs are applications the new one depends on
+	RequiredApplicationIDs []ApplicationID `json:"requiredApplicationIds,omitempty"`
+}
+
+// CreateApplication runs create-application for bytecodeID with opts
+func (l *LineraCLI) CreateApplication(ctx context.Context, bytecodeID BytecodeID, opts CreateApplicationOptions) (ApplicationID, error) {
+	args := []string{"create-application", string(bytecodeID)}
+	if opts.Chain != "" {
+		args = append(args, string(opts.Chain))
+	} else {
+		args = l.withChain(args...)
+	}
+	for _, flag := range []struct {
+		name  string
+		value json.RawMessage
+	}{
+		{"--json-argument", opts.Argument},
+		{"--json-parameters", opts.Parameters},
+	} {
+		if len(flag.value) == 0 {
+			continue
+		}
+		var compact bytes.Buffer
+		if err := json.Compact(&compact, flag.value); err != nil {
+			return "", fmt.Errorf("invalid %s: %w", strings.TrimPrefix(flag.name, "--json-"), err)
+		}
+		args = append(args, flag.name, compact.String())
+	}
+	if len(opts.RequiredApplicationIDs) > 0 {
+		args = append(args, "--required-application-ids")
+		for _, required := range opts.RequiredApplicationIDs {
+			args = append(args, string(required))
+		}
+	}
+
+	id, err := l.runForID(ctx, "application ID", applicationIDLength, args...)
+	return ApplicationID(id), err
+}
@@ -0,0 +1,133 @@
+package solver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testChainID = "e476187f6ddfeb9d588c7b45d3df334d5501d6499b3f9ad5595cae86cce16a65"
+
+// fakeLinera writes a linera stand-in running script and returns its path
+func fakeLinera(t *testing.T, script string) string {
+	path := filepath.Join(t.TempDir(), "linera")
+	require.NoError(t, os.WriteFile(path, []byte("#!/bin/sh\n"+script), 0755))
+	return path
+}
+
+func TestLineraCLI(t *testing.T) {
+	// Echo the arguments and configuration the command ran with
+	cli := NewLineraCLI(LineraConfig{
+		Binary:  fakeLinera(t, `echo "$@|$LINERA_WALLET|$LINERA_STORAGE"`),
+		Wallet:  "/wallets/wallet.json",
+		Storage: "rocksdb:/wallets/client.db",
+		Chain:   testChainID,
+	})
+	output, err := cli.Run(context.Background(), cli.withChain("publish-bytecode", "contract.wasm", "service.wasm")...)
+	require.NoError(t, err)
+	assert.Equal(t, "publish-bytecode contract.wasm service.wasm "+testChainID+"|/wallets/wallet.json|rocksdb:/wallets/client.db\n", output)
+
+	// Failures carry stderr
+	cli = NewLineraCLI(LineraConfig{Binary: fakeLinera(t, "echo 'Error: wallet not found' >&2\nexit 1")})
+	_, err = cli.CreateApplication(context.Background(), "bytecode", CreateApplicationOptions{})
+	var commandErr *LineraCommandError
+	require.True(t, errors.As(err, &commandErr))
+	assert.Equal(t, "linera create-application", commandErr.Command)
+	assert.Equal(t, "Error: wallet not found", commandErr.Stderr)
+	assert.Contains(t, err.Error(), "Error: wallet not found")
+
+	// Commands running past the timeout are stopped
+	cli = NewLineraCLI(LineraConfig{Binary: fakeLinera(t, "sleep 5"), Timeout: 100 * time.Millisecond})
+	_, err = cli.Run(context.Background(), "wallet", "show")
+	require.True(t, errors.As(err, &commandErr))
+	assert.Contains(t, err.Error(), "timed out after 100ms")
+}
+
+func TestLineraCLIOutput(t *testing.T) {
+	bytecodeID := strings.Repeat("ab", 64)
+	applicationID := bytecodeID + testChainID + "0000000000000003" + "00000000"
+
+	// IDs are found after log lines, alone or labelled
+	cli := NewLineraCLI(LineraConfig{Binary: fakeLinera(t, "echo 'Publishing...'\necho 'Bytecode ID: "+strings.ToUpper(bytecodeID)+"'")})
+	published, err := cli.PublishBytecode(context.Background(), "contract.wasm", "service.wasm")
+	require.NoError(t, err)
+	assert.Equal(t, BytecodeID(bytecodeID), published)
+
+	cli = NewLineraCLI(LineraConfig{Binary: fakeLinera(t, "echo "+applicationID)})
+	created, err := cli.CreateApplication(context.Background(), published, CreateApplicationOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, ApplicationID(applicationID), created)
+
+	// A changed format is reported with the output
+	cli = NewLineraCLI(LineraConfig{Binary: fakeLinera(t, "echo 'Bytecode published as 1234'\necho 'WARN slow validator' >&2")})
+	_, err = cli.PublishBytecode(context.Background(), "contract.wasm", "service.wasm")
+	var commandErr *LineraCommandError
+	require.True(t, errors.As(err, &commandErr))
+	assert.Equal(t, "Bytecode published as 1234", commandErr.Stdout)
+	assert.Equal(t, "WARN slow validator", commandErr.Stderr)
+	assert.Contains(t, err.Error(), "no bytecode ID (128 hex characters) in output")
+}
+
+func TestParseLineraIDs(t *testing.T) {
+	id, err := ParseChainID(" " + strings.ToUpper(testChainID) + "\n")
+	require.NoError(t, err)
+	assert.Equal(t, ChainID(testChainID), id)
+
+	_, err = ParseChainID(testChainID[:62])
+	assert.ErrorContains(t, err, "expected 64 hex characters, got 62")
+	_, err = ParseBytecodeID(strings.Repeat("zz", 64))
+	assert.ErrorContains(t, err, "not hex")
+	_, err = ParseApplicationID(strings.Repeat("ab", 64))
+	assert.ErrorContains(t, err, "expected 216 hex characters")
+}
+
+func TestCreateApplicationOptions(t *testing.T) {
+	bytecodeID := strings.Repeat("ab", 64)
+	requiredID := bytecodeID + testChainID + strings.Repeat("0", 24)
+	otherChain := strings.Repeat("cd", 32)
+
+	// Options are decoded from JSON with the IDs validated
+	var opts CreateApplicationOptions
+	require.NoError(t, json.Unmarshal([]byte(`{
+		"argument": {"owner": "alice", "fees": [1, 2]},
+		"parameters": 7,
+		"chain": "`+otherChain+`",
+		"requiredApplicationIds": ["`+strings.ToUpper(requiredID)+`"]
+	}`), &opts))
+	assert.Equal(t, ChainID(otherChain), opts.Chain)
+	assert.Equal(t, []ApplicationID{ApplicationID(requiredID)}, opts.RequiredApplicationIDs)
+	assert.ErrorContains(t, json.Unmarshal([]byte(`{"chain": "1234"}`), &opts), "invalid chain ID")
+
+	// and passed through as arguments, recorded one per line
+	argsPath := filepath.Join(t.TempDir(), "args")
+	cli := NewLineraCLI(LineraConfig{
+		Binary: fakeLinera(t, `for arg in "$@"; do echo "$arg"; done > `+argsPath+`; echo `+requiredID),
+		Chain:  testChainID,
+	})
+	created, err := cli.CreateApplication(context.Background(), BytecodeID(bytecodeID), opts)
+	require.NoError(t, err)
+	assert.Equal(t, ApplicationID(requiredID), created)
+	args, err := os.ReadFile(argsPath)
+	require.NoError(t, err)
+	assert.Equal(t, strings.Join([]string{
+		"create-application", bytecodeID, otherChain,
+		"--json-argument", `{"owner":"alice","fees":[1,2]}`,
+		"--json-parameters", "7",
+		"--required-application-ids", requiredID,
+	}, "\n")+"\n", string(args))
+
+	// Without a chain the configured one creates the application
+	_, err = cli.CreateApplication(context.Background(), BytecodeID(bytecodeID), CreateApplicationOptions{})
+	require.NoError(t, err)
+	args, err = os.ReadFile(argsPath)
+	require.NoError(t, err)
+	assert.Equal(t, "create-application\n"+bytecodeID+"\n"+testChainID+"\n", string(args))
+}
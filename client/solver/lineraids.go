@@ -0,0 +1,117 @@
+package solver
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Lengths of Linera IDs in hex characters
+const (
+	chainIDLength = 64
+	// bytecodeIDLength covers the contract and service blob hashes
+	bytecodeIDLength = 128
+	// applicationIDLength covers the bytecode ID and the creating message ID
+	// (chain ID, block height and message index)
+	applicationIDLength = 216
+)
+
+// ChainID identifies a Linera microchain
+type ChainID string
+
+// BytecodeID identifies published contract and service bytecode
+type BytecodeID string
+
+// ApplicationID identifies a created Linera application
+type ApplicationID string
+
+// ParseChainID checks s is a Linera chain ID
+func ParseChainID(s string) (ChainID, error) {
+	id, err := parseHexID("chain ID", s, chainIDLength)
+	return ChainID(id), err
+}
+
+// ParseBytecodeID checks s is a Linera bytecode ID
+func ParseBytecodeID(s string) (BytecodeID, error) {
+	id, err := parseHexID("bytecode ID", s, bytecodeIDLength)
+	return BytecodeID(id), err
+}
+
+// ParseApplicationID checks s is a Linera application ID
+func ParseApplicationID(s string) (ApplicationID, error) {
+	id, err := parseHexID("application ID", s, applicationIDLength)
+	return ApplicationID(id), err
+}
+
+// parseHexID checks s is length lowercase hex characters, after trimming
+// spaces and lowering the case
+func parseHexID(kind, s string, length int) (string, error) {
+	id := strings.ToLower(strings.TrimSpace(s))
+	if len(id) != length {
+		return "", fmt.Errorf("invalid %s %q: expected %d hex characters, got %d", kind, s, length, len(id))
+	}
+	if _, err := hex.DecodeString(id); err != nil {
+		return "", fmt.Errorf("invalid %s %q: not hex", kind, s)
+	}
+	return id, nil
+}
+
+// findHexID returns the last ID of length hex characters in the output of a
+// linera command. IDs are printed alone on a line or after "=", ":" or a
+// space, as in "Bytecode ID: <id>", and log lines may come before them.
+func findHexID(output string, length int) (string, bool) {
+	lines := strings.Split(output, "\n")
+	for i := len(lines) - 1; i >= 0; i-- {
+		line := strings.TrimSpace(lines[i])
+		if cut := strings.LastIndexAny(line, "=: \t"); cut >= 0 {
+			line = line[cut+1:]
+		}
+		if id, err := parseHexID("", line, length); err == nil {
+			return id, true
+		}
+	}
+	return "", false
+}
+
+// UnmarshalJSON decodes and validates a chain ID
+func (id *ChainID) UnmarshalJSON(data []byte) error {
+	return unmarshalHexID(data, func(s string) error {
+		parsed, err := ParseChainID(s)
+		if err == nil {
+			*id = parsed
+		}
+		return err
+	})
+}
+
+// UnmarshalJSON decodes and validates a bytecode ID
+func (id *BytecodeID) UnmarshalJSON(data []byte) error {
+	return unmarshalHexID(data, func(s string) error {
+		parsed, err := ParseBytecodeID(s)
+		if err == nil {
+			*id = parsed
+		}
+		return err
+	})
+}
+
+// UnmarshalJSON decodes and validates an application ID
+func (id *ApplicationID) UnmarshalJSON(data []byte) error {
+	return unmarshalHexID(data, func(s string) error {
+		parsed, err := ParseApplicationID(s)
+		if err == nil {
+			*id = parsed
+		}
+		return err
+	})
+}
+
+// unmarshalHexID decodes a JSON string and parses it with parse
+func unmarshalHexID(data []byte, parse func(string) error) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	return parse(s)
+}
@@ -0,0 +1,838 @@
+package solver
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	bin "github.com/gagliardetto/binary"
+	"github.com/gagliardetto/solana-go"
+	"github.com/linera-protocol/examples/universal-solver/client/solver/keys"
+)
+
+// Kinds of record a signed transaction must be linked to
+const (
+	SigningRecordSwap   = "swap"
+	SigningRecordFaucet = "faucet"
+	// SigningRecordSweep moves funds between the solver's own accounts, e.g.
+	// from a deposit address into the pool
+	SigningRecordSweep = "sweep"
+)
+
+// Rules a PolicyError can name
+const (
+	PolicyRuleUnlinked       = "unlinked"
+	PolicyRuleUnknownRecord  = "unknown-record"
+	PolicyRuleReplay         = "replay"
+	PolicyRuleRecordMismatch = "record-mismatch"
+	PolicyRuleRecipient      = "recipient"
+	PolicyRuleContract       = "contract"
+	PolicyRulePerTransaction = "per-transaction-cap"
+	PolicyRuleWindow         = "window-cap"
+	PolicyRuleFee            = "fee-cap"
+	PolicyRuleUnsupported    = "unsupported"
+)
+
+const (
+	// defaultPolicyWindow is the rolling window for PerWindow caps
+	defaultPolicyWindow = 24 * time.Hour
+	// policyRoundingAllowance covers payouts rounding the quoted amount to the
+	// nearest base unit (e.g. satoshis) rather than truncating it
+	policyRoundingAllowance = 1
+)
+
+// SigningRecord is the swap or faucet request a transaction pays out. At most
+// one transaction is signed per record.
+type SigningRecord struct {
+	Kind string
+	// Reference identifies the record: the reference or deposit transaction
+	// hash of a swap intent, the ID of a faucet grant or of a sweep
+	Reference string
+	Chain     string
+	Recipient string
+	// Asset is the symbol of the token paid out
+	Asset string
+	// Amount is the most the payout may transfer, in base units
+	Amount *big.Int
+}
+
+func (r SigningRecord) key() string {
+	return r.Kind + ":" + r.Reference
+}
+
+type signingRecordKey struct{}
+
+// WithSigningRecord links the transactions signed with ctx to record
+func WithSigningRecord(ctx context.Context, record SigningRecord) context.Context {
+	return context.WithValue(ctx, signingRecordKey{}, record)
+}
+
+func signingRecordFrom(ctx context.Context) (SigningRecord, bool) {
+	record, ok := ctx.Value(signingRecordKey{}).(SigningRecord)
+	return record, ok
+}
+
+// newRecordReference returns a random reference for records with no natural
+// identifier, such as faucet requests
+func newRecordReference() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate reference: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// faucetSigningContext links a faucet transfer to the faucet grant it pays
+func faucetSigningContext(grantID, chain, recipient, asset string, amount *big.Int) context.Context {
+	return WithSigningRecord(context.Background(), SigningRecord{
+		Kind:      SigningRecordFaucet,
+		Reference: grantID,
+		Chain:     chain,
+		Recipient: recipient,
+		Asset:     asset,
+		Amount:    amount,
+	})
+}
+
+// ErrUnknownRecord is returned by a RecordVerifier that holds no record with
+// the reference it was asked about
+var ErrUnknownRecord = errors.New("unknown record")
+
+// RecordVerifier checks that record matches a request the solver persisted,
+// e.g. a claimed swap intent or an unpaid faucet grant
+type RecordVerifier func(ctx context.Context, record SigningRecord) error
+
+// VerifySigningRecords checks the records of kind with verifier, when the
+// current signer runs a signing policy
+func VerifySigningRecords(kind string, verifier RecordVerifier) {
+	if policy, ok := signer.(*PolicySigner); ok {
+		policy.VerifyRecords(kind, verifier)
+	}
+}
+
+// PolicyError is returned when the signing policy denies a transaction
+type PolicyError struct {
+	Rule   string
+	Reason string
+}
+
+func (e *PolicyError) Error() string {
+	return fmt.Sprintf("signing policy denied transaction (%s): %s", e.Rule, e.Reason)
+}
+
+func policyDenied(rule, format string, args ...interface{}) *PolicyError {
+	return &PolicyError{Rule: rule, Reason: fmt.Sprintf(format, args...)}
+}
+
+// AssetLimit caps the value signed in one asset, in whole tokens. Zero means
+// no cap.
+type AssetLimit struct {
+	PerTransaction float64 `json:"perTransaction"`
+	PerWindow      float64 `json:"perWindow"`
+}
+
+// SigningPolicyConfig configures the checks beyond record linkage, e.g.
+//
+//	{
+//	  "window": "24h",
+//	  "recipients": {"ethereum": ["0x..."]},
+//	  "contracts": {"ethereum": ["0x..."]},
+//	  "limits": {"ETH": {"perTransaction": 1, "perWindow": 10}},
+//	  "maxFee": {"ethereum": 0.01},
+//	  "sweepRecipients": {"ethereum": ["0x..."]}
+//	}
+//
+// Chains without recipients may pay anyone a record names. Ethereum calls are
+// limited to ERC-20 transfers on configured tokens, further narrowed by
+// contracts. MaxFee caps the fee of one transaction in whole units of the
+// chain's native asset; fees also count toward its PerWindow cap. Sweeps may
+// pay the pool or sweepRecipients, the cold addresses a separate signer
+// process cannot learn from the client.
+type SigningPolicyConfig struct {
+	Window          string                `json:"window"`
+	Recipients      map[string][]string   `json:"recipients"`
+	Contracts       map[string][]string   `json:"contracts"`
+	Limits          map[string]AssetLimit `json:"limits"`
+	MaxFee          map[string]float64    `json:"maxFee"`
+	SweepRecipients map[string][]string   `json:"sweepRecipients"`
+}
+
+// LoadSigningPolicy reads the policy at path. An empty path returns the
+// default policy, which only enforces record linkage.
+func LoadSigningPolicy(path string) (*SigningPolicyConfig, error) {
+	var config SigningPolicyConfig
+	if err := readJSONFile(path, &config); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+// policyTransfer is a payment a transaction makes
+type policyTransfer struct {
+	Asset     string
+	Recipient string
+	Amount    *big.Int
+	// Mint is set when Recipient is the SPL token account of the paid wallet
+	Mint *solana.PublicKey
+}
+
+type policySpend struct {
+	Time   time.Time `json:"time"`
+	Asset  string    `json:"asset"`
+	Amount string    `json:"amount"`
+}
+
+// policyState is what the policy persists between restarts
+type policyState struct {
+	Used  map[string]time.Time `json:"used"`
+	Spent []policySpend        `json:"spent"`
+}
+
+// PolicySigner vets every transaction against the signing policy before
+// passing it to the wrapped signer
+type PolicySigner struct {
+	signer     Signer
+	window     time.Duration
+	recipients map[string][]string
+	contracts  map[string]bool
+	limits     map[string]AssetLimit
+	maxFee     map[string]float64
+
+	mu sync.Mutex
+	// sweepRecipients may receive sweeps besides the pool, e.g. cold storage
+	sweepRecipients map[string][]string
+	// verifiers check records by kind, records of kinds without any are denied
+	verifiers map[string][]RecordVerifier
+	path      string
+	state     policyState
+}
+
+// NewPolicySigner wraps signer with the policy in config, keeping used
+// records and spent amounts in the JSON file at statePath
+func NewPolicySigner(signer Signer, config *SigningPolicyConfig, statePath string) (*PolicySigner, error) {
+	s := &PolicySigner{
+		signer:          signer,
+		window:          defaultPolicyWindow,
+		recipients:      config.Recipients,
+		contracts:       make(map[string]bool),
+		limits:          config.Limits,
+		maxFee:          config.MaxFee,
+		sweepRecipients: make(map[string][]string),
+		verifiers:       make(map[string][]RecordVerifier),
+		path:            statePath,
+		state:           policyState{Used: make(map[string]time.Time)},
+	}
+	if config.Window != "" {
+		window, err := time.ParseDuration(config.Window)
+		if err != nil {
+			return nil, fmt.Errorf("invalid policy window: %w", err)
+		}
+		s.window = window
+	}
+	for _, contract := range config.Contracts[keys.ChainEthereum] {
+		s.contracts[strings.ToLower(contract)] = true
+	}
+	for chain, addresses := range config.SweepRecipients {
+		s.sweepRecipients[chain] = append(s.sweepRecipients[chain], addresses...)
+	}
+	for asset := range config.Limits {
+		if _, ok := LookupToken(asset); !ok {
+			return nil, fmt.Errorf("policy limit for unknown token %s", asset)
+		}
+	}
+	for chain := range config.MaxFee {
+		if _, err := nativeToken(chain); err != nil {
+			return nil, fmt.Errorf("policy fee cap: %w", err)
+		}
+	}
+
+	if err := readJSONFile(statePath, &s.state); err != nil {
+		return nil, err
+	}
+	if s.state.Used == nil {
+		s.state.Used = make(map[string]time.Time)
+	}
+	return s, nil
+}
+
+// UseSigningPolicy puts the policy in config in front of the current signer
+func UseSigningPolicy(config *SigningPolicyConfig, statePath string) error {
+	inner, err := currentSigner()
+	if err != nil {
+		return err
+	}
+	policy, err := NewPolicySigner(inner, config, statePath)
+	if err != nil {
+		return err
+	}
+	signer = policy
+	return nil
+}
+
+// AllowSweepRecipient lets sweeps on chain pay address as well as the pool
+func (s *PolicySigner) AllowSweepRecipient(chain, address string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sweepRecipients[chain] = append(s.sweepRecipients[chain], address)
+}
+
+// VerifyRecordsIn checks records against the faucet grants, swap intents and
+// cold sweeps a solver client keeps in its data dir, for a signer running
+// apart from the client
+func (s *PolicySigner) VerifyRecordsIn(dir string) {
+	payout, sweep := IntentVerifiers(filepath.Join(dir, "swap_intents.json"))
+	s.VerifyRecords(SigningRecordFaucet, FaucetGrantVerifier(filepath.Join(dir, "faucet_grants.json")))
+	s.VerifyRecords(SigningRecordSwap, payout)
+	s.VerifyRecords(SigningRecordSweep, sweep)
+	s.VerifyRecords(SigningRecordSweep, ColdSweepVerifier(filepath.Join(dir, "cold_sweeps.json")))
+}
+
+// VerifyRecords checks the records of kind with verifier. A record passes
+// once a verifier knows it; verifiers failing with ErrUnknownRecord pass it on.
+func (s *PolicySigner) VerifyRecords(kind string, verifier RecordVerifier) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.verifiers[kind] = append(s.verifiers[kind], verifier)
+}
+
+// verifyRecordLocked checks record against the verifiers of its kind
+func (s *PolicySigner) verifyRecordLocked(ctx context.Context, record SigningRecord) error {
+	for _, verify := range s.verifiers[record.Kind] {
+		err := verify(ctx, record)
+		if errors.Is(err, ErrUnknownRecord) {
+			continue
+		}
+		if err != nil {
+			return policyDenied(PolicyRuleRecordMismatch, "%s %s: %v", record.Kind, record.Reference, err)
+		}
+		return nil
+	}
+	return policyDenied(PolicyRuleUnknownRecord, "no %s %s was recorded", record.Kind, record.Reference)
+}
+
+// Address returns the address of the named account on chain
+func (s *PolicySigner) Address(ctx context.Context, chain, account string) (string, error) {
+	return s.signer.Address(ctx, chain, account)
+}
+
+// DeriveAccount derives and registers the account in spec, which must be a
+// deposit account (see keys.DepositAccountSpec)
+func (s *PolicySigner) DeriveAccount(ctx context.Context, spec keys.AccountSpec) (string, error) {
+	var index uint32
+	if _, err := fmt.Sscanf(spec.Name, "deposit-%d", &index); err != nil {
+		return "", policyDenied(PolicyRuleUnsupported, "only deposit accounts may be derived, not %s", spec.Name)
+	}
+	if deposit, err := keys.DepositAccountSpec(spec.Chain, index); err != nil || deposit != spec {
+		return "", policyDenied(PolicyRuleUnsupported, "%s is not at the deposit account path", spec.Name)
+	}
+	return s.signer.DeriveAccount(ctx, spec)
+}
+
+// SignEthereumTransaction signs tx if the policy allows it
+func (s *PolicySigner) SignEthereumTransaction(ctx context.Context, from string, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	var signedTx *types.Transaction
+	err := s.authorize(ctx, keys.ChainEthereum, func() ([]policyTransfer, *big.Int, error) {
+		return s.ethereumTransfers(tx)
+	}, func() (err error) {
+		signedTx, err = s.signer.SignEthereumTransaction(ctx, from, tx, chainID)
+		return err
+	})
+	return signedTx, err
+}
+
+// SignSolanaMessage signs message if the policy allows it
+func (s *PolicySigner) SignSolanaMessage(ctx context.Context, from string, message []byte) (solana.Signature, error) {
+	var signature solana.Signature
+	err := s.authorize(ctx, keys.ChainSolana, func() ([]policyTransfer, *big.Int, error) {
+		return solanaTransfers(message)
+	}, func() (err error) {
+		signature, err = s.signer.SignSolanaMessage(ctx, from, message)
+		return err
+	})
+	return signature, err
+}
+
+// SignBitcoinPsbt signs psbt if the policy allows it
+func (s *PolicySigner) SignBitcoinPsbt(ctx context.Context, from string, psbt string) (string, error) {
+	var signed string
+	err := s.authorize(ctx, keys.ChainBitcoin, func() ([]policyTransfer, *big.Int, error) {
+		return bitcoinTransfers(from, psbt)
+	}, func() (err error) {
+		signed, err = s.signer.SignBitcoinPsbt(ctx, from, psbt)
+		return err
+	})
+	return signed, err
+}
+
+// policyExtractor returns the transfers a transaction makes and the fee the
+// signing account pays for it, in base units of the chain's native asset
+type policyExtractor func() ([]policyTransfer, *big.Int, error)
+
+// authorize checks the transfers and fee of a transaction against the policy
+// and signs it, recording the spends, if they pass
+func (s *PolicySigner) authorize(ctx context.Context, chain string, extract policyExtractor, sign func() error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, spends, err := s.check(ctx, chain, extract)
+	if err != nil {
+		Logger.Printf("Signing policy: denied %s transaction: %v", chain, err)
+		return err
+	}
+	if err := sign(); err != nil {
+		return err
+	}
+
+	// Sweeps keep funds in the solver, so they neither use up the record nor
+	// count against the caps, but their fees do
+	now := time.Now()
+	if record.Kind != SigningRecordSweep {
+		s.state.Used[record.key()] = now
+	}
+	for _, asset := range sortedAssets(spends) {
+		if spends[asset].Sign() > 0 {
+			s.state.Spent = append(s.state.Spent, policySpend{Time: now, Asset: asset, Amount: spends[asset].String()})
+		}
+	}
+	s.pruneLocked(now)
+	if err := writeJSONFile(s.path, s.state); err != nil {
+		// The transaction is signed already, keep going with the in-memory state
+		Logger.Printf("Signing policy: failed to save state: %v", err)
+	}
+	return nil
+}
+
+// check returns the record of a transaction and what it spends per asset,
+// fees included, if the policy allows it
+func (s *PolicySigner) check(ctx context.Context, chain string, extract policyExtractor) (SigningRecord, map[string]*big.Int, error) {
+	record, ok := signingRecordFrom(ctx)
+	if !ok || record.Kind == "" || record.Reference == "" {
+		return record, nil, policyDenied(PolicyRuleUnlinked, "transaction is not linked to a swap or faucet record")
+	}
+	if record.Kind == SigningRecordSweep {
+		return s.checkSweep(ctx, record, chain, extract)
+	}
+	if _, used := s.state.Used[record.key()]; used {
+		return record, nil, policyDenied(PolicyRuleReplay, "%s %s was already signed for", record.Kind, record.Reference)
+	}
+	if record.Chain != chain {
+		return record, nil, policyDenied(PolicyRuleRecordMismatch, "%s %s pays out on %s, not %s", record.Kind, record.Reference, record.Chain, chain)
+	}
+	if err := s.verifyRecordLocked(ctx, record); err != nil {
+		return record, nil, err
+	}
+
+	transfers, fee, err := extract()
+	if err != nil {
+		return record, nil, err
+	}
+	if len(transfers) == 0 {
+		return record, nil, policyDenied(PolicyRuleUnsupported, "transaction makes no transfer")
+	}
+
+	total := new(big.Int)
+	for _, transfer := range transfers {
+		if allowed := s.recipients[chain]; len(allowed) > 0 && !paysAny(chain, transfer, allowed) {
+			return record, nil, policyDenied(PolicyRuleRecipient, "%s is not an allowed recipient", transfer.Recipient)
+		}
+		if !paysTo(chain, transfer, record.Recipient) {
+			return record, nil, policyDenied(PolicyRuleRecordMismatch, "transaction pays %s, %s %s pays %s",
+				transfer.Recipient, record.Kind, record.Reference, record.Recipient)
+		}
+		if transfer.Asset != record.Asset {
+			return record, nil, policyDenied(PolicyRuleRecordMismatch, "transaction pays %s, %s %s pays %s",
+				transfer.Asset, record.Kind, record.Reference, record.Asset)
+		}
+		total.Add(total, transfer.Amount)
+	}
+	if record.Amount != nil {
+		allowed := new(big.Int).Add(record.Amount, big.NewInt(policyRoundingAllowance))
+		if total.Cmp(allowed) > 0 {
+			return record, nil, policyDenied(PolicyRuleRecordMismatch, "transaction pays %s, %s %s pays at most %s",
+				total, record.Kind, record.Reference, record.Amount)
+		}
+	}
+
+	if limit, ok := s.limits[record.Asset]; ok && limit.PerTransaction > 0 {
+		token, _ := LookupToken(record.Asset)
+		if total.Cmp(token.ToBaseUnits(limit.PerTransaction)) > 0 {
+			return record, nil, policyDenied(PolicyRulePerTransaction, "%g %s exceeds the cap of %g %s per transaction",
+				token.FromBaseUnits(total), record.Asset, limit.PerTransaction, record.Asset)
+		}
+	}
+	spends, err := s.checkFee(chain, fee, map[string]*big.Int{record.Asset: total})
+	if err != nil {
+		return record, nil, err
+	}
+	return record, spends, s.checkWindow(spends)
+}
+
+// checkFee checks fee against the cap of chain and adds it to spends
+func (s *PolicySigner) checkFee(chain string, fee *big.Int, spends map[string]*big.Int) (map[string]*big.Int, error) {
+	native, err := nativeToken(chain)
+	if err != nil {
+		return nil, err
+	}
+	if fee == nil || fee.Sign() < 0 {
+		return nil, policyDenied(PolicyRuleUnsupported, "transaction fee is unknown")
+	}
+	if maxFee, ok := s.maxFee[chain]; ok && fee.Cmp(native.ToBaseUnits(maxFee)) > 0 {
+		return nil, policyDenied(PolicyRuleFee, "fee of %g %s exceeds the cap of %g %s",
+			native.FromBaseUnits(fee), native.Symbol, maxFee, native.Symbol)
+	}
+	if spent, ok := spends[native.Symbol]; ok {
+		spends[native.Symbol] = new(big.Int).Add(spent, fee)
+	} else {
+		spends[native.Symbol] = fee
+	}
+	return spends, nil
+}
+
+// checkWindow checks spends against the caps over the window
+func (s *PolicySigner) checkWindow(spends map[string]*big.Int) error {
+	for _, asset := range sortedAssets(spends) {
+		limit, ok := s.limits[asset]
+		if !ok || limit.PerWindow <= 0 {
+			continue
+		}
+		token, _ := LookupToken(asset)
+		spent := s.spentSince(asset, time.Now().Add(-s.window))
+		if new(big.Int).Add(spent, spends[asset]).Cmp(token.ToBaseUnits(limit.PerWindow)) > 0 {
+			return policyDenied(PolicyRuleWindow, "%g %s would exceed the cap of %g %s per %s (%g signed)",
+				token.FromBaseUnits(spends[asset]), asset, limit.PerWindow, asset, s.window, token.FromBaseUnits(spent))
+		}
+	}
+	return nil
+}
+
+func sortedAssets(spends map[string]*big.Int) []string {
+	assets := make([]string, 0, len(spends))
+	for asset := range spends {
+		assets = append(assets, asset)
+	}
+	sort.Strings(assets)
+	return assets
+}
+
+// spentSince sums the amounts of asset signed after since
+func (s *PolicySigner) spentSince(asset string, since time.Time) *big.Int {
+	total := new(big.Int)
+	for _, spend := range s.state.Spent {
+		if spend.Asset != asset || spend.Time.Before(since) {
+			continue
+		}
+		if amount, ok := new(big.Int).SetString(spend.Amount, 10); ok {
+			total.Add(total, amount)
+		}
+	}
+	return total
+}
+
+// checkSweep allows sweeps paying only the solver's own pool account or an
+// allowed sweep recipient
+func (s *PolicySigner) checkSweep(ctx context.Context, record SigningRecord, chain string, extract policyExtractor) (SigningRecord, map[string]*big.Int, error) {
+	pool, err := s.signer.Address(ctx, chain, keys.AccountPool)
+	if err != nil {
+		return record, nil, err
+	}
+	allowed := append([]string{pool}, s.sweepRecipients[chain]...)
+	if record.Chain != chain || !paysAny(chain, policyTransfer{Recipient: record.Recipient}, allowed) {
+		return record, nil, policyDenied(PolicyRuleRecipient, "sweep %s does not pay the %s pool or cold storage", record.Reference, chain)
+	}
+	if err := s.verifyRecordLocked(ctx, record); err != nil {
+		return record, nil, err
+	}
+
+	transfers, fee, err := extract()
+	if err != nil {
+		return record, nil, err
+	}
+	if len(transfers) == 0 {
+		return record, nil, policyDenied(PolicyRuleUnsupported, "transaction makes no transfer")
+	}
+	total := new(big.Int)
+	for _, transfer := range transfers {
+		if !paysTo(chain, transfer, record.Recipient) {
+			return record, nil, policyDenied(PolicyRuleRecipient, "sweep %s pays %s, not %s", record.Reference, transfer.Recipient, record.Recipient)
+		}
+		total.Add(total, transfer.Amount)
+	}
+	if record.Amount != nil && total.Cmp(new(big.Int).Add(record.Amount, big.NewInt(policyRoundingAllowance))) > 0 {
+		return record, nil, policyDenied(PolicyRuleRecordMismatch, "sweep %s moves %s, at most %s was recorded", record.Reference, total, record.Amount)
+	}
+	spends, err := s.checkFee(chain, fee, make(map[string]*big.Int))
+	if err != nil {
+		return record, nil, err
+	}
+	return record, spends, s.checkWindow(spends)
+}
+
+// pruneLocked drops spends that left the window
+func (s *PolicySigner) pruneLocked(now time.Time) {
+	kept := s.state.Spent[:0]
+	for _, spend := range s.state.Spent {
+		if now.Sub(spend.Time) <= s.window {
+			kept = append(kept, spend)
+		}
+	}
+	s.state.Spent = kept
+}
+
+// paysTo reports whether transfer pays the wallet owner
+func paysTo(chain string, transfer policyTransfer, owner string) bool {
+	if transfer.Mint != nil {
+		wallet, err := solana.PublicKeyFromBase58(owner)
+		if err != nil {
+			return false
+		}
+		tokenAccount, _, err := solana.FindAssociatedTokenAddress(wallet, *transfer.Mint)
+		return err == nil && tokenAccount.String() == transfer.Recipient
+	}
+	if chain == keys.ChainEthereum {
+		return strings.EqualFold(transfer.Recipient, owner)
+	}
+	return transfer.Recipient == owner
+}
+
+func paysAny(chain string, transfer policyTransfer, owners []string) bool {
+	for _, owner := range owners {
+		if paysTo(chain, transfer, owner) {
+			return true
+		}
+	}
+	return false
+}
+
+// ethereumTransfers returns the native transfer or the ERC-20 transfer call
+// tx makes, and the most it can pay in fees: its gas limit at its fee cap.
+// Any other call is denied.
+func (s *PolicySigner) ethereumTransfers(tx *types.Transaction) ([]policyTransfer, *big.Int, error) {
+	transfers, err := s.ethereumCall(tx)
+	if err != nil {
+		return nil, nil, err
+	}
+	if tx.Type() == types.BlobTxType {
+		return nil, nil, policyDenied(PolicyRuleUnsupported, "blob transaction")
+	}
+	fee := new(big.Int).Mul(tx.GasFeeCap(), new(big.Int).SetUint64(tx.Gas()))
+	return transfers, fee, nil
+}
+
+func (s *PolicySigner) ethereumCall(tx *types.Transaction) ([]policyTransfer, error) {
+	if tx.To() == nil {
+		return nil, policyDenied(PolicyRuleUnsupported, "contract creation")
+	}
+	if len(tx.Data()) == 0 {
+		return []policyTransfer{{Asset: "ETH", Recipient: tx.To().Hex(), Amount: tx.Value()}}, nil
+	}
+
+	token, ok := TokenByAddress(keys.ChainEthereum, tx.To().Hex())
+	if !ok || (len(s.contracts) > 0 && !s.contracts[strings.ToLower(tx.To().Hex())]) {
+		return nil, policyDenied(PolicyRuleContract, "calls to %s are not allowed", tx.To().Hex())
+	}
+	if tx.Value().Sign() != 0 {
+		return nil, policyDenied(PolicyRuleUnsupported, "token transfer carries value")
+	}
+	if len(tx.Data()) < 4 {
+		return nil, policyDenied(PolicyRuleUnsupported, "invalid calldata")
+	}
+	method, err := erc20.MethodById(tx.Data()[:4])
+	if err != nil || method.Name != "transfer" {
+		return nil, policyDenied(PolicyRuleUnsupported, "only ERC-20 transfer calls are allowed")
+	}
+	args, err := method.Inputs.Unpack(tx.Data()[4:])
+	if err != nil || len(args) != 2 {
+		return nil, policyDenied(PolicyRuleUnsupported, "invalid transfer calldata")
+	}
+	to, okTo := args[0].(common.Address)
+	amount, okAmount := args[1].(*big.Int)
+	if !okTo || !okAmount {
+		return nil, policyDenied(PolicyRuleUnsupported, "invalid transfer calldata")
+	}
+	return []policyTransfer{{Asset: token.Symbol, Recipient: to.Hex(), Amount: amount}}, nil
+}
+
+// SPL token and compute budget instruction tags the policy understands
+const (
+	splTokenTransferChecked   = 12
+	computeBudgetSetUnitLimit = 2
+	computeBudgetSetUnitPrice = 3
+	defaultComputeUnitLimit   = 200_000
+	maxComputeUnitLimit       = 1_400_000
+	microLamportsPerLamport   = 1_000_000
+)
+
+// solanaTransfers returns the system transfers, SPL TransferChecked calls and
+// associated token account creations a message makes, and its fee: the
+// signature fees plus the compute unit price over the compute unit limit.
+// Any other instruction is denied.
+func solanaTransfers(message []byte) ([]policyTransfer, *big.Int, error) {
+	var msg solana.Message
+	if err := msg.UnmarshalWithDecoder(bin.NewBinDecoder(message)); err != nil {
+		return nil, nil, policyDenied(PolicyRuleUnsupported, "invalid message: %v", err)
+	}
+	if len(msg.AddressTableLookups) > 0 {
+		return nil, nil, policyDenied(PolicyRuleUnsupported, "address table lookups")
+	}
+
+	account := func(instruction solana.CompiledInstruction, i int) (solana.PublicKey, error) {
+		if i >= len(instruction.Accounts) || int(instruction.Accounts[i]) >= len(msg.AccountKeys) {
+			return solana.PublicKey{}, policyDenied(PolicyRuleUnsupported, "instruction account %d out of range", i)
+		}
+		return msg.AccountKeys[instruction.Accounts[i]], nil
+	}
+
+	var (
+		transfers []policyTransfer
+		unitPrice uint64
+		unitLimit uint64
+		hasLimit  bool
+		others    uint64
+	)
+	for _, instruction := range msg.Instructions {
+		if int(instruction.ProgramIDIndex) >= len(msg.AccountKeys) {
+			return nil, nil, policyDenied(PolicyRuleUnsupported, "program index out of range")
+		}
+		program := msg.AccountKeys[instruction.ProgramIDIndex]
+		data := []byte(instruction.Data)
+
+		switch {
+		case program.Equals(solana.SystemProgramID):
+			// Transfer: u32 tag 2, u64 lamports; accounts [from, to]
+			if len(data) != 12 || binary.LittleEndian.Uint32(data) != 2 {
+				return nil, nil, policyDenied(PolicyRuleUnsupported, "system instruction other than transfer")
+			}
+			to, err := account(instruction, 1)
+			if err != nil {
+				return nil, nil, err
+			}
+			lamports := new(big.Int).SetUint64(binary.LittleEndian.Uint64(data[4:]))
+			transfers = append(transfers, policyTransfer{Asset: "SOL", Recipient: to.String(), Amount: lamports})
+
+		case program.Equals(solana.TokenProgramID):
+			// TransferChecked: u8 tag, u64 amount, u8 decimals; accounts [source, mint, destination, owner]
+			if len(data) != 10 || data[0] != splTokenTransferChecked {
+				return nil, nil, policyDenied(PolicyRuleUnsupported, "token instruction other than TransferChecked")
+			}
+			mint, err := account(instruction, 1)
+			if err != nil {
+				return nil, nil, err
+			}
+			destination, err := account(instruction, 2)
+			if err != nil {
+				return nil, nil, err
+			}
+			token, ok := TokenByAddress(keys.ChainSolana, mint.String())
+			if !ok {
+				return nil, nil, policyDenied(PolicyRuleContract, "transfers of mint %s are not allowed", mint)
+			}
+			amount := new(big.Int).SetUint64(binary.LittleEndian.Uint64(data[1:9]))
+			transfers = append(transfers, policyTransfer{Asset: token.Symbol, Recipient: destination.String(), Amount: amount, Mint: &mint})
+
+		case program.Equals(solana.SPLAssociatedTokenAccountProgramID):
+			// Create: accounts [payer, account, wallet, mint, ...]; the payer
+			// only funds the account's rent
+			wallet, err := account(instruction, 2)
+			if err != nil {
+				return nil, nil, err
+			}
+			mint, err := account(instruction, 3)
+			if err != nil {
+				return nil, nil, err
+			}
+			token, ok := TokenByAddress(keys.ChainSolana, mint.String())
+			if !ok {
+				return nil, nil, policyDenied(PolicyRuleContract, "token accounts for mint %s are not allowed", mint)
+			}
+			transfers = append(transfers, policyTransfer{Asset: token.Symbol, Recipient: wallet.String(), Amount: new(big.Int)})
+
+		case program.Equals(solana.ComputeBudget):
+			// SetComputeUnitLimit: u8 tag, u32 units; SetComputeUnitPrice:
+			// u8 tag, u64 micro-lamports per unit
+			switch {
+			case len(data) == 5 && data[0] == computeBudgetSetUnitLimit:
+				unitLimit = uint64(binary.LittleEndian.Uint32(data[1:]))
+				hasLimit = true
+			case len(data) == 9 && data[0] == computeBudgetSetUnitPrice:
+				unitPrice = binary.LittleEndian.Uint64(data[1:])
+			}
+			continue
+
+		default:
+			return nil, nil, policyDenied(PolicyRuleContract, "calls to program %s are not allowed", program)
+		}
+		others++
+	}
+
+	if !hasLimit {
+		unitLimit = others * defaultComputeUnitLimit
+	}
+	if unitLimit > maxComputeUnitLimit {
+		unitLimit = maxComputeUnitLimit
+	}
+	fee := new(big.Int).SetUint64(uint64(msg.Header.NumRequiredSignatures) * solanaSignatureFee)
+	priority := new(big.Int).Mul(new(big.Int).SetUint64(unitPrice), new(big.Int).SetUint64(unitLimit))
+	priority.Add(priority, big.NewInt(microLamportsPerLamport-1))
+	fee.Add(fee, priority.Div(priority, big.NewInt(microLamportsPerLamport)))
+	return transfers, fee, nil
+}
+
+// bitcoinTransfers returns the outputs of a PSBT that do not return change to
+// from, and its fee: what the inputs spend beyond the outputs. Every input
+// must carry its witness UTXO for the fee to be known.
+func bitcoinTransfers(from, encoded string) ([]policyTransfer, *big.Int, error) {
+	packet, err := decodePsbt(encoded)
+	if err != nil {
+		return nil, nil, err
+	}
+	address, err := decodeBitcoinAddress(from)
+	if err != nil {
+		return nil, nil, err
+	}
+	changeScript, err := txscript.PayToAddrScript(address)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	fee := new(big.Int)
+	for i, input := range packet.Inputs {
+		if input.WitnessUtxo == nil {
+			return nil, nil, policyDenied(PolicyRuleUnsupported, "input %d does not carry its witness UTXO", i)
+		}
+		fee.Add(fee, big.NewInt(input.WitnessUtxo.Value))
+	}
+
+	var transfers []policyTransfer
+	for i, output := range packet.UnsignedTx.TxOut {
+		fee.Sub(fee, big.NewInt(output.Value))
+		if bytes.Equal(output.PkScript, changeScript) {
+			continue
+		}
+		_, addresses, _, err := txscript.ExtractPkScriptAddrs(output.PkScript, bitcoinParams)
+		if err != nil || len(addresses) != 1 {
+			return nil, nil, policyDenied(PolicyRuleUnsupported, "output %d has a non-standard script", i)
+		}
+		transfers = append(transfers, policyTransfer{
+			Asset:     "BTC",
+			Recipient: addresses[0].EncodeAddress(),
+			Amount:    big.NewInt(output.Value),
+		})
+	}
+	if fee.Sign() < 0 {
+		return nil, nil, policyDenied(PolicyRuleUnsupported, "outputs spend more than the inputs")
+	}
+	return transfers, fee, nil
+}
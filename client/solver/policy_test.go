@@ -0,0 +1,182 @@
+package solver
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"math/big"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/programs/system"
+	"github.com/linera-protocol/examples/universal-solver/client/solver/keys"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// assertPolicyDenied checks err is a PolicyError for rule
+func assertPolicyDenied(t *testing.T, err error, rule string) {
+	t.Helper()
+	var policyErr *PolicyError
+	if assert.True(t, errors.As(err, &policyErr), "expected a policy error, got %v", err) {
+		assert.Equal(t, rule, policyErr.Rule)
+	}
+}
+
+func TestSigningPolicy(t *testing.T) {
+	chainKeys := testChainKeys(t)
+
+	statePath := filepath.Join(t.TempDir(), "signing_policy.json")
+	config := &SigningPolicyConfig{
+		Window: "1h",
+		Limits: map[string]AssetLimit{"ETH": {PerTransaction: 2, PerWindow: 3}},
+		MaxFee: map[string]float64{"ethereum": 0.001},
+	}
+	// Records name swaps and sweeps the solver persisted, except forged ones
+	verifier := func(_ context.Context, record SigningRecord) error {
+		switch record.Reference {
+		case "0xforged":
+			return ErrUnknownRecord
+		case "0xrefunded":
+			return errors.New("swap is pending, not claimed")
+		}
+		return nil
+	}
+	newPolicy := func() (*PolicySigner, error) {
+		policy, err := NewPolicySigner(NewLocalSigner(chainKeys), config, statePath)
+		if err == nil {
+			policy.VerifyRecords(SigningRecordSwap, verifier)
+			policy.VerifyRecords(SigningRecordSweep, verifier)
+		}
+		return policy, err
+	}
+	policy, err := newPolicy()
+	require.NoError(t, err)
+
+	pool := "0x9858EfFD232B4033E47d90003D41EC34EcaEda94"
+	recipient := "0x6Fac4D18c912343BF86fa7049364Dd4E424Ab9C0"
+	chainID := big.NewInt(1337)
+	eth := func(amount float64) *big.Int {
+		token, _ := LookupToken("ETH")
+		return token.ToBaseUnits(amount)
+	}
+	payout := func(nonce uint64, to string, amount float64) *types.Transaction {
+		return types.NewTransaction(nonce, common.HexToAddress(to), eth(amount), 21000, big.NewInt(1e9), nil)
+	}
+	linked := func(reference string, amount float64) context.Context {
+		return WithSigningRecord(context.Background(), SigningRecord{
+			Kind: SigningRecordSwap, Reference: reference, Chain: "ethereum",
+			Recipient: recipient, Asset: "ETH", Amount: eth(amount),
+		})
+	}
+
+	// Unlinked transactions are never signed
+	_, err = policy.SignEthereumTransaction(context.Background(), pool, payout(0, recipient, 1), chainID)
+	assertPolicyDenied(t, err, PolicyRuleUnlinked)
+
+	// Records must name what the solver persisted
+	_, err = policy.SignEthereumTransaction(linked("0xforged", 1), pool, payout(0, recipient, 1), chainID)
+	assertPolicyDenied(t, err, PolicyRuleUnknownRecord)
+	_, err = policy.SignEthereumTransaction(linked("0xrefunded", 1), pool, payout(0, recipient, 1), chainID)
+	assertPolicyDenied(t, err, PolicyRuleRecordMismatch)
+
+	// A linked payout is signed once
+	_, err = policy.SignEthereumTransaction(linked("0xdeposit1", 1.5), pool, payout(0, recipient, 1.5), chainID)
+	require.NoError(t, err)
+	_, err = policy.SignEthereumTransaction(linked("0xdeposit1", 1.5), pool, payout(1, recipient, 1.5), chainID)
+	assertPolicyDenied(t, err, PolicyRuleReplay)
+
+	// The transaction must pay what the record says
+	_, err = policy.SignEthereumTransaction(linked("0xdeposit2", 1), pool, payout(1, pool, 1), chainID)
+	assertPolicyDenied(t, err, PolicyRuleRecordMismatch)
+	_, err = policy.SignEthereumTransaction(linked("0xdeposit2", 1), pool, payout(1, recipient, 1.1), chainID)
+	assertPolicyDenied(t, err, PolicyRuleRecordMismatch)
+
+	// Fees are capped per transaction
+	expensive := types.NewTransaction(1, common.HexToAddress(recipient), eth(1), 21000, big.NewInt(1e12), nil)
+	_, err = policy.SignEthereumTransaction(linked("0xdeposit2", 1), pool, expensive, chainID)
+	assertPolicyDenied(t, err, PolicyRuleFee)
+
+	// Caps apply per transaction and over the window, fees included
+	_, err = policy.SignEthereumTransaction(linked("0xdeposit2", 1.5), pool, payout(1, recipient, 1.5), chainID)
+	assertPolicyDenied(t, err, PolicyRuleWindow)
+	_, err = policy.SignEthereumTransaction(linked("0xdeposit3", 2.5), pool, payout(1, recipient, 2.5), chainID)
+	assertPolicyDenied(t, err, PolicyRulePerTransaction)
+	_, err = policy.SignEthereumTransaction(linked("0xdeposit4", 2), pool, payout(1, recipient, 2), chainID)
+	assertPolicyDenied(t, err, PolicyRuleWindow)
+
+	// Calls other than ERC-20 transfers on configured tokens are denied
+	call := types.NewTransaction(1, common.HexToAddress(recipient), big.NewInt(0), 50000, big.NewInt(1e9), []byte{0xde, 0xad, 0xbe, 0xef})
+	_, err = policy.SignEthereumTransaction(linked("0xdeposit5", 1), pool, call, chainID)
+	assertPolicyDenied(t, err, PolicyRuleContract)
+
+	// Sweeps may only pay the pool, and count against no cap
+	spec, err := keys.DepositAccountSpec(keys.ChainEthereum, 0)
+	require.NoError(t, err)
+	deposit, err := policy.DeriveAccount(context.Background(), spec)
+	require.NoError(t, err)
+	sweep := func(to string) context.Context {
+		return WithSigningRecord(context.Background(), SigningRecord{
+			Kind: SigningRecordSweep, Reference: "intent1", Chain: "ethereum", Recipient: to, Asset: "ETH",
+		})
+	}
+	_, err = policy.SignEthereumTransaction(sweep(pool), deposit, payout(0, pool, 5), chainID)
+	require.NoError(t, err)
+	_, err = policy.SignEthereumTransaction(sweep(pool), deposit, payout(1, pool, 5), chainID)
+	require.NoError(t, err)
+	_, err = policy.SignEthereumTransaction(sweep(recipient), deposit, payout(2, recipient, 1), chainID)
+	assertPolicyDenied(t, err, PolicyRuleRecipient)
+	_, err = policy.SignEthereumTransaction(sweep(pool), deposit, payout(2, recipient, 1), chainID)
+	assertPolicyDenied(t, err, PolicyRuleRecipient)
+
+	// Used records and spends survive a restart
+	restarted, err := newPolicy()
+	require.NoError(t, err)
+	_, err = restarted.SignEthereumTransaction(linked("0xdeposit1", 1.5), pool, payout(1, recipient, 1.5), chainID)
+	assertPolicyDenied(t, err, PolicyRuleReplay)
+	_, err = restarted.SignEthereumTransaction(linked("0xdeposit6", 2), pool, payout(1, recipient, 2), chainID)
+	assertPolicyDenied(t, err, PolicyRuleWindow)
+
+	// Solana transfers are read from the message being signed
+	from := chainKeys.SolanaKey.PublicKey()
+	to := solana.NewWallet().PublicKey()
+	tx, err := solana.NewTransaction([]solana.Instruction{
+		system.NewTransferInstruction(5000, from, to).Build(),
+	}, solana.Hash{})
+	require.NoError(t, err)
+	message, err := tx.Message.MarshalBinary()
+	require.NoError(t, err)
+	solanaRecord := SigningRecord{
+		Kind: SigningRecordSwap, Reference: "deposit7", Chain: "solana",
+		Recipient: to.String(), Asset: "SOL", Amount: big.NewInt(5000),
+	}
+	_, err = policy.SignSolanaMessage(WithSigningRecord(context.Background(), solanaRecord), from.String(), message)
+	require.NoError(t, err)
+
+	solanaRecord.Reference = "deposit8"
+	solanaRecord.Amount = big.NewInt(4000)
+	_, err = policy.SignSolanaMessage(WithSigningRecord(context.Background(), solanaRecord), from.String(), message)
+	assertPolicyDenied(t, err, PolicyRuleRecordMismatch)
+
+	// Solana fees are the signature fees plus the priority fee
+	limit := make([]byte, 5)
+	limit[0] = computeBudgetSetUnitLimit
+	binary.LittleEndian.PutUint32(limit[1:], 300_000)
+	price := make([]byte, 9)
+	price[0] = computeBudgetSetUnitPrice
+	binary.LittleEndian.PutUint64(price[1:], 10_000)
+	priced, err := solana.NewTransaction([]solana.Instruction{
+		solana.NewInstruction(solana.ComputeBudget, nil, limit),
+		solana.NewInstruction(solana.ComputeBudget, nil, price),
+		system.NewTransferInstruction(5000, from, to).Build(),
+	}, solana.Hash{}, solana.TransactionPayer(from))
+	require.NoError(t, err)
+	message, err = priced.Message.MarshalBinary()
+	require.NoError(t, err)
+	_, fee, err := solanaTransfers(message)
+	require.NoError(t, err)
+	assert.Equal(t, int64(solanaSignatureFee+3_000), fee.Int64())
+}
@@ -0,0 +1,455 @@
+package solver
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/gagliardetto/solana-go"
+	"github.com/linera-protocol/examples/universal-solver/client/solver/keys"
+)
+
+// Signer JSON-RPC 2.0 methods. Transactions and messages travel encoded:
+// Ethereum transactions as hex RLP, Solana messages as base64, signatures as
+// base58 and PSBTs as base64.
+const (
+	signerMethodAddress                 = "signer_address"
+	signerMethodDeriveAccount           = "signer_deriveAccount"
+	signerMethodSignEthereumTransaction = "signer_signEthereumTransaction"
+	signerMethodSignSolanaMessage       = "signer_signSolanaMessage"
+	signerMethodSignBitcoinPsbt         = "signer_signBitcoinPsbt"
+)
+
+// Signer JSON-RPC error codes
+const (
+	signerErrParse          = -32700
+	signerErrInvalidRequest = -32600
+	signerErrMethodNotFound = -32601
+	signerErrInvalidParams  = -32602
+	signerErrInternal       = -32603
+	// signerErrUnknownAccount maps to ErrUnknownAccount
+	signerErrUnknownAccount = -32001
+	// signerErrPolicyDenied maps to a PolicyError, its rule in the error data
+	signerErrPolicyDenied = -32002
+)
+
+type signerRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      uint64          `json:"id"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params"`
+}
+
+type signerResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      uint64          `json:"id"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *signerError    `json:"error,omitempty"`
+}
+
+type signerError struct {
+	Code    int              `json:"code"`
+	Message string           `json:"message"`
+	Data    *signerErrorData `json:"data,omitempty"`
+}
+
+type signerErrorData struct {
+	Rule   string `json:"rule,omitempty"`
+	Reason string `json:"reason,omitempty"`
+}
+
+type signerAddressParams struct {
+	Chain   string `json:"chain"`
+	Account string `json:"account"`
+}
+
+// signerRecord is a SigningRecord on the wire, with the amount in base units
+// as a decimal string
+type signerRecord struct {
+	Kind      string `json:"kind"`
+	Reference string `json:"reference"`
+	Chain     string `json:"chain"`
+	Recipient string `json:"recipient"`
+	Asset     string `json:"asset"`
+	Amount    string `json:"amount,omitempty"`
+}
+
+// encodeSignerRecord returns the record linked to ctx, if any, for the wire
+func encodeSignerRecord(ctx context.Context) *signerRecord {
+	record, ok := signingRecordFrom(ctx)
+	if !ok {
+		return nil
+	}
+	encoded := &signerRecord{
+		Kind:      record.Kind,
+		Reference: record.Reference,
+		Chain:     record.Chain,
+		Recipient: record.Recipient,
+		Asset:     record.Asset,
+	}
+	if record.Amount != nil {
+		encoded.Amount = record.Amount.String()
+	}
+	return encoded
+}
+
+// withSignerRecord links the transactions signed with ctx to the record of a
+// request. Requests without one stay unlinked.
+func withSignerRecord(ctx context.Context, encoded *signerRecord) (context.Context, error) {
+	if encoded == nil {
+		return ctx, nil
+	}
+	record := SigningRecord{
+		Kind:      encoded.Kind,
+		Reference: encoded.Reference,
+		Chain:     encoded.Chain,
+		Recipient: encoded.Recipient,
+		Asset:     encoded.Asset,
+	}
+	if encoded.Amount != "" {
+		amount, ok := new(big.Int).SetString(encoded.Amount, 10)
+		if !ok {
+			return nil, fmt.Errorf("invalid record amount %q", encoded.Amount)
+		}
+		record.Amount = amount
+	}
+	return WithSigningRecord(ctx, record), nil
+}
+
+type signerEthereumParams struct {
+	From    string        `json:"from"`
+	Tx      string        `json:"tx"`
+	ChainID string        `json:"chainId"`
+	Record  *signerRecord `json:"record,omitempty"`
+}
+
+type signerSolanaParams struct {
+	From    string        `json:"from"`
+	Message string        `json:"message"`
+	Record  *signerRecord `json:"record,omitempty"`
+}
+
+type signerBitcoinParams struct {
+	From   string        `json:"from"`
+	Psbt   string        `json:"psbt"`
+	Record *signerRecord `json:"record,omitempty"`
+}
+
+// RemoteSigner signs through a separate signer process over JSON-RPC, so
+// private keys never enter the HTTP server. The record a transaction is
+// linked to travels with it, for the signer process to run its own policy.
+type RemoteSigner struct {
+	url    string
+	token  string
+	http   *http.Client
+	nextID uint64
+}
+
+// NewRemoteSigner returns a signer calling the signer process at url,
+// authenticating with token when it is not empty
+func NewRemoteSigner(url, token string) *RemoteSigner {
+	return &RemoteSigner{
+		url:   url,
+		token: token,
+		http:  &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+func (s *RemoteSigner) call(ctx context.Context, method string, params, result interface{}) error {
+	encodedParams, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(signerRequest{
+		JSONRPC: "2.0",
+		ID:      atomic.AddUint64(&s.nextID, 1),
+		Method:  method,
+		Params:  encodedParams,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.token)
+	}
+
+	resp, err := s.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("signer request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("signer returned status %d", resp.StatusCode)
+	}
+
+	var response signerResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return fmt.Errorf("failed to decode signer response: %w", err)
+	}
+	if response.Error != nil {
+		if response.Error.Code == signerErrUnknownAccount {
+			return fmt.Errorf("%w: %s", ErrUnknownAccount, response.Error.Message)
+		}
+		if response.Error.Code == signerErrPolicyDenied && response.Error.Data != nil {
+			return &PolicyError{Rule: response.Error.Data.Rule, Reason: response.Error.Data.Reason}
+		}
+		return fmt.Errorf("signer error %d: %s", response.Error.Code, response.Error.Message)
+	}
+	return json.Unmarshal(response.Result, result)
+}
+
+// Address returns the address of the named account on chain
+func (s *RemoteSigner) Address(ctx context.Context, chain, account string) (string, error) {
+	var address string
+	err := s.call(ctx, signerMethodAddress, signerAddressParams{Chain: chain, Account: account}, &address)
+	return address, err
+}
+
+// DeriveAccount derives and registers the account in spec
+func (s *RemoteSigner) DeriveAccount(ctx context.Context, spec keys.AccountSpec) (string, error) {
+	var address string
+	err := s.call(ctx, signerMethodDeriveAccount, spec, &address)
+	return address, err
+}
+
+// SignEthereumTransaction signs tx for chainID with the key of from
+func (s *RemoteSigner) SignEthereumTransaction(ctx context.Context, from string, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	unsigned, err := tx.MarshalBinary()
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode transaction: %w", err)
+	}
+
+	var raw string
+	params := signerEthereumParams{From: from, Tx: hexutil.Encode(unsigned), ChainID: chainID.String(), Record: encodeSignerRecord(ctx)}
+	if err := s.call(ctx, signerMethodSignEthereumTransaction, params, &raw); err != nil {
+		return nil, err
+	}
+
+	encoded, err := hexutil.Decode(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signed transaction: %w", err)
+	}
+	signedTx := new(types.Transaction)
+	if err := signedTx.UnmarshalBinary(encoded); err != nil {
+		return nil, fmt.Errorf("invalid signed transaction: %w", err)
+	}
+	// The signer must not change what it was asked to sign
+	if types.LatestSignerForChainID(chainID).Hash(signedTx) != types.LatestSignerForChainID(chainID).Hash(tx) {
+		return nil, fmt.Errorf("signer returned a different transaction")
+	}
+	return signedTx, nil
+}
+
+// SignSolanaMessage signs a serialized transaction message with the key of from
+func (s *RemoteSigner) SignSolanaMessage(ctx context.Context, from string, message []byte) (solana.Signature, error) {
+	var encoded string
+	params := signerSolanaParams{From: from, Message: base64.StdEncoding.EncodeToString(message), Record: encodeSignerRecord(ctx)}
+	if err := s.call(ctx, signerMethodSignSolanaMessage, params, &encoded); err != nil {
+		return solana.Signature{}, err
+	}
+
+	signature, err := solana.SignatureFromBase58(encoded)
+	if err != nil {
+		return solana.Signature{}, fmt.Errorf("invalid signature: %w", err)
+	}
+	publicKey, err := solana.PublicKeyFromBase58(from)
+	if err != nil {
+		return solana.Signature{}, fmt.Errorf("invalid signer address: %w", err)
+	}
+	if !signature.Verify(publicKey, message) {
+		return solana.Signature{}, fmt.Errorf("signer returned an invalid signature")
+	}
+	return signature, nil
+}
+
+// SignBitcoinPsbt signs and finalizes every input of a base64 PSBT
+func (s *RemoteSigner) SignBitcoinPsbt(ctx context.Context, from string, psbt string) (string, error) {
+	var signed string
+	params := signerBitcoinParams{From: from, Psbt: psbt, Record: encodeSignerRecord(ctx)}
+	err := s.call(ctx, signerMethodSignBitcoinPsbt, params, &signed)
+	return signed, err
+}
+
+// signerHandler serves a Signer over the RemoteSigner protocol
+type signerHandler struct {
+	signer Signer
+	token  string
+}
+
+// NewSignerHandler serves signer over JSON-RPC for RemoteSigner clients.
+// Requests must carry token as a bearer token when it is not empty. The
+// records sent with signing requests are linked to the context signer sees,
+// so a PolicySigner vets them as it would in-process.
+func NewSignerHandler(signer Signer, token string) http.Handler {
+	return &signerHandler{signer: signer, token: token}
+}
+
+func (h *signerHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if h.token != "" {
+		expected := []byte("Bearer " + h.token)
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), expected) != 1 {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+	}
+
+	var request signerRequest
+	response := signerResponse{JSONRPC: "2.0"}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&request); err != nil {
+		response.Error = &signerError{Code: signerErrParse, Message: err.Error()}
+	} else {
+		response.ID = request.ID
+		result, rpcErr := h.dispatch(r.Context(), request)
+		if rpcErr != nil {
+			Logger.Printf("Signer: %s failed: %s", request.Method, rpcErr.Message)
+			response.Error = rpcErr
+		} else if response.Result, rpcErr = marshalSignerResult(result); rpcErr != nil {
+			response.Error = rpcErr
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func marshalSignerResult(result interface{}) (json.RawMessage, *signerError) {
+	encoded, err := json.Marshal(result)
+	if err != nil {
+		return nil, &signerError{Code: signerErrInternal, Message: err.Error()}
+	}
+	return encoded, nil
+}
+
+func (h *signerHandler) dispatch(ctx context.Context, request signerRequest) (interface{}, *signerError) {
+	if request.JSONRPC != "2.0" {
+		return nil, &signerError{Code: signerErrInvalidRequest, Message: "jsonrpc must be 2.0"}
+	}
+
+	switch request.Method {
+	case signerMethodAddress:
+		var params signerAddressParams
+		if err := json.Unmarshal(request.Params, &params); err != nil {
+			return nil, invalidSignerParams(err)
+		}
+		address, err := h.signer.Address(ctx, params.Chain, params.Account)
+		if err != nil {
+			return nil, signerFailure(err)
+		}
+		return address, nil
+
+	case signerMethodDeriveAccount:
+		var spec keys.AccountSpec
+		if err := json.Unmarshal(request.Params, &spec); err != nil {
+			return nil, invalidSignerParams(err)
+		}
+		address, err := h.signer.DeriveAccount(ctx, spec)
+		if err != nil {
+			return nil, signerFailure(err)
+		}
+		return address, nil
+
+	case signerMethodSignEthereumTransaction:
+		var params signerEthereumParams
+		if err := json.Unmarshal(request.Params, &params); err != nil {
+			return nil, invalidSignerParams(err)
+		}
+		encoded, err := hexutil.Decode(params.Tx)
+		if err != nil {
+			return nil, invalidSignerParams(err)
+		}
+		tx := new(types.Transaction)
+		if err := tx.UnmarshalBinary(encoded); err != nil {
+			return nil, invalidSignerParams(err)
+		}
+		chainID, ok := new(big.Int).SetString(params.ChainID, 10)
+		if !ok {
+			return nil, invalidSignerParams(fmt.Errorf("invalid chainId %q", params.ChainID))
+		}
+		ctx, err := withSignerRecord(ctx, params.Record)
+		if err != nil {
+			return nil, invalidSignerParams(err)
+		}
+		signedTx, err := h.signer.SignEthereumTransaction(ctx, params.From, tx, chainID)
+		if err != nil {
+			return nil, signerFailure(err)
+		}
+		raw, err := signedTx.MarshalBinary()
+		if err != nil {
+			return nil, signerFailure(err)
+		}
+		return hexutil.Encode(raw), nil
+
+	case signerMethodSignSolanaMessage:
+		var params signerSolanaParams
+		if err := json.Unmarshal(request.Params, &params); err != nil {
+			return nil, invalidSignerParams(err)
+		}
+		message, err := base64.StdEncoding.DecodeString(params.Message)
+		if err != nil {
+			return nil, invalidSignerParams(err)
+		}
+		ctx, err := withSignerRecord(ctx, params.Record)
+		if err != nil {
+			return nil, invalidSignerParams(err)
+		}
+		signature, err := h.signer.SignSolanaMessage(ctx, params.From, message)
+		if err != nil {
+			return nil, signerFailure(err)
+		}
+		return signature.String(), nil
+
+	case signerMethodSignBitcoinPsbt:
+		var params signerBitcoinParams
+		if err := json.Unmarshal(request.Params, &params); err != nil {
+			return nil, invalidSignerParams(err)
+		}
+		ctx, err := withSignerRecord(ctx, params.Record)
+		if err != nil {
+			return nil, invalidSignerParams(err)
+		}
+		signed, err := h.signer.SignBitcoinPsbt(ctx, params.From, params.Psbt)
+		if err != nil {
+			return nil, signerFailure(err)
+		}
+		return signed, nil
+
+	default:
+		return nil, &signerError{Code: signerErrMethodNotFound, Message: "unknown method " + request.Method}
+	}
+}
+
+func invalidSignerParams(err error) *signerError {
+	return &signerError{Code: signerErrInvalidParams, Message: err.Error()}
+}
+
+func signerFailure(err error) *signerError {
+	if errors.Is(err, ErrUnknownAccount) {
+		return &signerError{Code: signerErrUnknownAccount, Message: err.Error()}
+	}
+	var policyErr *PolicyError
+	if errors.As(err, &policyErr) {
+		return &signerError{Code: signerErrPolicyDenied, Message: err.Error(),
+			Data: &signerErrorData{Rule: policyErr.Rule, Reason: policyErr.Reason}}
+	}
+	return &signerError{Code: signerErrInternal, Message: err.Error()}
+}
@@ -0,0 +1,126 @@
+package solver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/gagliardetto/solana-go"
+	"github.com/linera-protocol/examples/universal-solver/client/solver/keys"
+)
+
+// ErrUnknownAccount is returned when a signer holds no key for an account
+var ErrUnknownAccount = errors.New("unknown account")
+
+// Signer signs transactions for the derived accounts. Accounts are named by
+// chain and name (see keys.AccountSpec) and keys are selected by address.
+type Signer interface {
+	// Address returns the address of the named account on chain
+	Address(ctx context.Context, chain, account string) (string, error)
+	// DeriveAccount derives and registers the account in spec, returning its
+	// address. Deriving the same account again is a no-op.
+	DeriveAccount(ctx context.Context, spec keys.AccountSpec) (string, error)
+	// SignEthereumTransaction signs tx for chainID with the key of from
+	SignEthereumTransaction(ctx context.Context, from string, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error)
+	// SignSolanaMessage signs a serialized transaction message with the key of from
+	SignSolanaMessage(ctx context.Context, from string, message []byte) (solana.Signature, error)
+	// SignBitcoinPsbt signs and finalizes every input of a base64 PSBT
+	// spending outputs of from, returning the signed PSBT
+	SignBitcoinPsbt(ctx context.Context, from string, psbt string) (string, error)
+}
+
+// signer signs every outbound transaction, in-process by default
+var signer Signer
+
+// SetSigner replaces the signer, e.g. with a RemoteSigner
+func SetSigner(s Signer) {
+	signer = s
+}
+
+func currentSigner() (Signer, error) {
+	if signer == nil {
+		return nil, fmt.Errorf("signer not initialized")
+	}
+	return signer, nil
+}
+
+// LocalSigner signs in-process with keys derived from the seed
+type LocalSigner struct {
+	keys *keys.ChainKeys
+}
+
+// NewLocalSigner returns a signer for the accounts of chainKeys
+func NewLocalSigner(chainKeys *keys.ChainKeys) *LocalSigner {
+	return &LocalSigner{keys: chainKeys}
+}
+
+// account returns the derived account at address
+func (s *LocalSigner) account(chain, address string) (*keys.Account, error) {
+	account, ok := s.keys.AccountByAddress(chain, address)
+	if !ok {
+		return nil, fmt.Errorf("%w: no %s account derived for %s", ErrUnknownAccount, chain, address)
+	}
+	return account, nil
+}
+
+// Address returns the address of the named account on chain
+func (s *LocalSigner) Address(_ context.Context, chain, name string) (string, error) {
+	account, ok := s.keys.Account(chain, name)
+	if !ok {
+		return "", fmt.Errorf("%w: no %s account named %s", ErrUnknownAccount, chain, name)
+	}
+	return account.Address, nil
+}
+
+// DeriveAccount derives and registers the account in spec
+func (s *LocalSigner) DeriveAccount(_ context.Context, spec keys.AccountSpec) (string, error) {
+	account, err := s.keys.DeriveAccount(spec)
+	if err != nil {
+		return "", err
+	}
+	return account.Address, nil
+}
+
+// SignEthereumTransaction signs tx for chainID with the key of from
+func (s *LocalSigner) SignEthereumTransaction(_ context.Context, from string, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	account, err := s.account(keys.ChainEthereum, from)
+	if err != nil {
+		return nil, err
+	}
+	signedTx, err := types.SignTx(tx, types.LatestSignerForChainID(chainID), account.EthereumKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign transaction: %w", err)
+	}
+	return signedTx, nil
+}
+
+// SignSolanaMessage signs a serialized transaction message with the key of from
+func (s *LocalSigner) SignSolanaMessage(_ context.Context, from string, message []byte) (solana.Signature, error) {
+	account, err := s.account(keys.ChainSolana, from)
+	if err != nil {
+		return solana.Signature{}, err
+	}
+	signature, err := account.SolanaKey.Sign(message)
+	if err != nil {
+		return solana.Signature{}, fmt.Errorf("failed to sign message: %w", err)
+	}
+	return signature, nil
+}
+
+// SignBitcoinPsbt signs and finalizes every input of a base64 PSBT
+func (s *LocalSigner) SignBitcoinPsbt(_ context.Context, from string, psbt string) (string, error) {
+	account, err := s.account(keys.ChainBitcoin, from)
+	if err != nil {
+		return "", err
+	}
+	packet, err := decodePsbt(psbt)
+	if err != nil {
+		return "", err
+	}
+	if err := signBitcoinPsbt(packet, account.BitcoinKey); err != nil {
+		return "", err
+	}
+	return packet.B64Encode()
+}
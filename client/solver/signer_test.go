@@ -0,0 +1,110 @@
+package solver
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/linera-protocol/examples/universal-solver/client/solver/keys"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testMnemonic is the BIP39 test vector mnemonic the test keys derive from
+const testMnemonic = "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+
+// testChainKeys derives the chain keys of testMnemonic
+func testChainKeys(t *testing.T) *keys.ChainKeys {
+	t.Helper()
+	chainKeys, err := keys.DeriveKeys(testMnemonic, keys.DerivationOptions{})
+	require.NoError(t, err)
+	return chainKeys
+}
+
+func TestRemoteSigner(t *testing.T) {
+	chainKeys := testChainKeys(t)
+	local := NewLocalSigner(chainKeys)
+
+	server := httptest.NewServer(NewSignerHandler(local, "secret"))
+	defer server.Close()
+	remote := NewRemoteSigner(server.URL, "secret")
+	ctx := context.Background()
+
+	// Addresses match the locally derived accounts
+	ethereumPool, err := remote.Address(ctx, keys.ChainEthereum, keys.AccountPool)
+	require.NoError(t, err)
+	assert.Equal(t, "0x9858EfFD232B4033E47d90003D41EC34EcaEda94", ethereumPool)
+	_, err = remote.Address(ctx, keys.ChainEthereum, keys.AccountFaucet)
+	assert.True(t, errors.Is(err, ErrUnknownAccount))
+
+	// Ethereum transactions come back signed by the pool
+	chainID := big.NewInt(1337)
+	tx := types.NewTransaction(7, common.HexToAddress("0x6Fac4D18c912343BF86fa7049364Dd4E424Ab9C0"), big.NewInt(1e18), 21000, big.NewInt(1e9), nil)
+	signedTx, err := remote.SignEthereumTransaction(ctx, ethereumPool, tx, chainID)
+	require.NoError(t, err)
+	sender, err := types.Sender(types.LatestSignerForChainID(chainID), signedTx)
+	require.NoError(t, err)
+	assert.Equal(t, ethereumPool, sender.Hex())
+	assert.Equal(t, tx.Nonce(), signedTx.Nonce())
+
+	_, err = remote.SignEthereumTransaction(ctx, "0x6Fac4D18c912343BF86fa7049364Dd4E424Ab9C0", tx, chainID)
+	assert.True(t, errors.Is(err, ErrUnknownAccount))
+
+	// Solana signatures verify against the pool public key
+	solanaPool, err := remote.Address(ctx, keys.ChainSolana, keys.AccountPool)
+	require.NoError(t, err)
+	message := []byte("solana message")
+	signature, err := remote.SignSolanaMessage(ctx, solanaPool, message)
+	require.NoError(t, err)
+	assert.True(t, signature.Verify(chainKeys.SolanaKey.PublicKey(), message))
+
+	// Requests without the token are rejected
+	_, err = NewRemoteSigner(server.URL, "wrong").Address(ctx, keys.ChainEthereum, keys.AccountPool)
+	assert.Error(t, err)
+
+	// A signer process vets transactions against the records sent with them,
+	// which must name requests the client persisted
+	dataDir := t.TempDir()
+	intents, err := NewIntentStore(filepath.Join(dataDir, "swap_intents.json"), 0)
+	require.NoError(t, err)
+	_, err = intents.ClaimDeposit(keys.ChainEthereum, "SOL", "ETH", "0x6Fac4D18c912343BF86fa7049364Dd4E424Ab9C0", "0xdeposit")
+	require.NoError(t, err)
+	policy, err := NewPolicySigner(NewLocalSigner(chainKeys), &SigningPolicyConfig{}, "")
+	require.NoError(t, err)
+	policy.VerifyRecordsIn(dataDir)
+	policyServer := httptest.NewServer(NewSignerHandler(policy, ""))
+	defer policyServer.Close()
+	vetted := NewRemoteSigner(policyServer.URL, "")
+
+	_, err = vetted.SignEthereumTransaction(ctx, ethereumPool, tx, chainID)
+	assertPolicyDenied(t, err, PolicyRuleUnlinked)
+	linked := WithSigningRecord(ctx, SigningRecord{
+		Kind: SigningRecordSwap, Reference: "0xdeposit", Chain: keys.ChainEthereum,
+		Recipient: "0x6Fac4D18c912343BF86fa7049364Dd4E424Ab9C0", Asset: "ETH", Amount: big.NewInt(1e18),
+	})
+	forged := WithSigningRecord(ctx, SigningRecord{
+		Kind: SigningRecordSwap, Reference: "0xforged", Chain: keys.ChainEthereum,
+		Recipient: "0x6Fac4D18c912343BF86fa7049364Dd4E424Ab9C0", Asset: "ETH", Amount: big.NewInt(1e18),
+	})
+	_, err = vetted.SignEthereumTransaction(forged, ethereumPool, tx, chainID)
+	assertPolicyDenied(t, err, PolicyRuleUnknownRecord)
+	_, err = vetted.SignEthereumTransaction(linked, ethereumPool, tx, chainID)
+	require.NoError(t, err)
+	_, err = vetted.SignEthereumTransaction(linked, ethereumPool, tx, chainID)
+	assertPolicyDenied(t, err, PolicyRuleReplay)
+
+	// Only deposit accounts may be derived through it
+	spec, err := keys.DepositAccountSpec(keys.ChainEthereum, 3)
+	require.NoError(t, err)
+	_, err = vetted.DeriveAccount(ctx, spec)
+	require.NoError(t, err)
+	_, err = vetted.DeriveAccount(ctx, keys.AccountSpec{Chain: keys.ChainEthereum, Name: "deposit-4", Path: "m/44'/60'/0'/0/0"})
+	assertPolicyDenied(t, err, PolicyRuleUnsupported)
+	_, err = vetted.DeriveAccount(ctx, keys.AccountSpec{Chain: keys.ChainEthereum, Name: "fees", Path: "m/44'/60'/2'/0/0"})
+	assertPolicyDenied(t, err, PolicyRuleUnsupported)
+}
@@ -0,0 +1,240 @@
+package solver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/gagliardetto/solana-go"
+	associatedtokenaccount "github.com/gagliardetto/solana-go/programs/associated-token-account"
+	"github.com/gagliardetto/solana-go/programs/token"
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+// solanaTokenBalance is an entry of preTokenBalances/postTokenBalances
+type solanaTokenBalance struct {
+	AccountIndex  int    `json:"accountIndex"`
+	Mint          string `json:"mint"`
+	Owner         string `json:"owner"`
+	UITokenAmount struct {
+		Amount   string `json:"amount"`
+		Decimals int    `json:"decimals"`
+	} `json:"uiTokenAmount"`
+}
+
+// parseSPLDeposit finds configured SPL tokens whose balance increased on the
+// associated token account of that token's pool
+func parseSPLDeposit(decoded *solanaTransaction, poolByToken map[string]string) (*Deposit, error) {
+	keys := decoded.accountKeys()
+
+	// Token balance deltas per account index
+	deltas := make(map[int]*big.Int)
+	balances := make(map[int]solanaTokenBalance)
+	for _, balance := range decoded.Result.Meta.PreTokenBalances {
+		amount, ok := new(big.Int).SetString(balance.UITokenAmount.Amount, 10)
+		if !ok {
+			continue
+		}
+		deltas[balance.AccountIndex] = new(big.Int).Neg(amount)
+		balances[balance.AccountIndex] = balance
+	}
+	for _, balance := range decoded.Result.Meta.PostTokenBalances {
+		amount, ok := new(big.Int).SetString(balance.UITokenAmount.Amount, 10)
+		if !ok {
+			continue
+		}
+		// Accounts created in this transaction have no pre balance
+		if deltas[balance.AccountIndex] == nil {
+			deltas[balance.AccountIndex] = new(big.Int)
+		}
+		deltas[balance.AccountIndex].Add(deltas[balance.AccountIndex], amount)
+		balances[balance.AccountIndex] = balance
+	}
+
+	// Walk the accounts in order so the outcome does not depend on map order
+	indexes := make([]int, 0, len(deltas))
+	for index := range deltas {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+
+	var deposit *Deposit
+	for _, index := range indexes {
+		delta := deltas[index]
+		if delta.Sign() <= 0 || index >= len(keys) {
+			continue
+		}
+		balance := balances[index]
+		tok, ok := TokenByAddress("solana", balance.Mint)
+		if !ok {
+			continue
+		}
+		pool, ok := poolByToken[tok.Symbol]
+		if !ok || balance.Owner != pool {
+			continue
+		}
+
+		// Only the pool's associated token account counts as a deposit
+		poolKey, err := solana.PublicKeyFromBase58(pool)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pool address %s: %w", pool, err)
+		}
+		mint, err := solana.PublicKeyFromBase58(tok.Address)
+		if err != nil {
+			return nil, fmt.Errorf("invalid mint address %s: %w", tok.Address, err)
+		}
+		ata, _, err := solana.FindAssociatedTokenAddress(poolKey, mint)
+		if err != nil {
+			return nil, fmt.Errorf("failed to derive pool token account: %w", err)
+		}
+		if keys[index] != ata.String() {
+			continue
+		}
+
+		if deposit != nil {
+			return nil, fmt.Errorf("transaction deposits more than one token")
+		}
+		deposit = &Deposit{
+			Chain:     "solana",
+			Recipient: pool,
+			Amount:    delta,
+			Decimals:  tok.Decimals,
+			Symbol:    tok.Symbol,
+			Reference: decoded.memo(),
+		}
+	}
+
+	if deposit == nil {
+		return nil, fmt.Errorf("no deposit to a pool found in transaction")
+	}
+
+	// The sender is the owner whose balance of the same mint went down
+	mint, _ := LookupToken(deposit.Symbol)
+	for _, index := range indexes {
+		balance := balances[index]
+		if deltas[index].Sign() >= 0 || balance.Mint != mint.Address {
+			continue
+		}
+		if deposit.Sender != "" && deposit.Sender != balance.Owner {
+			return nil, fmt.Errorf("transaction contains transfers to the pool from multiple senders")
+		}
+		deposit.Sender = balance.Owner
+	}
+	if len(decoded.Result.Transaction.Signatures) > 0 {
+		deposit.TxHash = decoded.Result.Transaction.Signatures[0]
+	}
+	return deposit, nil
+}
+
+// GetSolanaDeposit returns the native SOL or configured SPL token deposit a
+// getTransaction response made into the matching pool
+func (c *Client) GetSolanaDeposit(tx interface{}) (*Deposit, error) {
+	pools, err := c.GetAllPools()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pools: %w", err)
+	}
+	poolByToken := make(map[string]string)
+	for _, pool := range pools {
+		poolByToken[pool.ChainName] = pool.PoolAddress
+	}
+	return ParseSolanaDeposit(tx, poolByToken)
+}
+
+// GetSolanaTokenBalance fetches the SPL token balance held in the associated
+// token account of address
+func (c *Client) GetSolanaTokenBalance(address string, tok Token) (*Balance, error) {
+	owner, err := solana.PublicKeyFromBase58(address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Solana address: %w", err)
+	}
+	mint, err := solana.PublicKeyFromBase58(tok.Address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid mint address: %w", err)
+	}
+
+	ata, _, err := solana.FindAssociatedTokenAddress(owner, mint)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive token account: %w", err)
+	}
+
+	client := rpc.New(SolanaRPC)
+
+	// An address that never held the token has no token account yet
+	exists, err := solanaTokenAccountExists(client, ata)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get token account: %w", err)
+	}
+	if !exists {
+		return &Balance{Address: address, Amount: 0, Symbol: tok.Symbol, Token: tok.Address}, nil
+	}
+
+	result, err := client.GetTokenAccountBalance(context.Background(), ata, rpc.CommitmentFinalized)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get token balance: %w", err)
+	}
+
+	amount, ok := new(big.Int).SetString(result.Value.Amount, 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid token amount: %s", result.Value.Amount)
+	}
+
+	return &Balance{
+		Address: address,
+		Amount:  tok.FromBaseUnits(amount),
+		Symbol:  tok.Symbol,
+		Token:   tok.Address,
+	}, nil
+}
+
+// solanaTokenAccountExists reports whether the token account has been created
+func solanaTokenAccountExists(client *rpc.Client, account solana.PublicKey) (bool, error) {
+	_, err := client.GetAccountInfo(context.Background(), account)
+	if errors.Is(err, rpc.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// splTransferInstructions builds a TransferChecked from the owner's token
+// account to the destination's, creating the destination account if needed
+func splTransferInstructions(params ChainParams, owner, destination solana.PublicKey) ([]solana.Instruction, error) {
+	mint, err := solana.PublicKeyFromBase58(params.TokenAddress)
+	if err != nil {
+		return nil, fmt.Errorf("invalid mint address: %w", err)
+	}
+	amount, ok := new(big.Int).SetString(params.TokenAmount, 10)
+	if !ok || !amount.IsUint64() {
+		return nil, fmt.Errorf("invalid token amount: %s", params.TokenAmount)
+	}
+
+	source, _, err := solana.FindAssociatedTokenAddress(owner, mint)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive source token account: %w", err)
+	}
+	target, _, err := solana.FindAssociatedTokenAddress(destination, mint)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive destination token account: %w", err)
+	}
+
+	var instructions []solana.Instruction
+	if params.CreateTokenAccount {
+		instructions = append(instructions,
+			associatedtokenaccount.NewCreateInstruction(owner, destination, mint).Build())
+	}
+	instructions = append(instructions,
+		token.NewTransferCheckedInstruction(
+			amount.Uint64(),
+			params.Decimals,
+			source,
+			mint,
+			target,
+			owner,
+			nil,
+		).Build())
+	return instructions, nil
+}
@@ -0,0 +1,184 @@
+package solver
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/programs/system"
+	"github.com/linera-protocol/examples/universal-solver/client/solver/keys"
+	"github.com/mr-tron/base58"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSPLDeposit(t *testing.T) {
+	usdc := Token{Chain: "solana", Symbol: "TEST-SPL", Address: "EPjFWdd5AufqSSqeM2qN1xzybapC8G4wEGGkZwyTDt1v", Decimals: 6}
+	usdt := Token{Chain: "solana", Symbol: "TEST-SPL2", Address: "Es9vMFrzaCERmJfrF4H2FYD4KCoNkY11McCe8BenwNYB", Decimals: 6}
+	require.NoError(t, RegisterToken(usdc))
+	require.NoError(t, RegisterToken(usdt))
+	pools := map[string]string{"TEST-SPL": testSolanaPool, "TEST-SPL2": testSolanaPool}
+
+	tokenAccount := func(owner string, tok Token) string {
+		ata, _, err := solana.FindAssociatedTokenAddress(solana.MustPublicKeyFromBase58(owner), solana.MustPublicKeyFromBase58(tok.Address))
+		require.NoError(t, err)
+		return ata.String()
+	}
+	balance := func(index int, owner string, tok Token, amount string) map[string]interface{} {
+		return map[string]interface{}{
+			"accountIndex":  index,
+			"mint":          tok.Address,
+			"owner":         owner,
+			"uiTokenAmount": map[string]interface{}{"amount": amount, "decimals": tok.Decimals},
+		}
+	}
+
+	// Account keys: fee payer, then token accounts
+	keys := []string{
+		testSolanaSender,
+		tokenAccount(testSolanaSender, usdc),
+		tokenAccount(testSolanaPool, usdc),
+		tokenAccount(testSolanaOther, usdc),
+		tokenAccount(testSolanaSender, usdt),
+		tokenAccount(testSolanaPool, usdt),
+		// A token account of the pool that is not its associated one
+		solana.NewWallet().PublicKey().String(),
+	}
+
+	tests := []struct {
+		name       string
+		pre        []map[string]interface{}
+		post       []map[string]interface{}
+		wantErr    string
+		wantSymbol string
+		wantAmount int64
+	}{
+		{
+			name:       "transfer into the pool",
+			pre:        []map[string]interface{}{balance(1, testSolanaSender, usdc, "5000000"), balance(2, testSolanaPool, usdc, "100")},
+			post:       []map[string]interface{}{balance(1, testSolanaSender, usdc, "4710000"), balance(2, testSolanaPool, usdc, "290100")},
+			wantSymbol: "TEST-SPL",
+			wantAmount: 290_000,
+		},
+		{
+			name:       "pool account created by the transfer",
+			pre:        []map[string]interface{}{balance(4, testSolanaSender, usdt, "5000000")},
+			post:       []map[string]interface{}{balance(4, testSolanaSender, usdt, "4000000"), balance(5, testSolanaPool, usdt, "1000000")},
+			wantSymbol: "TEST-SPL2",
+			wantAmount: 1_000_000,
+		},
+		{
+			name: "transfers from several owners",
+			pre: []map[string]interface{}{
+				balance(1, testSolanaSender, usdc, "5000000"),
+				balance(2, testSolanaPool, usdc, "0"),
+				balance(3, testSolanaOther, usdc, "5000000"),
+			},
+			post: []map[string]interface{}{
+				balance(1, testSolanaSender, usdc, "4000000"),
+				balance(2, testSolanaPool, usdc, "2000000"),
+				balance(3, testSolanaOther, usdc, "4000000"),
+			},
+			wantErr: "multiple senders",
+		},
+		{
+			name: "transfers of several tokens",
+			pre: []map[string]interface{}{
+				balance(1, testSolanaSender, usdc, "5000000"),
+				balance(2, testSolanaPool, usdc, "0"),
+				balance(4, testSolanaSender, usdt, "5000000"),
+				balance(5, testSolanaPool, usdt, "0"),
+			},
+			post: []map[string]interface{}{
+				balance(1, testSolanaSender, usdc, "4000000"),
+				balance(2, testSolanaPool, usdc, "1000000"),
+				balance(4, testSolanaSender, usdt, "4000000"),
+				balance(5, testSolanaPool, usdt, "1000000"),
+			},
+			wantErr: "more than one token",
+		},
+		{
+			name:    "transfer into another token account of the pool",
+			pre:     []map[string]interface{}{balance(1, testSolanaSender, usdc, "5000000"), balance(6, testSolanaPool, usdc, "0")},
+			post:    []map[string]interface{}{balance(1, testSolanaSender, usdc, "4000000"), balance(6, testSolanaPool, usdc, "1000000")},
+			wantErr: "no deposit to a pool found",
+		},
+		{
+			name:    "transfer to another owner",
+			pre:     []map[string]interface{}{balance(1, testSolanaSender, usdc, "5000000"), balance(3, testSolanaOther, usdc, "0")},
+			post:    []map[string]interface{}{balance(1, testSolanaSender, usdc, "4000000"), balance(3, testSolanaOther, usdc, "1000000")},
+			wantErr: "no deposit to a pool found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx := testSolanaTx(keys, []map[string]interface{}{}, tt.pre, tt.post)
+			// Map order must not change the outcome
+			for i := 0; i < 20; i++ {
+				deposit, err := ParseSolanaDeposit(tx, pools)
+				if tt.wantErr != "" {
+					require.ErrorContains(t, err, tt.wantErr)
+					continue
+				}
+				require.NoError(t, err)
+				assert.Equal(t, tt.wantSymbol, deposit.Symbol)
+				assert.Equal(t, testSolanaSender, deposit.Sender)
+				assert.Equal(t, testSolanaPool, deposit.Recipient)
+				assert.Equal(t, tt.wantAmount, deposit.Amount.Int64())
+			}
+		})
+	}
+}
+
+func TestSolanaPayout(t *testing.T) {
+	chainKeys := testChainKeys(t)
+	SetSigner(NewLocalSigner(chainKeys))
+	pool, err := signer.Address(context.Background(), keys.ChainSolana, keys.AccountPool)
+	require.NoError(t, err)
+
+	solverServer := fakeSolver(t, map[string]string{"SOL": pool}, 1)
+	defer solverServer.Close()
+	blockhash := solana.HashFromBytes(make([]byte, 32))
+	solanaServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req fakeRPCRequest
+		if !assert.NoError(t, json.NewDecoder(r.Body).Decode(&req)) || !assert.Equal(t, "getLatestBlockhash", req.Method) {
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"jsonrpc": "2.0", "id": req.ID, "result": map[string]interface{}{
+			"context": map[string]interface{}{"slot": 1},
+			"value":   map[string]interface{}{"blockhash": blockhash.String(), "lastValidBlockHeight": 100},
+		}})
+	}))
+	defer solanaServer.Close()
+	InitRPCEndpoints("", solanaServer.URL)
+	client := NewClient(solverServer.URL)
+
+	// Whole SOL amounts are paid out in lamports
+	swap := &SwapResponse{
+		SwapResult:         SwapResult{FromToken: "ETH", ToToken: "SOL", ToAmount: 0.29},
+		DestinationAddress: testSolanaOther,
+	}
+	require.NoError(t, client.prepareSolanaTransaction(swap))
+	assert.Equal(t, uint64(290_000_000), swap.TxToSign.ChainParams.Lamports)
+	require.NoError(t, client.SignTransaction(context.Background(), swap))
+
+	raw, err := base58.Decode(swap.TxToSign.RawTx)
+	require.NoError(t, err)
+	tx, err := solana.TransactionFromBytes(raw)
+	require.NoError(t, err)
+	require.Len(t, tx.Message.Instructions, 1)
+	instruction := tx.Message.Instructions[0]
+	accounts, err := instruction.ResolveInstructionAccounts(&tx.Message)
+	require.NoError(t, err)
+	decoded, err := system.DecodeInstruction(accounts, instruction.Data)
+	require.NoError(t, err)
+	transfer, ok := decoded.Impl.(*system.Transfer)
+	require.True(t, ok)
+	assert.Equal(t, uint64(290_000_000), *transfer.Lamports)
+	assert.Equal(t, pool, transfer.GetFundingAccount().PublicKey.String())
+	assert.Equal(t, testSolanaOther, transfer.GetRecipientAccount().PublicKey.String())
+}
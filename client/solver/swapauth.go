@@ -0,0 +1,254 @@
+package solver
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+	"github.com/gagliardetto/solana-go"
+)
+
+// Supported swap authorization signature schemes
+const (
+	SignatureSchemeEIP191  = "eip191"
+	SignatureSchemeEIP712  = "eip712"
+	SignatureSchemeEd25519 = "ed25519"
+	SignatureSchemeBIP137  = "bip137"
+)
+
+// MaxSwapAuthorizationLifetime bounds how far ahead an authorization may
+// expire, and so how long its nonce has to be remembered
+const MaxSwapAuthorizationLifetime = 24 * time.Hour
+
+// SwapAuthorization is the payload a depositor signs to prove they own the
+// account that sent the deposit and to bind the payout to a destination.
+type SwapAuthorization struct {
+	TxHash             string `json:"txHash"`
+	ToToken            string `json:"toToken"`
+	DestinationAddress string `json:"destinationAddress"`
+	Nonce              string `json:"nonce"`
+	// ExpiresAt is the unix time in seconds after which the authorization
+	// is no longer accepted
+	ExpiresAt int64 `json:"expiresAt"`
+}
+
+// Message returns the plain-text message signed with EIP-191 personal_sign,
+// ed25519 or Bitcoin signmessage
+func (a SwapAuthorization) Message() []byte {
+	return []byte(fmt.Sprintf(
+		"Universal Solver swap authorization\ntxHash: %s\ntoToken: %s\ndestinationAddress: %s\nnonce: %s\nexpiresAt: %d",
+		a.TxHash, a.ToToken, a.DestinationAddress, a.Nonce, a.ExpiresAt,
+	))
+}
+
+// TypedData returns the EIP-712 representation of the authorization
+func (a SwapAuthorization) TypedData() apitypes.TypedData {
+	return apitypes.TypedData{
+		Types: apitypes.Types{
+			"EIP712Domain": {
+				{Name: "name", Type: "string"},
+				{Name: "version", Type: "string"},
+			},
+			"SwapAuthorization": {
+				{Name: "txHash", Type: "string"},
+				{Name: "toToken", Type: "string"},
+				{Name: "destinationAddress", Type: "string"},
+				{Name: "nonce", Type: "string"},
+				{Name: "expiresAt", Type: "uint256"},
+			},
+		},
+		PrimaryType: "SwapAuthorization",
+		Domain: apitypes.TypedDataDomain{
+			Name:    "Universal Solver",
+			Version: "1",
+		},
+		Message: apitypes.TypedDataMessage{
+			"txHash":             a.TxHash,
+			"toToken":            a.ToToken,
+			"destinationAddress": a.DestinationAddress,
+			"nonce":              a.Nonce,
+			"expiresAt":          big.NewInt(a.ExpiresAt),
+		},
+	}
+}
+
+// VerifySwapAuthorization checks that signature was produced over auth by the
+// deposit sender on the given chain. When scheme is empty the default scheme
+// for the chain is used (eip191 for ethereum, ed25519 for solana, bip137 for
+// bitcoin).
+func VerifySwapAuthorization(chain, sender string, auth SwapAuthorization, signature, scheme string) error {
+	if auth.Nonce == "" {
+		return fmt.Errorf("nonce is required")
+	}
+	if err := auth.checkExpiry(time.Now()); err != nil {
+		return err
+	}
+
+	switch chain {
+	case "ethereum":
+		if scheme == "" {
+			scheme = SignatureSchemeEIP191
+		}
+		return verifyEthereumSwapAuthorization(sender, auth, signature, scheme)
+	case "solana":
+		if scheme != "" && scheme != SignatureSchemeEd25519 {
+			return fmt.Errorf("unsupported signature scheme for solana: %s", scheme)
+		}
+		return verifySolanaSwapAuthorization(sender, auth, signature)
+	case "bitcoin":
+		if scheme != "" && scheme != SignatureSchemeBIP137 {
+			return fmt.Errorf("unsupported signature scheme for bitcoin: %s", scheme)
+		}
+		return verifyBitcoinSwapAuthorization(sender, auth, signature)
+	default:
+		return fmt.Errorf("unsupported chain for swap authorization: %s", chain)
+	}
+}
+
+func verifyEthereumSwapAuthorization(sender string, auth SwapAuthorization, signature, scheme string) error {
+	if !common.IsHexAddress(sender) {
+		return fmt.Errorf("invalid Ethereum sender address: %s", sender)
+	}
+
+	var hash []byte
+	switch scheme {
+	case SignatureSchemeEIP191:
+		hash = accounts.TextHash(auth.Message())
+	case SignatureSchemeEIP712:
+		typedHash, _, err := apitypes.TypedDataAndHash(auth.TypedData())
+		if err != nil {
+			return fmt.Errorf("failed to hash typed data: %w", err)
+		}
+		hash = typedHash
+	default:
+		return fmt.Errorf("unsupported signature scheme for ethereum: %s", scheme)
+	}
+
+	sig, err := hex.DecodeString(strings.TrimPrefix(signature, "0x"))
+	if err != nil {
+		return fmt.Errorf("invalid signature encoding: %w", err)
+	}
+	if len(sig) != crypto.SignatureLength {
+		return fmt.Errorf("invalid signature length: %d", len(sig))
+	}
+
+	// Wallets return V as 27/28, go-ethereum expects 0/1
+	if sig[crypto.RecoveryIDOffset] >= 27 {
+		sig[crypto.RecoveryIDOffset] -= 27
+	}
+
+	pubKey, err := crypto.SigToPub(hash, sig)
+	if err != nil {
+		return fmt.Errorf("failed to recover signer: %w", err)
+	}
+
+	recovered := crypto.PubkeyToAddress(*pubKey)
+	if recovered != common.HexToAddress(sender) {
+		return fmt.Errorf("signature does not match deposit sender: recovered %s, expected %s", recovered.Hex(), sender)
+	}
+	return nil
+}
+
+func verifySolanaSwapAuthorization(sender string, auth SwapAuthorization, signature string) error {
+	pubKey, err := solana.PublicKeyFromBase58(sender)
+	if err != nil {
+		return fmt.Errorf("invalid Solana sender address: %w", err)
+	}
+
+	sig, err := solana.SignatureFromBase58(signature)
+	if err != nil {
+		return fmt.Errorf("invalid signature encoding: %w", err)
+	}
+
+	if !sig.Verify(pubKey, auth.Message()) {
+		return fmt.Errorf("signature does not match deposit sender: %s", sender)
+	}
+	return nil
+}
+
+// checkExpiry rejects authorizations without an expiry, past it, or expiring
+// further ahead than MaxSwapAuthorizationLifetime
+func (a SwapAuthorization) checkExpiry(now time.Time) error {
+	if a.ExpiresAt == 0 {
+		return fmt.Errorf("expiresAt is required")
+	}
+	expiresAt := time.Unix(a.ExpiresAt, 0)
+	if now.After(expiresAt) {
+		return fmt.Errorf("authorization expired at %s", expiresAt.UTC().Format(time.RFC3339))
+	}
+	if expiresAt.Sub(now) > MaxSwapAuthorizationLifetime {
+		return fmt.Errorf("authorization expires more than %s ahead", MaxSwapAuthorizationLifetime)
+	}
+	return nil
+}
+
+// NonceTracker remembers which authorization nonces each sender has used so a
+// signed authorization cannot be replayed. Nonces are kept, by sender, with
+// the expiry of their authorization: once it passes the authorization is
+// rejected anyway and the nonce is dropped.
+type NonceTracker struct {
+	mu   sync.Mutex
+	path string
+	used map[string]map[string]int64
+}
+
+// NewNonceTracker loads the nonces saved at path. An empty path keeps them in
+// memory only.
+func NewNonceTracker(path string) (*NonceTracker, error) {
+	n := &NonceTracker{
+		path: path,
+		used: make(map[string]map[string]int64),
+	}
+	if err := readJSONFile(path, &n.used); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+// Use marks the nonce of an authorization expiring at expiresAt as consumed
+// for sender, returning an error if it was already used
+func (n *NonceTracker) Use(sender, nonce string, expiresAt int64) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.pruneLocked(time.Now().Unix())
+
+	// Hex addresses are case-insensitive, base58 addresses are not
+	key := sender
+	if common.IsHexAddress(sender) {
+		key = strings.ToLower(sender)
+	}
+	if n.used[key] == nil {
+		n.used[key] = make(map[string]int64)
+	}
+	if _, seen := n.used[key][nonce]; seen {
+		return fmt.Errorf("nonce %s already used", nonce)
+	}
+	n.used[key][nonce] = expiresAt
+	if err := writeJSONFile(n.path, n.used); err != nil {
+		delete(n.used[key], nonce)
+		return err
+	}
+	return nil
+}
+
+// pruneLocked drops the nonces of expired authorizations, saved with the
+// next change
+func (n *NonceTracker) pruneLocked(now int64) {
+	for sender, nonces := range n.used {
+		for nonce, expiresAt := range nonces {
+			if expiresAt < now {
+				delete(nonces, nonce)
+			}
+		}
+		if len(nonces) == 0 {
+			delete(n.used, sender)
+		}
+	}
+}
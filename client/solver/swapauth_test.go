@@ -0,0 +1,219 @@
+package solver
+
+import (
+	"encoding/base64"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+	"github.com/gagliardetto/solana-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifySwapAuthorization(t *testing.T) {
+	ethKey, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	ethAddress := crypto.PubkeyToAddress(ethKey.PublicKey).Hex()
+
+	solKey, err := solana.NewRandomPrivateKey()
+	require.NoError(t, err)
+
+	auth := SwapAuthorization{
+		TxHash:             "0x106125634d7a095de31cb4c04a297011bc42c2becce8de788b1c30059192eda6",
+		ToToken:            "SOL",
+		DestinationAddress: "2Qv1eJ5d8mW8J6rAHrajXgbEZNyAyBkZZDaRtHhh8KVW",
+		Nonce:              "1",
+		ExpiresAt:          time.Now().Add(time.Hour).Unix(),
+	}
+
+	signEthereum := func(hash []byte) string {
+		sig, err := crypto.Sign(hash, ethKey)
+		require.NoError(t, err)
+		// Mimic wallets, which return V as 27/28
+		sig[crypto.RecoveryIDOffset] += 27
+		return hexutil.Encode(sig)
+	}
+
+	typedHash, _, err := apitypes.TypedDataAndHash(auth.TypedData())
+	require.NoError(t, err)
+
+	solSig, err := solKey.Sign(auth.Message())
+	require.NoError(t, err)
+
+	btcKey, err := btcec.NewPrivateKey(btcec.S256())
+	require.NoError(t, err)
+	btcAddress, err := bitcoinKeyAddress(btcKey)
+	require.NoError(t, err)
+	btcHash, err := bitcoinMessageHash(auth.Message())
+	require.NoError(t, err)
+	btcSig, err := btcec.SignCompact(btcec.S256(), btcKey, btcHash, true)
+	require.NoError(t, err)
+	// BIP-137 wallets mark P2WPKH signatures with headers 39-42
+	btcSegwitSig := append([]byte{btcSig[0] + 8}, btcSig[1:]...)
+	btcNestedSegwitSig := append([]byte{btcSig[0] + 4}, btcSig[1:]...)
+
+	tampered := auth
+	tampered.DestinationAddress = "9xQeWvG816bUx9EPjHmaT23yvVM2ZWbrrpZb9PusVFin"
+
+	signSolana := func(auth SwapAuthorization) string {
+		sig, err := solKey.Sign(auth.Message())
+		require.NoError(t, err)
+		return sig.String()
+	}
+	expired := auth
+	expired.ExpiresAt = time.Now().Add(-time.Minute).Unix()
+	farAhead := auth
+	farAhead.ExpiresAt = time.Now().Add(MaxSwapAuthorizationLifetime + time.Hour).Unix()
+	noExpiry := auth
+	noExpiry.ExpiresAt = 0
+
+	tests := []struct {
+		name      string
+		chain     string
+		sender    string
+		auth      SwapAuthorization
+		signature string
+		scheme    string
+		wantErr   bool
+	}{
+		{
+			name:      "EIP-191 signature",
+			chain:     "ethereum",
+			sender:    ethAddress,
+			auth:      auth,
+			signature: signEthereum(accounts.TextHash(auth.Message())),
+		},
+		{
+			name:      "EIP-712 signature",
+			chain:     "ethereum",
+			sender:    ethAddress,
+			auth:      auth,
+			signature: signEthereum(typedHash),
+			scheme:    SignatureSchemeEIP712,
+		},
+		{
+			name:      "EIP-191 signature over tampered payload",
+			chain:     "ethereum",
+			sender:    ethAddress,
+			auth:      tampered,
+			signature: signEthereum(accounts.TextHash(auth.Message())),
+			wantErr:   true,
+		},
+		{
+			name:      "ed25519 signature",
+			chain:     "solana",
+			sender:    solKey.PublicKey().String(),
+			auth:      auth,
+			signature: solSig.String(),
+		},
+		{
+			name:      "ed25519 signature from another account",
+			chain:     "solana",
+			sender:    solana.NewWallet().PublicKey().String(),
+			auth:      auth,
+			signature: solSig.String(),
+			wantErr:   true,
+		},
+		{
+			name:      "Bitcoin signmessage signature",
+			chain:     "bitcoin",
+			sender:    btcAddress.EncodeAddress(),
+			auth:      auth,
+			signature: base64.StdEncoding.EncodeToString(btcSig),
+		},
+		{
+			name:      "BIP-137 P2WPKH signature",
+			chain:     "bitcoin",
+			sender:    btcAddress.EncodeAddress(),
+			auth:      auth,
+			signature: base64.StdEncoding.EncodeToString(btcSegwitSig),
+			scheme:    SignatureSchemeBIP137,
+		},
+		{
+			name:      "BIP-137 P2SH-P2WPKH signature",
+			chain:     "bitcoin",
+			sender:    btcAddress.EncodeAddress(),
+			auth:      auth,
+			signature: base64.StdEncoding.EncodeToString(btcNestedSegwitSig),
+			wantErr:   true,
+		},
+		{
+			name:      "Bitcoin signature over tampered payload",
+			chain:     "bitcoin",
+			sender:    btcAddress.EncodeAddress(),
+			auth:      tampered,
+			signature: base64.StdEncoding.EncodeToString(btcSig),
+			wantErr:   true,
+		},
+		{
+			name:      "Missing nonce",
+			chain:     "solana",
+			sender:    solKey.PublicKey().String(),
+			auth:      SwapAuthorization{TxHash: auth.TxHash},
+			signature: solSig.String(),
+			wantErr:   true,
+		},
+		{
+			name:      "Expired authorization",
+			chain:     "solana",
+			sender:    solKey.PublicKey().String(),
+			auth:      expired,
+			signature: signSolana(expired),
+			wantErr:   true,
+		},
+		{
+			name:      "Authorization expiring too far ahead",
+			chain:     "solana",
+			sender:    solKey.PublicKey().String(),
+			auth:      farAhead,
+			signature: signSolana(farAhead),
+			wantErr:   true,
+		},
+		{
+			name:      "Missing expiry",
+			chain:     "solana",
+			sender:    solKey.PublicKey().String(),
+			auth:      noExpiry,
+			signature: signSolana(noExpiry),
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := VerifySwapAuthorization(tt.chain, tt.sender, tt.auth, tt.signature, tt.scheme)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestNonceTrackerRejectsReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "swap_nonces.json")
+	tracker, err := NewNonceTracker(path)
+	require.NoError(t, err)
+	expiresAt := time.Now().Add(time.Hour).Unix()
+
+	assert.NoError(t, tracker.Use("0xAbC0000000000000000000000000000000000001", "7", expiresAt))
+	assert.Error(t, tracker.Use("0xabc0000000000000000000000000000000000001", "7", expiresAt))
+	assert.NoError(t, tracker.Use("0xabc0000000000000000000000000000000000001", "8", expiresAt))
+
+	// Used nonces outlive a restart
+	reloaded, err := NewNonceTracker(path)
+	require.NoError(t, err)
+	assert.Error(t, reloaded.Use("0xabc0000000000000000000000000000000000001", "7", expiresAt))
+
+	// Nonces of expired authorizations are dropped
+	require.NoError(t, reloaded.Use("0xabc0000000000000000000000000000000000002", "1", time.Now().Add(-time.Minute).Unix()))
+	require.NoError(t, reloaded.Use("0xabc0000000000000000000000000000000000002", "2", expiresAt))
+	assert.NotContains(t, reloaded.used["0xabc0000000000000000000000000000000000002"], "1")
+}
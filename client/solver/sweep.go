@@ -0,0 +1,273 @@
+package solver
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+
+	"github.com/btcsuite/btcd/chaincfg/chainhash"
+	"github.com/btcsuite/btcd/txscript"
+	"github.com/btcsuite/btcd/wire"
+	"github.com/btcsuite/btcutil/psbt"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/programs/system"
+	"github.com/gagliardetto/solana-go/rpc"
+	"github.com/linera-protocol/examples/universal-solver/client/solver/keys"
+)
+
+const (
+	// ethereumTransferGas is the gas used by a plain value transfer
+	ethereumTransferGas = 21000
+	// solanaSignatureFee is the fee in lamports per transaction signature
+	solanaSignatureFee = 5000
+)
+
+// SweepDeposit empties the deposit address of intent into the pool of its
+// token. It returns the sweep transaction hash, or "" when the balance does
+// not cover the fees.
+func (c *Client) SweepDeposit(ctx context.Context, intent *SwapIntent) (string, error) {
+	pool, err := c.getPoolAddress(intent.FromToken)
+	if err != nil {
+		return "", fmt.Errorf("failed to get pool address: %w", err)
+	}
+	return c.sweepNative(ctx, intent.Chain, intent.Reference, intent.DepositAddress, pool, nil)
+}
+
+// sweepNative moves amount of the native asset of chain between two solver
+// accounts, or all that from holds less fees when amount is nil. The transfer
+// is signed as a sweep linked to reference. It returns the transaction hash,
+// or "" when there is nothing left to move after fees.
+func (c *Client) sweepNative(ctx context.Context, chain, reference, from, to string, amount *big.Int) (string, error) {
+	signer, err := currentSigner()
+	if err != nil {
+		return "", err
+	}
+	record := SigningRecord{
+		Kind:      SigningRecordSweep,
+		Reference: reference,
+		Chain:     chain,
+		Recipient: to,
+	}
+
+	switch chain {
+	case keys.ChainEthereum:
+		record.Asset = "ETH"
+		return c.sweepEthereum(ctx, signer, record, from, amount)
+	case keys.ChainSolana:
+		record.Asset = "SOL"
+		return c.sweepSolana(ctx, signer, record, from, amount)
+	case keys.ChainBitcoin:
+		record.Asset = "BTC"
+		return c.sweepBitcoin(ctx, signer, record, from, amount)
+	default:
+		return "", fmt.Errorf("unsupported chain: %s", chain)
+	}
+}
+
+func (c *Client) sweepEthereum(ctx context.Context, signer Signer, record SigningRecord, from string, amount *big.Int) (string, error) {
+	client, err := ethclient.Dial(EthereumRPC)
+	if err != nil {
+		return "", fmt.Errorf("failed to connect to Ethereum node: %w", err)
+	}
+	defer client.Close()
+
+	account := common.HexToAddress(from)
+	gasPrice, err := client.SuggestGasPrice(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to get gas price: %w", err)
+	}
+	value := amount
+	if value == nil {
+		balance, err := client.BalanceAt(ctx, account, nil)
+		if err != nil {
+			return "", fmt.Errorf("failed to get balance: %w", err)
+		}
+		fee := new(big.Int).Mul(gasPrice, big.NewInt(ethereumTransferGas))
+		value = new(big.Int).Sub(balance, fee)
+	}
+	if value.Sign() <= 0 {
+		return "", nil
+	}
+
+	nonce, err := client.PendingNonceAt(ctx, account)
+	if err != nil {
+		return "", fmt.Errorf("failed to get nonce: %w", err)
+	}
+	chainID, err := client.NetworkID(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to get chain id: %w", err)
+	}
+	tx := types.NewTransaction(nonce, common.HexToAddress(record.Recipient), value, ethereumTransferGas, gasPrice, nil)
+
+	record.Amount = value
+	signedTx, err := signer.SignEthereumTransaction(WithSigningRecord(ctx, record), from, tx, chainID)
+	if err != nil {
+		return "", err
+	}
+	if err := client.SendTransaction(ctx, signedTx); err != nil {
+		return "", fmt.Errorf("failed to send transaction: %w", err)
+	}
+	return signedTx.Hash().Hex(), nil
+}
+
+func (c *Client) sweepSolana(ctx context.Context, signer Signer, record SigningRecord, from string, amount *big.Int) (string, error) {
+	fromKey, err := solana.PublicKeyFromBase58(from)
+	if err != nil {
+		return "", fmt.Errorf("invalid Solana address: %w", err)
+	}
+	toKey, err := solana.PublicKeyFromBase58(record.Recipient)
+	if err != nil {
+		return "", fmt.Errorf("invalid Solana address: %w", err)
+	}
+
+	client := rpc.New(SolanaRPC)
+	value := amount
+	if value == nil {
+		balance, err := client.GetBalance(ctx, fromKey, rpc.CommitmentFinalized)
+		if err != nil {
+			return "", fmt.Errorf("failed to get balance: %w", err)
+		}
+		// Emptying the account entirely also frees it from rent
+		value = new(big.Int).SetUint64(balance.Value)
+		value.Sub(value, big.NewInt(solanaSignatureFee))
+	}
+	if value.Sign() <= 0 {
+		return "", nil
+	}
+
+	blockhash, err := client.GetLatestBlockhash(ctx, rpc.CommitmentConfirmed)
+	if err != nil {
+		return "", fmt.Errorf("failed to get recent blockhash: %w", err)
+	}
+	tx, err := solana.NewTransaction([]solana.Instruction{
+		system.NewTransferInstruction(value.Uint64(), fromKey, toKey).Build(),
+	}, blockhash.Value.Blockhash, solana.TransactionPayer(fromKey))
+	if err != nil {
+		return "", fmt.Errorf("failed to create transaction: %w", err)
+	}
+	message, err := tx.Message.MarshalBinary()
+	if err != nil {
+		return "", fmt.Errorf("failed to serialize transaction message: %w", err)
+	}
+
+	record.Amount = value
+	signature, err := signer.SignSolanaMessage(WithSigningRecord(ctx, record), from, message)
+	if err != nil {
+		return "", err
+	}
+	tx.Signatures = []solana.Signature{signature}
+
+	sent, err := client.SendTransaction(ctx, tx)
+	if err != nil {
+		return "", fmt.Errorf("failed to send transaction: %w", err)
+	}
+	return sent.String(), nil
+}
+
+func (c *Client) sweepBitcoin(ctx context.Context, signer Signer, record SigningRecord, from string, amount *big.Int) (string, error) {
+	fromAddress, err := decodeBitcoinAddress(from)
+	if err != nil {
+		return "", fmt.Errorf("invalid source address: %w", err)
+	}
+	toAddress, err := decodeBitcoinAddress(record.Recipient)
+	if err != nil {
+		return "", fmt.Errorf("invalid destination address: %w", err)
+	}
+	changeScript, err := txscript.PayToAddrScript(fromAddress)
+	if err != nil {
+		return "", fmt.Errorf("failed to build change script: %w", err)
+	}
+	destScript, err := txscript.PayToAddrScript(toAddress)
+	if err != nil {
+		return "", fmt.Errorf("failed to build destination script: %w", err)
+	}
+
+	utxos, err := c.spendableBitcoinOutputs(from)
+	if err != nil {
+		return "", fmt.Errorf("failed to get UTXOs: %w", err)
+	}
+	if len(utxos) == 0 {
+		return "", nil
+	}
+	feeRate, err := c.bitcoinFeeRate()
+	if err != nil {
+		return "", err
+	}
+
+	var (
+		tx       *wire.MsgTx
+		prevOuts []*wire.TxOut
+	)
+	if amount != nil {
+		tx, prevOuts, _, err = buildBitcoinPayout(utxos, changeScript, destScript, amount.Int64(), feeRate)
+		if err != nil {
+			return "", err
+		}
+	} else {
+		// Spend every output to the destination, fees coming out of the total
+		tx = wire.NewMsgTx(2)
+		var total int64
+		for _, utxo := range utxos {
+			hash, err := chainhash.NewHashFromStr(utxo.TxID)
+			if err != nil {
+				return "", fmt.Errorf("invalid UTXO txid %s: %w", utxo.TxID, err)
+			}
+			tx.AddTxIn(wire.NewTxIn(wire.NewOutPoint(hash, utxo.Vout), nil, nil))
+			prevOuts = append(prevOuts, wire.NewTxOut(utxo.Amount, utxo.Script))
+			total += utxo.Amount
+		}
+		value := total - estimateBitcoinVSize(len(utxos), destScript)*feeRate
+		if value < bitcoinDustLimit {
+			return "", nil
+		}
+		tx.AddTxOut(wire.NewTxOut(value, destScript))
+	}
+	var sent int64
+	for _, out := range tx.TxOut {
+		if bytes.Equal(out.PkScript, destScript) {
+			sent += out.Value
+		}
+	}
+
+	packet, err := newPsbt(tx, prevOuts)
+	if err != nil {
+		return "", fmt.Errorf("failed to create PSBT: %w", err)
+	}
+	encoded, err := packet.B64Encode()
+	if err != nil {
+		return "", fmt.Errorf("failed to encode PSBT: %w", err)
+	}
+
+	record.Amount = big.NewInt(sent)
+	signed, err := signer.SignBitcoinPsbt(WithSigningRecord(ctx, record), from, encoded)
+	if err != nil {
+		return "", err
+	}
+	signedPacket, err := decodePsbt(signed)
+	if err != nil {
+		return "", err
+	}
+	// The signer must not change what it was asked to sign
+	if signedPacket.UnsignedTx.TxHash() != tx.TxHash() {
+		return "", fmt.Errorf("signer returned a different transaction")
+	}
+	signedTx, err := psbt.Extract(signedPacket)
+	if err != nil {
+		return "", fmt.Errorf("failed to extract signed transaction: %w", err)
+	}
+	var raw bytes.Buffer
+	if err := signedTx.Serialize(&raw); err != nil {
+		return "", fmt.Errorf("failed to serialize signed transaction: %w", err)
+	}
+
+	var txid string
+	if err := c.bitcoinCall("sendrawtransaction", []interface{}{hex.EncodeToString(raw.Bytes())}, &txid); err != nil {
+		return "", fmt.Errorf("failed to submit transaction: %w", err)
+	}
+	return txid, nil
+}
@@ -0,0 +1,168 @@
+package solver
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/gagliardetto/solana-go"
+)
+
+// Token describes an asset the solver can receive or pay out. Address is the
+// contract (ERC-20) or mint (SPL) address and is empty for native assets.
+type Token struct {
+	Chain    string `json:"chain"`
+	Symbol   string `json:"symbol"`
+	Address  string `json:"address,omitempty"`
+	Decimals int    `json:"decimals"`
+}
+
+// IsNative reports whether the token is the chain's native asset
+func (t Token) IsNative() bool {
+	return t.Address == ""
+}
+
+var (
+	tokensMu sync.RWMutex
+	// tokens is keyed by symbol and always contains the native assets. Swaps
+	// name tokens by symbol alone, so a symbol belongs to a single chain.
+	tokens = map[string]Token{
+		"ETH": {Chain: "ethereum", Symbol: "ETH", Decimals: 18},
+		"SOL": {Chain: "solana", Symbol: "SOL", Decimals: 9},
+		"BTC": {Chain: "bitcoin", Symbol: "BTC", Decimals: 8},
+	}
+)
+
+// LoadTokens registers the tokens listed in the JSON file at path, e.g.
+// [{"chain": "ethereum", "symbol": "USDC", "address": "0x...", "decimals": 6}]
+func LoadTokens(path string) error {
+	var configured []Token
+	if err := readJSONFile(path, &configured); err != nil {
+		return err
+	}
+
+	for _, token := range configured {
+		if err := RegisterToken(token); err != nil {
+			return err
+		}
+	}
+	Logger.Printf("Loaded %d token(s) from %s", len(configured), path)
+	return nil
+}
+
+// RegisterToken adds a token to the registry, replacing a token registered
+// under the same symbol on the same chain
+func RegisterToken(token Token) error {
+	if token.Symbol == "" || token.Chain == "" {
+		return fmt.Errorf("token chain and symbol are required")
+	}
+	if token.Address == "" {
+		return fmt.Errorf("token %s: address is required", token.Symbol)
+	}
+	if err := validateTokenAddress(token.Chain, token.Address); err != nil {
+		return fmt.Errorf("token %s: %w", token.Symbol, err)
+	}
+	if token.Decimals < 0 || token.Decimals > 36 {
+		return fmt.Errorf("token %s: invalid decimals %d", token.Symbol, token.Decimals)
+	}
+
+	tokensMu.Lock()
+	defer tokensMu.Unlock()
+
+	if existing, ok := tokens[token.Symbol]; ok {
+		if existing.IsNative() {
+			return fmt.Errorf("token %s: cannot override native asset", token.Symbol)
+		}
+		if existing.Chain != token.Chain {
+			return fmt.Errorf("token %s: already registered on %s", token.Symbol, existing.Chain)
+		}
+	}
+	tokens[token.Symbol] = token
+	return nil
+}
+
+// validateTokenAddress checks that address is a contract (Ethereum) or mint
+// (Solana) address on chain
+func validateTokenAddress(chain, address string) error {
+	switch chain {
+	case "ethereum":
+		if !common.IsHexAddress(address) {
+			return fmt.Errorf("invalid Ethereum address %s", address)
+		}
+	case "solana":
+		if _, err := solana.PublicKeyFromBase58(address); err != nil {
+			return fmt.Errorf("invalid Solana address %s: %w", address, err)
+		}
+	default:
+		return fmt.Errorf("unsupported token chain %s", chain)
+	}
+	return nil
+}
+
+// LookupToken returns the token registered under symbol
+func LookupToken(symbol string) (Token, bool) {
+	tokensMu.RLock()
+	defer tokensMu.RUnlock()
+
+	token, ok := tokens[symbol]
+	return token, ok
+}
+
+// TokenByAddress returns the token with the given contract or mint address on chain
+func TokenByAddress(chain, address string) (Token, bool) {
+	tokensMu.RLock()
+	defer tokensMu.RUnlock()
+
+	for _, token := range tokens {
+		if token.Chain != chain || token.IsNative() {
+			continue
+		}
+		// EVM addresses are case-insensitive, base58 addresses are not
+		if token.Address == address || (chain == "ethereum" && strings.EqualFold(token.Address, address)) {
+			return token, true
+		}
+	}
+	return Token{}, false
+}
+
+// ResolveToken looks a token up by symbol, falling back to its address on chain
+func ResolveToken(chain, symbolOrAddress string) (Token, error) {
+	if token, ok := LookupToken(symbolOrAddress); ok && token.Chain == chain {
+		return token, nil
+	}
+	if token, ok := TokenByAddress(chain, symbolOrAddress); ok {
+		return token, nil
+	}
+	return Token{}, fmt.Errorf("unknown %s token: %s", chain, symbolOrAddress)
+}
+
+// ToBaseUnits converts a whole-token amount to the token's smallest unit,
+// rounded to the nearest. The amount is taken as the shortest decimal that
+// reads back as the same float64, so 0.29 USDC is 290000 rather than the
+// 289999 its binary value truncates to. NaN and infinities convert to zero.
+func (t Token) ToBaseUnits(amount float64) *big.Int {
+	if math.IsNaN(amount) || math.IsInf(amount, 0) {
+		return new(big.Int)
+	}
+	decimal, _ := new(big.Rat).SetString(strconv.FormatFloat(amount, 'f', -1, 64))
+	multiplier := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(t.Decimals)), nil)
+	scaled := decimal.Mul(decimal, new(big.Rat).SetInt(multiplier))
+
+	// Round half away from zero
+	quotient, remainder := new(big.Int).QuoRem(scaled.Num(), scaled.Denom(), new(big.Int))
+	if new(big.Int).Lsh(new(big.Int).Abs(remainder), 1).Cmp(scaled.Denom()) >= 0 {
+		quotient.Add(quotient, big.NewInt(int64(scaled.Sign())))
+	}
+	return quotient
+}
+
+// FromBaseUnits converts an amount in the token's smallest unit to whole tokens
+func (t Token) FromBaseUnits(amount *big.Int) float64 {
+	divisor := new(big.Float).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(t.Decimals)), nil))
+	result, _ := new(big.Float).Quo(new(big.Float).SetInt(amount), divisor).Float64()
+	return result
+}
@@ -0,0 +1,85 @@
+package solver
+
+import (
+	"math"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestToBaseUnits(t *testing.T) {
+	tests := []struct {
+		name     string
+		decimals int
+		amount   float64
+		want     string
+	}{
+		{name: "whole amount", decimals: 6, amount: 12, want: "12000000"},
+		{name: "binary fraction below the decimal", decimals: 6, amount: 0.29, want: "290000"},
+		{name: "binary fraction above the decimal", decimals: 6, amount: 0.3, want: "300000"},
+		{name: "18 decimals", decimals: 18, amount: 0.1, want: "100000000000000000"},
+		{name: "rounded up to the nearest unit", decimals: 2, amount: 0.125, want: "13"},
+		{name: "rounded down to the nearest unit", decimals: 2, amount: 0.124, want: "12"},
+		{name: "negative amount", decimals: 8, amount: -0.29, want: "-29000000"},
+		{name: "zero decimals", decimals: 0, amount: 2.5, want: "3"},
+		{name: "NaN", decimals: 6, amount: math.NaN(), want: "0"},
+		{name: "infinity", decimals: 6, amount: math.Inf(1), want: "0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Token{Decimals: tt.decimals}.ToBaseUnits(tt.amount).String())
+		})
+	}
+
+	// Converting back gives the amount converted
+	usdc := Token{Decimals: 6}
+	assert.Equal(t, 0.29, usdc.FromBaseUnits(usdc.ToBaseUnits(0.29)))
+	assert.Equal(t, 1.5, Token{Decimals: 9}.FromBaseUnits(big.NewInt(1_500_000_000)))
+}
+
+func TestRegisterToken(t *testing.T) {
+	const address = "0xA0b86991c6218b36c1d19D4a2e9Eb0cE3606eB48"
+	tests := []struct {
+		name    string
+		token   Token
+		wantErr string
+	}{
+		{name: "missing chain", token: Token{Symbol: "TEST-A", Address: address, Decimals: 6}, wantErr: "chain and symbol are required"},
+		{name: "missing address", token: Token{Chain: "ethereum", Symbol: "TEST-A", Decimals: 6}, wantErr: "address is required"},
+		{name: "invalid Ethereum address", token: Token{Chain: "ethereum", Symbol: "TEST-A", Address: "0x01", Decimals: 6}, wantErr: "invalid Ethereum address"},
+		{name: "invalid Solana address", token: Token{Chain: "solana", Symbol: "TEST-A", Address: address, Decimals: 6}, wantErr: "invalid Solana address"},
+		{name: "unsupported chain", token: Token{Chain: "bitcoin", Symbol: "TEST-A", Address: address, Decimals: 6}, wantErr: "unsupported token chain"},
+		{name: "too many decimals", token: Token{Chain: "ethereum", Symbol: "TEST-A", Address: address, Decimals: 37}, wantErr: "invalid decimals"},
+		{name: "native asset", token: Token{Chain: "ethereum", Symbol: "ETH", Address: address, Decimals: 18}, wantErr: "cannot override native asset"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.ErrorContains(t, RegisterToken(tt.token), tt.wantErr)
+		})
+	}
+
+	require.NoError(t, RegisterToken(Token{Chain: "ethereum", Symbol: "TEST-USDC", Address: address, Decimals: 6}))
+
+	// EVM addresses match in any case
+	token, ok := TokenByAddress("ethereum", "0xa0b86991c6218b36c1d19d4a2e9eb0ce3606eb48")
+	require.True(t, ok)
+	assert.Equal(t, "TEST-USDC", token.Symbol)
+	_, ok = TokenByAddress("solana", address)
+	assert.False(t, ok)
+
+	token, err := ResolveToken("ethereum", "TEST-USDC")
+	require.NoError(t, err)
+	assert.Equal(t, address, token.Address)
+	_, err = ResolveToken("solana", "TEST-USDC")
+	assert.ErrorContains(t, err, "unknown solana token")
+
+	// A symbol stays on the chain it was first registered on
+	err = RegisterToken(Token{Chain: "solana", Symbol: "TEST-USDC", Address: "EPjFWdd5AufqSSqeM2qN1xzybapC8G4wEGGkZwyTDt1v", Decimals: 6})
+	assert.ErrorContains(t, err, "already registered on ethereum")
+	token, ok = LookupToken("TEST-USDC")
+	require.True(t, ok)
+	assert.Equal(t, "ethereum", token.Chain)
+}
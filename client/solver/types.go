@@ -51,14 +51,30 @@ type ChainParams struct {
 	ToAddress   string `json:"to_address"`
 	Amount      string `json:"amount"`
 
-	// Ethereum specific
+	// Ethereum specific (native value in wei)
+	ChainID  string `json:"chain_id,omitempty"`
+	Value    string `json:"value,omitempty"`
 	GasPrice string `json:"gas_price,omitempty"`
 	GasLimit uint64 `json:"gas_limit,omitempty"`
 	Nonce    uint64 `json:"nonce,omitempty"`
 
+	// ERC-20 token transfers (contract address and transfer calldata)
+	TokenAddress string `json:"token_address,omitempty"`
+	Data         string `json:"data,omitempty"`
+
 	// Solana specific
-	RecentBlockhash string  `json:"recent_blockhash,omitempty"`
-	Lamports        float64 `json:"lamports,omitempty"`
+	RecentBlockhash string `json:"recent_blockhash,omitempty"`
+	Lamports        uint64 `json:"lamports,omitempty"`
+
+	// SPL token transfers (amount in base units, mint address in TokenAddress)
+	TokenAmount        string `json:"token_amount,omitempty"`
+	Decimals           uint8  `json:"decimals,omitempty"`
+	CreateTokenAccount bool   `json:"create_token_account,omitempty"`
+
+	// Bitcoin specific (base64 PSBT, fee rate in sat/vB and fee in sat)
+	Psbt    string `json:"psbt,omitempty"`
+	FeeRate int64  `json:"fee_rate,omitempty"`
+	Fee     int64  `json:"fee,omitempty"`
 }
 
 type SwapResponse struct {
@@ -84,4 +100,5 @@ type Balance struct {
 	Address string  `json:"address"`
 	Amount  float64 `json:"amount"`
 	Symbol  string  `json:"symbol"`
+	Token   string  `json:"token,omitempty"`
 }
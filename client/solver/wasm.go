@@ -0,0 +1,101 @@
+package solver
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+)
+
+// wasmHeader is the magic number and version 1 every WASM module starts with
+var wasmHeader = []byte{0x00, 'a', 's', 'm', 0x01, 0x00, 0x00, 0x00}
+
+const (
+	wasmCustomSection    = 0
+	wasmCodeSection      = 10
+	wasmDataCountSection = 12
+)
+
+// wasmSectionOrder is where each known section may appear, custom sections
+// excepted. The data count section comes before the code section.
+var wasmSectionOrder = map[byte]int{
+	1: 1, 2: 2, 3: 3, 4: 4, 5: 5, 6: 6, 7: 7, 8: 8, 9: 9,
+	wasmDataCountSection: 10, wasmCodeSection: 11, 11: 12,
+}
+
+// WasmFile describes a checked WASM module
+type WasmFile struct {
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// InspectWasmFile checks the file at path is a WASM module and returns its
+// size and digest
+func InspectWasmFile(path string) (*WasmFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := ValidateWasm(data); err != nil {
+		return nil, err
+	}
+	digest := sha256.Sum256(data)
+	return &WasmFile{Size: int64(len(data)), SHA256: hex.EncodeToString(digest[:])}, nil
+}
+
+// ValidateWasm checks data is a WASM module: the magic number and version,
+// then well-formed sections in order, with a code section
+func ValidateWasm(data []byte) error {
+	if !bytes.HasPrefix(data, wasmHeader) {
+		return fmt.Errorf("invalid WASM: missing magic number and version 1 header")
+	}
+
+	offset := len(wasmHeader)
+	last := 0
+	hasCode := false
+	for offset < len(data) {
+		id := data[offset]
+		size, n, err := readLEB128(data[offset+1:])
+		if err != nil {
+			return fmt.Errorf("invalid WASM: section at offset %d: %w", offset, err)
+		}
+		start := offset + 1 + n
+		if size > uint64(len(data)-start) {
+			return fmt.Errorf("invalid WASM: section %d at offset %d runs past the end of the module", id, offset)
+		}
+
+		if id != wasmCustomSection {
+			order, ok := wasmSectionOrder[id]
+			if !ok {
+				return fmt.Errorf("invalid WASM: unknown section %d at offset %d", id, offset)
+			}
+			if order <= last {
+				return fmt.Errorf("invalid WASM: section %d at offset %d is out of order", id, offset)
+			}
+			last = order
+			hasCode = hasCode || id == wasmCodeSection
+		}
+		offset = start + int(size)
+	}
+	if !hasCode {
+		return fmt.Errorf("invalid WASM: no code section")
+	}
+	return nil
+}
+
+// readLEB128 decodes an unsigned 32-bit LEB128 number, returning it with the
+// number of bytes read
+func readLEB128(data []byte) (uint64, int, error) {
+	var value uint64
+	for i := 0; i < 5; i++ {
+		if i >= len(data) {
+			return 0, 0, fmt.Errorf("truncated size")
+		}
+		value |= uint64(data[i]&0x7f) << (7 * i)
+		if data[i]&0x80 == 0 {
+			return value, i + 1, nil
+		}
+	}
+	return 0, 0, fmt.Errorf("size is too long")
+}
@@ -0,0 +1,54 @@
+package solver
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// wasmModule builds a module from the header and the given raw sections
+func wasmModule(sections ...[]byte) []byte {
+	module := append([]byte{}, wasmHeader...)
+	for _, section := range sections {
+		module = append(module, section...)
+	}
+	return module
+}
+
+func TestValidateWasm(t *testing.T) {
+	typeSection := []byte{1, 4, 1, 0x60, 0, 0}
+	functionSection := []byte{3, 2, 1, 0}
+	codeSection := []byte{10, 4, 1, 2, 0, 0x0b}
+	customSection := []byte{0, 3, 2, 'h', 'i'}
+
+	valid := wasmModule(typeSection, customSection, functionSection, codeSection)
+	assert.NoError(t, ValidateWasm(valid))
+
+	for name, test := range map[string]struct {
+		module []byte
+		err    string
+	}{
+		"not WASM":        {[]byte("PK\x03\x04 a zip file"), "missing magic number"},
+		"wrong version":   {append([]byte{0, 'a', 's', 'm', 2, 0, 0, 0}, codeSection...), "missing magic number"},
+		"no code":         {wasmModule(typeSection, functionSection), "no code section"},
+		"out of order":    {wasmModule(functionSection, typeSection, codeSection), "section 1 at offset 12 is out of order"},
+		"unknown section": {wasmModule([]byte{13, 0}, codeSection), "unknown section 13"},
+		"truncated":       {valid[:len(valid)-1], "runs past the end"},
+		"truncated size":  {wasmModule(codeSection, []byte{11, 0x80}), "truncated size"},
+		"duplicate":       {wasmModule(codeSection, codeSection), "out of order"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.ErrorContains(t, ValidateWasm(test.module), test.err)
+		})
+	}
+
+	path := filepath.Join(t.TempDir(), "module.wasm")
+	require.NoError(t, os.WriteFile(path, valid, 0600))
+	file, err := InspectWasmFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(valid)), file.Size)
+	assert.Len(t, file.SHA256, 64)
+}